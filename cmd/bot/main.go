@@ -2,60 +2,151 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"rss_bot/internal/bot"
 	"rss_bot/internal/config"
+	"rss_bot/internal/feedlock"
+	"rss_bot/internal/health"
+	"rss_bot/internal/metrics"
+	"rss_bot/internal/outbox"
+	"rss_bot/internal/retention"
 	"rss_bot/internal/scheduler"
 	"rss_bot/internal/storage"
 )
 
 func main() {
+	if err := run(); err != nil {
+		slog.Error("bot failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+// run starts the bot and blocks until it stops. Its deferred cleanup, such as
+// closing the database, runs before main exits.
+func run() error {
 	cfg, err := config.Load()
 	if err != nil {
-		slog.Error("load config", "error", err)
-		os.Exit(1)
+		return fmt.Errorf("load config: %w", err)
 	}
 
 	log := newLogger(cfg.LogLevel)
+	slog.SetDefault(log)
 
 	if dir := filepath.Dir(cfg.DatabasePath); dir != "." {
 		if err := os.MkdirAll(dir, 0o750); err != nil {
-			log.Error("create data directory", "path", dir, "error", err)
-			os.Exit(1)
+			return fmt.Errorf("create data directory %s: %w", dir, err)
 		}
 	}
 
 	store, err := storage.NewSQLite(cfg.DatabasePath)
 	if err != nil {
-		log.Error("open database", "path", cfg.DatabasePath, "error", err)
-		os.Exit(1)
+		return fmt.Errorf("open database %s: %w", cfg.DatabasePath, err)
 	}
 	defer func() { _ = store.Close() }()
+	store.SetContentLimit(cfg.ContentMaxBytes)
+	// Freed space is still reused without incremental vacuum, only the file
+	// doesn't shrink. Converting the database is a manual step in the README.
+	if ok, err := store.IncrementalVacuumEnabled(context.Background()); err != nil {
+		return err
+	} else if !ok {
+		log.Warn("incremental vacuum disabled, database file will not shrink", "path", cfg.DatabasePath)
+	}
 
 	b, err := bot.New(cfg.TelegramBotToken, store, cfg, log)
 	if err != nil {
-		log.Error("create bot", "error", err)
-		os.Exit(1)
+		return fmt.Errorf("create bot: %w", err)
 	}
 
+	locks := feedlock.New()
+	b.SetFeedLocks(locks)
+
 	sched := scheduler.New(store, b, log)
+	sched.SetMaxFailures(cfg.MaxFeedFailures)
+	sched.SetConcurrency(cfg.SchedulerWorkers, cfg.PerHostConcurrency, cfg.PerHostDelay)
+	sched.SetFeedLocks(locks)
+
+	disp := outbox.New(store, b, log)
+
+	cleaner := retention.New(store, log)
+	cleaner.SetMaxAge(time.Duration(cfg.RetentionDays) * 24 * time.Hour)
+	b.SetRetention(cleaner)
 
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
+	if cfg.HTTPListen != "" {
+		m := metrics.New()
+		b.SetMetrics(m)
+		sched.SetMetrics(m)
+
+		checker := health.New(store, sched.TickInterval(), sched.LastCheck, b.LastHeartbeat)
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", m.Handler())
+		mux.Handle("/healthz", checker.Healthz())
+		mux.Handle("/readyz", checker.Readyz())
+		if err := serveHTTP(ctx, cfg.HTTPListen, mux, log); err != nil {
+			return fmt.Errorf("start http server: %w", err)
+		}
+	}
+
 	log.Info("starting bot")
 
-	go sched.Run(ctx)
+	// The background jobs write to the database, so they must be done before
+	// the deferred Close.
+	var wg sync.WaitGroup
+	for _, job := range []func(context.Context){sched.Run, disp.Run, cleaner.Run} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			job(ctx)
+		}()
+	}
 
-	b.Run(ctx)
+	err = b.Run(ctx)
+	cancel()
+	wg.Wait()
+	if err != nil {
+		return fmt.Errorf("run bot: %w", err)
+	}
 
 	log.Info("bot stopped")
+	return nil
+}
+
+// serveHTTP serves handler on addr until ctx is cancelled. It returns once the
+// server listens, so that a bad address stops the start.
+func serveHTTP(ctx context.Context, addr string, handler http.Handler, log *slog.Logger) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %w", addr, err)
+	}
+	srv := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("serve http", "error", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	log.Info("http server listening", "addr", ln.Addr().String())
+	return nil
 }
 
 func newLogger(level string) *slog.Logger {
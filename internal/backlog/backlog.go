@@ -0,0 +1,83 @@
+// Package backlog decides what to deliver when a feed is checked for the first
+// time after being added or resumed, so that a chat is not flooded with old items.
+package backlog
+
+import (
+	"sort"
+
+	"rss_bot/internal/fetcher"
+	"rss_bot/internal/model"
+)
+
+// Plan is the outcome of applying a backlog policy to the unseen items of a feed.
+type Plan struct {
+	// Send holds the items to deliver, in feed order.
+	Send []fetcher.MatchedItem
+	// Skip holds the items to mark seen without delivering them.
+	Skip []fetcher.MatchedItem
+	// Ask is set when the chat has to choose; Send and Skip are empty then.
+	Ask bool
+}
+
+// Apply splits unseen items according to policy. Items are expected in feed
+// order. An unknown policy or a non-positive limit falls back to the defaults.
+func Apply(policy model.BacklogPolicy, limit int, items []fetcher.MatchedItem) Plan {
+	if limit <= 0 {
+		limit = model.DefaultBacklogLimit
+	}
+	switch policy {
+	case model.BacklogAll:
+		return Plan{Send: items}
+	case model.BacklogSkip:
+		return Plan{Skip: items}
+	case model.BacklogAsk:
+		if len(items) > limit {
+			return Plan{Ask: true}
+		}
+		return Plan{Send: items}
+	default:
+		send, skip := Newest(items, limit)
+		return Plan{Send: send, Skip: skip}
+	}
+}
+
+// Newest splits items into the n newest and the rest, both in their original
+// order. Items are ranked by publication time when every item has one;
+// otherwise the feed order is trusted to be newest first.
+func Newest(items []fetcher.MatchedItem, n int) (newest, rest []fetcher.MatchedItem) {
+	if len(items) <= n {
+		return items, nil
+	}
+
+	rank := make([]int, len(items))
+	for i := range rank {
+		rank[i] = i
+	}
+	if allDated(items) {
+		sort.SliceStable(rank, func(a, b int) bool {
+			return items[rank[a]].Published.After(*items[rank[b]].Published)
+		})
+	}
+
+	keep := make(map[int]bool, n)
+	for _, i := range rank[:n] {
+		keep[i] = true
+	}
+	for i, item := range items {
+		if keep[i] {
+			newest = append(newest, item)
+		} else {
+			rest = append(rest, item)
+		}
+	}
+	return newest, rest
+}
+
+func allDated(items []fetcher.MatchedItem) bool {
+	for _, item := range items {
+		if item.Published == nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package backlog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"rss_bot/internal/fetcher"
+	"rss_bot/internal/model"
+)
+
+func items(guids ...string) []fetcher.MatchedItem {
+	out := make([]fetcher.MatchedItem, len(guids))
+	for i, g := range guids {
+		out[i] = fetcher.MatchedItem{GUID: g}
+	}
+	return out
+}
+
+func guids(items []fetcher.MatchedItem) []string {
+	var out []string
+	for _, it := range items {
+		out = append(out, it.GUID)
+	}
+	return out
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   model.BacklogPolicy
+		limit    int
+		items    []fetcher.MatchedItem
+		wantSend []string
+		wantSkip []string
+		wantAsk  bool
+	}{
+		{
+			name:     "all sends everything",
+			policy:   model.BacklogAll,
+			limit:    2,
+			items:    items("a", "b", "c"),
+			wantSend: []string{"a", "b", "c"},
+		},
+		{
+			name:     "skip marks everything seen",
+			policy:   model.BacklogSkip,
+			limit:    2,
+			items:    items("a", "b", "c"),
+			wantSkip: []string{"a", "b", "c"},
+		},
+		{
+			name:     "newest keeps the first n in feed order",
+			policy:   model.BacklogNewest,
+			limit:    2,
+			items:    items("a", "b", "c"),
+			wantSend: []string{"a", "b"},
+			wantSkip: []string{"c"},
+		},
+		{
+			name:     "ask within limit sends",
+			policy:   model.BacklogAsk,
+			limit:    3,
+			items:    items("a", "b", "c"),
+			wantSend: []string{"a", "b", "c"},
+		},
+		{
+			name:    "ask over limit asks",
+			policy:  model.BacklogAsk,
+			limit:   2,
+			items:   items("a", "b", "c"),
+			wantAsk: true,
+		},
+		{
+			name:     "unknown policy and limit use defaults",
+			policy:   "",
+			limit:    0,
+			items:    items("a", "b", "c", "d", "e", "f"),
+			wantSend: []string{"a", "b", "c", "d", "e"},
+			wantSkip: []string{"f"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Apply(tt.policy, tt.limit, tt.items)
+			if diff := cmp.Diff(tt.wantSend, guids(got.Send)); diff != "" {
+				t.Errorf("send mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantSkip, guids(got.Skip)); diff != "" {
+				t.Errorf("skip mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantAsk, got.Ask); diff != "" {
+				t.Errorf("ask mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewestByDate(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(guid string, days int) fetcher.MatchedItem {
+		ts := base.AddDate(0, 0, days)
+		return fetcher.MatchedItem{GUID: guid, Published: &ts}
+	}
+
+	t.Run("ranks by publication time", func(t *testing.T) {
+		in := []fetcher.MatchedItem{at("old", 1), at("newest", 3), at("oldest", 0), at("new", 2)}
+		newest, rest := Newest(in, 2)
+		if diff := cmp.Diff([]string{"newest", "new"}, guids(newest)); diff != "" {
+			t.Errorf("newest mismatch (-want +got):\n%s", diff)
+		}
+		if diff := cmp.Diff([]string{"old", "oldest"}, guids(rest)); diff != "" {
+			t.Errorf("rest mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("falls back to feed order when a date is missing", func(t *testing.T) {
+		in := []fetcher.MatchedItem{at("a", 0), {GUID: "b"}, at("c", 5)}
+		newest, _ := Newest(in, 2)
+		if diff := cmp.Diff([]string{"a", "b"}, guids(newest)); diff != "" {
+			t.Errorf("newest mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
@@ -0,0 +1,178 @@
+package bot
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"rss_bot/internal/backlog"
+	"rss_bot/internal/fetcher"
+	"rss_bot/internal/model"
+)
+
+// deliverItems queues notifications for items in the outbox, which marks
+// each item seen once Telegram accepts it. Quiet hours don't apply: the chat
+// asked for the items.
+func (b *Bot) deliverItems(ctx context.Context, feed *model.Feed, items []fetcher.MatchedItem) {
+	settings := b.chatSettings(ctx, feed.ChatID)
+	for _, item := range items {
+		m, err := ComposeOutboxMessage(ctx, b.store, feed, settings, item)
+		if err != nil {
+			b.log.Error("compose notification", "feed_id", feed.ID, "guid", item.GUID, "error", err)
+			continue
+		}
+		if err := b.store.EnqueueMessage(ctx, m); err != nil {
+			b.log.Error("enqueue notification", "feed_id", feed.ID, "guid", item.GUID, "error", err)
+		}
+	}
+}
+
+func (b *Bot) handleBacklog(ctx context.Context, chatID int64, args string) {
+	lang := b.lang(ctx, chatID)
+	usage := lang.T("Usage: /backlog <number> <all|newest N|ask N|skip>")
+
+	parts := strings.Fields(args)
+	if len(parts) < 2 {
+		b.reply(chatID, usage)
+		return
+	}
+	pos, err := strconv.Atoi(parts[0])
+	if err != nil {
+		b.reply(chatID, usage)
+		return
+	}
+
+	policy := model.BacklogPolicy(strings.ToLower(parts[1]))
+	switch policy {
+	case model.BacklogAll, model.BacklogSkip, model.BacklogNewest, model.BacklogAsk:
+	default:
+		b.reply(chatID, usage)
+		return
+	}
+
+	feed, err := b.store.GetFeedByPosition(ctx, chatID, pos)
+	if err != nil {
+		b.reply(chatID, lang.Sprintf("Feed #%d not found.", pos))
+		return
+	}
+
+	if len(parts) > 2 {
+		n, err := strconv.Atoi(parts[2])
+		if err != nil || n < 1 || n > 100 {
+			b.reply(chatID, lang.T("The number of items must be between 1 and 100."))
+			return
+		}
+		feed.BacklogLimit = n
+	}
+	feed.BacklogPolicy = policy
+
+	if err := b.store.UpdateFeed(ctx, feed); err != nil {
+		b.reply(chatID, lang.Sprintf("Error: %v", err))
+		return
+	}
+	b.reply(chatID, lang.Sprintf("Backlog policy of #%d \"%s\": %s.", pos, feed.Name, FormatBacklogPolicy(lang, feed)))
+}
+
+func (b *Bot) handleMarkRead(ctx context.Context, chatID int64, args string) {
+	lang := b.lang(ctx, chatID)
+	pos, err := ParseFeedArg(args)
+	if err != nil {
+		b.reply(chatID, lang.T("Usage: /markread <number>"))
+		return
+	}
+
+	feed, err := b.store.GetFeedByPosition(ctx, chatID, pos)
+	if err != nil {
+		b.reply(chatID, lang.Sprintf("Feed #%d not found.", pos))
+		return
+	}
+
+	if !b.locks.TryLock(feed.ID) {
+		b.reply(chatID, lang.Sprintf("Feed #%d is being checked right now. Try again in a moment.", pos))
+		return
+	}
+	defer b.locks.Unlock(feed.ID)
+
+	rssFeed, err := b.fetcher.Fetch(ctx, feed.URL)
+	if err != nil {
+		b.reply(chatID, lang.Sprintf("Failed to fetch: %v", err))
+		return
+	}
+
+	marked := 0
+	for _, item := range rssFeed.Items {
+		guid := fetcher.ItemGUID(item)
+		if seen, _ := b.store.IsSeen(ctx, feed.ID, guid); seen {
+			continue
+		}
+		if err := b.store.MarkSeen(ctx, feed.ID, guid, item.Description); err != nil {
+			b.log.Error("mark seen", "feed_id", feed.ID, "guid", guid, "error", err)
+			continue
+		}
+		marked++
+	}
+	if err := b.store.SetBacklogState(ctx, feed.ID, model.BacklogDone); err != nil {
+		b.log.Error("set backlog state", "feed_id", feed.ID, "error", err)
+	}
+
+	b.reply(chatID, lang.Sprintf("Marked %d item(s) in #%d \"%s\" as read.", marked, pos, feed.Name))
+}
+
+// handleBacklogChoice applies the answer to a backlog prompt: all, read or
+// the number of newest items to send.
+func (b *Bot) handleBacklogChoice(ctx context.Context, chatID, feedID int64, choice string) {
+	lang := b.lang(ctx, chatID)
+	feed, err := b.store.GetFeed(ctx, feedID)
+	if err != nil || feed.ChatID != chatID {
+		b.reply(chatID, lang.T("Feed not found."))
+		return
+	}
+	if feed.BacklogState != model.BacklogAsked {
+		b.reply(chatID, lang.T("This choice has expired."))
+		return
+	}
+
+	if !b.locks.TryLock(feed.ID) {
+		b.reply(chatID, lang.Sprintf("Feed #%d is being checked right now. Try again in a moment.", feed.Position))
+		return
+	}
+	defer b.locks.Unlock(feed.ID)
+
+	rssFeed, err := b.fetcher.Fetch(ctx, feed.URL)
+	if err != nil {
+		b.reply(chatID, lang.Sprintf("Failed to fetch: %v", err))
+		return
+	}
+
+	filters, _ := b.store.ListFilters(ctx, feed.ID)
+	var unseen []fetcher.MatchedItem
+	for _, item := range fetcher.FilterItems(rssFeed.Items, filters) {
+		if seen, _ := b.store.IsSeen(ctx, feed.ID, item.GUID); !seen {
+			unseen = append(unseen, item)
+		}
+	}
+
+	var send, skip []fetcher.MatchedItem
+	switch choice {
+	case backlogSendAll:
+		send = unseen
+	case backlogMarkRead:
+		skip = unseen
+	default:
+		n, err := strconv.Atoi(choice)
+		if err != nil || n < 1 {
+			return
+		}
+		send, skip = backlog.Newest(unseen, n)
+	}
+
+	for _, item := range skip {
+		_ = b.store.MarkSeen(ctx, feed.ID, item.GUID, item.Description)
+	}
+	b.deliverItems(ctx, feed, send)
+	if err := b.store.SetBacklogState(ctx, feed.ID, model.BacklogDone); err != nil {
+		b.log.Error("set backlog state", "feed_id", feed.ID, "error", err)
+	}
+
+	b.reply(chatID, lang.Sprintf("Sent %d item(s) of #%d \"%s\", marked %d as read.", len(send), feed.Position, feed.Name, len(skip)))
+}
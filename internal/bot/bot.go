@@ -2,31 +2,66 @@ package bot
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 
 	"rss_bot/internal/config"
+	"rss_bot/internal/feedlock"
 	"rss_bot/internal/fetcher"
+	"rss_bot/internal/i18n"
+	"rss_bot/internal/metrics"
+	"rss_bot/internal/model"
+	"rss_bot/internal/outbox"
+	"rss_bot/internal/retention"
 	"rss_bot/internal/storage"
+	"rss_bot/internal/text"
+)
+
+const (
+	// pollTimeout is how long, in seconds, a request for updates waits for one.
+	pollTimeout = 60
+	// pollRetryDelay is the wait after a failed request for updates.
+	pollRetryDelay = 3 * time.Second
+	// heartbeatInterval is how often an idle webhook asks Telegram whether
+	// updates are getting through.
+	heartbeatInterval = 30 * time.Second
 )
 
 type telegramAPI interface {
 	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
-	GetUpdatesChan(config tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel
-	StopReceivingUpdates()
+	SendMediaGroup(config tgbotapi.MediaGroupConfig) ([]tgbotapi.Message, error)
+	GetUpdates(config tgbotapi.UpdateConfig) ([]tgbotapi.Update, error)
+	GetFileDirectURL(fileID string) (string, error)
+	MakeRequest(endpoint string, params tgbotapi.Params) (*tgbotapi.APIResponse, error)
 }
 
 // Bot is the Telegram bot that handles user commands and sends notifications.
 type Bot struct {
-	api     telegramAPI
-	store   storage.Storage
-	cfg     *config.Config
-	fetcher *fetcher.Fetcher
-	log     *slog.Logger
+	api       telegramAPI
+	store     storage.Storage
+	cfg       *config.Config
+	fetcher   *fetcher.Fetcher
+	locks     *feedlock.Set
+	limiter   *sendLimiter
+	metrics   *metrics.Metrics
+	retention *retention.Job
+	log       *slog.Logger
+
+	// heartbeat is when the updates loop last made progress, in Unix nanoseconds.
+	heartbeat atomic.Int64
+
+	mu sync.Mutex
+	// languages caches the Telegram language code last stored for each chat.
+	languages map[int64]string
 }
 
 // New creates a Bot with the given Telegram token, storage, and config.
@@ -41,50 +76,157 @@ func New(token string, store storage.Storage, cfg *config.Config, log *slog.Logg
 		store:   store,
 		cfg:     cfg,
 		fetcher: fetcher.New(http.DefaultClient),
+		locks:   feedlock.New(),
+		limiter: newSendLimiter(),
 		log:     log,
 	}, nil
 }
 
-// Run starts the bot's long-polling loop, blocking until ctx is cancelled.
-func (b *Bot) Run(ctx context.Context) {
+// SetFeedLocks shares a lock set with the scheduler so that a manual /check
+// never runs at the same time as a scheduled check of the same feed.
+func (b *Bot) SetFeedLocks(locks *feedlock.Set) {
+	b.locks = locks
+}
+
+// SetMetrics makes the bot and its fetcher record metrics.
+func (b *Bot) SetMetrics(m *metrics.Metrics) {
+	b.metrics = m
+	b.fetcher.SetMetrics(m)
+}
+
+// Run receives and handles updates until ctx is cancelled: from a webhook if
+// one is configured, otherwise by long polling.
+func (b *Bot) Run(ctx context.Context) error {
+	if b.cfg.WebhookURL != "" {
+		return b.runWebhook(ctx)
+	}
+	b.runPolling(ctx)
+	return nil
+}
+
+// runPolling is the long-polling loop. It beats after every request for
+// updates that Telegram answers, which is at least every pollTimeout seconds,
+// and after every handled update.
+func (b *Bot) runPolling(ctx context.Context) {
 	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
+	u.Timeout = pollTimeout
 
-	updates := b.api.GetUpdatesChan(u)
+	type poll struct {
+		updates []tgbotapi.Update
+		err     error
+	}
+	// The request cannot be cancelled, so it runs aside while ctx is watched.
+	results := make(chan poll, 1)
+	b.beat()
 
 	for {
+		go func(u tgbotapi.UpdateConfig) {
+			updates, err := b.api.GetUpdates(u)
+			results <- poll{updates, err}
+		}(u)
+
+		var p poll
 		select {
 		case <-ctx.Done():
-			b.api.StopReceivingUpdates()
 			return
-		case update := <-updates:
-			if update.CallbackQuery != nil {
-				b.handleCallback(ctx, update.CallbackQuery)
-				continue
-			}
-			if update.Message == nil || !update.Message.IsCommand() {
-				continue
+		case p = <-results:
+		}
+		if p.err != nil {
+			b.log.Error("get updates", "error", p.err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(pollRetryDelay):
 			}
-			if !b.cfg.IsUserAllowed(update.Message.From.ID) {
-				b.log.Warn("access denied",
-					"user_id", update.Message.From.ID,
-					"username", update.Message.From.UserName,
-					"chat_id", update.Message.Chat.ID,
-					"cmd", update.Message.Command(),
-				)
-				b.reply(update.Message.Chat.ID, "Access denied.")
-				continue
+			continue
+		}
+		b.beat()
+
+		for _, update := range p.updates {
+			if update.UpdateID >= u.Offset {
+				u.Offset = update.UpdateID + 1
 			}
-			b.handleCommand(ctx, update.Message)
+			b.handleUpdate(ctx, update)
+			b.beat()
 		}
 	}
 }
 
+// beat records that the updates loop made progress.
+func (b *Bot) beat() {
+	b.heartbeat.Store(time.Now().UnixNano())
+}
+
+// LastHeartbeat returns when the updates loop last made progress, or the zero
+// time if it has not started.
+func (b *Bot) LastHeartbeat() time.Time {
+	ns := b.heartbeat.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// handleUpdate dispatches an update however it was received.
+func (b *Bot) handleUpdate(ctx context.Context, update tgbotapi.Update) {
+	if update.CallbackQuery != nil {
+		b.handleCallback(ctx, update.CallbackQuery)
+		return
+	}
+	if update.Message == nil || (!update.Message.IsCommand() && !isImport(update.Message)) {
+		return
+	}
+	if !b.cfg.IsUserAllowed(update.Message.From.ID) {
+		b.log.Warn("access denied",
+			"user_id", update.Message.From.ID,
+			"username", update.Message.From.UserName,
+			"chat_id", update.Message.Chat.ID,
+			"cmd", update.Message.Command(),
+		)
+		lang := i18n.Choose("", update.Message.From.LanguageCode)
+		b.reply(update.Message.Chat.ID, lang.T("Access denied."))
+		return
+	}
+	if isImport(update.Message) {
+		b.handleDocument(ctx, update.Message)
+		return
+	}
+	b.handleCommand(ctx, update.Message)
+}
+
+// send passes c to Telegram once the rate limiter allows a message to chatID.
+// Every message goes through here or sendAlbum, and every other request except
+// the long poll for updates goes through request, so that all of them are
+// paced and their errors counted.
+func (b *Bot) send(ctx context.Context, chatID int64, c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	if err := b.limiter.wait(ctx, chatID); err != nil {
+		return tgbotapi.Message{}, err
+	}
+	msg, err := b.api.Send(c)
+	if err != nil {
+		b.metrics.SendError(sendErrorType(err))
+	}
+	return msg, err
+}
+
+// request makes an API request that is not a message to a chat, such as
+// getWebhookInfo, once the global rate limit allows it.
+func (b *Bot) request(ctx context.Context, endpoint string, params tgbotapi.Params) (*tgbotapi.APIResponse, error) {
+	if err := b.limiter.wait(ctx, 0); err != nil {
+		return nil, err
+	}
+	resp, err := b.api.MakeRequest(endpoint, params)
+	if err != nil {
+		b.metrics.SendError(sendErrorType(err))
+	}
+	return resp, err
+}
+
 // SendMessage sends a text message to the given chat.
 func (b *Bot) SendMessage(chatID int64, text string) {
 	msg := tgbotapi.NewMessage(chatID, text)
 	msg.DisableWebPagePreview = true
-	if _, err := b.api.Send(msg); err != nil {
+	if _, err := b.send(context.Background(), chatID, msg); err != nil {
 		b.log.Error("send message", "chat_id", chatID, "error", err)
 	}
 }
@@ -97,23 +239,166 @@ func (b *Bot) SendMessageWithKeyboard(chatID int64, text string, markup interfac
 	case *tgbotapi.InlineKeyboardMarkup:
 		msg.ReplyMarkup = m
 	}
-	if _, err := b.api.Send(msg); err != nil {
+	if _, err := b.send(context.Background(), chatID, msg); err != nil {
 		b.log.Error("send message with keyboard", "chat_id", chatID, "error", err)
 	}
 }
 
-// SendPhotoWithCaption sends a photo with caption and optional keyboard.
-func (b *Bot) SendPhotoWithCaption(chatID int64, photoURL string, caption string, markup interface{}) {
-	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileURL(photoURL))
-	photo.Caption = caption
-	photo.ParseMode = tgbotapi.ModeHTML
-	switch m := markup.(type) {
-	case *tgbotapi.InlineKeyboardMarkup:
-		photo.ReplyMarkup = m
+// sendOptions adjust how a notification is sent.
+type sendOptions struct {
+	silent      bool // without a notification sound
+	linkPreview bool // let Telegram show a preview of the first link
+}
+
+// sendHTML sends a message in Telegram's HTML parse mode with an optional
+// keyboard. If Telegram rejects the markup, the message is resent as plain text.
+func (b *Bot) sendHTML(ctx context.Context, chatID int64, body string, markup *tgbotapi.InlineKeyboardMarkup, opts sendOptions) error {
+	msg := tgbotapi.NewMessage(chatID, body)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.DisableWebPagePreview = !opts.linkPreview
+	msg.DisableNotification = opts.silent
+	if markup != nil {
+		msg.ReplyMarkup = markup
 	}
-	if _, err := b.api.Send(photo); err != nil {
-		b.log.Error("send photo", "chat_id", chatID, "photo_url", photoURL, "error", err)
+	_, err := b.send(ctx, chatID, msg)
+	if isEntityError(err) {
+		b.log.Warn("html rejected, sending plain text", "chat_id", chatID, "error", err)
+		msg.Text = text.StripHTML(body)
+		msg.ParseMode = ""
+		_, err = b.send(ctx, chatID, msg)
 	}
+	return err
+}
+
+// isEntityError reports whether Telegram refused a message because of its markup.
+func isEntityError(err error) bool {
+	var apiErr *tgbotapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusBadRequest &&
+		strings.Contains(apiErr.Message, "can't parse entities")
+}
+
+// Deliver sends a queued notification and reports whether Telegram accepted it.
+// Telegram errors are translated into *outbox.DeliveryError.
+func (b *Bot) Deliver(ctx context.Context, m model.OutboxMessage) error {
+	var markup *tgbotapi.InlineKeyboardMarkup
+	if m.Markup != "" {
+		if err := json.Unmarshal([]byte(m.Markup), &markup); err != nil {
+			return &outbox.DeliveryError{Err: fmt.Errorf("decode markup: %w", err), Permanent: true}
+		}
+	}
+	var media []model.Media
+	if m.Media != "" {
+		if err := json.Unmarshal([]byte(m.Media), &media); err != nil {
+			return &outbox.DeliveryError{Err: fmt.Errorf("decode media: %w", err), Permanent: true}
+		}
+	}
+	opts := sendOptions{silent: m.Silent, linkPreview: b.chatSettings(ctx, m.ChatID).LinkPreview}
+	if err := b.sendNotification(ctx, m.ChatID, m.Text, media, markup, opts); err != nil {
+		var sent *albumSentError
+		if errors.As(err, &sent) {
+			// Retry with the text alone rather than send the album again.
+			if err := b.store.DropMessageMedia(ctx, m.ID); err != nil {
+				b.log.Error("drop message media", "id", m.ID, "error", err)
+			}
+		}
+		return deliveryError(err)
+	}
+	b.metrics.ItemSent()
+	return nil
+}
+
+// chatSettings returns the settings of a chat, falling back to the defaults
+// if they cannot be read.
+func (b *Bot) chatSettings(ctx context.Context, chatID int64) *model.ChatSettings {
+	settings, err := b.store.GetChatSettings(ctx, chatID)
+	if err != nil {
+		b.log.Error("get chat settings", "chat_id", chatID, "error", err)
+		return model.DefaultChatSettings(chatID)
+	}
+	return settings
+}
+
+// ChatLanguage returns the language of a chat's messages; nil settings give
+// English.
+func ChatLanguage(cs *model.ChatSettings) i18n.Lang {
+	if cs == nil {
+		return i18n.English
+	}
+	return i18n.Choose(cs.Language, cs.TelegramLanguage)
+}
+
+// lang returns the language to answer a chat in.
+func (b *Bot) lang(ctx context.Context, chatID int64) i18n.Lang {
+	return ChatLanguage(b.chatSettings(ctx, chatID))
+}
+
+// rememberLanguage stores the language of the client that wrote to a chat,
+// so that messages the bot sends on its own use it too.
+func (b *Bot) rememberLanguage(ctx context.Context, chatID int64, from *tgbotapi.User) {
+	if from == nil || from.LanguageCode == "" {
+		return
+	}
+	b.mu.Lock()
+	known := b.languages[chatID] == from.LanguageCode
+	b.mu.Unlock()
+	if known {
+		return
+	}
+
+	settings, err := b.store.GetChatSettings(ctx, chatID)
+	if err != nil {
+		b.log.Error("get chat settings", "chat_id", chatID, "error", err)
+		return
+	}
+	if settings.TelegramLanguage != from.LanguageCode {
+		settings.TelegramLanguage = from.LanguageCode
+		if err := b.store.SaveChatSettings(ctx, settings); err != nil {
+			b.log.Error("save chat language", "chat_id", chatID, "error", err)
+			return
+		}
+	}
+	b.mu.Lock()
+	if b.languages == nil {
+		b.languages = make(map[int64]string)
+	}
+	b.languages[chatID] = from.LanguageCode
+	b.mu.Unlock()
+}
+
+// deliveryError classifies a Telegram API error for the outbox.
+func deliveryError(err error) error {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	de := &outbox.DeliveryError{Err: err}
+	switch {
+	case apiErr.RetryAfter > 0:
+		de.RetryAfter = time.Duration(apiErr.RetryAfter) * time.Second
+	case apiErr.Code == http.StatusBadRequest, apiErr.Code == http.StatusForbidden:
+		// Malformed message, chat not found or bot blocked: retrying won't help.
+		de.Permanent = true
+	}
+	return de
+}
+
+// sendErrorType names the kind of a failed Telegram request for metrics.
+func sendErrorType(err error) string {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) {
+		return "network"
+	}
+	switch {
+	case apiErr.RetryAfter > 0, apiErr.Code == http.StatusTooManyRequests:
+		return "rate_limited"
+	case apiErr.Code == http.StatusForbidden:
+		return "forbidden"
+	case apiErr.Code == http.StatusBadRequest:
+		return "bad_request"
+	case apiErr.Code >= http.StatusInternalServerError:
+		return "server"
+	}
+	return "other"
 }
 
 func (b *Bot) reply(chatID int64, text string) {
@@ -133,15 +418,19 @@ func (b *Bot) handleCommand(ctx context.Context, msg *tgbotapi.Message) {
 		"username", msg.From.UserName,
 	)
 
+	b.rememberLanguage(ctx, chatID, msg.From)
+
 	switch cmd {
 	case "start":
-		b.handleStart(chatID)
+		b.handleStart(ctx, chatID)
 	case "help":
-		b.handleHelp(chatID)
+		b.handleHelp(ctx, chatID)
 	case cmdAdd:
 		b.handleAdd(ctx, chatID, args)
 	case "list":
 		b.handleList(ctx, chatID)
+	case "export":
+		b.handleExport(ctx, chatID)
 	case cmdInfo:
 		b.handleInfo(ctx, chatID, args)
 	case cmdRemove:
@@ -156,6 +445,26 @@ func (b *Bot) handleCommand(ctx context.Context, msg *tgbotapi.Message) {
 		b.handleResume(ctx, chatID, args)
 	case cmdCheck:
 		b.handleCheck(ctx, chatID, args)
+	case "test":
+		b.handleTest(ctx, chatID, args)
+	case "markread":
+		b.handleMarkRead(ctx, chatID, args)
+	case "backlog":
+		b.handleBacklog(ctx, chatID, args)
+	case "digest":
+		b.handleDigest(ctx, chatID, args)
+	case "timezone":
+		b.handleTimezone(ctx, chatID, args)
+	case "quiet":
+		b.handleQuiet(ctx, chatID, args)
+	case "settings":
+		b.handleSettings(ctx, chatID)
+	case "language":
+		b.handleLanguage(ctx, chatID, args)
+	case "stats":
+		b.handleStats(ctx, chatID, msg.From.ID)
+	case "search":
+		b.handleSearch(ctx, chatID, args)
 	case cmdFilters:
 		b.handleFilters(ctx, chatID, args)
 	case cmdInclude:
@@ -166,9 +475,11 @@ func (b *Bot) handleCommand(ctx context.Context, msg *tgbotapi.Message) {
 		b.handleAddFilter(ctx, chatID, args, "include_re")
 	case "exclude_re":
 		b.handleAddFilter(ctx, chatID, args, "exclude_re")
+	case "query":
+		b.handleAddFilter(ctx, chatID, args, "query")
 	case cmdRmFilter:
 		b.handleRmFilter(ctx, chatID, args)
 	default:
-		b.reply(chatID, "Unknown command. Use /help for a list of commands.")
+		b.reply(chatID, b.lang(ctx, chatID).T("Unknown command. Use /help for a list of commands."))
 	}
 }
@@ -3,21 +3,32 @@ package bot
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"strings"
 	"sync"
 	"testing"
+	"time"
+	"unicode/utf8"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 
 	"rss_bot/internal/config"
+	"rss_bot/internal/feedlock"
 	"rss_bot/internal/fetcher"
+	"rss_bot/internal/metrics"
 	"rss_bot/internal/model"
+	"rss_bot/internal/outbox"
+	"rss_bot/internal/retention"
 	"rss_bot/internal/storage"
 )
 
@@ -28,25 +39,153 @@ type sentMsg struct {
 	Text   string
 }
 
+// sentFile is a file sent by URL together with its caption.
+type sentFile struct {
+	ChatID   int64
+	Kind     string
+	URL      string
+	Caption  string
+	Duration int
+}
+
 type mockAPI struct {
-	mu   sync.Mutex
-	sent []sentMsg
+	mu     sync.Mutex
+	sent   []sentMsg
+	docs   []tgbotapi.DocumentConfig
+	files  []sentFile
+	albums [][]string
+	// sendErr, when set, fails every Send.
+	sendErr error
+	// rejectHTML makes Send refuse messages in HTML parse mode.
+	rejectHTML bool
+	// rejectMedia makes Telegram refuse to fetch any file sent by URL.
+	rejectMedia bool
+	// keyboard is the inline keyboard of the last message that had one.
+	keyboard *tgbotapi.InlineKeyboardMarkup
+	// silent counts messages, photos and albums sent without a sound.
+	silent int
+	// edits holds the new texts of edited messages.
+	edits []string
+	// requests holds the raw API requests, such as setWebhook.
+	requests []apiRequest
+	// updates is returned by the next request for updates.
+	updates []tgbotapi.Update
+	// polls holds the requests for updates.
+	polls []tgbotapi.UpdateConfig
+	// pollErr, when set, fails every request for updates.
+	pollErr error
+	// pendingUpdates is reported by getWebhookInfo.
+	pendingUpdates int
+	// requestErr, when set, fails every raw API request.
+	requestErr error
+}
+
+type apiRequest struct {
+	Endpoint string
+	Params   tgbotapi.Params
 }
 
+var errFetchFile = &tgbotapi.Error{Code: http.StatusBadRequest, Message: "Bad Request: failed to get HTTP URL content"}
+
 func (m *mockAPI) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
-	if msg, ok := c.(tgbotapi.MessageConfig); ok {
-		m.mu.Lock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.sendErr != nil {
+		return tgbotapi.Message{}, m.sendErr
+	}
+	switch msg := c.(type) {
+	case tgbotapi.MessageConfig:
+		if m.rejectHTML && msg.ParseMode == tgbotapi.ModeHTML {
+			return tgbotapi.Message{}, &tgbotapi.Error{Code: http.StatusBadRequest, Message: "Bad Request: can't parse entities: unsupported start tag"}
+		}
 		m.sent = append(m.sent, sentMsg{ChatID: msg.ChatID, Text: msg.Text})
-		m.mu.Unlock()
+		m.countSilent(msg.DisableNotification)
+		if kb, ok := msg.ReplyMarkup.(*tgbotapi.InlineKeyboardMarkup); ok {
+			m.keyboard = kb
+		}
+	case tgbotapi.EditMessageTextConfig:
+		m.edits = append(m.edits, msg.Text)
+		if msg.ReplyMarkup != nil {
+			m.keyboard = msg.ReplyMarkup
+		}
+	case tgbotapi.DocumentConfig:
+		if url, ok := msg.File.(tgbotapi.FileURL); ok {
+			return m.sendFile(msg.ChatID, "document", url, msg.Caption, 0)
+		}
+		m.docs = append(m.docs, msg)
+	case tgbotapi.PhotoConfig:
+		m.countSilent(msg.DisableNotification)
+		return m.sendFile(msg.ChatID, "photo", msg.File, msg.Caption, 0)
+	case tgbotapi.AudioConfig:
+		return m.sendFile(msg.ChatID, "audio", msg.File, msg.Caption, msg.Duration)
+	case tgbotapi.VideoConfig:
+		return m.sendFile(msg.ChatID, "video", msg.File, msg.Caption, msg.Duration)
 	}
 	return tgbotapi.Message{}, nil
 }
 
-func (m *mockAPI) GetUpdatesChan(_ tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel {
-	return make(tgbotapi.UpdatesChannel)
+func (m *mockAPI) sendFile(chatID int64, kind string, file tgbotapi.RequestFileData, caption string, duration int) (tgbotapi.Message, error) {
+	if m.rejectMedia {
+		return tgbotapi.Message{}, errFetchFile
+	}
+	m.files = append(m.files, sentFile{ChatID: chatID, Kind: kind, URL: string(file.(tgbotapi.FileURL)), Caption: caption, Duration: duration})
+	return tgbotapi.Message{}, nil
+}
+
+func (m *mockAPI) SendMediaGroup(c tgbotapi.MediaGroupConfig) ([]tgbotapi.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.rejectMedia {
+		return nil, errFetchFile
+	}
+	var urls []string
+	for _, f := range c.Media {
+		urls = append(urls, string(f.(tgbotapi.InputMediaPhoto).Media.(tgbotapi.FileURL)))
+	}
+	m.albums = append(m.albums, urls)
+	m.countSilent(c.DisableNotification)
+	return nil, nil
+}
+
+func (m *mockAPI) countSilent(silent bool) {
+	if silent {
+		m.silent++
+	}
+}
+
+func (m *mockAPI) GetUpdates(c tgbotapi.UpdateConfig) ([]tgbotapi.Update, error) {
+	m.mu.Lock()
+	m.polls = append(m.polls, c)
+	updates, err := m.updates, m.pollErr
+	m.updates = nil
+	m.mu.Unlock()
+	if err != nil || len(updates) == 0 {
+		// Telegram holds a request without updates until its timeout.
+		time.Sleep(10 * time.Millisecond)
+	}
+	return updates, err
+}
+
+// testToken is the bot token in the file URLs of the mock API.
+const testToken = "123456:secret-token"
+
+func (m *mockAPI) GetFileDirectURL(fileID string) (string, error) {
+	return "https://api.telegram.org/file/bot" + testToken + "/" + fileID, nil
 }
 
-func (m *mockAPI) StopReceivingUpdates() {}
+func (m *mockAPI) MakeRequest(endpoint string, params tgbotapi.Params) (*tgbotapi.APIResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, apiRequest{Endpoint: endpoint, Params: params})
+	if m.requestErr != nil {
+		return nil, m.requestErr
+	}
+	if endpoint == "getWebhookInfo" {
+		result, _ := json.Marshal(tgbotapi.WebhookInfo{PendingUpdateCount: m.pendingUpdates})
+		return &tgbotapi.APIResponse{Ok: true, Result: result}, nil
+	}
+	return &tgbotapi.APIResponse{Ok: true}, nil
+}
 
 func (m *mockAPI) lastText() string {
 	m.mu.Lock()
@@ -67,6 +206,25 @@ func (m *mockAPI) allTexts() []string {
 	return out
 }
 
+// button returns the callback data of the button labelled label in the last
+// inline keyboard.
+func (m *mockAPI) button(t *testing.T, label string) string {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.keyboard != nil {
+		for _, row := range m.keyboard.InlineKeyboard {
+			for _, b := range row {
+				if b.Text == label && b.CallbackData != nil {
+					return *b.CallbackData
+				}
+			}
+		}
+	}
+	t.Fatalf("no button %q in the last keyboard", label)
+	return ""
+}
+
 func (m *mockAPI) reset() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -78,9 +236,10 @@ type mockHTTPClient struct {
 	err  error
 }
 
-func (m *mockHTTPClient) Do(_ *http.Request) (*http.Response, error) {
+func (m *mockHTTPClient) Do(req *http.Request) (*http.Response, error) {
 	if m.err != nil {
-		return nil, m.err
+		// Like http.Client, report the URL along with the error.
+		return nil, &url.Error{Op: req.Method, URL: req.URL.String(), Err: m.err}
 	}
 	return &http.Response{
 		StatusCode: 200,
@@ -88,6 +247,19 @@ func (m *mockHTTPClient) Do(_ *http.Request) (*http.Response, error) {
 	}, nil
 }
 
+// routeHTTPClient serves canned bodies by URL and replies 404 to anything else.
+type routeHTTPClient struct {
+	routes map[string]string
+}
+
+func (m *routeHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	body, ok := m.routes[req.URL.String()]
+	if !ok {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(bytes.NewBufferString(""))}, nil
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+}
+
 // --- helpers ---
 
 func newTestBot(t *testing.T, httpBody string) (*Bot, *mockAPI, *storage.SQLite) {
@@ -104,11 +276,18 @@ func newTestBot(t *testing.T, httpBody string) (*Bot, *mockAPI, *storage.SQLite)
 		store:   store,
 		cfg:     &config.Config{},
 		fetcher: fetcher.New(&mockHTTPClient{body: httpBody}),
+		locks:   feedlock.New(),
 		log:     slog.New(slog.NewTextHandler(io.Discard, nil)),
 	}
 	return b, api, store
 }
 
+// flushOutbox delivers the messages queued in the outbox through the bot.
+func flushOutbox(t *testing.T, b *Bot, store *storage.SQLite) {
+	t.Helper()
+	outbox.New(store, b, b.log).Flush(context.Background())
+}
+
 func seedFeed(t *testing.T, store *storage.SQLite, chatID int64, name, url string) *model.Feed {
 	t.Helper()
 	f := &model.Feed{ChatID: chatID, Name: name, URL: url, IntervalMinutes: 15, IsActive: true}
@@ -118,6 +297,16 @@ func seedFeed(t *testing.T, store *storage.SQLite, chatID int64, name, url strin
 	return f
 }
 
+// register stores a callback payload and returns its token.
+func register(t *testing.T, store *storage.SQLite, cb model.Callback) string {
+	t.Helper()
+	cb.ExpiresAt = time.Now().Add(time.Hour)
+	if err := store.CreateCallback(context.Background(), &cb); err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+	return cb.Token
+}
+
 func seedFilter(t *testing.T, store *storage.SQLite, feedID int64, kind model.FilterKind, value string) *model.Filter {
 	t.Helper()
 	f := &model.Filter{FeedID: feedID, Kind: kind, Scope: model.ScopeAll, Value: value}
@@ -147,13 +336,13 @@ func requireContains(t *testing.T, got, want string) {
 
 func TestHandleStart(t *testing.T) {
 	b, api, _ := newTestBot(t, "")
-	b.handleStart(100)
+	b.handleStart(context.Background(), 100)
 	requireContains(t, api.lastText(), "Welcome to RSS Notify Bot")
 }
 
 func TestHandleHelp(t *testing.T) {
 	b, api, _ := newTestBot(t, "")
-	b.handleHelp(100)
+	b.handleHelp(context.Background(), 100)
 	requireContains(t, api.lastText(), "/add")
 	requireContains(t, api.lastText(), "/filters")
 }
@@ -189,6 +378,59 @@ func TestHandleAdd(t *testing.T) {
 		}
 	})
 
+	t.Run("web page with several feeds", func(t *testing.T) {
+		b, api, store := newTestBot(t, "")
+		page := `<html><head>
+<link rel="alternate" type="application/rss+xml" title="Posts" href="/posts.rss">
+<link rel="alternate" type="application/atom+xml" title="Comments" href="/comments.atom">
+</head><body></body></html>`
+		b.fetcher = fetcher.New(&routeHTTPClient{routes: map[string]string{
+			"https://blog.example.com/":              page,
+			"https://blog.example.com/comments.atom": xml,
+		}})
+
+		b.handleAdd(ctx, 100, "https://blog.example.com/")
+		requireContains(t, api.lastText(), "Found 2 feeds")
+
+		// The buttons keep working after a restart.
+		b = &Bot{api: api, store: store, cfg: b.cfg, fetcher: b.fetcher, locks: b.locks, log: b.log}
+		cb := &tgbotapi.CallbackQuery{
+			ID:      "cb",
+			From:    &tgbotapi.User{ID: 42},
+			Data:    api.button(t, "Comments"),
+			Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: 100}},
+		}
+		b.handleCallback(ctx, cb)
+		requireContains(t, api.lastText(), "Feed added")
+
+		feeds, _ := store.ListFeeds(ctx, 100)
+		if diff := cmp.Diff(1, len(feeds)); diff != "" {
+			t.Fatalf("feed count (-want +got):\n%s", diff)
+		}
+		if diff := cmp.Diff("https://blog.example.com/comments.atom", feeds[0].URL); diff != "" {
+			t.Errorf("feed url (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("web page with single feed is added directly", func(t *testing.T) {
+		b, api, _ := newTestBot(t, "")
+		page := `<html><head><link rel="alternate" type="application/rss+xml" href="/rss"></head></html>`
+		b.fetcher = fetcher.New(&routeHTTPClient{routes: map[string]string{
+			"https://blog.example.com/":    page,
+			"https://blog.example.com/rss": xml,
+		}})
+
+		b.handleAdd(ctx, 100, "https://blog.example.com/")
+		requireContains(t, api.lastText(), "Feed added")
+		requireContains(t, api.lastText(), "URL: https://blog.example.com/rss")
+	})
+
+	t.Run("web page without feeds", func(t *testing.T) {
+		b, api, _ := newTestBot(t, "<!DOCTYPE html><html><body>hi</body></html>")
+		b.handleAdd(ctx, 100, "https://blog.example.com/")
+		requireContains(t, api.lastText(), "no feeds were found")
+	})
+
 	t.Run("success fallback to url", func(t *testing.T) {
 		noTitle := `<?xml version="1.0"?><rss><channel><title></title></channel></rss>`
 		b, api, _ := newTestBot(t, noTitle)
@@ -395,6 +637,7 @@ func TestHandleResume(t *testing.T) {
 		f := seedFeed(t, store, 100, "Feed", "https://x.com")
 		f.IsActive = false
 		_ = store.UpdateFeed(ctx, f)
+		_ = store.SetBacklogState(ctx, f.ID, model.BacklogDone)
 
 		b.handleResume(ctx, 100, "1")
 		requireContains(t, api.lastText(), "resumed")
@@ -403,6 +646,9 @@ func TestHandleResume(t *testing.T) {
 		if diff := cmp.Diff(true, feed.IsActive); diff != "" {
 			t.Errorf("IsActive (-want +got):\n%s", diff)
 		}
+		if diff := cmp.Diff(model.BacklogPending, feed.BacklogState); diff != "" {
+			t.Errorf("BacklogState (-want +got):\n%s", diff)
+		}
 	})
 }
 
@@ -441,15 +687,38 @@ func TestHandleCheck(t *testing.T) {
 
 	t.Run("with new items", func(t *testing.T) {
 		b, api, store := newTestBot(t, xml)
-		seedFeed(t, store, 100, "Feed", "https://x.com")
+		m := metrics.New()
+		b.SetMetrics(m)
+		f := seedFeed(t, store, 100, "Feed", "https://x.com")
 		b.handleCheck(ctx, 100, "1")
+		requireContains(t, api.lastText(), "Found 5 new item(s)")
+		if seen, _ := store.IsSeen(ctx, f.ID, "item-1"); seen {
+			t.Error("item marked seen before it was sent")
+		}
 
+		flushOutbox(t, b, store)
 		texts := api.allTexts()
-		// 5 items + 1 summary
+		// 1 summary + 5 items
 		if diff := cmp.Diff(6, len(texts)); diff != "" {
 			t.Errorf("reply count (-want +got):\n%s", diff)
 		}
-		requireContains(t, texts[len(texts)-1], "Found 5 new item(s)")
+		if seen, _ := store.IsSeen(ctx, f.ID, "item-1"); !seen {
+			t.Error("sent item not marked seen")
+		}
+		if _, total, _ := store.SearchArchive(ctx, 100, `feed : "feed"*`, 10, 0); total != 5 {
+			t.Errorf("archived items = %d, want 5", total)
+		}
+		rec := httptest.NewRecorder()
+		m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		requireContains(t, rec.Body.String(), "rss_bot_items_sent_total 5\n")
+	})
+
+	t.Run("feed locked by scheduler", func(t *testing.T) {
+		b, api, store := newTestBot(t, xml)
+		f := seedFeed(t, store, 100, "Feed", "https://x.com")
+		b.locks.TryLock(f.ID)
+		b.handleCheck(ctx, 100, "1")
+		requireContains(t, api.lastText(), "being checked right now")
 	})
 
 	t.Run("with include filter", func(t *testing.T) {
@@ -457,13 +726,658 @@ func TestHandleCheck(t *testing.T) {
 		f := seedFeed(t, store, 100, "Feed", "https://x.com")
 		seedFilter(t, store, f.ID, model.FilterInclude, "docker")
 		b.handleCheck(ctx, 100, "1")
+		flushOutbox(t, b, store)
 
 		texts := api.allTexts()
-		// 1 matching item + 1 summary
+		// 1 summary + 1 matching item
 		if diff := cmp.Diff(2, len(texts)); diff != "" {
 			t.Errorf("reply count (-want +got):\n%s", diff)
 		}
-		requireContains(t, texts[0], "Docker Desktop")
+		requireContains(t, texts[1], "Docker Desktop")
+	})
+}
+
+func TestHandleTest(t *testing.T) {
+	xml := loadSampleXML(t)
+	ctx := context.Background()
+
+	t.Run("bad args", func(t *testing.T) {
+		b, api, _ := newTestBot(t, xml)
+		b.handleTest(ctx, 100, "")
+		requireContains(t, api.lastText(), "Usage: /test")
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		b, api, _ := newTestBot(t, xml)
+		b.handleTest(ctx, 100, "999")
+		requireContains(t, api.lastText(), "not found")
+	})
+
+	t.Run("explains every item without marking seen", func(t *testing.T) {
+		b, api, store := newTestBot(t, xml)
+		f := seedFeed(t, store, 100, "Feed", "https://x.com")
+		seedFilter(t, store, f.ID, model.FilterInclude, "kubernetes")
+		seedFilter(t, store, f.ID, model.FilterExclude, "vacancy")
+		_ = store.MarkSeen(ctx, f.ID, "item-4", "")
+
+		b.handleTest(ctx, 100, "1")
+
+		texts := api.allTexts()
+		if diff := cmp.Diff(1, len(texts)); diff != "" {
+			t.Fatalf("reply count (-want +got):\n%s", diff)
+		}
+		reply := texts[0]
+		requireContains(t, reply, "3 of 5 item(s) pass")
+		requireContains(t, reply, "[+] Kubernetes 1.32 Released\n    passed by F1 include \"kubernetes\"")
+		requireContains(t, reply, "[-] Docker Desktop Update\n    rejected: no include filter matched")
+		requireContains(t, reply, "[-] DevOps Job Vacancy at BigCorp\n    rejected by F2 exclude \"vacancy\"")
+		requireContains(t, reply, "[+] Helm Chart Best Practices\n    passed by F1 include \"kubernetes\" (already seen)")
+
+		for _, guid := range []string{"item-1", "item-2", "item-3", "item-5"} {
+			seen, _ := store.IsSeen(ctx, f.ID, guid)
+			if seen {
+				t.Errorf("item %s marked seen by /test", guid)
+			}
+		}
+	})
+
+	t.Run("long result is split between items", func(t *testing.T) {
+		b, api, store := newTestBot(t, xml)
+		f := seedFeed(t, store, 100, "Feed", "https://x.com")
+		seedFilter(t, store, f.ID, model.FilterQuery, "kubernetes OR "+strings.Repeat("x", 1500))
+
+		b.handleTest(ctx, 100, "1")
+
+		texts := api.allTexts()
+		if len(texts) < 2 {
+			t.Fatalf("got %d reply, want the result split", len(texts))
+		}
+		for i, text := range texts {
+			if n := utf8.RuneCountInString(text); n > 4096 {
+				t.Errorf("reply %d has %d characters", i, n)
+			}
+		}
+		all := strings.Join(texts, "\n")
+		for _, title := range []string{"Kubernetes 1.32 Released", "Online Course: K8s Training for Beginners"} {
+			requireContains(t, all, title)
+		}
+	})
+}
+
+func TestHandleBacklog(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("bad args", func(t *testing.T) {
+		b, api, _ := newTestBot(t, "")
+		b.handleBacklog(ctx, 100, "1 sometimes")
+		requireContains(t, api.lastText(), "Usage: /backlog")
+	})
+
+	t.Run("bad limit", func(t *testing.T) {
+		b, api, store := newTestBot(t, "")
+		seedFeed(t, store, 100, "Feed", "https://x.com")
+		b.handleBacklog(ctx, 100, "1 newest 0")
+		requireContains(t, api.lastText(), "between 1 and 100")
+	})
+
+	t.Run("sets policy and limit", func(t *testing.T) {
+		b, api, store := newTestBot(t, "")
+		f := seedFeed(t, store, 100, "Feed", "https://x.com")
+		b.handleBacklog(ctx, 100, "1 ask 10")
+		requireContains(t, api.lastText(), "ask when more than 10")
+
+		got, _ := store.GetFeed(ctx, f.ID)
+		if diff := cmp.Diff(model.BacklogAsk, got.BacklogPolicy); diff != "" {
+			t.Errorf("policy (-want +got):\n%s", diff)
+		}
+		if diff := cmp.Diff(10, got.BacklogLimit); diff != "" {
+			t.Errorf("limit (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestHandleDigest(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("bad args", func(t *testing.T) {
+		b, api, _ := newTestBot(t, "")
+		b.handleDigest(ctx, 100, "sometimes")
+		requireContains(t, api.lastText(), "Usage: /digest")
+	})
+
+	t.Run("bad times", func(t *testing.T) {
+		b, api, _ := newTestBot(t, "")
+		b.handleDigest(ctx, 100, "at 25:00")
+		requireContains(t, api.lastText(), "Invalid digest times")
+	})
+
+	t.Run("chat schedule", func(t *testing.T) {
+		b, api, store := newTestBot(t, "")
+		b.handleDigest(ctx, 100, "on")
+		b.handleDigest(ctx, 100, "at 18:00 09:00")
+		requireContains(t, api.lastText(), "Digest on by default, at 09:00, 18:00 (UTC).")
+
+		got, _ := store.GetChatSettings(ctx, 100)
+		want := model.DefaultChatSettings(100)
+		want.Digest, want.DigestTimes = true, []string{"09:00", "18:00"}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("settings (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("feed override", func(t *testing.T) {
+		b, api, store := newTestBot(t, "")
+		f := seedFeed(t, store, 100, "Feed", "https://x.com")
+		b.handleDigest(ctx, 100, "1 on")
+		requireContains(t, api.lastText(), `Delivery of #1 "Feed": digest.`)
+
+		got, _ := store.GetFeed(ctx, f.ID)
+		if diff := cmp.Diff(model.DeliveryDigest, got.Delivery); diff != "" {
+			t.Errorf("delivery (-want +got):\n%s", diff)
+		}
+
+		b.handleDigest(ctx, 100, "")
+		requireContains(t, api.lastText(), "Digest: off by default")
+		requireContains(t, api.lastText(), `#1 "Feed": digest`)
+	})
+
+	t.Run("missing feed", func(t *testing.T) {
+		b, api, _ := newTestBot(t, "")
+		b.handleDigest(ctx, 100, "3 off")
+		requireContains(t, api.lastText(), "Feed #3 not found")
+	})
+}
+
+func TestHandleQuiet(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("bad window", func(t *testing.T) {
+		b, api, _ := newTestBot(t, "")
+		b.handleQuiet(ctx, 100, "23:00-23:00")
+		requireContains(t, api.lastText(), "Invalid quiet hours")
+	})
+
+	t.Run("chat quiet hours", func(t *testing.T) {
+		b, api, store := newTestBot(t, "")
+		b.handleQuiet(ctx, 100, "")
+		requireContains(t, api.lastText(), "Quiet hours: off")
+
+		b.handleQuiet(ctx, 100, "23:00-8:00")
+		requireContains(t, api.lastText(), "Quiet hours: 23:00-08:00 (UTC), notifications held until the end.")
+		b.handleQuiet(ctx, 100, "silent")
+		requireContains(t, api.lastText(), "notifications sent silently.")
+
+		got, _ := store.GetChatSettings(ctx, 100)
+		want := model.DefaultChatSettings(100)
+		want.QuietStart, want.QuietEnd, want.QuietMode = "23:00", "08:00", model.QuietSilent
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("settings (-want +got):\n%s", diff)
+		}
+
+		b.handleQuiet(ctx, 100, "off")
+		requireContains(t, api.lastText(), "Quiet hours turned off.")
+	})
+
+	t.Run("feed override", func(t *testing.T) {
+		b, api, store := newTestBot(t, "")
+		f := seedFeed(t, store, 100, "Feed", "https://x.com")
+		b.handleQuiet(ctx, 100, "1 silent")
+		requireContains(t, api.lastText(), `notifications of #1 "Feed": sent silently.`)
+
+		got, _ := store.GetFeed(ctx, f.ID)
+		if diff := cmp.Diff(model.QuietSilent, got.Quiet); diff != "" {
+			t.Errorf("quiet mode (-want +got):\n%s", diff)
+		}
+
+		b.handleQuiet(ctx, 100, "22:00 - 07:00")
+		b.handleQuiet(ctx, 100, "")
+		requireContains(t, api.lastText(), "Notifications: held until the end")
+		requireContains(t, api.lastText(), `#1 "Feed": sent silently`)
+
+		b.handleQuiet(ctx, 100, "1 loud")
+		requireContains(t, api.lastText(), "Usage: /quiet")
+	})
+}
+
+func TestHandleSettings(t *testing.T) {
+	ctx := context.Background()
+	b, api, store := newTestBot(t, "")
+
+	b.handleSettings(ctx, 100)
+	requireContains(t, api.lastText(), "Default interval: every 15 min")
+
+	press := func(label string) string {
+		t.Helper()
+		b.handleCallback(ctx, &tgbotapi.CallbackQuery{
+			ID:      "cb",
+			From:    &tgbotapi.User{ID: 42},
+			Data:    api.button(t, label),
+			Message: &tgbotapi.Message{MessageID: 7, Chat: &tgbotapi.Chat{ID: 100}},
+		})
+		return api.edits[len(api.edits)-1]
+	}
+
+	requireContains(t, press("Interval: 15 min"), "Default interval: every 30 min")
+	requireContains(t, press("Scope: all"), "Default filter scope: author")
+	requireContains(t, press("Preview: 1500"), "Preview length: 3000 characters")
+	requireContains(t, press("Images: on"), "Images: off")
+	requireContains(t, press("Link previews: off"), "Link previews: on")
+	requireContains(t, press("Time zone: UTC"), "Pick a time zone")
+	requireContains(t, press("Europe/Berlin"), "Time zone: Europe/Berlin")
+	requireContains(t, press("Language: auto"), "Language: English")
+
+	got, _ := store.GetChatSettings(ctx, 100)
+	want := &model.ChatSettings{
+		ChatID: 100, Language: "en", Timezone: "Europe/Berlin", DigestTimes: []string{"09:00"}, QuietMode: model.QuietHold,
+		IntervalMinutes: 30, FilterScope: model.ScopeAuthor, PreviewLength: 3000, LinkPreview: true,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("settings (-want +got):\n%s", diff)
+	}
+
+	t.Run("new feeds and filters use the defaults", func(t *testing.T) {
+		b.addFeed(ctx, 100, "https://x.com/rss", "Feed")
+		requireContains(t, api.lastText(), "(every 30 min)")
+
+		b.handleAddFilter(ctx, 100, "1 golang", string(model.FilterInclude))
+		feed, err := store.GetFeedByPosition(ctx, 100, 1)
+		if err != nil {
+			t.Fatalf("get feed: %v", err)
+		}
+		filters, _ := store.ListFilters(ctx, feed.ID)
+		if len(filters) != 1 || filters[0].Scope != model.ScopeAuthor {
+			t.Errorf("filters = %+v, want one with the author scope", filters)
+		}
+	})
+}
+
+func TestHandleTimezone(t *testing.T) {
+	ctx := context.Background()
+	b, api, store := newTestBot(t, "")
+
+	b.handleTimezone(ctx, 100, "Mars/Olympus")
+	requireContains(t, api.lastText(), "Unknown time zone")
+
+	b.handleTimezone(ctx, 100, "UTC")
+	requireContains(t, api.lastText(), "Time zone set to UTC.")
+
+	b.handleTimezone(ctx, 100, "")
+	requireContains(t, api.lastText(), "Time zone: UTC")
+
+	if got, _ := store.GetChatSettings(ctx, 100); got.Timezone != "UTC" {
+		t.Errorf("timezone = %q, want UTC", got.Timezone)
+	}
+}
+
+func TestHandleLanguage(t *testing.T) {
+	ctx := context.Background()
+	b, api, store := newTestBot(t, "")
+
+	b.handleLanguage(ctx, 100, "")
+	requireContains(t, api.lastText(), "Language: auto")
+
+	b.handleLanguage(ctx, 100, "de")
+	requireContains(t, api.lastText(), "Usage: /language <en|ru|auto>")
+
+	b.handleLanguage(ctx, 100, "ru")
+	requireContains(t, api.lastText(), "Язык: Русский.")
+
+	b.handleList(ctx, 100)
+	requireContains(t, api.lastText(), "Лент пока нет.")
+
+	b.handleLanguage(ctx, 100, "auto")
+	requireContains(t, api.lastText(), "Language set to auto.")
+
+	got, _ := store.GetChatSettings(ctx, 100)
+	if got.Language != "" {
+		t.Errorf("language = %q, want automatic", got.Language)
+	}
+}
+
+func TestHandleStats(t *testing.T) {
+	ctx := context.Background()
+	b, api, store := newTestBot(t, "")
+	b.cfg.AdminUsers = []int64{42}
+	b.cfg.RetentionDays = 90
+	f := seedFeed(t, store, 100, "Feed", "https://x.com")
+	_ = store.MarkSeen(ctx, f.ID, "item-1", "full content")
+
+	b.handleStats(ctx, 100, 7)
+	requireContains(t, api.lastText(), "Unknown command.")
+
+	b.handleStats(ctx, 100, 42)
+	for _, want := range []string{"Seen items: 1, 1 of them in the latest downloads", "Retention: 90 days", "Last retention run: not yet"} {
+		requireContains(t, api.lastText(), want)
+	}
+
+	job := retention.New(store, b.log)
+	b.SetRetention(job)
+	job.RunOnce(ctx)
+	b.handleStats(ctx, 100, 42)
+	requireContains(t, api.lastText(), "0 pruned, 0 compressed")
+}
+
+func TestHandleSearch(t *testing.T) {
+	ctx := context.Background()
+	b, api, store := newTestBot(t, "")
+	f := seedFeed(t, store, 100, "Feed", "https://x.com")
+	published := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	deliver := func(chatID int64, guid, title string) {
+		t.Helper()
+		m := &model.OutboxMessage{ChatID: chatID, FeedID: f.ID, GUID: guid, Text: title, Archive: []model.ArchivedItem{{
+			FeedID: f.ID, GUID: guid, FeedName: "Feed", Title: title, Link: "https://x.com/" + guid, Published: &published,
+		}}}
+		if err := store.EnqueueMessage(ctx, m); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+		if err := store.CompleteMessage(ctx, m); err != nil {
+			t.Fatalf("complete: %v", err)
+		}
+	}
+	for i := 1; i <= 7; i++ {
+		deliver(100, fmt.Sprintf("k%d", i), fmt.Sprintf("Kubernetes release %d", i))
+	}
+	deliver(100, "webinar", "Kubernetes webinar")
+	deliver(100, "docker", "Docker news")
+	deliver(200, "other", "Kubernetes in another chat")
+
+	tests := []struct {
+		name string
+		args string
+		want []string
+	}{
+		{name: "usage", args: "", want: []string{"Usage: /search <query>"}},
+		{name: "invalid query", args: "go AND", want: []string{"invalid query: position 7", "Example: /search"}},
+		{name: "only negated", args: "NOT webinar", want: []string{"a search needs a term that is not negated"}},
+		{name: "nothing found", args: "golang", want: []string{"Nothing found among the delivered items."}},
+		{
+			name: "single page",
+			args: "title:docker",
+			want: []string{"Found 1 item(s), page 1 of 1:", "1. Docker news\nFeed · 2024-05-01 12:00 UTC\nhttps://x.com/docker"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b.handleSearch(ctx, 100, tt.args)
+			for _, want := range tt.want {
+				requireContains(t, api.lastText(), want)
+			}
+		})
+	}
+
+	t.Run("pages", func(t *testing.T) {
+		b.handleSearch(ctx, 100, "kubernetes NOT webinar")
+		first := api.lastText()
+		for _, want := range []string{"Found 7 item(s), page 1 of 2:", "1. Kubernetes release 7", "5. Kubernetes release 3"} {
+			requireContains(t, first, want)
+		}
+		if strings.Contains(first, "another chat") {
+			t.Errorf("results include another chat:\n%s", first)
+		}
+
+		press := func(label string) string {
+			t.Helper()
+			b.handleCallback(ctx, &tgbotapi.CallbackQuery{
+				ID:      "cb",
+				From:    &tgbotapi.User{ID: 42},
+				Data:    api.button(t, label),
+				Message: &tgbotapi.Message{MessageID: 7, Chat: &tgbotapi.Chat{ID: 100}},
+			})
+			return api.edits[len(api.edits)-1]
+		}
+		second := press("Older »")
+		for _, want := range []string{"page 2 of 2:", "6. Kubernetes release 2", "7. Kubernetes release 1"} {
+			requireContains(t, second, want)
+		}
+		if diff := cmp.Diff(first, press("« Newer")); diff != "" {
+			t.Errorf("first page again (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestHandleMarkRead(t *testing.T) {
+	xml := loadSampleXML(t)
+	ctx := context.Background()
+
+	b, api, store := newTestBot(t, xml)
+	f := seedFeed(t, store, 100, "Feed", "https://x.com")
+	_ = store.MarkSeen(ctx, f.ID, "item-1", "")
+
+	b.handleMarkRead(ctx, 100, "1")
+
+	texts := api.allTexts()
+	if diff := cmp.Diff([]string{`Marked 4 item(s) in #1 "Feed" as read.`}, texts); diff != "" {
+		t.Errorf("replies (-want +got):\n%s", diff)
+	}
+	got, _ := store.GetFeed(ctx, f.ID)
+	if diff := cmp.Diff(model.BacklogDone, got.BacklogState); diff != "" {
+		t.Errorf("backlog state (-want +got):\n%s", diff)
+	}
+	for _, guid := range []string{"item-2", "item-3", "item-4", "item-5"} {
+		if seen, _ := store.IsSeen(ctx, f.ID, guid); !seen {
+			t.Errorf("item %s not marked seen", guid)
+		}
+	}
+}
+
+func TestHandleBacklogChoice(t *testing.T) {
+	xml := loadSampleXML(t)
+	ctx := context.Background()
+
+	setup := func(t *testing.T) (*Bot, *mockAPI, *storage.SQLite, *model.Feed) {
+		t.Helper()
+		b, api, store := newTestBot(t, xml)
+		f := seedFeed(t, store, 100, "Feed", "https://x.com")
+		if err := store.SetBacklogState(ctx, f.ID, model.BacklogAsked); err != nil {
+			t.Fatalf("set backlog state: %v", err)
+		}
+		return b, api, store, f
+	}
+
+	tests := []struct {
+		choice    string
+		wantSent  int
+		wantReply string
+	}{
+		{choice: "all", wantSent: 5, wantReply: `Sent 5 item(s) of #1 "Feed", marked 0 as read.`},
+		{choice: "2", wantSent: 2, wantReply: `Sent 2 item(s) of #1 "Feed", marked 3 as read.`},
+		{choice: "read", wantSent: 0, wantReply: `Sent 0 item(s) of #1 "Feed", marked 5 as read.`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.choice, func(t *testing.T) {
+			b, api, store, f := setup(t)
+			b.handleBacklogChoice(ctx, 100, f.ID, tt.choice)
+			if diff := cmp.Diff(tt.wantReply, api.lastText()); diff != "" {
+				t.Errorf("summary (-want +got):\n%s", diff)
+			}
+
+			flushOutbox(t, b, store)
+			texts := api.allTexts()
+			if diff := cmp.Diff(tt.wantSent+1, len(texts)); diff != "" {
+				t.Errorf("reply count (-want +got):\n%s", diff)
+			}
+			got, _ := store.GetFeed(ctx, f.ID)
+			if diff := cmp.Diff(model.BacklogDone, got.BacklogState); diff != "" {
+				t.Errorf("backlog state (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("expired", func(t *testing.T) {
+		b, api, store, f := setup(t)
+		_ = store.SetBacklogState(ctx, f.ID, model.BacklogDone)
+		b.handleBacklogChoice(ctx, 100, f.ID, "all")
+		requireContains(t, api.lastText(), "expired")
+	})
+
+	t.Run("other chat", func(t *testing.T) {
+		b, api, _, f := setup(t)
+		b.handleBacklogChoice(ctx, 200, f.ID, "all")
+		requireContains(t, api.lastText(), "Feed not found")
+	})
+}
+
+func TestHandleExport(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("no feeds", func(t *testing.T) {
+		b, api, _ := newTestBot(t, "")
+		b.handleExport(ctx, 100)
+		requireContains(t, api.lastText(), "no feeds to export")
+	})
+
+	t.Run("sends opml document", func(t *testing.T) {
+		b, api, store := newTestBot(t, "")
+		seedFeed(t, store, 100, "Go Blog", "https://go.dev/blog/feed.atom")
+		seedFeed(t, store, 200, "Other", "https://other.example.com/rss")
+		b.handleExport(ctx, 100)
+
+		if diff := cmp.Diff(1, len(api.docs)); diff != "" {
+			t.Fatalf("document count (-want +got):\n%s", diff)
+		}
+		file, ok := api.docs[0].File.(tgbotapi.FileBytes)
+		if !ok {
+			t.Fatalf("file type = %T, want FileBytes", api.docs[0].File)
+		}
+		if diff := cmp.Diff("feeds.opml", file.Name); diff != "" {
+			t.Errorf("file name (-want +got):\n%s", diff)
+		}
+		requireContains(t, string(file.Bytes), `xmlUrl="https://go.dev/blog/feed.atom"`)
+		if strings.Contains(string(file.Bytes), "other.example.com") {
+			t.Errorf("export contains another chat's feed:\n%s", file.Bytes)
+		}
+	})
+}
+
+func TestHandleDocument(t *testing.T) {
+	ctx := context.Background()
+	const opmlBody = `<?xml version="1.0"?>
+<opml version="2.0"><body>
+  <outline text="Tech">
+    <outline text="Go Blog" type="rss" xmlUrl="https://go.dev/blog/feed.atom" interval="60"/>
+    <outline text="Existing" type="rss" xmlUrl="https://existing.example.com/rss"/>
+    <outline text="Local" type="rss" xmlUrl="file:///etc/passwd"/>
+  </outline>
+  <outline type="rss" xmlUrl="https://untitled.example.com/rss" interval="5000"/>
+  <outline text="Go Blog again" type="rss" xmlUrl="https://go.dev/blog/feed.atom"/>
+</body></opml>`
+
+	docMsg := func(name string, size int) *tgbotapi.Message {
+		return &tgbotapi.Message{
+			From:     &tgbotapi.User{ID: 1},
+			Chat:     &tgbotapi.Chat{ID: 100},
+			Document: &tgbotapi.Document{FileID: "file-1", FileName: name, FileSize: size},
+		}
+	}
+
+	t.Run("rejects non opml file", func(t *testing.T) {
+		b, api, _ := newTestBot(t, "")
+		b.handleDocument(ctx, docMsg("photo.jpg", 100))
+		requireContains(t, api.lastText(), "Only OPML files")
+	})
+
+	t.Run("ignores other files in groups", func(t *testing.T) {
+		b, api, _ := newTestBot(t, "")
+		b.cfg.AllowedUsers = []int64{42}
+		send := func(chatType, name, caption string) {
+			msg := docMsg(name, 100)
+			msg.Chat.Type = chatType
+			msg.Caption = caption
+			b.handleUpdate(ctx, tgbotapi.Update{Message: msg})
+		}
+
+		send("group", "photo.jpg", "")
+		send("supergroup", "report.pdf", "see page 3")
+		send("group", "feeds.xml", "")
+		send("group", "feeds.opml", "")
+		if diff := cmp.Diff([]string{}, api.allTexts()); diff != "" {
+			t.Errorf("replies (-want +got):\n%s", diff)
+		}
+
+		send("group", "feeds.opml", "/import")
+		requireContains(t, api.lastText(), "Access denied.")
+		send("group", "feeds.txt", "/import")
+		send("private", "photo.jpg", "")
+		if diff := cmp.Diff(3, len(api.allTexts())); diff != "" {
+			t.Errorf("replies (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("rejects large file", func(t *testing.T) {
+		b, api, _ := newTestBot(t, "")
+		b.handleDocument(ctx, docMsg("feeds.opml", maxImportSize+1))
+		requireContains(t, api.lastText(), "too large")
+	})
+
+	t.Run("download error does not leak the token", func(t *testing.T) {
+		b, api, _ := newTestBot(t, "")
+		b.fetcher = fetcher.New(&mockHTTPClient{err: errors.New("connection reset")})
+		b.handleDocument(ctx, docMsg("feeds.opml", 100))
+		if diff := cmp.Diff([]string{"Failed to download file."}, api.allTexts()); diff != "" {
+			t.Errorf("replies (-want +got):\n%s", diff)
+		}
+		if strings.Contains(api.lastText(), testToken) {
+			t.Errorf("reply %q contains the bot token", api.lastText())
+		}
+	})
+
+	t.Run("too many feeds", func(t *testing.T) {
+		var body strings.Builder
+		body.WriteString(`<opml version="2.0"><body>`)
+		for i := range maxImportFeeds + 1 {
+			fmt.Fprintf(&body, `<outline type="rss" xmlUrl="https://feed%d.example.com/rss"/>`, i)
+		}
+		body.WriteString(`</body></opml>`)
+
+		b, api, store := newTestBot(t, body.String())
+		b.handleDocument(ctx, docMsg("feeds.opml", body.Len()))
+		requireContains(t, api.lastText(), "at most 200 can be imported")
+		feeds, err := store.ListFeeds(ctx, 100)
+		if err != nil {
+			t.Fatalf("list feeds: %v", err)
+		}
+		if len(feeds) != 0 {
+			t.Errorf("imported %d feeds, want none", len(feeds))
+		}
+	})
+
+	t.Run("invalid opml", func(t *testing.T) {
+		b, api, _ := newTestBot(t, "not xml")
+		b.handleDocument(ctx, docMsg("feeds.opml", 7))
+		requireContains(t, api.lastText(), "Failed to read OPML")
+	})
+
+	t.Run("imports feeds and skips duplicates", func(t *testing.T) {
+		b, api, store := newTestBot(t, opmlBody)
+		seedFeed(t, store, 100, "Existing", "https://existing.example.com/rss")
+		b.handleDocument(ctx, docMsg("feeds.opml", len(opmlBody)))
+
+		requireContains(t, api.lastText(), "Imported 2 feed(s)")
+		requireContains(t, api.lastText(), "Skipped 2 duplicate(s)")
+		requireContains(t, api.lastText(), "Skipped 1 feed(s) without an http or https URL")
+
+		feeds, err := store.ListFeeds(ctx, 100)
+		if err != nil {
+			t.Fatalf("list feeds: %v", err)
+		}
+		type row struct {
+			Name     string
+			URL      string
+			Interval int
+		}
+		var got []row
+		for _, f := range feeds {
+			got = append(got, row{f.Name, f.URL, f.IntervalMinutes})
+		}
+		want := []row{
+			{"Existing", "https://existing.example.com/rss", 15},
+			{"Go Blog", "https://go.dev/blog/feed.atom", 60},
+			{"https://untitled.example.com/rss", "https://untitled.example.com/rss", 15},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("feeds (-want +got):\n%s", diff)
+		}
 	})
 }
 
@@ -554,6 +1468,18 @@ func TestHandleAddFilter(t *testing.T) {
 		}
 	})
 
+	t.Run("success with link scope", func(t *testing.T) {
+		b, api, store := newTestBot(t, "")
+		seedFeed(t, store, 100, "Feed", "https://x.com")
+		b.handleAddFilter(ctx, 100, "1 -s link github.com", "include")
+		requireContains(t, api.lastText(), "F1: github.com (link)")
+
+		filters, _ := store.ListFilters(ctx, 1)
+		if diff := cmp.Diff(model.ScopeLink, filters[0].Scope); diff != "" {
+			t.Errorf("scope (-want +got):\n%s", diff)
+		}
+	})
+
 	t.Run("success regex", func(t *testing.T) {
 		b, api, store := newTestBot(t, "")
 		seedFeed(t, store, 100, "Feed", "https://x.com")
@@ -565,6 +1491,31 @@ func TestHandleAddFilter(t *testing.T) {
 			t.Errorf("kind (-want +got):\n%s", diff)
 		}
 	})
+
+	t.Run("invalid query", func(t *testing.T) {
+		b, api, store := newTestBot(t, "")
+		seedFeed(t, store, 100, "Feed", "https://x.com")
+		b.handleAddFilter(ctx, 100, "1 kubernetes AND", "query")
+		requireContains(t, api.lastText(), "invalid query: position 15: unexpected end of expression")
+
+		filters, _ := store.ListFilters(ctx, 1)
+		if diff := cmp.Diff(0, len(filters)); diff != "" {
+			t.Errorf("filter count (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("success query", func(t *testing.T) {
+		b, api, store := newTestBot(t, "")
+		seedFeed(t, store, 100, "Feed", "https://x.com")
+		b.handleAddFilter(ctx, 100, `1 kubernetes AND (security OR "CVE") AND NOT title:webinar`, "query")
+		requireContains(t, api.lastText(), "Filter F1 added")
+		requireContains(t, api.lastText(), "Query:")
+
+		filters, _ := store.ListFilters(ctx, 1)
+		if diff := cmp.Diff(model.FilterQuery, filters[0].Kind); diff != "" {
+			t.Errorf("kind (-want +got):\n%s", diff)
+		}
+	})
 }
 
 func TestHandleRmFilter(t *testing.T) {
@@ -666,6 +1617,19 @@ func TestHandleCommand(t *testing.T) {
 			requireContains(t, api.lastText(), "Filter F")
 		}
 	})
+
+	t.Run("answers in the language of the client", func(t *testing.T) {
+		b, api, store := newTestBot(t, "")
+		msg := makeMsg("info", "")
+		msg.From.LanguageCode = "ru"
+		b.handleCommand(ctx, msg)
+		requireContains(t, api.lastText(), "Использование: /info <номер>")
+
+		settings, _ := store.GetChatSettings(ctx, 100)
+		if settings.TelegramLanguage != "ru" {
+			t.Errorf("telegram language = %q, want ru", settings.TelegramLanguage)
+		}
+	})
 }
 
 func TestHandleCallback(t *testing.T) {
@@ -673,41 +1637,56 @@ func TestHandleCallback(t *testing.T) {
 
 	testUser := &tgbotapi.User{ID: 42, UserName: "testuser"}
 
-	t.Run("invalid data format", func(t *testing.T) {
+	t.Run("unknown token", func(t *testing.T) {
 		b, api, _ := newTestBot(t, "")
 		cb := &tgbotapi.CallbackQuery{
 			ID:      "cb1",
 			From:    testUser,
-			Data:    "nocolon",
+			Data:    "filters:1",
 			Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: 100}},
 		}
 		b.handleCallback(ctx, cb)
-		if diff := cmp.Diff(0, len(api.allTexts())); diff != "" {
-			t.Errorf("expected no text messages (-want +got):\n%s", diff)
+		if diff := cmp.Diff([]string{"This button has expired."}, api.allTexts()); diff != "" {
+			t.Errorf("texts (-want +got):\n%s", diff)
 		}
 	})
 
-	t.Run("invalid id", func(t *testing.T) {
-		b, api, _ := newTestBot(t, "")
+	t.Run("expired token", func(t *testing.T) {
+		b, api, store := newTestBot(t, "")
+		c := &model.Callback{ChatID: 100, Action: model.CallbackFilters, Arg: "1", ExpiresAt: time.Now().Add(-time.Minute)}
+		if err := store.CreateCallback(ctx, c); err != nil {
+			t.Fatalf("create callback: %v", err)
+		}
 		cb := &tgbotapi.CallbackQuery{
 			ID:      "cb2",
 			From:    testUser,
-			Data:    "filters:abc",
+			Data:    c.Token,
 			Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: 100}},
 		}
 		b.handleCallback(ctx, cb)
-		if diff := cmp.Diff(0, len(api.allTexts())); diff != "" {
-			t.Errorf("expected no text messages (-want +got):\n%s", diff)
+		requireContains(t, api.lastText(), "expired")
+	})
+
+	t.Run("token of another chat", func(t *testing.T) {
+		b, api, store := newTestBot(t, "")
+		seedFeed(t, store, 100, "Feed", "https://x.com")
+		cb := &tgbotapi.CallbackQuery{
+			ID:      "cb3",
+			From:    testUser,
+			Data:    register(t, store, model.Callback{ChatID: 100, Action: model.CallbackFilters, Arg: "1"}),
+			Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: 200}},
 		}
+		b.handleCallback(ctx, cb)
+		requireContains(t, api.lastText(), "expired")
 	})
 
 	t.Run("filters callback", func(t *testing.T) {
 		b, api, store := newTestBot(t, "")
 		seedFeed(t, store, 100, "Feed", "https://x.com")
 		cb := &tgbotapi.CallbackQuery{
-			ID:      "cb3",
+			ID:      "cb4",
 			From:    testUser,
-			Data:    fmt.Sprintf("filters:%d", 1),
+			Data:    register(t, store, model.Callback{ChatID: 100, Action: model.CallbackFilters, Arg: "1"}),
 			Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: 100}},
 		}
 		b.handleCallback(ctx, cb)
@@ -716,11 +1695,11 @@ func TestHandleCallback(t *testing.T) {
 
 	t.Run("delete callback", func(t *testing.T) {
 		b, api, store := newTestBot(t, "")
-		seedFeed(t, store, 100, "Feed", "https://x.com")
+		f := seedFeed(t, store, 100, "Feed", "https://x.com")
 		cb := &tgbotapi.CallbackQuery{
-			ID:      "cb4",
+			ID:      "cb5",
 			From:    testUser,
-			Data:    "delete:1",
+			Data:    register(t, store, model.Callback{ChatID: 100, Action: model.CallbackDelete, FeedID: f.ID}),
 			Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: 100}},
 		}
 		b.handleCallback(ctx, cb)
@@ -728,15 +1707,21 @@ func TestHandleCallback(t *testing.T) {
 	})
 
 	t.Run("delete_confirm callback", func(t *testing.T) {
-		b, _, store := newTestBot(t, "")
-		seedFeed(t, store, 100, "Feed", "https://x.com")
+		b, api, store := newTestBot(t, "")
+		f := seedFeed(t, store, 100, "Feed", "https://x.com")
 		cb := &tgbotapi.CallbackQuery{
-			ID:      "cb5",
+			ID:      "cb6",
 			From:    testUser,
-			Data:    "delete_confirm:1",
+			Data:    register(t, store, model.Callback{ChatID: 100, Action: model.CallbackDeleteConfirm, FeedID: f.ID}),
 			Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: 100}},
 		}
 		b.handleCallback(ctx, cb)
+		requireContains(t, api.lastText(), "Delete #1")
+
+		// The confirmation button carries its own token.
+		cb.Data = api.button(t, "Yes, delete")
+		b.handleCallback(ctx, cb)
+		requireContains(t, api.lastText(), "deleted")
 	})
 
 	t.Run("rmfilter callback", func(t *testing.T) {
@@ -744,12 +1729,332 @@ func TestHandleCallback(t *testing.T) {
 		f := seedFeed(t, store, 100, "Feed", "https://x.com")
 		seedFilter(t, store, f.ID, model.FilterInclude, "go")
 		cb := &tgbotapi.CallbackQuery{
-			ID:      "cb6",
+			ID:      "cb7",
 			From:    testUser,
-			Data:    "rmfilter:1",
+			Data:    register(t, store, model.Callback{ChatID: 100, Action: model.CallbackRmFilter, Arg: "1"}),
 			Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: 100}},
 		}
 		b.handleCallback(ctx, cb)
 		requireContains(t, api.lastText(), "Filter F1 removed")
 	})
 }
+
+func TestComposeNotification(t *testing.T) {
+	ctx := context.Background()
+	_, _, store := newTestBot(t, "")
+	f := seedFeed(t, store, 100, "Feed", "https://x.com")
+
+	t.Run("short item has no button", func(t *testing.T) {
+		msg, err := ComposeNotification(ctx, store, f, nil, fetcher.MatchedItem{Title: "Hi", Content: "Short", GUID: "a"})
+		if err != nil {
+			t.Fatalf("compose: %v", err)
+		}
+		if msg.Markup != nil {
+			t.Errorf("unexpected markup: %+v", msg.Markup)
+		}
+	})
+
+	t.Run("long item with a long guid", func(t *testing.T) {
+		guid := "https://example.com/" + strings.Repeat("segment/", 20)
+		item := fetcher.MatchedItem{Title: "Hi", Content: strings.Repeat("word ", 1000), GUID: guid}
+		msg, err := ComposeNotification(ctx, store, f, nil, item)
+		if err != nil {
+			t.Fatalf("compose: %v", err)
+		}
+		if msg.Markup == nil {
+			t.Fatal("expected a show more button")
+		}
+		token := *msg.Markup.InlineKeyboard[0][0].CallbackData
+		if len(token) > 64 {
+			t.Errorf("callback data is %d bytes, Telegram allows 64", len(token))
+		}
+		cb, err := store.GetCallback(ctx, token, time.Now())
+		if err != nil {
+			t.Fatalf("get callback: %v", err)
+		}
+		want := model.Callback{ChatID: 100, Action: model.CallbackShowMore, FeedID: f.ID, GUID: guid}
+		if diff := cmp.Diff(want, *cb, cmpopts.IgnoreFields(model.Callback{}, "Token", "ExpiresAt")); diff != "" {
+			t.Errorf("callback mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestDeliver(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("sends text", func(t *testing.T) {
+		b, api, _ := newTestBot(t, "")
+		m := model.OutboxMessage{ChatID: 100, Text: "hello", Markup: `{"inline_keyboard":[[{"text":"More","callback_data":"show_more:1:x"}]]}`}
+		if err := b.Deliver(ctx, m); err != nil {
+			t.Fatalf("deliver: %v", err)
+		}
+		if diff := cmp.Diff([]sentMsg{{ChatID: 100, Text: "hello"}}, api.sent); diff != "" {
+			t.Errorf("sent mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("sends silently", func(t *testing.T) {
+		for _, media := range []string{"", `[{"kind":"photo","url":"https://example.com/a.jpg"}]`} {
+			b, api, _ := newTestBot(t, "")
+			if err := b.Deliver(ctx, model.OutboxMessage{ChatID: 100, Text: "hello", Media: media, Silent: true}); err != nil {
+				t.Fatalf("deliver: %v", err)
+			}
+			if diff := cmp.Diff(1, api.silent); diff != "" {
+				t.Errorf("silent sends with media %q (-want +got):\n%s", media, diff)
+			}
+		}
+	})
+
+	t.Run("falls back to plain text", func(t *testing.T) {
+		b, api, _ := newTestBot(t, "")
+		api.rejectHTML = true
+		if err := b.Deliver(ctx, model.OutboxMessage{ChatID: 100, Text: "<b>Tom &amp; Jerry</b>"}); err != nil {
+			t.Fatalf("deliver: %v", err)
+		}
+		if diff := cmp.Diff([]sentMsg{{ChatID: 100, Text: "Tom & Jerry"}}, api.sent); diff != "" {
+			t.Errorf("sent mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("sends attachments", func(t *testing.T) {
+		tests := []struct {
+			name      string
+			media     string
+			wantFiles []sentFile
+			wantAlbum [][]string
+			wantSent  []sentMsg
+		}{
+			{
+				name:      "photo with caption",
+				media:     `[{"kind":"photo","url":"https://example.com/a.jpg"}]`,
+				wantFiles: []sentFile{{ChatID: 100, Kind: "photo", URL: "https://example.com/a.jpg", Caption: "hello"}},
+			},
+			{
+				name:      "audio with duration",
+				media:     `[{"kind":"audio","url":"https://example.com/e.mp3","type":"audio/mpeg","duration":3723}]`,
+				wantFiles: []sentFile{{ChatID: 100, Kind: "audio", URL: "https://example.com/e.mp3", Caption: "hello", Duration: 3723}},
+			},
+			{
+				name:      "video",
+				media:     `[{"kind":"video","url":"https://example.com/v.mp4","type":"video/mp4"}]`,
+				wantFiles: []sentFile{{ChatID: 100, Kind: "video", URL: "https://example.com/v.mp4", Caption: "hello"}},
+			},
+			{
+				name:      "pdf document",
+				media:     `[{"kind":"document","url":"https://example.com/r.pdf","type":"application/pdf"}]`,
+				wantFiles: []sentFile{{ChatID: 100, Kind: "document", URL: "https://example.com/r.pdf", Caption: "hello"}},
+			},
+			{
+				name:      "album followed by text",
+				media:     `[{"kind":"photo","url":"https://example.com/a.jpg"},{"kind":"photo","url":"https://example.com/b.jpg"}]`,
+				wantAlbum: [][]string{{"https://example.com/a.jpg", "https://example.com/b.jpg"}},
+				wantSent:  []sentMsg{{ChatID: 100, Text: "hello"}},
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				b, api, _ := newTestBot(t, "")
+				if err := b.Deliver(ctx, model.OutboxMessage{ChatID: 100, Text: "hello", Media: tt.media}); err != nil {
+					t.Fatalf("deliver: %v", err)
+				}
+				if diff := cmp.Diff(tt.wantFiles, api.files); diff != "" {
+					t.Errorf("files mismatch (-want +got):\n%s", diff)
+				}
+				if diff := cmp.Diff(tt.wantAlbum, api.albums); diff != "" {
+					t.Errorf("albums mismatch (-want +got):\n%s", diff)
+				}
+				if diff := cmp.Diff(tt.wantSent, api.sent); diff != "" {
+					t.Errorf("sent mismatch (-want +got):\n%s", diff)
+				}
+			})
+		}
+	})
+
+	t.Run("falls back to text when files cannot be fetched", func(t *testing.T) {
+		for _, media := range []string{
+			`[{"kind":"photo","url":"https://example.com/a.jpg"}]`,
+			`[{"kind":"photo","url":"https://example.com/a.jpg"},{"kind":"photo","url":"https://example.com/b.jpg"}]`,
+		} {
+			b, api, _ := newTestBot(t, "")
+			api.rejectMedia = true
+			if err := b.Deliver(ctx, model.OutboxMessage{ChatID: 100, Text: "hello", Media: media}); err != nil {
+				t.Fatalf("deliver: %v", err)
+			}
+			if diff := cmp.Diff([]sentMsg{{ChatID: 100, Text: "hello"}}, api.sent); diff != "" {
+				t.Errorf("sent mismatch (-want +got):\n%s", diff)
+			}
+		}
+	})
+
+	t.Run("retry after the album only sends the text", func(t *testing.T) {
+		b, api, store := newTestBot(t, "")
+		f := seedFeed(t, store, 100, "Feed", "https://x.com")
+		m := &model.OutboxMessage{ChatID: 100, FeedID: f.ID, GUID: "a", Text: "hello",
+			Media: `[{"kind":"photo","url":"https://example.com/a.jpg"},{"kind":"photo","url":"https://example.com/b.jpg"}]`}
+		if err := store.EnqueueMessage(ctx, m); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+
+		api.sendErr = errors.New("connection reset")
+		flushOutbox(t, b, store)
+		api.sendErr = nil
+		msgs, _ := store.ListDueMessages(ctx, time.Now().Add(time.Hour), 10)
+		if len(msgs) != 1 {
+			t.Fatalf("due messages = %d, want 1", len(msgs))
+		}
+		if err := b.Deliver(ctx, msgs[0]); err != nil {
+			t.Fatalf("deliver: %v", err)
+		}
+
+		if diff := cmp.Diff(1, len(api.albums)); diff != "" {
+			t.Errorf("album count (-want +got):\n%s", diff)
+		}
+		if diff := cmp.Diff([]sentMsg{{ChatID: 100, Text: "hello"}}, api.sent); diff != "" {
+			t.Errorf("sent mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("bad markup is permanent", func(t *testing.T) {
+		b, _, _ := newTestBot(t, "")
+		err := b.Deliver(ctx, model.OutboxMessage{ChatID: 100, Text: "hello", Markup: "{"})
+		var de *outbox.DeliveryError
+		if !errors.As(err, &de) || !de.Permanent {
+			t.Errorf("err = %v, want permanent delivery error", err)
+		}
+	})
+
+	t.Run("classifies telegram errors", func(t *testing.T) {
+		tests := []struct {
+			name string
+			err  error
+			want outbox.DeliveryError
+		}{
+			{
+				name: "flood control",
+				err:  &tgbotapi.Error{Code: 429, ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 7}},
+				want: outbox.DeliveryError{RetryAfter: 7 * time.Second},
+			},
+			{
+				name: "bot blocked",
+				err:  &tgbotapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"},
+				want: outbox.DeliveryError{Permanent: true},
+			},
+			{
+				name: "server error",
+				err:  &tgbotapi.Error{Code: 502, Message: "Bad Gateway"},
+				want: outbox.DeliveryError{},
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				b, api, _ := newTestBot(t, "")
+				api.sendErr = tt.err
+				var de *outbox.DeliveryError
+				if err := b.Deliver(ctx, model.OutboxMessage{ChatID: 100, Text: "hello"}); !errors.As(err, &de) {
+					t.Fatalf("err = %v, want delivery error", err)
+				}
+				if diff := cmp.Diff(tt.want, *de, cmpopts.IgnoreFields(outbox.DeliveryError{}, "Err")); diff != "" {
+					t.Errorf("delivery error mismatch (-want +got):\n%s", diff)
+				}
+			})
+		}
+	})
+
+	t.Run("network error is retried", func(t *testing.T) {
+		b, api, _ := newTestBot(t, "")
+		api.sendErr = errors.New("connection reset")
+		err := b.Deliver(ctx, model.OutboxMessage{ChatID: 100, Text: "hello"})
+		var de *outbox.DeliveryError
+		if err == nil || errors.As(err, &de) {
+			t.Errorf("err = %v, want plain error", err)
+		}
+	})
+
+	t.Run("records metrics", func(t *testing.T) {
+		b, api, _ := newTestBot(t, "")
+		m := metrics.New()
+		b.SetMetrics(m)
+		for _, err := range []error{
+			nil,
+			&tgbotapi.Error{Code: 429, ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 7}},
+			&tgbotapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"},
+			&tgbotapi.Error{Code: 502, Message: "Bad Gateway"},
+			errors.New("connection reset"),
+		} {
+			api.sendErr = err
+			_ = b.Deliver(ctx, model.OutboxMessage{ChatID: 100, Text: "hello"})
+		}
+
+		rec := httptest.NewRecorder()
+		m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		for _, line := range []string{
+			`rss_bot_items_sent_total 1`,
+			`rss_bot_telegram_send_errors_total{type="rate_limited"} 1`,
+			`rss_bot_telegram_send_errors_total{type="forbidden"} 1`,
+			`rss_bot_telegram_send_errors_total{type="server"} 1`,
+			`rss_bot_telegram_send_errors_total{type="network"} 1`,
+		} {
+			if !strings.Contains(rec.Body.String(), line+"\n") {
+				t.Errorf("metrics lack %q:\n%s", line, rec.Body.String())
+			}
+		}
+	})
+}
+
+func TestRunPolling(t *testing.T) {
+	var start tgbotapi.Update
+	if err := json.Unmarshal([]byte(startUpdate), &start); err != nil {
+		t.Fatalf("decode update: %v", err)
+	}
+
+	t.Run("handles updates and beats", func(t *testing.T) {
+		b, api, _ := newTestBot(t, "")
+		api.updates = []tgbotapi.Update{start}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- b.Run(ctx) }()
+
+		waitFor(t, "reply to /start", func() bool { return strings.Contains(api.lastText(), "Welcome") })
+		waitFor(t, "next poll", func() bool {
+			api.mu.Lock()
+			defer api.mu.Unlock()
+			return len(api.polls) >= 2
+		})
+		cancel()
+		if err := <-done; err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		if diff := cmp.Diff(2, api.polls[1].Offset); diff != "" {
+			t.Errorf("offset (-want +got):\n%s", diff)
+		}
+		if b.LastHeartbeat().IsZero() {
+			t.Error("no heartbeat")
+		}
+	})
+
+	t.Run("failed polls don't beat", func(t *testing.T) {
+		b, api, _ := newTestBot(t, "")
+		api.pollErr = errors.New("connection refused")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- b.Run(ctx) }()
+
+		waitFor(t, "first poll", func() bool {
+			api.mu.Lock()
+			defer api.mu.Unlock()
+			return len(api.polls) >= 1
+		})
+		started := b.LastHeartbeat()
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+		<-done
+		if diff := cmp.Diff(started, b.LastHeartbeat()); diff != "" {
+			t.Errorf("heartbeat (-want +got):\n%s", diff)
+		}
+	})
+}
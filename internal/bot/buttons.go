@@ -0,0 +1,183 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"rss_bot/internal/fetcher"
+	"rss_bot/internal/i18n"
+	"rss_bot/internal/model"
+	"rss_bot/internal/storage"
+	"rss_bot/internal/text"
+)
+
+// How long inline buttons keep working.
+const (
+	showMoreTTL = 30 * 24 * time.Hour
+	promptTTL   = 7 * 24 * time.Hour
+	choiceTTL   = time.Hour
+)
+
+// newButton registers cb in the callback registry and returns a button that
+// carries its token.
+func newButton(ctx context.Context, store storage.Storage, label string, cb model.Callback, ttl time.Duration) (tgbotapi.InlineKeyboardButton, error) {
+	cb.ExpiresAt = time.Now().Add(ttl)
+	if err := store.CreateCallback(ctx, &cb); err != nil {
+		return tgbotapi.InlineKeyboardButton{}, err
+	}
+	return tgbotapi.NewInlineKeyboardButtonData(label, cb.Token), nil
+}
+
+// ComposeNotification formats a notification for an item of feed, following
+// the chat's settings. When the text had to be shortened, a "Show more"
+// button is registered for it.
+func ComposeNotification(ctx context.Context, store storage.Storage, feed *model.Feed, settings *model.ChatSettings, item fetcher.MatchedItem) (NotificationWithKeyboard, error) {
+	msg := FormatNotificationShort(feed.Position, feed.Name, item, NotificationOptions(settings))
+	if !msg.Truncated {
+		return msg, nil
+	}
+
+	button, err := newButton(ctx, store, ChatLanguage(settings).T("Show more"), model.Callback{
+		ChatID: feed.ChatID,
+		Action: model.CallbackShowMore,
+		FeedID: feed.ID,
+		GUID:   item.GUID,
+	}, showMoreTTL)
+	if err != nil {
+		return msg, fmt.Errorf("register show more: %w", err)
+	}
+	markup := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(button))
+	msg.Markup = &markup
+	return msg, nil
+}
+
+// ComposeOutboxMessage composes the notification for an item of feed as a
+// message for the outbox, which sends it, marks the item seen and archives it.
+func ComposeOutboxMessage(ctx context.Context, store storage.Storage, feed *model.Feed, settings *model.ChatSettings, item fetcher.MatchedItem) (*model.OutboxMessage, error) {
+	msg, err := ComposeNotification(ctx, store, feed, settings, item)
+	if err != nil {
+		return nil, err
+	}
+	m := &model.OutboxMessage{
+		ChatID:      feed.ChatID,
+		FeedID:      feed.ID,
+		GUID:        item.GUID,
+		Text:        msg.Text,
+		FullContent: item.Description,
+		Archive:     []model.ArchivedItem{*ArchiveEntry(feed, item)},
+	}
+	if msg.Markup != nil {
+		data, err := json.Marshal(msg.Markup)
+		if err != nil {
+			return nil, fmt.Errorf("encode markup: %w", err)
+		}
+		m.Markup = string(data)
+	}
+	if len(msg.Media) > 0 {
+		data, err := json.Marshal(msg.Media)
+		if err != nil {
+			return nil, fmt.Errorf("encode media: %w", err)
+		}
+		m.Media = string(data)
+	}
+	return m, nil
+}
+
+// ArchiveEntry returns the archive entry of an item, with its content as
+// plain text for the full-text index.
+func ArchiveEntry(feed *model.Feed, item fetcher.MatchedItem) *model.ArchivedItem {
+	content := item.Content
+	if content == "" {
+		content = item.Description
+	}
+	return &model.ArchivedItem{
+		FeedID:    feed.ID,
+		GUID:      item.GUID,
+		FeedName:  feed.Name,
+		Title:     item.Title,
+		Link:      item.Link,
+		Author:    item.Author,
+		Published: item.Published,
+		Content:   text.ParseHTMLToPlain(content).Text,
+	}
+}
+
+// BacklogPromptExpired reports whether the buttons of a feed's backlog prompt
+// have expired without an answer, so that the feed must not wait for it.
+func BacklogPromptExpired(feed *model.Feed, now time.Time) bool {
+	return feed.BacklogAskedAt == nil || now.Sub(*feed.BacklogAskedAt) > promptTTL
+}
+
+// BacklogPrompt formats the question asked when a feed with the "ask" backlog
+// policy has more unseen items than its limit.
+func BacklogPrompt(ctx context.Context, store storage.Storage, lang i18n.Lang, feed *model.Feed, count int) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	choices := []struct {
+		label  string
+		choice string
+	}{
+		{lang.Sprintf("Send all (%d)", count), backlogSendAll},
+		{lang.Sprintf("Send %d", feed.BacklogLimit), strconv.Itoa(feed.BacklogLimit)},
+		{lang.T("Mark read"), backlogMarkRead},
+	}
+
+	var row []tgbotapi.InlineKeyboardButton
+	for _, c := range choices {
+		button, err := newButton(ctx, store, c.label, model.Callback{
+			ChatID: feed.ChatID,
+			Action: model.CallbackBacklog,
+			FeedID: feed.ID,
+			Arg:    c.choice,
+		}, promptTTL)
+		if err != nil {
+			return "", nil, fmt.Errorf("register backlog choice: %w", err)
+		}
+		row = append(row, button)
+	}
+
+	markup := tgbotapi.NewInlineKeyboardMarkup(row)
+	return FormatBacklogPrompt(lang, feed, count), &markup, nil
+}
+
+// discoveredKeyboard builds a keyboard with one button per discovered feed.
+// The argument of a button is the feed URL followed by its title.
+func (b *Bot) discoveredKeyboard(ctx context.Context, chatID int64, feeds []fetcher.DiscoveredFeed) (*tgbotapi.InlineKeyboardMarkup, error) {
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(feeds))
+	for _, f := range feeds {
+		button, err := newButton(ctx, b.store, FormatDiscoveredLabel(f), model.Callback{
+			ChatID: chatID,
+			Action: model.CallbackAddFeed,
+			Arg:    f.URL + " " + f.Title,
+		}, choiceTTL)
+		if err != nil {
+			return nil, fmt.Errorf("register discovered feed: %w", err)
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(button))
+	}
+	return &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}, nil
+}
+
+// deleteKeyboard asks to confirm the deletion of a feed.
+func (b *Bot) deleteKeyboard(ctx context.Context, lang i18n.Lang, feed *model.Feed) (*tgbotapi.InlineKeyboardMarkup, error) {
+	yes, err := newButton(ctx, b.store, lang.T("Yes, delete"), model.Callback{
+		ChatID: feed.ChatID,
+		Action: model.CallbackDelete,
+		FeedID: feed.ID,
+	}, choiceTTL)
+	if err != nil {
+		return nil, fmt.Errorf("register delete: %w", err)
+	}
+	cancel, err := newButton(ctx, b.store, lang.T("Cancel"), model.Callback{
+		ChatID: feed.ChatID,
+		Action: model.CallbackNoop,
+	}, choiceTTL)
+	if err != nil {
+		return nil, fmt.Errorf("register cancel: %w", err)
+	}
+	markup := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(yes, cancel))
+	return &markup, nil
+}
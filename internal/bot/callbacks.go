@@ -2,20 +2,20 @@ package bot
 
 import (
 	"context"
-	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 
-	"rss_bot/internal/text"
+	"rss_bot/internal/fetcher"
+	"rss_bot/internal/model"
 )
 
 const (
 	cmdCheck    = "check"
 	cmdFilters  = "filters"
 	cmdRmFilter = "rmfilter"
-	cmdShowMore = "show_more"
 
 	cmdAdd      = "add"
 	cmdInfo     = "info"
@@ -29,120 +29,103 @@ const (
 )
 
 func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
-	data := cb.Data
 	chatID := cb.Message.Chat.ID
 
+	b.rememberLanguage(ctx, chatID, cb.From)
+	lang := b.lang(ctx, chatID)
+
 	callback := tgbotapi.NewCallback(cb.ID, "")
-	if _, err := b.api.Send(callback); err != nil {
+	if _, err := b.send(ctx, 0, callback); err != nil {
 		b.log.Error("send callback ack", "error", err)
 	}
 
-	parts := strings.SplitN(data, ":", 2)
-	if len(parts) != 2 {
+	data, err := b.store.GetCallback(ctx, cb.Data, time.Now())
+	if err != nil || data.ChatID != chatID {
+		b.log.Info("unknown callback", "token", cb.Data, "chat_id", chatID, "error", err)
+		b.reply(chatID, lang.T("This button has expired."))
 		return
 	}
 
-	action := parts[0]
-	idStr := parts[1]
-
 	b.log.Info("callback",
-		"action", action,
-		"id", idStr,
+		"action", data.Action,
+		"feed_id", data.FeedID,
 		"chat_id", chatID,
 		"user_id", cb.From.ID,
 		"username", cb.From.UserName,
 	)
 
-	if action != cmdShowMore {
-		if _, err := strconv.ParseInt(idStr, 10, 64); err != nil {
+	switch data.Action {
+	case model.CallbackShowMore:
+		b.handleShowMore(ctx, chatID, data.FeedID, data.GUID)
+	case model.CallbackFilters:
+		b.handleFilters(ctx, chatID, data.Arg)
+	case model.CallbackCheck:
+		b.handleCheck(ctx, chatID, data.Arg)
+	case model.CallbackDeleteConfirm:
+		feed, err := b.store.GetFeed(ctx, data.FeedID)
+		if err != nil || feed.ChatID != chatID {
+			b.reply(chatID, lang.T("Feed not found."))
 			return
 		}
-	}
-
-	switch action {
-	case cmdShowMore:
-		b.handleShowMore(ctx, chatID, idStr)
-	case cmdFilters:
-		b.handleFilters(ctx, chatID, idStr)
-	case cmdCheck:
-		b.handleCheck(ctx, chatID, idStr)
-	case "delete_confirm":
-		id, err := strconv.ParseInt(idStr, 10, 64)
+		markup, err := b.deleteKeyboard(ctx, lang, feed)
 		if err != nil {
+			b.reply(chatID, lang.Sprintf("Error: %v", err))
 			return
 		}
-		feed, err := b.store.GetFeed(ctx, id)
+		b.SendMessageWithKeyboard(chatID, lang.Sprintf("Delete #%d \"%s\"? This cannot be undone.", feed.Position, feed.Name), markup)
+	case model.CallbackDelete:
+		feed, err := b.store.GetFeed(ctx, data.FeedID)
 		if err != nil || feed.ChatID != chatID {
-			b.reply(chatID, fmt.Sprintf("Feed #%d not found.", id))
+			b.reply(chatID, lang.T("Feed not found."))
 			return
 		}
-		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Delete #%d \"%s\"? This cannot be undone.", id, feed.Name))
-		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
-			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("Yes, delete", fmt.Sprintf("delete:%d", id)),
-				tgbotapi.NewInlineKeyboardButtonData("Cancel", "noop:0"),
-			),
-		)
-		if _, err := b.api.Send(msg); err != nil {
-			b.log.Error("send delete confirmation", "error", err)
+		b.handleRemove(ctx, chatID, strconv.Itoa(feed.Position))
+	case model.CallbackRmFilter:
+		b.handleRmFilter(ctx, chatID, data.Arg)
+	case model.CallbackBacklog:
+		b.handleBacklogChoice(ctx, chatID, data.FeedID, data.Arg)
+	case model.CallbackSettings:
+		b.handleSettingsButton(ctx, chatID, cb.Message.MessageID, data.Arg)
+	case model.CallbackSearch:
+		b.handleSearchButton(ctx, chatID, cb.Message.MessageID, data.Arg)
+	case model.CallbackAddFeed:
+		u, title, _ := strings.Cut(data.Arg, " ")
+		if u == "" {
+			b.reply(chatID, lang.T("This choice has expired. Send /add again."))
+			return
 		}
-	case "delete":
-		b.handleRemove(ctx, chatID, idStr)
-	case cmdRmFilter:
-		b.handleRmFilter(ctx, chatID, idStr)
+		b.addDiscoveredFeed(ctx, chatID, fetcher.DiscoveredFeed{URL: u, Title: title})
 	}
 }
 
-func (b *Bot) handleShowMore(ctx context.Context, chatID int64, data string) {
-	parts := strings.SplitN(data, ":", 2)
-	if len(parts) != 2 {
-		b.reply(chatID, "Invalid request.")
-		return
-	}
-
-	feedIDStr, guid := parts[0], parts[1]
-	feedID, err := strconv.ParseInt(feedIDStr, 10, 64)
-	if err != nil {
-		b.reply(chatID, "Invalid feed ID.")
-		return
-	}
-
+func (b *Bot) handleShowMore(ctx context.Context, chatID, feedID int64, guid string) {
+	lang := b.lang(ctx, chatID)
 	feed, err := b.store.GetFeed(ctx, feedID)
 	if err != nil || feed.ChatID != chatID {
-		b.reply(chatID, "Feed not found.")
+		b.reply(chatID, lang.T("Feed not found."))
 		return
 	}
 
 	content, err := b.store.GetFullContent(ctx, feedID, guid)
 	if err != nil {
-		b.reply(chatID, "Could not retrieve content.")
+		b.reply(chatID, lang.T("Could not retrieve content."))
 		return
 	}
 
 	if content == "" {
-		b.reply(chatID, "Full content not available.")
+		b.reply(chatID, lang.T("Full content not available."))
 		return
 	}
 
-	processed := content
-	if text.IsHTML(content) {
-		processed = text.ParseHTMLToPlain(content).Text
-	}
-
-	fullMsg := FormatNotificationFull(feed.Name, struct {
-		Title       string
-		Description string
-		Content     string
-		Link        string
-		GUID        string
-		ImageURL    string
-	}{
+	messages := FormatNotificationFull(feed.Name, fetcher.MatchedItem{
 		Title:       feed.Name,
-		Description: processed,
-		Content:     "",
-		Link:        "",
+		Description: content,
 		GUID:        guid,
-		ImageURL:    "",
 	})
-	b.reply(chatID, fullMsg)
+	for _, msg := range messages {
+		if err := b.sendHTML(ctx, chatID, msg, nil, sendOptions{}); err != nil {
+			b.log.Error("send full content", "chat_id", chatID, "error", err)
+			return
+		}
+	}
 }
@@ -0,0 +1,125 @@
+package bot
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"rss_bot/internal/digest"
+	"rss_bot/internal/i18n"
+	"rss_bot/internal/model"
+)
+
+func (b *Bot) handleDigest(ctx context.Context, chatID int64, args string) {
+	lang := b.lang(ctx, chatID)
+	usage := lang.T("Usage: /digest [on|off] | /digest at HH:MM [HH:MM ...] | /digest <number> <on|off|default>")
+
+	settings, err := b.store.GetChatSettings(ctx, chatID)
+	if err != nil {
+		b.reply(chatID, lang.Sprintf("Error: %v", err))
+		return
+	}
+
+	parts := strings.Fields(args)
+	switch {
+	case len(parts) == 0:
+		feeds, err := b.store.ListFeeds(ctx, chatID)
+		if err != nil {
+			b.reply(chatID, lang.Sprintf("Error: %v", err))
+			return
+		}
+		b.reply(chatID, FormatDigestSettings(lang, settings, feeds))
+		return
+	case len(parts) == 1 && (parts[0] == "on" || parts[0] == "off"):
+		settings.Digest = parts[0] == "on"
+	case parts[0] == "at":
+		times, err := digest.ParseTimes(strings.Join(parts[1:], " "))
+		if err != nil {
+			b.reply(chatID, lang.Sprintf("Invalid digest times: %s.\n%s", lang.Error(err), usage))
+			return
+		}
+		settings.DigestTimes = times
+	case len(parts) == 2:
+		b.setFeedDelivery(ctx, chatID, parts[0], parts[1], usage)
+		return
+	default:
+		b.reply(chatID, usage)
+		return
+	}
+
+	if err := b.store.SaveChatSettings(ctx, settings); err != nil {
+		b.reply(chatID, lang.Sprintf("Error: %v", err))
+		return
+	}
+	b.reply(chatID, lang.Sprintf("Digest %s by default, at %s (%s).",
+		onOff(lang, settings.Digest), strings.Join(settings.DigestTimes, ", "), settings.Timezone))
+}
+
+// setFeedDelivery switches a single feed between digest and instant delivery.
+func (b *Bot) setFeedDelivery(ctx context.Context, chatID int64, arg, value, usage string) {
+	lang := b.lang(ctx, chatID)
+	pos, err := strconv.Atoi(arg)
+	if err != nil {
+		b.reply(chatID, usage)
+		return
+	}
+
+	var mode model.DeliveryMode
+	switch value {
+	case "on":
+		mode = model.DeliveryDigest
+	case "off":
+		mode = model.DeliveryInstant
+	case "default":
+		mode = model.DeliveryDefault
+	default:
+		b.reply(chatID, usage)
+		return
+	}
+
+	feed, err := b.store.GetFeedByPosition(ctx, chatID, pos)
+	if err != nil {
+		b.reply(chatID, lang.Sprintf("Feed #%d not found.", pos))
+		return
+	}
+	feed.Delivery = mode
+	if err := b.store.UpdateFeed(ctx, feed); err != nil {
+		b.reply(chatID, lang.Sprintf("Error: %v", err))
+		return
+	}
+	b.reply(chatID, lang.Sprintf("Delivery of #%d \"%s\": %s.", pos, feed.Name, FormatDelivery(lang, mode)))
+}
+
+func (b *Bot) handleTimezone(ctx context.Context, chatID int64, args string) {
+	lang := b.lang(ctx, chatID)
+	settings, err := b.store.GetChatSettings(ctx, chatID)
+	if err != nil {
+		b.reply(chatID, lang.Sprintf("Error: %v", err))
+		return
+	}
+
+	name := strings.TrimSpace(args)
+	if name == "" {
+		b.reply(chatID, lang.Sprintf("Time zone: %s\nUsage: /timezone <Area/City>, e.g. /timezone Europe/Berlin", settings.Timezone))
+		return
+	}
+	if _, err := time.LoadLocation(name); err != nil || name == "Local" {
+		b.reply(chatID, lang.Sprintf("Unknown time zone %q. Use a name like Europe/Berlin or UTC.", name))
+		return
+	}
+
+	settings.Timezone = name
+	if err := b.store.SaveChatSettings(ctx, settings); err != nil {
+		b.reply(chatID, lang.Sprintf("Error: %v", err))
+		return
+	}
+	b.reply(chatID, lang.Sprintf("Time zone set to %s.", name))
+}
+
+func onOff(lang i18n.Lang, on bool) string {
+	if on {
+		return lang.T("on")
+	}
+	return lang.T("off")
+}
@@ -2,45 +2,75 @@ package bot
 
 import (
 	"fmt"
+	"html"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 
 	"rss_bot/internal/fetcher"
+	"rss_bot/internal/filter"
+	"rss_bot/internal/i18n"
 	"rss_bot/internal/model"
+	"rss_bot/internal/retention"
 	"rss_bot/internal/text"
 )
 
 const (
-	statusActive     = "active"
-	statusPaused     = "paused"
-	callbackShowMore = "show_more"
+	statusActive = "active"
+	statusPaused = "paused"
+
+	backlogSendAll  = "all"
+	backlogMarkRead = "read"
+
+	maxButtonLabel = 60
+	maxTestItems   = 30
+	maxTestTitle   = 80
 )
 
 // NotificationWithKeyboard holds a formatted notification and its optional keyboard.
 type NotificationWithKeyboard struct {
 	Text     string
 	ImageURL string
+	Media    []model.Media
 	Markup   *tgbotapi.InlineKeyboardMarkup
+	// Truncated is set when the text was shortened and needs a "Show more" button.
+	Truncated bool
 }
 
-// FormatNotificationShort formats a shortened notification with a "Show more" button.
-func FormatNotificationShort(feedPosition int, feedName string, item fetcher.MatchedItem) NotificationWithKeyboard {
-	formatted := text.FormatNotificationShort(int64(feedPosition), feedName, item)
+// FormatNotificationShort formats a shortened notification. The "Show more"
+// button is added by ComposeNotification.
+func FormatNotificationShort(feedPosition int, feedName string, item fetcher.MatchedItem, opts text.Options) NotificationWithKeyboard {
+	formatted := text.FormatNotificationShort(int64(feedPosition), feedName, item, opts)
+	return NotificationWithKeyboard{
+		Text:      formatted.Text,
+		ImageURL:  formatted.ImageURL,
+		Media:     formatted.Media,
+		Truncated: formatted.Truncated,
+	}
+}
 
-	var markup *tgbotapi.InlineKeyboardMarkup
-	if formatted.Truncated {
-		row := tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("Show more", fmt.Sprintf("%s:%d:%s", callbackShowMore, feedPosition, item.GUID)),
-		)
-		markup = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{row}}
+// NotificationOptions returns the formatting options of a chat's
+// notifications; nil settings give the defaults.
+func NotificationOptions(cs *model.ChatSettings) text.Options {
+	if cs == nil {
+		return text.Options{}
 	}
+	return text.Options{PreviewLength: cs.PreviewLength, NoImages: !cs.Images}
+}
 
-	return NotificationWithKeyboard{
-		Text:     formatted.Text,
-		ImageURL: formatted.ImageURL,
-		Markup:   markup,
+// FormatDiscoveredLabel returns the button label of a discovered feed.
+func FormatDiscoveredLabel(f fetcher.DiscoveredFeed) string {
+	label := f.Title
+	if label == "" {
+		label = f.URL
+	}
+	if r := []rune(label); len(r) > maxButtonLabel {
+		label = string(r[:maxButtonLabel-1]) + "…"
 	}
+	return label
 }
 
 // FormatNotification formats an RSS item as a Telegram notification message.
@@ -48,18 +78,172 @@ func FormatNotification(feedName string, item fetcher.MatchedItem) string {
 	return text.FormatNotification(feedName, item)
 }
 
-// FormatNotificationFull formats a full notification without truncation.
-func FormatNotificationFull(feedName string, item fetcher.MatchedItem) string {
+// FormatNotificationFull formats a full notification without truncation,
+// split into as many messages as needed.
+func FormatNotificationFull(feedName string, item fetcher.MatchedItem) []string {
 	return text.FormatNotificationFull(feedName, item)
 }
 
+// FormatBacklogPrompt formats the text of the question asked when a feed with
+// the "ask" backlog policy has more unseen items than its limit.
+func FormatBacklogPrompt(lang i18n.Lang, feed *model.Feed, count int) string {
+	return lang.Sprintf("#%d \"%s\" has %d new items. What should be sent?", feed.Position, feed.Name, count)
+}
+
+// FormatBacklogPolicy describes a feed's backlog policy.
+func FormatBacklogPolicy(lang i18n.Lang, feed *model.Feed) string {
+	switch feed.BacklogPolicy {
+	case model.BacklogAll:
+		return lang.T("send all")
+	case model.BacklogSkip:
+		return lang.T("mark read")
+	case model.BacklogAsk:
+		return lang.Sprintf("ask when more than %d", feed.BacklogLimit)
+	default:
+		return lang.Sprintf("newest %d", feed.BacklogLimit)
+	}
+}
+
+// FormatDelivery describes how a feed's items are delivered.
+func FormatDelivery(lang i18n.Lang, mode model.DeliveryMode) string {
+	switch mode {
+	case model.DeliveryDigest:
+		return lang.T("digest")
+	case model.DeliveryInstant:
+		return lang.T("instant")
+	default:
+		return lang.T("chat default")
+	}
+}
+
+// FormatDigestSettings describes a chat's digest schedule and the feeds that
+// override it.
+func FormatDigestSettings(lang i18n.Lang, cs *model.ChatSettings, feeds []model.Feed) string {
+	var b strings.Builder
+	b.WriteString(lang.Sprintf("Digest: %s by default\n", onOff(lang, cs.Digest)))
+	b.WriteString(lang.Sprintf("Times: %s (%s)\n", strings.Join(cs.DigestTimes, ", "), cs.Timezone))
+	for _, f := range feeds {
+		if f.Delivery != model.DeliveryDefault {
+			fmt.Fprintf(&b, "#%d \"%s\": %s\n", f.Position, f.Name, FormatDelivery(lang, f.Delivery))
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// FormatQuietMode describes what happens to a feed's notifications during
+// quiet hours.
+func FormatQuietMode(lang i18n.Lang, mode model.QuietMode) string {
+	switch mode {
+	case model.QuietHold:
+		return lang.T("held until the end")
+	case model.QuietSilent:
+		return lang.T("sent silently")
+	default:
+		return lang.T("chat default")
+	}
+}
+
+// FormatQuietSettings describes a chat's quiet hours and the feeds that
+// override its quiet mode.
+func FormatQuietSettings(lang i18n.Lang, cs *model.ChatSettings, feeds []model.Feed) string {
+	if cs.QuietStart == "" {
+		return lang.T("Quiet hours: off")
+	}
+	var b strings.Builder
+	b.WriteString(lang.Sprintf("Quiet hours: %s-%s (%s)\n", cs.QuietStart, cs.QuietEnd, cs.Timezone))
+	b.WriteString(lang.Sprintf("Notifications: %s\n", FormatQuietMode(lang, quietMode(cs.QuietMode))))
+	for _, f := range feeds {
+		if f.Quiet != model.QuietDefault {
+			fmt.Fprintf(&b, "#%d \"%s\": %s\n", f.Position, f.Name, FormatQuietMode(lang, f.Quiet))
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// quietMode returns the chat's quiet mode, holding notifications unless the
+// chat chose otherwise.
+func quietMode(mode model.QuietMode) model.QuietMode {
+	if mode == model.QuietSilent {
+		return model.QuietSilent
+	}
+	return model.QuietHold
+}
+
+// FormatSettings describes the settings shown by the /settings editor.
+func FormatSettings(lang i18n.Lang, cs *model.ChatSettings) string {
+	var b strings.Builder
+	b.WriteString(lang.T("Settings:") + "\n")
+	b.WriteString(lang.Sprintf("Language: %s\n", FormatLanguage(lang, cs.Language)))
+	b.WriteString(lang.Sprintf("Time zone: %s\n", cs.Timezone))
+	b.WriteString(lang.Sprintf("Default interval: every %d min\n", cs.IntervalMinutes))
+	b.WriteString(lang.Sprintf("Default filter scope: %s (%s)\n", cs.FilterScope, scopeLabel(lang, cs.FilterScope)))
+	b.WriteString(lang.Sprintf("Preview length: %d characters\n", cs.PreviewLength))
+	b.WriteString(lang.Sprintf("Images: %s\n", onOff(lang, cs.Images)))
+	b.WriteString(lang.Sprintf("Link previews: %s\n", onOff(lang, cs.LinkPreview)))
+	b.WriteString("\n" + lang.T("Tap a button to change a setting. The interval and scope apply to feeds and filters added from now on."))
+	return b.String()
+}
+
+// FormatLanguage describes the language set for a chat; an empty one follows
+// the Telegram client.
+func FormatLanguage(lang i18n.Lang, code string) string {
+	if l, ok := i18n.Parse(code); ok {
+		return l.Name()
+	}
+	return lang.T("auto")
+}
+
+// FormatDigest formats collected items as a digest in Telegram's HTML parse
+// mode: titles and links grouped by feed. Items are expected grouped by feed.
+// A long digest is split into numbered messages.
+func FormatDigest(lang i18n.Lang, items []model.DigestItem) []string {
+	var b strings.Builder
+	b.WriteString(lang.Sprintf("<b>Digest</b>: %d new item(s)", len(items)))
+	var feedID int64
+	for _, item := range items {
+		if item.FeedID != feedID {
+			feedID = item.FeedID
+			fmt.Fprintf(&b, "\n\n<b>[%s]</b>", text.EscapeHTML(item.FeedName))
+		}
+		title := item.Title
+		if title == "" {
+			title = item.Link
+		}
+		if item.Link != "" {
+			fmt.Fprintf(&b, "\n• <a href=\"%s\">%s</a>", html.EscapeString(item.Link), text.EscapeHTML(title))
+		} else {
+			fmt.Fprintf(&b, "\n• %s", text.EscapeHTML(title))
+		}
+	}
+	return text.SplitHTML(b.String(), text.MaxMessageLength)
+}
+
+// FormatImportSummary formats the result of an OPML import.
+func FormatImportSummary(lang i18n.Lang, added, duplicates, invalid, failed int) string {
+	var b strings.Builder
+	b.WriteString(lang.Sprintf("Imported %d feed(s) from OPML.", added))
+	if duplicates > 0 {
+		b.WriteString("\n" + lang.Sprintf("Skipped %d duplicate(s).", duplicates))
+	}
+	if invalid > 0 {
+		b.WriteString("\n" + lang.Sprintf("Skipped %d feed(s) without an http or https URL.", invalid))
+	}
+	if failed > 0 {
+		b.WriteString("\n" + lang.Sprintf("Failed to save %d feed(s).", failed))
+	}
+	if added > 0 {
+		b.WriteString("\n" + lang.T("Use /list to see your feeds."))
+	}
+	return b.String()
+}
+
 // FormatFeedList formats a list of feeds for display.
-func FormatFeedList(feeds []model.Feed, filterCounts map[int64][2]int) string {
+func FormatFeedList(lang i18n.Lang, feeds []model.Feed, filterCounts map[int64][2]int) string {
 	if len(feeds) == 0 {
-		return "You have no feeds yet. Use /add <url> to add one."
+		return lang.T("You have no feeds yet. Use /add <url> to add one.")
 	}
 	var b strings.Builder
-	b.WriteString("Your feeds:\n")
+	b.WriteString(lang.T("Your feeds:") + "\n")
 	for _, f := range feeds {
 		status := statusActive
 		if !f.IsActive {
@@ -68,37 +252,144 @@ func FormatFeedList(feeds []model.Feed, filterCounts map[int64][2]int) string {
 		inc, exc := filterCounts[f.ID][0], filterCounts[f.ID][1]
 		var filtersLine string
 		if inc == 0 && exc == 0 {
-			filtersLine = "no filters"
+			filtersLine = lang.T("no filters")
 		} else {
-			filtersLine = fmt.Sprintf("%d include, %d exclude", inc, exc)
+			filtersLine = lang.Sprintf("%d include, %d exclude", inc, exc)
 		}
-		fmt.Fprintf(&b, "\n#%d %s [%s]\nURL: %s\nFilters: %s\n", f.Position, f.Name, status, f.URL, filtersLine)
+		b.WriteString(lang.Sprintf("\n#%d %s [%s]\nURL: %s\nFilters: %s\n", f.Position, f.Name, lang.T(status), f.URL, filtersLine))
 	}
 	return b.String()
 }
 
-// FormatFeedInfo formats detailed information about a single feed.
-func FormatFeedInfo(feed *model.Feed, filters []model.Filter) string {
+// timeLayout is how times are shown to a chat, in its time zone.
+const timeLayout = "2006-01-02 15:04 MST"
+
+// FormatFeedInfo formats detailed information about a single feed, with
+// times in loc.
+func FormatFeedInfo(lang i18n.Lang, feed *model.Feed, filters []model.Filter, loc *time.Location) string {
 	var b strings.Builder
 	status := statusActive
 	if !feed.IsActive {
 		status = statusPaused
 	}
-	fmt.Fprintf(&b, "#%d %s [%s]\n", feed.Position, feed.Name, status)
+	fmt.Fprintf(&b, "#%d %s [%s]\n", feed.Position, feed.Name, lang.T(status))
 	fmt.Fprintf(&b, "URL: %s\n", feed.URL)
-	fmt.Fprintf(&b, "Interval: every %d min\n", feed.IntervalMinutes)
+	b.WriteString(lang.Sprintf("Interval: every %d min\n", feed.IntervalMinutes))
+	b.WriteString(lang.Sprintf("Backlog: %s\n", FormatBacklogPolicy(lang, feed)))
+	if feed.BacklogState == model.BacklogAsked && feed.BacklogAskedAt != nil {
+		b.WriteString(lang.Sprintf("Waiting for a backlog choice since %s: /check sends everything, /markread skips it\n",
+			feed.BacklogAskedAt.In(loc).Format(timeLayout)))
+	}
+	if feed.Delivery != model.DeliveryDefault {
+		b.WriteString(lang.Sprintf("Delivery: %s\n", FormatDelivery(lang, feed.Delivery)))
+	}
+	if feed.Quiet != model.QuietDefault {
+		b.WriteString(lang.Sprintf("Quiet hours: %s\n", FormatQuietMode(lang, feed.Quiet)))
+	}
 	if feed.LastCheckAt != nil {
-		fmt.Fprintf(&b, "Last check: %s\n", feed.LastCheckAt.Format("2006-01-02 15:04 UTC"))
+		b.WriteString(lang.Sprintf("Last check: %s\n", feed.LastCheckAt.In(loc).Format(timeLayout)))
+	}
+	if feed.FailureCount > 0 {
+		b.WriteString(lang.Sprintf("Failures: %d in a row\n", feed.FailureCount))
+		b.WriteString(lang.Sprintf("Last error: %s\n", feed.LastError))
+		if feed.NextRetryAt != nil && feed.IsActive {
+			b.WriteString(lang.Sprintf("Next retry: %s\n", feed.NextRetryAt.In(loc).Format(timeLayout)))
+		}
+	}
+	b.WriteString("\n" + lang.T("Filters:") + "\n\n")
+	b.WriteString(FormatFilterList(lang, feed, filters))
+	return b.String()
+}
+
+// FormatFailureHistory formats the recent failed checks of a feed, newest
+// first, with times in loc.
+func FormatFailureHistory(lang i18n.Lang, failures []model.FeedFailure, loc *time.Location) string {
+	if len(failures) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(lang.T("Recent failures:") + "\n")
+	for _, f := range failures {
+		fmt.Fprintf(&b, "  %s: %s\n", f.FailedAt.In(loc).Format(timeLayout), f.Error)
+	}
+	return b.String()
+}
+
+// FormatStorageStats formats the seen items statistics and the latest
+// retention run for admins, with times in loc.
+func FormatStorageStats(lang i18n.Lang, st *model.SeenStats, retentionDays int, last *retention.Result, loc *time.Location) string {
+	var b strings.Builder
+	b.WriteString(lang.T("Storage:") + "\n")
+	b.WriteString(lang.Sprintf("Seen items: %d, %d of them in the latest downloads\n", st.Items, st.InFeed))
+	b.WriteString(lang.Sprintf("Stored content: %s\n", formatBytes(lang, st.ContentBytes)))
+	if st.Uncompressed > 0 {
+		b.WriteString(lang.Sprintf("Not compressed yet: %d item(s)\n", st.Uncompressed))
+	}
+	if st.Oldest != nil {
+		b.WriteString(lang.Sprintf("Oldest seen item: %s\n", st.Oldest.In(loc).Format(timeLayout)))
+	}
+	b.WriteString(lang.Sprintf("Retention: %d days\n", retentionDays))
+	switch {
+	case last == nil:
+		b.WriteString(lang.T("Last retention run: not yet"))
+	case last.Err != nil:
+		b.WriteString(lang.Sprintf("Last retention run: %s, failed: %s", last.At.In(loc).Format(timeLayout), last.Err))
+	default:
+		b.WriteString(lang.Sprintf("Last retention run: %s, %d pruned, %d compressed",
+			last.At.In(loc).Format(timeLayout), last.Pruned, last.Compacted))
+	}
+	return b.String()
+}
+
+// FormatSearchResults formats a page of search results, counted from 0, with
+// times in loc. Items are dated by publication, or by delivery when their
+// feed gave no date.
+func FormatSearchResults(lang i18n.Lang, items []model.ArchivedItem, page, total int, loc *time.Location) string {
+	if total == 0 {
+		return lang.T("Nothing found among the delivered items.")
+	}
+	pages := (total + searchPageSize - 1) / searchPageSize
+	var b strings.Builder
+	b.WriteString(lang.Sprintf("Found %d item(s), page %d of %d:", total, page+1, pages))
+	for i, item := range items {
+		at := item.DeliveredAt
+		if item.Published != nil {
+			at = *item.Published
+		}
+		title := item.Title
+		if title == "" {
+			title = item.Link
+		}
+		fmt.Fprintf(&b, "\n\n%d. %s\n%s · %s", page*searchPageSize+i+1, title, item.FeedName, at.In(loc).Format(timeLayout))
+		if item.Link != "" {
+			b.WriteString("\n" + item.Link)
+		}
 	}
-	b.WriteString("\nFilters:\n\n")
-	b.WriteString(FormatFilterList(feed, filters))
 	return b.String()
 }
 
+// formatBytes formats a size in bytes for people.
+func formatBytes(lang i18n.Lang, n int64) string {
+	switch {
+	case n >= 1<<20:
+		return lang.Sprintf("%s MB", strconv.FormatFloat(float64(n)/(1<<20), 'f', 1, 64))
+	case n >= 1<<10:
+		return lang.Sprintf("%s KB", strconv.FormatFloat(float64(n)/(1<<10), 'f', 1, 64))
+	}
+	return lang.Sprintf("%d bytes", n)
+}
+
+// FormatFeedPaused formats the notice sent when a feed is paused
+// automatically. The reason is expected in lang already.
+func FormatFeedPaused(lang i18n.Lang, feed *model.Feed, reason string) string {
+	return lang.Sprintf("Feed #%d \"%s\" was paused: %s.\nLast error: %s\nUse /resume %d to try again.",
+		feed.Position, feed.Name, reason, feed.LastError, feed.Position)
+}
+
 // FormatFilterList formats the filter rules of a feed grouped by kind.
-func FormatFilterList(feed *model.Feed, filters []model.Filter) string {
+func FormatFilterList(lang i18n.Lang, feed *model.Feed, filters []model.Filter) string {
 	if len(filters) == 0 {
-		return fmt.Sprintf("No filters for #%d \"%s\".\nUse /include, /exclude, /include_re, /exclude_re to add filters.", feed.Position, feed.Name)
+		return lang.Sprintf("No filters for #%d \"%s\".\nUse /include, /exclude, /include_re, /exclude_re, /query to add filters.", feed.Position, feed.Name)
 	}
 
 	groups := map[string][]model.Filter{
@@ -106,6 +397,7 @@ func FormatFilterList(feed *model.Feed, filters []model.Filter) string {
 		"Include (regex)": {},
 		"Exclude (word)":  {},
 		"Exclude (regex)": {},
+		"Query":           {},
 	}
 	for _, f := range filters {
 		switch f.Kind {
@@ -117,13 +409,15 @@ func FormatFilterList(feed *model.Feed, filters []model.Filter) string {
 			groups["Exclude (word)"] = append(groups["Exclude (word)"], f)
 		case model.FilterExcludeRe:
 			groups["Exclude (regex)"] = append(groups["Exclude (regex)"], f)
+		case model.FilterQuery:
+			groups["Query"] = append(groups["Query"], f)
 		}
 	}
 
 	var b strings.Builder
 
 	firstPrinted := false
-	order := []string{"Include (word)", "Include (regex)", "Exclude (word)", "Exclude (regex)"}
+	order := []string{"Include (word)", "Include (regex)", "Exclude (word)", "Exclude (regex)", "Query"}
 	for _, groupName := range order {
 		fs := groups[groupName]
 		if len(fs) == 0 {
@@ -133,21 +427,117 @@ func FormatFilterList(feed *model.Feed, filters []model.Filter) string {
 			b.WriteString("\n")
 		}
 		firstPrinted = true
-		fmt.Fprintf(&b, "%s:\n", groupName)
+		fmt.Fprintf(&b, "%s:\n", lang.T(groupName))
 		for _, f := range fs {
-			fmt.Fprintf(&b, "  F%d: %s (%s)\n", f.Position, f.Value, scopeLabel(f.Scope))
+			fmt.Fprintf(&b, "  F%d: %s (%s)\n", f.Position, f.Value, scopeLabel(lang, f.Scope))
 		}
 	}
 	return b.String()
 }
 
-func scopeLabel(s model.FilterScope) string {
+// FormatTestResult formats a /test dry run: every item with the filter
+// decision behind it. Items in seen are marked as already delivered. A result
+// too long for one Telegram message is split between items.
+func FormatTestResult(lang i18n.Lang, feed *model.Feed, items []fetcher.ExplainedItem, seen map[string]bool) []string {
+	if len(items) == 0 {
+		return []string{lang.Sprintf("Feed #%d \"%s\" has no items.", feed.Position, feed.Name)}
+	}
+
+	passed := 0
+	for _, it := range items {
+		if it.Explanation.Passed {
+			passed++
+		}
+	}
+
+	blocks := []string{lang.Sprintf("Test of #%d \"%s\": %d of %d item(s) pass.\n", feed.Position, feed.Name, passed, len(items))}
+	for i, it := range items {
+		if i == maxTestItems {
+			blocks = append(blocks, "\n"+lang.Sprintf("…and %d more item(s).", len(items)-maxTestItems)+"\n")
+			break
+		}
+		mark := "[-]"
+		if it.Explanation.Passed {
+			mark = "[+]"
+		}
+		title := it.Title
+		if title == "" {
+			title = it.Link
+		}
+		if r := []rune(title); len(r) > maxTestTitle {
+			title = string(r[:maxTestTitle-1]) + "…"
+		}
+		block := fmt.Sprintf("\n%s %s\n    %s", mark, title, explainReason(lang, it.Explanation))
+		if seen[it.GUID] {
+			block += " " + lang.T("(already seen)")
+		}
+		blocks = append(blocks, block+"\n")
+	}
+	return joinBlocks(blocks, text.MaxMessageLength)
+}
+
+// joinBlocks joins plain text blocks into messages of at most limit
+// characters. A block too long for a message of its own is cut.
+func joinBlocks(blocks []string, limit int) []string {
+	var msgs []string
+	var cur strings.Builder
+	size := 0
+	for _, block := range blocks {
+		n := utf8.RuneCountInString(block)
+		if n > limit {
+			block = string([]rune(block)[:limit-1]) + "…"
+			n = limit
+		}
+		if size+n > limit {
+			msgs = append(msgs, cur.String())
+			cur.Reset()
+			size = 0
+			block = strings.TrimPrefix(block, "\n")
+			n = utf8.RuneCountInString(block)
+		}
+		cur.WriteString(block)
+		size += n
+	}
+	return append(msgs, cur.String())
+}
+
+func explainReason(lang i18n.Lang, e filter.Explanation) string {
+	var label string
+	if e.Filter != nil {
+		label = fmt.Sprintf("F%d %s \"%s\"", e.Filter.Position, e.Filter.Kind, e.Filter.Value)
+	}
+	switch e.Reason {
+	case filter.ReasonNoFilters:
+		return lang.T("passed: no filters")
+	case filter.ReasonIncluded, filter.ReasonQueryMatched:
+		return lang.Sprintf("passed by %s", label)
+	case filter.ReasonNotExcluded:
+		return lang.T("passed: no exclude filter matched")
+	case filter.ReasonExcluded:
+		return lang.Sprintf("rejected by %s", label)
+	case filter.ReasonQueryFailed:
+		return lang.Sprintf("rejected: %s did not match", label)
+	case filter.ReasonNoInclude:
+		return lang.T("rejected: no include filter matched")
+	}
+	return string(e.Reason)
+}
+
+func scopeLabel(lang i18n.Lang, s model.FilterScope) string {
 	switch s {
 	case model.ScopeTitle:
-		return "title only"
+		return lang.T("title only")
 	case model.ScopeContent:
-		return "content only"
+		return lang.T("content only")
+	case model.ScopeAuthor:
+		return lang.T("author")
+	case model.ScopeCategories:
+		return lang.T("categories")
+	case model.ScopeLink:
+		return lang.T("link")
+	case model.ScopeEnclosures:
+		return lang.T("enclosures")
 	default:
-		return "title+content"
+		return lang.T("title+content")
 	}
 }
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"rss_bot/internal/fetcher"
+	"rss_bot/internal/text"
 )
 
 const testFeedName = "Test Feed"
@@ -30,11 +31,11 @@ func TestFormatNotification_WithHTML(t *testing.T) {
 	if !strings.Contains(result, "Test Title") {
 		t.Error("should contain title")
 	}
-	if !strings.Contains(result, "HTML content") {
-		t.Error("should contain parsed HTML content")
+	if !strings.Contains(result, "<b>HTML</b> content") {
+		t.Error("should contain rendered HTML content")
 	}
-	if !strings.Contains(result, "a link") {
-		t.Error("should contain link text")
+	if !strings.Contains(result, `<a href="http://example.com">a link</a>`) {
+		t.Error("should contain link")
 	}
 	if !strings.Contains(result, "example.com/article") {
 		t.Error("should contain link")
@@ -68,7 +69,7 @@ func TestFormatNotificationShort_WithImage(t *testing.T) {
 		GUID:        "item-123",
 	}
 
-	result := FormatNotificationShort(feedPosition, feedName, item)
+	result := FormatNotificationShort(feedPosition, feedName, item, text.Options{})
 
 	if result.ImageURL == "" {
 		t.Error("should have ImageURL")
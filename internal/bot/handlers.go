@@ -2,16 +2,17 @@ package bot
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"time"
 
+	"rss_bot/internal/digest"
 	"rss_bot/internal/fetcher"
 	"rss_bot/internal/filter"
 	"rss_bot/internal/model"
 )
 
-func (b *Bot) handleStart(chatID int64) {
-	b.reply(chatID, `Welcome to RSS Notify Bot!
+func (b *Bot) handleStart(ctx context.Context, chatID int64) {
+	b.reply(chatID, b.lang(ctx, chatID).T(`Welcome to RSS Notify Bot!
 
 Subscribe to RSS feeds and get filtered notifications.
 
@@ -20,11 +21,11 @@ Quick start:
 2. /include <id> <word> — add a whitelist filter
 3. /exclude <id> <word> — add a blacklist filter
 
-Use /help for the full command reference.`)
+Use /help for the full command reference.`))
 }
 
-func (b *Bot) handleHelp(chatID int64) {
-	b.reply(chatID, `Feed management:
+func (b *Bot) handleHelp(ctx context.Context, chatID int64) {
+	b.reply(chatID, b.lang(ctx, chatID).T(`Feed management:
 /add <url> — add a new RSS feed
 /list — show all feeds
 /info <id> — feed details
@@ -34,6 +35,21 @@ func (b *Bot) handleHelp(chatID int64) {
 /pause <id> — pause checking
 /resume <id> — resume checking
 /check <id> — force check now
+/test <id> — show which items pass the filters, without sending them
+/markread <id> — mark all current items as read
+/backlog <id> <all|newest N|ask N|skip> — what to send after add or resume
+/digest [on|off] — collect items into a digest instead of one message each
+/digest at <HH:MM> [HH:MM ...] — when to send the digest
+/digest <id> <on|off|default> — digest mode of a single feed
+/quiet <HH:MM-HH:MM|off> — quiet hours, e.g. /quiet 23:00-08:00
+/quiet <hold|silent> — hold notifications until quiet hours end, or send them silently
+/quiet <id> <hold|silent|default> — quiet mode of a single feed
+/timezone <Area/City> — time zone for digest times, quiet hours and feed info
+/settings — time zone, defaults for new feeds and filters, notification look
+/language <en|ru|auto> — language of the bot
+/search <query> — search the items delivered to this chat, with the syntax of /query
+/export — download your feeds as OPML
+Send an OPML file to import feeds.
 
 Filter management:
 /filters <id> — show filters for a feed
@@ -41,41 +57,96 @@ Filter management:
 /exclude <id> [-s scope] <word> — blacklist word/phrase
 /include_re <id> [-s scope] <regex> — whitelist regex
 /exclude_re <id> [-s scope] <regex> — blacklist regex
+/query <id> [-s scope] <expression> — boolean query, e.g.
+  kubernetes AND (security OR "CVE") AND NOT title:webinar
 /rmfilter <filter_id> — remove a filter
 
-Scope flag: -s title | content | all (default: all)`)
+Scope flag: -s title | content | all | author | categories | link | enclosures (default: all)`))
 }
 
 func (b *Bot) handleAdd(ctx context.Context, chatID int64, args string) {
+	lang := b.lang(ctx, chatID)
 	if args == "" {
-		b.reply(chatID, "Usage: /add <url>")
+		b.reply(chatID, lang.T("Usage: /add <url>"))
 		return
 	}
 
 	feed, err := b.fetcher.Fetch(ctx, args)
+	var notFeed *fetcher.NotFeedError
+	if errors.As(err, &notFeed) {
+		b.discoverFeeds(ctx, chatID, args, notFeed.Page)
+		return
+	}
+	if err != nil {
+		b.reply(chatID, lang.Sprintf("Failed to fetch feed: %v", err))
+		return
+	}
+
+	b.addFeed(ctx, chatID, args, feed.Title)
+}
+
+// discoverFeeds looks for feeds on a downloaded web page. A single feed is
+// added right away; several are offered as inline keyboard buttons.
+func (b *Bot) discoverFeeds(ctx context.Context, chatID int64, pageURL string, page []byte) {
+	lang := b.lang(ctx, chatID)
+	found, err := b.fetcher.DiscoverPage(ctx, pageURL, page)
+	if err != nil {
+		b.reply(chatID, lang.Sprintf("Failed to fetch feed: %v", err))
+		return
+	}
+
+	switch len(found) {
+	case 0:
+		b.reply(chatID, lang.Sprintf("%s is a web page and no feeds were found on it.", pageURL))
+	case 1:
+		b.addDiscoveredFeed(ctx, chatID, found[0])
+	default:
+		markup, err := b.discoveredKeyboard(ctx, chatID, found)
+		if err != nil {
+			b.reply(chatID, lang.Sprintf("Error: %v", err))
+			return
+		}
+		b.SendMessageWithKeyboard(chatID,
+			lang.Sprintf("Found %d feeds on %s. Pick one to add:", len(found), pageURL),
+			markup)
+	}
+}
+
+// addDiscoveredFeed fetches a feed found by autodiscovery and subscribes to it.
+func (b *Bot) addDiscoveredFeed(ctx context.Context, chatID int64, d fetcher.DiscoveredFeed) {
+	lang := b.lang(ctx, chatID)
+	feed, err := b.fetcher.Fetch(ctx, d.URL)
 	if err != nil {
-		b.reply(chatID, fmt.Sprintf("Failed to fetch feed: %v", err))
+		b.reply(chatID, lang.Sprintf("Failed to fetch feed: %v", err))
 		return
 	}
+	title := feed.Title
+	if title == "" {
+		title = d.Title
+	}
+	b.addFeed(ctx, chatID, d.URL, title)
+}
 
-	name := feed.Title
+func (b *Bot) addFeed(ctx context.Context, chatID int64, url, title string) {
+	lang := b.lang(ctx, chatID)
+	name := title
 	if name == "" {
-		name = args
+		name = url
 	}
 
 	f := &model.Feed{
 		ChatID:          chatID,
 		Name:            name,
-		URL:             args,
-		IntervalMinutes: 15,
+		URL:             url,
+		IntervalMinutes: b.chatSettings(ctx, chatID).IntervalMinutes,
 		IsActive:        true,
 	}
 	if err := b.store.CreateFeed(ctx, f); err != nil {
-		b.reply(chatID, fmt.Sprintf("Failed to save feed: %v", err))
+		b.reply(chatID, lang.Sprintf("Failed to save feed: %v", err))
 		return
 	}
 
-	b.reply(chatID, fmt.Sprintf(`Feed added successfully!
+	b.reply(chatID, lang.Sprintf(`Feed added successfully!
 
 #%d %s (every %d min)
 URL: %s
@@ -84,9 +155,10 @@ No filters yet. Use /include, /exclude to add filters.`,
 }
 
 func (b *Bot) handleList(ctx context.Context, chatID int64) {
+	lang := b.lang(ctx, chatID)
 	feeds, err := b.store.ListFeeds(ctx, chatID)
 	if err != nil {
-		b.reply(chatID, fmt.Sprintf("Error: %v", err))
+		b.reply(chatID, lang.Sprintf("Error: %v", err))
 		return
 	}
 
@@ -99,7 +171,7 @@ func (b *Bot) handleList(ctx context.Context, chatID int64) {
 		var inc, exc int
 		for _, fl := range filters {
 			switch fl.Kind {
-			case model.FilterInclude, model.FilterIncludeRe:
+			case model.FilterInclude, model.FilterIncludeRe, model.FilterQuery:
 				inc++
 			case model.FilterExclude, model.FilterExcludeRe:
 				exc++
@@ -108,146 +180,171 @@ func (b *Bot) handleList(ctx context.Context, chatID int64) {
 		counts[f.ID] = [2]int{inc, exc}
 	}
 
-	b.reply(chatID, FormatFeedList(feeds, counts))
+	b.reply(chatID, FormatFeedList(lang, feeds, counts))
 }
 
 func (b *Bot) handleInfo(ctx context.Context, chatID int64, args string) {
+	settings := b.chatSettings(ctx, chatID)
+	lang := ChatLanguage(settings)
 	pos, err := ParseFeedArg(args)
 	if err != nil {
-		b.reply(chatID, "Usage: /info <number>")
+		b.reply(chatID, lang.T("Usage: /info <number>"))
 		return
 	}
 
 	feed, err := b.store.GetFeedByPosition(ctx, chatID, pos)
 	if err != nil {
-		b.reply(chatID, fmt.Sprintf("Feed #%d not found.", pos))
+		b.reply(chatID, lang.Sprintf("Feed #%d not found.", pos))
 		return
 	}
 
+	loc := digest.Location(settings.Timezone)
 	filters, _ := b.store.ListFilters(ctx, feed.ID)
-	b.reply(chatID, FormatFeedInfo(feed, filters))
+	info := FormatFeedInfo(lang, feed, filters, loc)
+	if failures, err := b.store.ListFeedFailures(ctx, feed.ID, 5); err == nil && len(failures) > 0 {
+		info += "\n" + FormatFailureHistory(lang, failures, loc)
+	}
+	b.reply(chatID, info)
 }
 
 func (b *Bot) handleRemove(ctx context.Context, chatID int64, args string) {
+	lang := b.lang(ctx, chatID)
 	pos, err := ParseFeedArg(args)
 	if err != nil {
-		b.reply(chatID, "Usage: /remove <number>")
+		b.reply(chatID, lang.T("Usage: /remove <number>"))
 		return
 	}
 
 	feed, err := b.store.GetFeedByPosition(ctx, chatID, pos)
 	if err != nil {
-		b.reply(chatID, fmt.Sprintf("Feed #%d not found.", pos))
+		b.reply(chatID, lang.Sprintf("Feed #%d not found.", pos))
 		return
 	}
 
 	if err := b.store.DeleteFeed(ctx, feed.ID); err != nil {
-		b.reply(chatID, fmt.Sprintf("Error deleting feed: %v", err))
+		b.reply(chatID, lang.Sprintf("Error deleting feed: %v", err))
 		return
 	}
-	b.reply(chatID, fmt.Sprintf("Feed #%d \"%s\" deleted.", pos, feed.Name))
+	b.reply(chatID, lang.Sprintf("Feed #%d \"%s\" deleted.", pos, feed.Name))
 }
 
 func (b *Bot) handleRename(ctx context.Context, chatID int64, args string) {
+	lang := b.lang(ctx, chatID)
 	pos, name, err := ParseRenameArgs(args)
 	if err != nil {
-		b.reply(chatID, err.Error())
+		b.reply(chatID, lang.Error(err))
 		return
 	}
 
 	feed, err := b.store.GetFeedByPosition(ctx, chatID, pos)
 	if err != nil {
-		b.reply(chatID, fmt.Sprintf("Feed #%d not found.", pos))
+		b.reply(chatID, lang.Sprintf("Feed #%d not found.", pos))
 		return
 	}
 
 	feed.Name = name
 	if err := b.store.UpdateFeed(ctx, feed); err != nil {
-		b.reply(chatID, fmt.Sprintf("Error: %v", err))
+		b.reply(chatID, lang.Sprintf("Error: %v", err))
 		return
 	}
-	b.reply(chatID, fmt.Sprintf("Feed #%d renamed to \"%s\".", pos, name))
+	b.reply(chatID, lang.Sprintf("Feed #%d renamed to \"%s\".", pos, name))
 }
 
 func (b *Bot) handleInterval(ctx context.Context, chatID int64, args string) {
+	lang := b.lang(ctx, chatID)
 	pos, mins, err := ParseIntervalArgs(args)
 	if err != nil {
-		b.reply(chatID, err.Error())
+		b.reply(chatID, lang.Error(err))
 		return
 	}
 
 	feed, err := b.store.GetFeedByPosition(ctx, chatID, pos)
 	if err != nil {
-		b.reply(chatID, fmt.Sprintf("Feed #%d not found.", pos))
+		b.reply(chatID, lang.Sprintf("Feed #%d not found.", pos))
 		return
 	}
 
 	feed.IntervalMinutes = mins
 	if err := b.store.UpdateFeed(ctx, feed); err != nil {
-		b.reply(chatID, fmt.Sprintf("Error: %v", err))
+		b.reply(chatID, lang.Sprintf("Error: %v", err))
 		return
 	}
-	b.reply(chatID, fmt.Sprintf("Feed #%d interval set to %d min.", pos, mins))
+	b.reply(chatID, lang.Sprintf("Feed #%d interval set to %d min.", pos, mins))
 }
 
 func (b *Bot) handlePause(ctx context.Context, chatID int64, args string) {
+	lang := b.lang(ctx, chatID)
 	pos, err := ParseFeedArg(args)
 	if err != nil {
-		b.reply(chatID, "Usage: /pause <number>")
+		b.reply(chatID, lang.T("Usage: /pause <number>"))
 		return
 	}
 
 	feed, err := b.store.GetFeedByPosition(ctx, chatID, pos)
 	if err != nil {
-		b.reply(chatID, fmt.Sprintf("Feed #%d not found.", pos))
+		b.reply(chatID, lang.Sprintf("Feed #%d not found.", pos))
 		return
 	}
 
 	feed.IsActive = false
 	if err := b.store.UpdateFeed(ctx, feed); err != nil {
-		b.reply(chatID, fmt.Sprintf("Error: %v", err))
+		b.reply(chatID, lang.Sprintf("Error: %v", err))
 		return
 	}
-	b.reply(chatID, fmt.Sprintf("Feed #%d \"%s\" paused.", pos, feed.Name))
+	b.reply(chatID, lang.Sprintf("Feed #%d \"%s\" paused.", pos, feed.Name))
 }
 
 func (b *Bot) handleResume(ctx context.Context, chatID int64, args string) {
+	lang := b.lang(ctx, chatID)
 	pos, err := ParseFeedArg(args)
 	if err != nil {
-		b.reply(chatID, "Usage: /resume <number>")
+		b.reply(chatID, lang.T("Usage: /resume <number>"))
 		return
 	}
 
 	feed, err := b.store.GetFeedByPosition(ctx, chatID, pos)
 	if err != nil {
-		b.reply(chatID, fmt.Sprintf("Feed #%d not found.", pos))
+		b.reply(chatID, lang.Sprintf("Feed #%d not found.", pos))
 		return
 	}
 
 	feed.IsActive = true
 	if err := b.store.UpdateFeed(ctx, feed); err != nil {
-		b.reply(chatID, fmt.Sprintf("Error: %v", err))
+		b.reply(chatID, lang.Sprintf("Error: %v", err))
 		return
 	}
-	b.reply(chatID, fmt.Sprintf("Feed #%d \"%s\" resumed.", pos, feed.Name))
+	if err := b.store.ResetFeedFailures(ctx, feed.ID); err != nil {
+		b.log.Error("reset feed failures", "feed_id", feed.ID, "error", err)
+	}
+	if err := b.store.SetBacklogState(ctx, feed.ID, model.BacklogPending); err != nil {
+		b.log.Error("set backlog state", "feed_id", feed.ID, "error", err)
+	}
+	b.reply(chatID, lang.Sprintf("Feed #%d \"%s\" resumed.", pos, feed.Name))
 }
 
 func (b *Bot) handleCheck(ctx context.Context, chatID int64, args string) {
+	lang := b.lang(ctx, chatID)
 	pos, err := ParseFeedArg(args)
 	if err != nil {
-		b.reply(chatID, "Usage: /check <number>")
+		b.reply(chatID, lang.T("Usage: /check <number>"))
 		return
 	}
 
 	feed, err := b.store.GetFeedByPosition(ctx, chatID, pos)
 	if err != nil {
-		b.reply(chatID, fmt.Sprintf("Feed #%d not found.", pos))
+		b.reply(chatID, lang.Sprintf("Feed #%d not found.", pos))
+		return
+	}
+
+	if !b.locks.TryLock(feed.ID) {
+		b.reply(chatID, lang.Sprintf("Feed #%d is being checked right now. Try again in a moment.", pos))
 		return
 	}
+	defer b.locks.Unlock(feed.ID)
 
 	rssFeed, err := b.fetcher.Fetch(ctx, feed.URL)
 	if err != nil {
-		b.reply(chatID, fmt.Sprintf("Failed to fetch: %v", err))
+		b.reply(chatID, lang.Sprintf("Failed to fetch: %v", err))
 		return
 	}
 
@@ -272,63 +369,102 @@ func (b *Bot) handleCheck(ctx context.Context, chatID int64, args string) {
 	)
 
 	if len(newItems) == 0 {
-		b.reply(chatID, fmt.Sprintf("No new matching items in #%d \"%s\".", pos, feed.Name))
+		b.reply(chatID, lang.Sprintf("No new matching items in #%d \"%s\".", pos, feed.Name))
 		return
 	}
 
-	for _, item := range newItems {
-		msg := FormatNotificationShort(feed.Position, feed.Name, item)
-		if msg.ImageURL != "" {
-			b.SendPhotoWithCaption(chatID, msg.ImageURL, msg.Text, msg.Markup)
-		} else if msg.Markup != nil {
-			b.SendMessageWithKeyboard(chatID, msg.Text, msg.Markup)
-		} else {
-			b.reply(chatID, msg.Text)
+	b.deliverItems(ctx, feed, newItems)
+	if feed.BacklogState != model.BacklogDone {
+		// A manual check delivers everything, so the backlog is settled.
+		if err := b.store.SetBacklogState(ctx, feed.ID, model.BacklogDone); err != nil {
+			b.log.Error("set backlog state", "feed_id", feed.ID, "error", err)
 		}
-		_ = b.store.MarkSeen(ctx, feed.ID, item.GUID, item.Description)
 	}
 	now := time.Now()
 	feed.LastCheckAt = &now
 	if err := b.store.UpdateFeed(ctx, feed); err != nil {
 		b.log.Error("update feed last check", "error", err)
 	}
-	b.reply(chatID, fmt.Sprintf("Found %d new item(s) in #%d \"%s\".", len(newItems), pos, feed.Name))
+	b.reply(chatID, lang.Sprintf("Found %d new item(s) in #%d \"%s\".", len(newItems), pos, feed.Name))
+}
+
+func (b *Bot) handleTest(ctx context.Context, chatID int64, args string) {
+	lang := b.lang(ctx, chatID)
+	pos, err := ParseFeedArg(args)
+	if err != nil {
+		b.reply(chatID, lang.T("Usage: /test <number>"))
+		return
+	}
+
+	feed, err := b.store.GetFeedByPosition(ctx, chatID, pos)
+	if err != nil {
+		b.reply(chatID, lang.Sprintf("Feed #%d not found.", pos))
+		return
+	}
+
+	rssFeed, err := b.fetcher.Fetch(ctx, feed.URL)
+	if err != nil {
+		b.reply(chatID, lang.Sprintf("Failed to fetch: %v", err))
+		return
+	}
+
+	filters, _ := b.store.ListFilters(ctx, feed.ID)
+	items := fetcher.ExplainItems(rssFeed.Items, filters)
+
+	seen := make(map[string]bool, len(items))
+	for _, it := range items {
+		if ok, _ := b.store.IsSeen(ctx, feed.ID, it.GUID); ok {
+			seen[it.GUID] = true
+		}
+	}
+
+	for _, msg := range FormatTestResult(lang, feed, items, seen) {
+		b.reply(chatID, msg)
+	}
 }
 
 func (b *Bot) handleFilters(ctx context.Context, chatID int64, args string) {
+	lang := b.lang(ctx, chatID)
 	pos, err := ParseFeedArg(args)
 	if err != nil {
-		b.reply(chatID, "Usage: /filters <number>")
+		b.reply(chatID, lang.T("Usage: /filters <number>"))
 		return
 	}
 
 	feed, err := b.store.GetFeedByPosition(ctx, chatID, pos)
 	if err != nil {
-		b.reply(chatID, fmt.Sprintf("Feed #%d not found.", pos))
+		b.reply(chatID, lang.Sprintf("Feed #%d not found.", pos))
 		return
 	}
 
 	filters, _ := b.store.ListFilters(ctx, feed.ID)
-	b.reply(chatID, fmt.Sprintf("Filters for #%d \"%s\":\n\n%s", feed.Position, feed.Name, FormatFilterList(feed, filters)))
+	b.reply(chatID, lang.Sprintf("Filters for #%d \"%s\":\n\n%s", feed.Position, feed.Name, FormatFilterList(lang, feed, filters)))
 }
 
 func (b *Bot) handleAddFilter(ctx context.Context, chatID int64, args string, kind string) {
-	parsed, err := ParseFilterCommand(args)
+	lang := b.lang(ctx, chatID)
+	parsed, err := ParseFilterCommand(args, b.chatSettings(ctx, chatID).FilterScope)
 	if err != nil {
-		b.reply(chatID, err.Error())
+		b.reply(chatID, lang.Error(err))
 		return
 	}
 
 	feed, err := b.store.GetFeedByPosition(ctx, chatID, parsed.FeedPosition)
 	if err != nil {
-		b.reply(chatID, fmt.Sprintf("Feed #%d not found.", parsed.FeedPosition))
+		b.reply(chatID, lang.Sprintf("Feed #%d not found.", parsed.FeedPosition))
 		return
 	}
 
 	fk := model.FilterKind(kind)
 	if fk == model.FilterIncludeRe || fk == model.FilterExcludeRe {
 		if err := filter.ValidateRegex(parsed.Value); err != nil {
-			b.reply(chatID, fmt.Sprintf("Invalid regex: %v", err))
+			b.reply(chatID, lang.Sprintf("Invalid regex: %v", err))
+			return
+		}
+	}
+	if fk == model.FilterQuery {
+		if err := filter.ValidateQuery(parsed.Value); err != nil {
+			b.reply(chatID, lang.Sprintf("%v\n\nExample: /query %d kubernetes AND (security OR \"CVE\") AND NOT title:webinar", err, parsed.FeedPosition))
 			return
 		}
 	}
@@ -340,24 +476,25 @@ func (b *Bot) handleAddFilter(ctx context.Context, chatID int64, args string, ki
 		Value:  parsed.Value,
 	}
 	if err := b.store.CreateFilter(ctx, f); err != nil {
-		b.reply(chatID, fmt.Sprintf("Error: %v", err))
+		b.reply(chatID, lang.Sprintf("Error: %v", err))
 		return
 	}
 
 	filters, _ := b.store.ListFilters(ctx, feed.ID)
-	b.reply(chatID, fmt.Sprintf("Filter F%d added to #%d \"%s\".\n\nActual filters:\n\n%s", f.Position, feed.Position, feed.Name, FormatFilterList(feed, filters)))
+	b.reply(chatID, lang.Sprintf("Filter F%d added to #%d \"%s\".\n\nActual filters:\n\n%s", f.Position, feed.Position, feed.Name, FormatFilterList(lang, feed, filters)))
 }
 
 func (b *Bot) handleRmFilter(ctx context.Context, chatID int64, args string) {
+	lang := b.lang(ctx, chatID)
 	pos, err := ParseFilterArg(args)
 	if err != nil {
-		b.reply(chatID, "Usage: /rmfilter <filter_number>")
+		b.reply(chatID, lang.T("Usage: /rmfilter <filter_number>"))
 		return
 	}
 
 	feeds, err := b.store.ListFeeds(ctx, chatID)
 	if err != nil {
-		b.reply(chatID, fmt.Sprintf("Error: %v", err))
+		b.reply(chatID, lang.Sprintf("Error: %v", err))
 		return
 	}
 
@@ -384,18 +521,18 @@ func (b *Bot) handleRmFilter(ctx context.Context, chatID int64, args string) {
 	}
 
 	if targetFilter == nil || targetFeed == nil {
-		b.reply(chatID, fmt.Sprintf("Filter F%d not found.", pos))
+		b.reply(chatID, lang.Sprintf("Filter F%d not found.", pos))
 		return
 	}
 
 	if err := b.store.DeleteFilter(ctx, targetFilter.ID); err != nil {
-		b.reply(chatID, fmt.Sprintf("Error: %v", err))
+		b.reply(chatID, lang.Sprintf("Error: %v", err))
 		return
 	}
 	remaining, _ := b.store.ListFilters(ctx, targetFeed.ID)
 	if len(remaining) > 0 {
-		b.reply(chatID, fmt.Sprintf("Filter F%d removed from #%d \"%s\".\n\nActual filters:\n\n%s", pos, targetFeed.Position, targetFeed.Name, FormatFilterList(targetFeed, remaining)))
+		b.reply(chatID, lang.Sprintf("Filter F%d removed from #%d \"%s\".\n\nActual filters:\n\n%s", pos, targetFeed.Position, targetFeed.Name, FormatFilterList(lang, targetFeed, remaining)))
 	} else {
-		b.reply(chatID, fmt.Sprintf("Filter F%d removed from #%d \"%s\".\n\n%s", pos, targetFeed.Position, targetFeed.Name, FormatFilterList(targetFeed, remaining)))
+		b.reply(chatID, lang.Sprintf("Filter F%d removed from #%d \"%s\".\n\n%s", pos, targetFeed.Position, targetFeed.Name, FormatFilterList(lang, targetFeed, remaining)))
 	}
 }
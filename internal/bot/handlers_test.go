@@ -8,6 +8,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 
 	"rss_bot/internal/fetcher"
+	"rss_bot/internal/i18n"
 	"rss_bot/internal/model"
 )
 
@@ -43,6 +44,26 @@ func TestParseFilterCommand(t *testing.T) {
 			args: "1 -s all kubernetes",
 			want: FilterArgs{FeedPosition: 1, Scope: model.ScopeAll, Value: "kubernetes"},
 		},
+		{
+			name: "with scope author",
+			args: "1 -s author Rob Pike",
+			want: FilterArgs{FeedPosition: 1, Scope: model.ScopeAuthor, Value: "Rob Pike"},
+		},
+		{
+			name: "with scope categories",
+			args: "1 -s categories golang",
+			want: FilterArgs{FeedPosition: 1, Scope: model.ScopeCategories, Value: "golang"},
+		},
+		{
+			name: "with scope link",
+			args: "1 -s link github.com",
+			want: FilterArgs{FeedPosition: 1, Scope: model.ScopeLink, Value: "github.com"},
+		},
+		{
+			name: "with scope enclosures",
+			args: "1 -s enclosures audio/",
+			want: FilterArgs{FeedPosition: 1, Scope: model.ScopeEnclosures, Value: "audio/"},
+		},
 		{
 			name:    "missing value",
 			args:    "1",
@@ -72,7 +93,7 @@ func TestParseFilterCommand(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := ParseFilterCommand(tt.args)
+			got, err := ParseFilterCommand(tt.args, model.ScopeAll)
 			if tt.wantErr {
 				if err == nil {
 					t.Fatal("expected error, got nil")
@@ -87,6 +108,16 @@ func TestParseFilterCommand(t *testing.T) {
 			}
 		})
 	}
+
+	t.Run("chat default scope", func(t *testing.T) {
+		got, err := ParseFilterCommand("1 golang", model.ScopeTitle)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if diff := cmp.Diff(model.ScopeTitle, got.Scope); diff != "" {
+			t.Errorf("scope (-want +got):\n%s", diff)
+		}
+	})
 }
 
 func TestParseFeedArg(t *testing.T) {
@@ -269,7 +300,7 @@ func TestFormatFeedList(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := FormatFeedList(tt.feeds, tt.filterCounts)
+			got := FormatFeedList(i18n.English, tt.feeds, tt.filterCounts)
 			for _, want := range tt.wantContains {
 				if !contains(got, want) {
 					t.Errorf("output missing %q:\n%s", want, got)
@@ -285,6 +316,7 @@ func TestFormatFeedInfo(t *testing.T) {
 		name         string
 		feed         *model.Feed
 		filters      []model.Filter
+		loc          *time.Location
 		wantContains []string
 	}{
 		{
@@ -306,6 +338,38 @@ func TestFormatFeedInfo(t *testing.T) {
 				"F2: ad (title only)",
 			},
 		},
+		{
+			name: "failing feed",
+			feed: &model.Feed{
+				ID: 2, Position: 2, Name: "Flaky", URL: "https://f.com", IntervalMinutes: 15, IsActive: true,
+				FailureCount: 3, LastError: "unexpected status 503", NextRetryAt: &lastCheck,
+			},
+			wantContains: []string{
+				"Failures: 3 in a row",
+				"Last error: unexpected status 503",
+				"Next retry: 2025-06-15 10:30 UTC",
+			},
+		},
+		{
+			name: "waiting for a backlog choice",
+			feed: &model.Feed{
+				ID: 4, Position: 4, Name: "Asked", URL: "https://a.com", IntervalMinutes: 15, IsActive: true,
+				BacklogPolicy: model.BacklogAsk, BacklogLimit: 5, BacklogState: model.BacklogAsked, BacklogAskedAt: &lastCheck,
+			},
+			wantContains: []string{
+				"Backlog: ask when more than 5",
+				"Waiting for a backlog choice since 2025-06-15 10:30 UTC: /check sends everything, /markread skips it",
+			},
+		},
+		{
+			name: "times in the chat's time zone",
+			feed: &model.Feed{
+				ID: 3, Position: 3, Name: "Local", URL: "https://l.com", IntervalMinutes: 15, IsActive: true,
+				LastCheckAt: &lastCheck,
+			},
+			loc:          time.FixedZone("MSK", 3*60*60),
+			wantContains: []string{"Last check: 2025-06-15 13:30 MSK"},
+		},
 		{
 			name: "paused feed no filters",
 			feed: &model.Feed{
@@ -321,7 +385,11 @@ func TestFormatFeedInfo(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := FormatFeedInfo(tt.feed, tt.filters)
+			loc := tt.loc
+			if loc == nil {
+				loc = time.UTC
+			}
+			got := FormatFeedInfo(i18n.English, tt.feed, tt.filters, loc)
 			for _, want := range tt.wantContains {
 				if !strings.Contains(got, want) {
 					t.Errorf("output missing %q:\n%s", want, got)
@@ -370,7 +438,7 @@ func TestFormatFilterList(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := FormatFilterList(feed, tt.filters)
+			got := FormatFilterList(i18n.English, feed, tt.filters)
 			for _, want := range tt.wantContains {
 				if !strings.Contains(got, want) {
 					t.Errorf("output missing %q:\n%s", want, got)
@@ -393,7 +461,7 @@ func TestScopeLabel(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(string(tt.scope), func(t *testing.T) {
-			got := scopeLabel(tt.scope)
+			got := scopeLabel(i18n.English, tt.scope)
 			if diff := cmp.Diff(tt.want, got); diff != "" {
 				t.Errorf("scopeLabel(%q) mismatch (-want +got):\n%s", tt.scope, diff)
 			}
@@ -404,3 +472,22 @@ func TestScopeLabel(t *testing.T) {
 func contains(s, substr string) bool {
 	return strings.Contains(s, substr)
 }
+
+func TestFormatDigest(t *testing.T) {
+	items := []model.DigestItem{
+		{FeedID: 1, FeedName: "Go & Rust", Title: "Generics <finally>", Link: "https://example.com/a?x=1&y=2"},
+		{FeedID: 1, FeedName: "Go & Rust", Title: "No link"},
+		{FeedID: 2, FeedName: "Other", Link: "https://example.com/b"},
+	}
+	want := []string{`<b>Digest</b>: 3 new item(s)
+
+<b>[Go &amp; Rust]</b>
+• <a href="https://example.com/a?x=1&amp;y=2">Generics &lt;finally&gt;</a>
+• No link
+
+<b>[Other]</b>
+• <a href="https://example.com/b">https://example.com/b</a>`}
+	if diff := cmp.Diff(want, FormatDigest(i18n.English, items)); diff != "" {
+		t.Errorf("FormatDigest mismatch (-want +got):\n%s", diff)
+	}
+}
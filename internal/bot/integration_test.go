@@ -15,7 +15,9 @@ import (
 	"github.com/h2non/gock"
 
 	"rss_bot/internal/config"
+	"rss_bot/internal/feedlock"
 	"rss_bot/internal/fetcher"
+	"rss_bot/internal/model"
 	"rss_bot/internal/storage"
 )
 
@@ -104,6 +106,7 @@ func setupBot(t *testing.T, xmlBody string) (*Bot, *fakeAPI, *storage.SQLite) {
 		store:   store,
 		cfg:     &config.Config{},
 		fetcher: fetcher.New(httpClient),
+		locks:   feedlock.New(),
 		log:     slog.New(slog.NewTextHandler(io.Discard, nil)),
 	}
 	return b, api, store
@@ -139,11 +142,21 @@ func (m *fakeAPI) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
 	return tgbotapi.Message{}, nil
 }
 
-func (m *fakeAPI) GetUpdatesChan(_ tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel {
-	return make(tgbotapi.UpdatesChannel)
+func (m *fakeAPI) SendMediaGroup(_ tgbotapi.MediaGroupConfig) ([]tgbotapi.Message, error) {
+	return nil, nil
 }
 
-func (m *fakeAPI) StopReceivingUpdates() {}
+func (m *fakeAPI) GetUpdates(_ tgbotapi.UpdateConfig) ([]tgbotapi.Update, error) {
+	return nil, nil
+}
+
+func (m *fakeAPI) GetFileDirectURL(fileID string) (string, error) {
+	return "https://files.example.com/" + fileID, nil
+}
+
+func (m *fakeAPI) MakeRequest(_ string, _ tgbotapi.Params) (*tgbotapi.APIResponse, error) {
+	return &tgbotapi.APIResponse{Ok: true}, nil
+}
 
 // last returns the most recent message.
 func (m *fakeAPI) last() string {
@@ -175,7 +188,7 @@ func TestIntegrationCommands(t *testing.T) {
 		chatID := int64(100)
 
 		// клиент ввел /start -> получил полный текст приветствия
-		cmd(t, api, "/start", func() { b.handleStart(chatID) },
+		cmd(t, api, "/start", func() { b.handleStart(context.Background(), chatID) },
 			`Welcome to RSS Notify Bot!
 
 Subscribe to RSS feeds and get filtered notifications.
@@ -239,6 +252,7 @@ Filters: 1 include, 1 exclude`)
 		}, `#1 DevOps Weekly [active]
 URL: https://devops.example.com/rss
 Interval: every 15 min
+Backlog: newest 5
 
 Filters:
 
@@ -274,6 +288,7 @@ Exclude (word):
 		}, fmt.Sprintf(`#1 DevOps Weekly [active]
 URL: https://devops.example.com/rss
 Interval: every 15 min
+Backlog: newest 5
 Last check: %s
 
 Filters:
@@ -540,7 +555,7 @@ func TestIntegrationCallbacks(t *testing.T) {
 	testUser := &tgbotapi.User{ID: 42, UserName: "testuser"}
 
 	t.Run("filters callback", func(t *testing.T) {
-		b, api, _ := setupBot(t, sampleRSSFeed)
+		b, api, store := setupBot(t, sampleRSSFeed)
 		chatID := int64(100)
 
 		b.handleAdd(ctx, chatID, "https://devops.example.com/rss")
@@ -549,7 +564,7 @@ func TestIntegrationCallbacks(t *testing.T) {
 		cb := &tgbotapi.CallbackQuery{
 			ID:   "cb1",
 			From: testUser,
-			Data: "filters:1",
+			Data: register(t, store, model.Callback{ChatID: chatID, Action: model.CallbackFilters, Arg: "1"}),
 			Message: &tgbotapi.Message{
 				Chat: &tgbotapi.Chat{ID: chatID},
 			},
@@ -578,7 +593,7 @@ Include (word):
 		cb := &tgbotapi.CallbackQuery{
 			ID:   "cb2",
 			From: testUser,
-			Data: "delete:1",
+			Data: register(t, store, model.Callback{ChatID: chatID, Action: model.CallbackDelete, FeedID: feeds[0].ID}),
 			Message: &tgbotapi.Message{
 				Chat: &tgbotapi.Chat{ID: chatID},
 			},
@@ -611,7 +626,7 @@ Include (word):
 		cb := &tgbotapi.CallbackQuery{
 			ID:   "cb4",
 			From: testUser,
-			Data: "rmfilter:1",
+			Data: register(t, store, model.Callback{ChatID: chatID, Action: model.CallbackRmFilter, Arg: "1"}),
 			Message: &tgbotapi.Message{
 				Chat: &tgbotapi.Chat{ID: chatID},
 			},
@@ -620,7 +635,7 @@ Include (word):
 		want := `Filter F1 removed from #1 "DevOps Weekly".
 
 No filters for #1 "DevOps Weekly".
-Use /include, /exclude, /include_re, /exclude_re to add filters.`
+Use /include, /exclude, /include_re, /exclude_re, /query to add filters.`
 		if got := strings.TrimSpace(api.last()); got != want {
 			t.Errorf("callback rmfilter:\n  want: %q\n  got: %q", want, got)
 		}
@@ -655,7 +670,7 @@ console.log(foo);</code></pre>
 		cb := &tgbotapi.CallbackQuery{
 			ID:   "cb5",
 			From: testUser,
-			Data: "show_more:1:item-1",
+			Data: register(t, s, model.Callback{ChatID: chatID, Action: model.CallbackShowMore, FeedID: feeds[0].ID, GUID: "item-1"}),
 			Message: &tgbotapi.Message{
 				Chat: &tgbotapi.Chat{ID: chatID},
 			},
@@ -665,7 +680,7 @@ console.log(foo);</code></pre>
 
 DevOps Weekly
 
-This is the full content of the article.
+This is the <b>full content</b> of the article.
 
 It contains multiple paragraphs:
 
@@ -675,8 +690,9 @@ It contains multiple paragraphs:
 
 And a code block:
 
-const foo = "bar";
-console.log(foo);
+<pre>const foo = "bar";
+console.log(foo);</pre>
+
 End of article.`
 		if got := api.last(); got != want {
 			t.Errorf("callback show_more:\n  want: %q\n  got: %q", want, got)
@@ -684,15 +700,16 @@ End of article.`
 	})
 
 	t.Run("show more not found", func(t *testing.T) {
-		b, api, _ := setupBot(t, sampleRSSFeed)
+		b, api, store := setupBot(t, sampleRSSFeed)
 		chatID := int64(100)
 
 		b.handleAdd(ctx, chatID, "https://devops.example.com/rss")
+		feeds, _ := store.ListFeeds(ctx, chatID)
 
 		cb := &tgbotapi.CallbackQuery{
 			ID:   "cb6",
 			From: testUser,
-			Data: "show_more:1:non-existent-item",
+			Data: register(t, store, model.Callback{ChatID: chatID, Action: model.CallbackShowMore, FeedID: feeds[0].ID, GUID: "non-existent-item"}),
 			Message: &tgbotapi.Message{
 				Chat: &tgbotapi.Chat{ID: chatID},
 			},
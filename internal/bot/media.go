@@ -0,0 +1,129 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"rss_bot/internal/model"
+	"rss_bot/internal/text"
+)
+
+// albumSentError is returned when an album was sent but the text that
+// follows it was not.
+type albumSentError struct {
+	err error
+}
+
+func (e *albumSentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *albumSentError) Unwrap() error {
+	return e.err
+}
+
+// sendNotification sends a notification with its attachments. A single
+// attachment carries the text as its caption; an album is followed by the
+// text as a separate message, since albums cannot have a keyboard. If
+// Telegram cannot fetch the files, the text is sent on its own. A failure to
+// send the text after the album is an *albumSentError.
+func (b *Bot) sendNotification(ctx context.Context, chatID int64, body string, media []model.Media, markup *tgbotapi.InlineKeyboardMarkup, opts sendOptions) error {
+	switch len(media) {
+	case 0:
+		return b.sendHTML(ctx, chatID, body, markup, opts)
+	case 1:
+		err := b.sendAttachment(ctx, chatID, media[0], body, markup, opts.silent)
+		if !isMediaError(err) {
+			return err
+		}
+		b.log.Warn("attachment rejected, sending text", "chat_id", chatID, "url", media[0].URL, "error", err)
+		return b.sendHTML(ctx, chatID, body, markup, opts)
+	default:
+		err := b.sendAlbum(ctx, chatID, media, opts.silent)
+		switch {
+		case isMediaError(err):
+			b.log.Warn("album rejected, sending text", "chat_id", chatID, "error", err)
+			return b.sendHTML(ctx, chatID, body, markup, opts)
+		case err != nil:
+			return err
+		}
+		if err := b.sendHTML(ctx, chatID, body, markup, opts); err != nil {
+			return &albumSentError{err: err}
+		}
+		return nil
+	}
+}
+
+// sendAttachment sends a single file with an HTML caption, falling back to a
+// plain caption if Telegram rejects the markup.
+func (b *Bot) sendAttachment(ctx context.Context, chatID int64, m model.Media, caption string, markup *tgbotapi.InlineKeyboardMarkup, silent bool) error {
+	caption, _ = text.TruncateHTML(caption, text.MaxCaptionLength)
+	_, err := b.send(ctx, chatID, attachment(chatID, m, caption, tgbotapi.ModeHTML, markup, silent))
+	if isEntityError(err) {
+		b.log.Warn("html rejected, sending plain caption", "chat_id", chatID, "error", err)
+		_, err = b.send(ctx, chatID, attachment(chatID, m, text.StripHTML(caption), "", markup, silent))
+	}
+	return err
+}
+
+func attachment(chatID int64, m model.Media, caption, parseMode string, markup *tgbotapi.InlineKeyboardMarkup, silent bool) tgbotapi.Chattable {
+	file := tgbotapi.FileURL(m.URL)
+	switch m.Kind {
+	case model.MediaAudio:
+		cfg := tgbotapi.NewAudio(chatID, file)
+		cfg.Caption, cfg.ParseMode, cfg.Duration = caption, parseMode, m.Duration
+		setBase(&cfg.BaseChat, markup, silent)
+		return cfg
+	case model.MediaVideo:
+		cfg := tgbotapi.NewVideo(chatID, file)
+		cfg.Caption, cfg.ParseMode, cfg.Duration = caption, parseMode, m.Duration
+		cfg.SupportsStreaming = true
+		setBase(&cfg.BaseChat, markup, silent)
+		return cfg
+	case model.MediaDocument:
+		cfg := tgbotapi.NewDocument(chatID, file)
+		cfg.Caption, cfg.ParseMode = caption, parseMode
+		setBase(&cfg.BaseChat, markup, silent)
+		return cfg
+	default:
+		cfg := tgbotapi.NewPhoto(chatID, file)
+		cfg.Caption, cfg.ParseMode = caption, parseMode
+		setBase(&cfg.BaseChat, markup, silent)
+		return cfg
+	}
+}
+
+func setBase(chat *tgbotapi.BaseChat, markup *tgbotapi.InlineKeyboardMarkup, silent bool) {
+	if markup != nil {
+		chat.ReplyMarkup = markup
+	}
+	chat.DisableNotification = silent
+}
+
+// sendAlbum sends photos as a media group.
+func (b *Bot) sendAlbum(ctx context.Context, chatID int64, photos []model.Media, silent bool) error {
+	files := make([]interface{}, 0, len(photos))
+	for _, p := range photos {
+		files = append(files, tgbotapi.NewInputMediaPhoto(tgbotapi.FileURL(p.URL)))
+	}
+	if err := b.limiter.wait(ctx, chatID); err != nil {
+		return err
+	}
+	group := tgbotapi.NewMediaGroup(chatID, files)
+	group.DisableNotification = silent
+	_, err := b.api.SendMediaGroup(group)
+	if err != nil {
+		b.metrics.SendError(sendErrorType(err))
+	}
+	return err
+}
+
+// isMediaError reports whether Telegram refused a request for a reason other
+// than its markup, typically because it could not fetch or use a file.
+func isMediaError(err error) bool {
+	var apiErr *tgbotapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusBadRequest && !isEntityError(err)
+}
@@ -0,0 +1,180 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"path"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"rss_bot/internal/model"
+	"rss_bot/internal/opml"
+)
+
+const maxImportSize = 1024 * 1024
+
+// maxImportFeeds is the largest number of feeds an OPML import may hold.
+const maxImportFeeds = 200
+
+func (b *Bot) handleExport(ctx context.Context, chatID int64) {
+	lang := b.lang(ctx, chatID)
+	feeds, err := b.store.ListFeeds(ctx, chatID)
+	if err != nil {
+		b.reply(chatID, lang.Sprintf("Error: %v", err))
+		return
+	}
+	if len(feeds) == 0 {
+		b.reply(chatID, lang.T("You have no feeds to export. Use /add <url> to add one."))
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := opml.Export(&buf, "RSS Notify Bot feeds", feeds); err != nil {
+		b.reply(chatID, lang.Sprintf("Error: %v", err))
+		return
+	}
+
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: "feeds.opml", Bytes: buf.Bytes()})
+	doc.Caption = lang.Sprintf("%d feed(s) exported.", len(feeds))
+	if _, err := b.send(ctx, chatID, doc); err != nil {
+		b.log.Error("send export", "chat_id", chatID, "error", err)
+		b.reply(chatID, lang.T("Failed to send the OPML file."))
+	}
+}
+
+func (b *Bot) handleDocument(ctx context.Context, msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	doc := msg.Document
+
+	b.log.Info("document",
+		"file_name", doc.FileName,
+		"mime_type", doc.MimeType,
+		"size", doc.FileSize,
+		"chat_id", chatID,
+		"user_id", msg.From.ID,
+	)
+
+	b.rememberLanguage(ctx, chatID, msg.From)
+	lang := b.lang(ctx, chatID)
+	if !isOPMLFile(doc) {
+		b.reply(chatID, lang.T("Only OPML files can be imported. Use /export to see the expected format."))
+		return
+	}
+	if doc.FileSize > maxImportSize {
+		b.reply(chatID, lang.T("The OPML file is too large (max 1 MB)."))
+		return
+	}
+
+	// The file URL contains the bot token, so download errors are only logged.
+	fileURL, err := b.api.GetFileDirectURL(doc.FileID)
+	if err != nil {
+		b.log.Error("get import file", "chat_id", chatID, "error", err)
+		b.reply(chatID, lang.T("Failed to download file."))
+		return
+	}
+	data, err := b.fetcher.Download(ctx, fileURL, maxImportSize)
+	if err != nil {
+		b.log.Error("download import file", "chat_id", chatID, "error", err)
+		b.reply(chatID, lang.T("Failed to download file."))
+		return
+	}
+
+	b.importOPML(ctx, chatID, data)
+}
+
+// importOPML subscribes the chat to every feed of an OPML document that it
+// does not follow yet and replies with a summary.
+func (b *Bot) importOPML(ctx context.Context, chatID int64, data []byte) {
+	lang := b.lang(ctx, chatID)
+	subs, err := opml.Import(bytes.NewReader(data))
+	if err != nil {
+		b.reply(chatID, lang.Sprintf("Failed to read OPML: %v", err))
+		return
+	}
+	if len(subs) == 0 {
+		b.reply(chatID, lang.T("No feeds found in the OPML file."))
+		return
+	}
+	if len(subs) > maxImportFeeds {
+		b.reply(chatID, lang.Sprintf("The OPML file has %d feeds, at most %d can be imported at once.", len(subs), maxImportFeeds))
+		return
+	}
+
+	existing, err := b.store.ListFeeds(ctx, chatID)
+	if err != nil {
+		b.reply(chatID, lang.Sprintf("Error: %v", err))
+		return
+	}
+	known := make(map[string]bool, len(existing))
+	for _, f := range existing {
+		known[f.URL] = true
+	}
+
+	settings := b.chatSettings(ctx, chatID)
+	var added, duplicates, invalid, failed int
+	for _, sub := range subs {
+		if !isHTTPURL(sub.URL) {
+			invalid++
+			continue
+		}
+		if known[sub.URL] {
+			duplicates++
+			continue
+		}
+		known[sub.URL] = true
+
+		name := sub.Name
+		if name == "" {
+			name = sub.URL
+		}
+		interval := sub.IntervalMinutes
+		if interval < 1 || interval > 1440 {
+			interval = settings.IntervalMinutes
+		}
+
+		f := &model.Feed{
+			ChatID:          chatID,
+			Name:            name,
+			URL:             sub.URL,
+			IntervalMinutes: interval,
+			IsActive:        true,
+		}
+		if err := b.store.CreateFeed(ctx, f); err != nil {
+			b.log.Error("import feed", "chat_id", chatID, "url", sub.URL, "error", err)
+			failed++
+			continue
+		}
+		added++
+	}
+
+	b.reply(chatID, FormatImportSummary(lang, added, duplicates, invalid, failed))
+}
+
+// isImport reports whether a message is a document to import: any document
+// sent in a private chat, or one captioned /import. Files shared in groups are
+// not the bot's business unless they are meant for it.
+func isImport(msg *tgbotapi.Message) bool {
+	if msg.Document == nil {
+		return false
+	}
+	if msg.Chat.IsPrivate() {
+		return true
+	}
+	cmd, _, _ := strings.Cut(strings.TrimSpace(msg.Caption), " ")
+	cmd, _, _ = strings.Cut(cmd, "@")
+	return cmd == "/import"
+}
+
+// isHTTPURL reports whether an imported feed URL is one the fetcher can
+// download: an absolute http or https URL.
+func isHTTPURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func isOPMLFile(doc *tgbotapi.Document) bool {
+	ext := strings.ToLower(path.Ext(doc.FileName))
+	return ext == ".opml" || ext == ".xml" || strings.Contains(strings.ToLower(doc.MimeType), "opml")
+}
@@ -1,10 +1,10 @@
 package bot
 
 import (
-	"fmt"
 	"strconv"
 	"strings"
 
+	"rss_bot/internal/i18n"
 	"rss_bot/internal/model"
 )
 
@@ -16,19 +16,20 @@ type FilterArgs struct {
 }
 
 // ParseFilterCommand parses arguments for /include, /exclude, etc.
-// Format: <feed_position> [-s title|content|all] <value...>
-func ParseFilterCommand(args string) (FilterArgs, error) {
+// Format: <feed_position> [-s scope] <value...>
+// Without -s the filter gets defaultScope.
+func ParseFilterCommand(args string, defaultScope model.FilterScope) (FilterArgs, error) {
 	parts := strings.Fields(args)
 	if len(parts) < 2 {
-		return FilterArgs{}, fmt.Errorf("usage: <feed_number> [-s title|content|all] <value>")
+		return FilterArgs{}, i18n.Errorf("usage: <feed_number> [-s scope] <value>")
 	}
 
 	feedPos, err := strconv.Atoi(parts[0])
 	if err != nil {
-		return FilterArgs{}, fmt.Errorf("invalid feed number %q", parts[0])
+		return FilterArgs{}, i18n.Errorf("invalid feed number %q", parts[0])
 	}
 
-	scope := model.ScopeAll
+	scope := defaultScope
 	rest := parts[1:]
 
 	if len(rest) >= 2 && rest[0] == "-s" {
@@ -39,14 +40,22 @@ func ParseFilterCommand(args string) (FilterArgs, error) {
 			scope = model.ScopeContent
 		case "all":
 			scope = model.ScopeAll
+		case "author":
+			scope = model.ScopeAuthor
+		case "categories":
+			scope = model.ScopeCategories
+		case "link":
+			scope = model.ScopeLink
+		case "enclosures":
+			scope = model.ScopeEnclosures
 		default:
-			return FilterArgs{}, fmt.Errorf("invalid scope %q, use: title, content, all", rest[1])
+			return FilterArgs{}, i18n.Errorf("invalid scope %q, use: title, content, all, author, categories, link, enclosures", rest[1])
 		}
 		rest = rest[2:]
 	}
 
 	if len(rest) == 0 {
-		return FilterArgs{}, fmt.Errorf("filter value is required")
+		return FilterArgs{}, i18n.Errorf("filter value is required")
 	}
 
 	return FilterArgs{
@@ -60,11 +69,11 @@ func ParseFilterCommand(args string) (FilterArgs, error) {
 func ParseFeedArg(args string) (int, error) {
 	s := strings.TrimSpace(args)
 	if s == "" {
-		return 0, fmt.Errorf("feed number is required")
+		return 0, i18n.Errorf("feed number is required")
 	}
 	n, err := strconv.Atoi(strings.Fields(s)[0])
 	if err != nil {
-		return 0, fmt.Errorf("invalid feed number %q", s)
+		return 0, i18n.Errorf("invalid feed number %q", s)
 	}
 	return n, nil
 }
@@ -73,11 +82,11 @@ func ParseFeedArg(args string) (int, error) {
 func ParseFilterArg(args string) (int, error) {
 	s := strings.TrimSpace(args)
 	if s == "" {
-		return 0, fmt.Errorf("filter number is required")
+		return 0, i18n.Errorf("filter number is required")
 	}
 	n, err := strconv.Atoi(strings.Fields(s)[0])
 	if err != nil {
-		return 0, fmt.Errorf("invalid filter number %q", s)
+		return 0, i18n.Errorf("invalid filter number %q", s)
 	}
 	return n, nil
 }
@@ -86,15 +95,15 @@ func ParseFilterArg(args string) (int, error) {
 func ParseRenameArgs(args string) (int, string, error) {
 	parts := strings.SplitN(strings.TrimSpace(args), " ", 2)
 	if len(parts) < 2 {
-		return 0, "", fmt.Errorf("usage: /rename <number> <new_name>")
+		return 0, "", i18n.Errorf("usage: /rename <number> <new_name>")
 	}
 	n, err := strconv.Atoi(parts[0])
 	if err != nil {
-		return 0, "", fmt.Errorf("invalid feed number %q", parts[0])
+		return 0, "", i18n.Errorf("invalid feed number %q", parts[0])
 	}
 	name := strings.TrimSpace(parts[1])
 	if name == "" {
-		return 0, "", fmt.Errorf("new name cannot be empty")
+		return 0, "", i18n.Errorf("new name cannot be empty")
 	}
 	return n, name, nil
 }
@@ -103,15 +112,15 @@ func ParseRenameArgs(args string) (int, string, error) {
 func ParseIntervalArgs(args string) (int, int, error) {
 	parts := strings.Fields(args)
 	if len(parts) < 2 {
-		return 0, 0, fmt.Errorf("usage: /interval <number> <minutes>")
+		return 0, 0, i18n.Errorf("usage: /interval <number> <minutes>")
 	}
 	n, err := strconv.Atoi(parts[0])
 	if err != nil {
-		return 0, 0, fmt.Errorf("invalid feed number %q", parts[0])
+		return 0, 0, i18n.Errorf("invalid feed number %q", parts[0])
 	}
 	mins, err := strconv.Atoi(parts[1])
 	if err != nil || mins < 1 || mins > 1440 {
-		return 0, 0, fmt.Errorf("interval must be between 1 and 1440 minutes")
+		return 0, 0, i18n.Errorf("interval must be between 1 and 1440 minutes")
 	}
 	return n, mins, nil
 }
@@ -0,0 +1,92 @@
+package bot
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"rss_bot/internal/model"
+	"rss_bot/internal/quiet"
+)
+
+func (b *Bot) handleQuiet(ctx context.Context, chatID int64, args string) {
+	lang := b.lang(ctx, chatID)
+	usage := lang.T("Usage: /quiet <HH:MM-HH:MM|off> | /quiet <hold|silent> | /quiet <number> <hold|silent|default>")
+
+	settings, err := b.store.GetChatSettings(ctx, chatID)
+	if err != nil {
+		b.reply(chatID, lang.Sprintf("Error: %v", err))
+		return
+	}
+
+	parts := strings.Fields(args)
+	switch {
+	case len(parts) == 0:
+		feeds, err := b.store.ListFeeds(ctx, chatID)
+		if err != nil {
+			b.reply(chatID, lang.Sprintf("Error: %v", err))
+			return
+		}
+		b.reply(chatID, FormatQuietSettings(lang, settings, feeds))
+		return
+	case len(parts) == 2 && !strings.Contains(parts[0], ":"):
+		b.setFeedQuiet(ctx, chatID, parts[0], parts[1], usage)
+		return
+	case parts[0] == "off":
+		settings.QuietStart, settings.QuietEnd = "", ""
+	case parts[0] == string(model.QuietHold), parts[0] == string(model.QuietSilent):
+		settings.QuietMode = model.QuietMode(parts[0])
+	default:
+		start, end, err := quiet.ParseWindow(strings.Join(parts, ""))
+		if err != nil {
+			b.reply(chatID, lang.Sprintf("Invalid quiet hours: %s.\n%s", lang.Error(err), usage))
+			return
+		}
+		settings.QuietStart, settings.QuietEnd = start, end
+	}
+
+	if err := b.store.SaveChatSettings(ctx, settings); err != nil {
+		b.reply(chatID, lang.Sprintf("Error: %v", err))
+		return
+	}
+	if settings.QuietStart == "" {
+		b.reply(chatID, lang.T("Quiet hours turned off."))
+		return
+	}
+	b.reply(chatID, lang.Sprintf("Quiet hours: %s-%s (%s), notifications %s.",
+		settings.QuietStart, settings.QuietEnd, settings.Timezone, FormatQuietMode(lang, quietMode(settings.QuietMode))))
+}
+
+// setFeedQuiet sets what happens to a single feed's notifications during
+// quiet hours.
+func (b *Bot) setFeedQuiet(ctx context.Context, chatID int64, arg, value, usage string) {
+	lang := b.lang(ctx, chatID)
+	pos, err := strconv.Atoi(arg)
+	if err != nil {
+		b.reply(chatID, usage)
+		return
+	}
+
+	var mode model.QuietMode
+	switch value {
+	case string(model.QuietHold), string(model.QuietSilent):
+		mode = model.QuietMode(value)
+	case "default":
+		mode = model.QuietDefault
+	default:
+		b.reply(chatID, usage)
+		return
+	}
+
+	feed, err := b.store.GetFeedByPosition(ctx, chatID, pos)
+	if err != nil {
+		b.reply(chatID, lang.Sprintf("Feed #%d not found.", pos))
+		return
+	}
+	feed.Quiet = mode
+	if err := b.store.UpdateFeed(ctx, feed); err != nil {
+		b.reply(chatID, lang.Sprintf("Error: %v", err))
+		return
+	}
+	b.reply(chatID, lang.Sprintf("During quiet hours, notifications of #%d \"%s\": %s.", pos, feed.Name, FormatQuietMode(lang, mode)))
+}
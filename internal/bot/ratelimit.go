@@ -0,0 +1,141 @@
+package bot
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Telegram's documented limits: about 30 messages per second overall, one
+// message per second to the same chat and 20 messages per minute to a group.
+const (
+	globalRate  = 30
+	globalBurst = 30
+	chatRate    = 1
+	groupRate   = 20.0 / 60
+	chatBurst   = 1
+
+	// maxIdleBuckets bounds how many per-chat buckets are kept before idle
+	// ones are dropped.
+	maxIdleBuckets = 1000
+)
+
+// bucket is a token bucket. Tokens may go negative: every reservation takes a
+// token immediately and the caller waits until the debt is paid off.
+type bucket struct {
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate, burst float64, now time.Time) *bucket {
+	return &bucket{rate: rate, burst: burst, tokens: burst, last: now}
+}
+
+// reserve takes a token and returns how long to wait before using it.
+func (b *bucket) reserve(now time.Time) time.Duration {
+	b.refill(now)
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel returns a token that was reserved but not used.
+func (b *bucket) cancel() {
+	b.tokens = min(b.tokens+1, b.burst)
+}
+
+func (b *bucket) refill(now time.Time) {
+	if now.After(b.last) {
+		b.tokens = min(b.tokens+now.Sub(b.last).Seconds()*b.rate, b.burst)
+		b.last = now
+	}
+}
+
+func (b *bucket) full(now time.Time) bool {
+	b.refill(now)
+	return b.tokens >= b.burst
+}
+
+// sendLimiter paces outgoing Telegram requests with a global bucket and one
+// bucket per chat, so that a burst to one chat does not hold up the others.
+// A nil *sendLimiter does not limit.
+type sendLimiter struct {
+	mu     sync.Mutex
+	global *bucket
+	chats  map[int64]*bucket
+}
+
+func newSendLimiter() *sendLimiter {
+	return &sendLimiter{
+		global: newBucket(globalRate, globalBurst, time.Now()),
+		chats:  make(map[int64]*bucket),
+	}
+}
+
+// wait blocks until a message to chatID may be sent. A zero chatID, used for
+// requests that are not chat messages, only counts against the global bucket.
+func (l *sendLimiter) wait(ctx context.Context, chatID int64) error {
+	if l == nil {
+		return nil
+	}
+	// The chat bucket is waited on first, without holding a global token,
+	// so a busy chat only slows itself down.
+	if chatID != 0 {
+		if err := l.take(ctx, func() *bucket { return l.chat(chatID) }); err != nil {
+			return err
+		}
+	}
+	return l.take(ctx, func() *bucket { return l.global })
+}
+
+func (l *sendLimiter) take(ctx context.Context, get func() *bucket) error {
+	l.mu.Lock()
+	b := get()
+	delay := b.reserve(time.Now())
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		b.cancel()
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// chat returns the bucket of chatID. The caller must hold l.mu.
+func (l *sendLimiter) chat(chatID int64) *bucket {
+	b, ok := l.chats[chatID]
+	if ok {
+		return b
+	}
+
+	now := time.Now()
+	if len(l.chats) >= maxIdleBuckets {
+		for id, c := range l.chats {
+			if c.full(now) {
+				delete(l.chats, id)
+			}
+		}
+	}
+
+	rate := float64(chatRate)
+	if chatID < 0 {
+		// Groups, supergroups and channels have negative IDs.
+		rate = groupRate
+	}
+	b = newBucket(rate, chatBurst, now)
+	l.chats[chatID] = b
+	return b
+}
@@ -0,0 +1,77 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBucketReserve(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := newBucket(2, 2, start)
+
+	// The burst is available at once, then tokens arrive every 1/rate.
+	var got []time.Duration
+	for range 4 {
+		got = append(got, b.reserve(start))
+	}
+	want := []time.Duration{0, 0, 500 * time.Millisecond, time.Second}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("delays (-want +got):\n%s", diff)
+	}
+
+	// After the debt is paid and the bucket refilled, the burst is back.
+	later := start.Add(2 * time.Second)
+	if !b.full(later) {
+		t.Error("bucket should be full again")
+	}
+	if diff := cmp.Diff(time.Duration(0), b.reserve(later)); diff != "" {
+		t.Errorf("delay after refill (-want +got):\n%s", diff)
+	}
+}
+
+func TestSendLimiterPerChat(t *testing.T) {
+	ctx := context.Background()
+	l := newSendLimiter()
+
+	if err := l.wait(ctx, 100); err != nil {
+		t.Fatalf("first wait: %v", err)
+	}
+
+	// Chat 100 has used its token; another chat is not held up by it.
+	start := time.Now()
+	if err := l.wait(ctx, 200); err != nil {
+		t.Fatalf("other chat: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("other chat waited %v", elapsed)
+	}
+
+	// A second message to chat 100 has to wait; give up quickly.
+	short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := l.wait(short, 100); err == nil {
+		t.Error("second message to the same chat should wait")
+	}
+}
+
+func TestSendLimiterGroupRate(t *testing.T) {
+	l := newSendLimiter()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if diff := cmp.Diff(float64(chatRate), l.chat(100).rate); diff != "" {
+		t.Errorf("private chat rate (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(groupRate, l.chat(-100).rate); diff != "" {
+		t.Errorf("group rate (-want +got):\n%s", diff)
+	}
+}
+
+func TestNilSendLimiter(t *testing.T) {
+	var l *sendLimiter
+	if err := l.wait(context.Background(), 100); err != nil {
+		t.Errorf("nil limiter: %v", err)
+	}
+}
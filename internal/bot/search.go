@@ -0,0 +1,150 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"rss_bot/internal/digest"
+	"rss_bot/internal/filter"
+	"rss_bot/internal/i18n"
+	"rss_bot/internal/model"
+)
+
+// searchPageSize is how many archived items a page of search results shows.
+const searchPageSize = 5
+
+const searchExample = `kubernetes AND (security OR "CVE") AND NOT title:webinar`
+
+// searchMatch translates a query in the syntax of /query filters into an
+// FTS5 expression for the archive.
+func searchMatch(query string) (string, error) {
+	q, err := filter.ParseQuery(query, model.ScopeAll)
+	if err != nil {
+		return "", fmt.Errorf("invalid query: %w", err)
+	}
+	match, err := q.FTS()
+	if err != nil {
+		return "", fmt.Errorf("invalid query: %w", err)
+	}
+	return match, nil
+}
+
+// handleSearch looks up the items delivered to the chat. The query has the
+// syntax of /query filters.
+func (b *Bot) handleSearch(ctx context.Context, chatID int64, args string) {
+	lang := b.lang(ctx, chatID)
+	if args == "" {
+		b.reply(chatID, lang.Sprintf("Usage: /search <query>\nExample: /search %s", searchExample))
+		return
+	}
+	if _, err := searchMatch(args); err != nil {
+		b.reply(chatID, lang.Sprintf("%v\n\nExample: /search %s", err, searchExample))
+		return
+	}
+
+	text, markup, err := b.searchPage(ctx, lang, chatID, args, 0)
+	if err != nil {
+		b.log.Error("search archive", "chat_id", chatID, "error", err)
+		b.reply(chatID, lang.T("Search failed. Please try again later."))
+		return
+	}
+	if markup == nil {
+		b.reply(chatID, text)
+		return
+	}
+	b.SendMessageWithKeyboard(chatID, text, markup)
+}
+
+// handleSearchButton shows another page of search results in place. The
+// argument is the page number followed by the query.
+func (b *Bot) handleSearchButton(ctx context.Context, chatID int64, messageID int, arg string) {
+	lang := b.lang(ctx, chatID)
+	n, query, _ := strings.Cut(arg, " ")
+	page, err := strconv.Atoi(n)
+	if err != nil || page < 0 {
+		b.reply(chatID, lang.T("This button has expired."))
+		return
+	}
+
+	text, markup, err := b.searchPage(ctx, lang, chatID, query, page)
+	if err != nil {
+		b.log.Error("search archive", "chat_id", chatID, "error", err)
+		b.reply(chatID, lang.T("Search failed. Please try again later."))
+		return
+	}
+	if markup == nil {
+		markup = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+	}
+	b.editMessage(ctx, chatID, messageID, text, markup)
+}
+
+// searchPage returns a page of search results, counted from 0, with buttons
+// to turn the pages, or a nil keyboard when everything fits on one page.
+func (b *Bot) searchPage(ctx context.Context, lang i18n.Lang, chatID int64, query string, page int) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	match, err := searchMatch(query)
+	if err != nil {
+		return "", nil, err
+	}
+	items, total, err := b.store.SearchArchive(ctx, chatID, match, searchPageSize, page*searchPageSize)
+	if err != nil {
+		return "", nil, err
+	}
+	loc := digest.Location(b.chatSettings(ctx, chatID).Timezone)
+	text := FormatSearchResults(lang, items, page, total, loc)
+
+	pages := (total + searchPageSize - 1) / searchPageSize
+	if pages <= 1 {
+		return text, nil, nil
+	}
+	markup, err := b.searchKeyboard(ctx, lang, chatID, query, page, pages)
+	if err != nil {
+		return "", nil, err
+	}
+	return text, markup, nil
+}
+
+// searchKeyboard turns the pages of search results: newer items are on the
+// earlier pages.
+func (b *Bot) searchKeyboard(ctx context.Context, lang i18n.Lang, chatID int64, query string, page, pages int) (*tgbotapi.InlineKeyboardMarkup, error) {
+	button := func(label string, to int) (tgbotapi.InlineKeyboardButton, error) {
+		btn, err := newButton(ctx, b.store, label, model.Callback{
+			ChatID: chatID,
+			Action: model.CallbackSearch,
+			Arg:    strconv.Itoa(to) + " " + query,
+		}, promptTTL)
+		if err != nil {
+			return btn, fmt.Errorf("register search page: %w", err)
+		}
+		return btn, nil
+	}
+
+	var row []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		prev, err := button(lang.T("« Newer"), page-1)
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, prev)
+	}
+	counter, err := newButton(ctx, b.store, fmt.Sprintf("%d/%d", page+1, pages), model.Callback{
+		ChatID: chatID,
+		Action: model.CallbackNoop,
+	}, promptTTL)
+	if err != nil {
+		return nil, fmt.Errorf("register page counter: %w", err)
+	}
+	row = append(row, counter)
+	if page < pages-1 {
+		next, err := button(lang.T("Older »"), page+1)
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, next)
+	}
+	markup := tgbotapi.NewInlineKeyboardMarkup(row)
+	return &markup, nil
+}
@@ -0,0 +1,226 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"rss_bot/internal/i18n"
+	"rss_bot/internal/model"
+)
+
+// Buttons of the /settings editor. Pressing one moves the setting to its next
+// choice, except for the time zone, which opens a list of zones.
+const (
+	settingLanguage = "lang"
+	settingTimezone = "tz"
+	settingInterval = "interval"
+	settingScope    = "scope"
+	settingPreview  = "preview"
+	settingImages   = "images"
+	settingLinks    = "links"
+)
+
+var (
+	intervalChoices = []int{5, 15, 30, 60, 180, 720, 1440}
+	previewChoices  = []int{300, 700, 1500, 3000}
+	// languageChoices start with automatic detection.
+	languageChoices = []string{"", string(i18n.English), string(i18n.Russian)}
+	// timezoneChoices are offered by the editor; /timezone accepts any zone.
+	timezoneChoices = []string{
+		"UTC", "Europe/London", "Europe/Berlin", "Europe/Moscow",
+		"America/New_York", "America/Los_Angeles", "Asia/Kolkata", "Asia/Tokyo",
+	}
+)
+
+func (b *Bot) handleSettings(ctx context.Context, chatID int64) {
+	lang := b.lang(ctx, chatID)
+	settings, err := b.store.GetChatSettings(ctx, chatID)
+	if err != nil {
+		b.reply(chatID, lang.Sprintf("Error: %v", err))
+		return
+	}
+	markup, err := b.settingsKeyboard(ctx, settings)
+	if err != nil {
+		b.reply(chatID, lang.Sprintf("Error: %v", err))
+		return
+	}
+	b.SendMessageWithKeyboard(chatID, FormatSettings(lang, settings), markup)
+}
+
+// handleSettingsButton applies a button of the settings editor and updates
+// the editor message in place. The argument is a setting, "tz=<zone>" for a
+// chosen time zone, or empty to go back to the settings.
+func (b *Bot) handleSettingsButton(ctx context.Context, chatID int64, messageID int, arg string) {
+	settings, err := b.store.GetChatSettings(ctx, chatID)
+	if err != nil {
+		b.reply(chatID, b.lang(ctx, chatID).Sprintf("Error: %v", err))
+		return
+	}
+
+	if arg == settingTimezone {
+		lang := ChatLanguage(settings)
+		markup, err := b.timezoneKeyboard(ctx, lang, chatID)
+		if err != nil {
+			b.reply(chatID, lang.Sprintf("Error: %v", err))
+			return
+		}
+		b.editMessage(ctx, chatID, messageID,
+			lang.Sprintf("Time zone: %s\nPick a time zone, or send /timezone <Area/City> for any other.", settings.Timezone), markup)
+		return
+	}
+
+	changed := applySetting(settings, arg)
+	// The editor switches to a newly chosen language right away.
+	lang := ChatLanguage(settings)
+	if changed {
+		if err := b.store.SaveChatSettings(ctx, settings); err != nil {
+			b.reply(chatID, lang.Sprintf("Error: %v", err))
+			return
+		}
+	}
+	markup, err := b.settingsKeyboard(ctx, settings)
+	if err != nil {
+		b.reply(chatID, lang.Sprintf("Error: %v", err))
+		return
+	}
+	b.editMessage(ctx, chatID, messageID, FormatSettings(lang, settings), markup)
+}
+
+// applySetting changes cs as a settings button asks and reports whether
+// anything changed.
+func applySetting(cs *model.ChatSettings, arg string) bool {
+	if zone, ok := strings.CutPrefix(arg, settingTimezone+"="); ok {
+		if !slices.Contains(timezoneChoices, zone) {
+			return false
+		}
+		cs.Timezone = zone
+		return true
+	}
+
+	switch arg {
+	case settingLanguage:
+		cs.Language = nextChoice(languageChoices, cs.Language)
+	case settingInterval:
+		cs.IntervalMinutes = nextChoice(intervalChoices, cs.IntervalMinutes)
+	case settingScope:
+		cs.FilterScope = nextChoice(model.FilterScopes, cs.FilterScope)
+	case settingPreview:
+		cs.PreviewLength = nextChoice(previewChoices, cs.PreviewLength)
+	case settingImages:
+		cs.Images = !cs.Images
+	case settingLinks:
+		cs.LinkPreview = !cs.LinkPreview
+	default:
+		return false
+	}
+	return true
+}
+
+// nextChoice returns the choice after current, wrapping around. A value that
+// is not among the choices moves to the first one.
+func nextChoice[T comparable](choices []T, current T) T {
+	i := slices.Index(choices, current)
+	return choices[(i+1)%len(choices)]
+}
+
+func (b *Bot) settingsKeyboard(ctx context.Context, cs *model.ChatSettings) (*tgbotapi.InlineKeyboardMarkup, error) {
+	lang := ChatLanguage(cs)
+	buttons := []struct {
+		label string
+		arg   string
+	}{
+		{lang.Sprintf("Language: %s", FormatLanguage(lang, cs.Language)), settingLanguage},
+		{lang.Sprintf("Time zone: %s", cs.Timezone), settingTimezone},
+		{lang.Sprintf("Interval: %d min", cs.IntervalMinutes), settingInterval},
+		{lang.Sprintf("Scope: %s", cs.FilterScope), settingScope},
+		{lang.Sprintf("Preview: %d", cs.PreviewLength), settingPreview},
+		{lang.Sprintf("Images: %s", onOff(lang, cs.Images)), settingImages},
+		{lang.Sprintf("Link previews: %s", onOff(lang, cs.LinkPreview)), settingLinks},
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, s := range buttons {
+		button, err := newButton(ctx, b.store, s.label, model.Callback{
+			ChatID: cs.ChatID,
+			Action: model.CallbackSettings,
+			Arg:    s.arg,
+		}, promptTTL)
+		if err != nil {
+			return nil, fmt.Errorf("register setting: %w", err)
+		}
+		// Two buttons per row.
+		if i%2 == 0 {
+			rows = append(rows, nil)
+		}
+		rows[len(rows)-1] = append(rows[len(rows)-1], button)
+	}
+	return &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}, nil
+}
+
+func (b *Bot) timezoneKeyboard(ctx context.Context, lang i18n.Lang, chatID int64) (*tgbotapi.InlineKeyboardMarkup, error) {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, zone := range timezoneChoices {
+		button, err := newButton(ctx, b.store, zone, model.Callback{
+			ChatID: chatID,
+			Action: model.CallbackSettings,
+			Arg:    settingTimezone + "=" + zone,
+		}, choiceTTL)
+		if err != nil {
+			return nil, fmt.Errorf("register time zone: %w", err)
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(button))
+	}
+	back, err := newButton(ctx, b.store, lang.T("Back"), model.Callback{
+		ChatID: chatID,
+		Action: model.CallbackSettings,
+	}, choiceTTL)
+	if err != nil {
+		return nil, fmt.Errorf("register back: %w", err)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(back))
+	return &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}, nil
+}
+
+// editMessage replaces the text and keyboard of a message the bot sent.
+func (b *Bot) editMessage(ctx context.Context, chatID int64, messageID int, text string, markup *tgbotapi.InlineKeyboardMarkup) {
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, *markup)
+	if _, err := b.send(ctx, chatID, edit); err != nil {
+		b.log.Error("edit message", "chat_id", chatID, "message_id", messageID, "error", err)
+	}
+}
+
+func (b *Bot) handleLanguage(ctx context.Context, chatID int64, args string) {
+	settings, err := b.store.GetChatSettings(ctx, chatID)
+	if err != nil {
+		b.reply(chatID, b.lang(ctx, chatID).Sprintf("Error: %v", err))
+		return
+	}
+	lang := ChatLanguage(settings)
+
+	code := strings.ToLower(strings.TrimSpace(args))
+	switch code {
+	case "":
+		b.reply(chatID, lang.Sprintf("Language: %s\nUsage: /language <en|ru|auto>", FormatLanguage(lang, settings.Language)))
+		return
+	case "auto":
+		settings.Language = ""
+	default:
+		l, ok := i18n.Parse(code)
+		if !ok {
+			b.reply(chatID, lang.T("Usage: /language <en|ru|auto>"))
+			return
+		}
+		settings.Language = string(l)
+	}
+
+	if err := b.store.SaveChatSettings(ctx, settings); err != nil {
+		b.reply(chatID, lang.Sprintf("Error: %v", err))
+		return
+	}
+	lang = ChatLanguage(settings)
+	b.reply(chatID, lang.Sprintf("Language set to %s.", FormatLanguage(lang, settings.Language)))
+}
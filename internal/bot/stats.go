@@ -0,0 +1,36 @@
+package bot
+
+import (
+	"context"
+
+	"rss_bot/internal/digest"
+	"rss_bot/internal/retention"
+)
+
+// SetRetention lets admins see the outcome of the latest retention run.
+func (b *Bot) SetRetention(job *retention.Job) {
+	b.retention = job
+}
+
+// handleStats shows admins how much the seen items take up. Other users are
+// told the command does not exist.
+func (b *Bot) handleStats(ctx context.Context, chatID, userID int64) {
+	lang := b.lang(ctx, chatID)
+	if !b.cfg.IsAdmin(userID) {
+		b.reply(chatID, lang.T("Unknown command. Use /help for a list of commands."))
+		return
+	}
+
+	st, err := b.store.SeenStats(ctx)
+	if err != nil {
+		b.log.Error("seen stats", "error", err)
+		b.reply(chatID, lang.T("Could not read the storage statistics."))
+		return
+	}
+	var last *retention.Result
+	if b.retention != nil {
+		last = b.retention.Last()
+	}
+	loc := digest.Location(b.chatSettings(ctx, chatID).Timezone)
+	b.reply(chatID, FormatStorageStats(lang, st, b.cfg.RetentionDays, last, loc))
+}
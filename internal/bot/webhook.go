@@ -0,0 +1,191 @@
+package bot
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// secretHeader carries the webhook secret in every request from Telegram.
+const secretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+const (
+	// maxUpdateSize bounds the body of a webhook request.
+	maxUpdateSize = 1 << 20
+	// webhookQueue is how many received updates may wait to be handled.
+	webhookQueue = 100
+	// webhookShutdownTimeout bounds how long in-flight requests may finish.
+	webhookShutdownTimeout = 5 * time.Second
+)
+
+// runWebhook registers the webhook with Telegram and serves it until ctx is
+// cancelled, then removes it so that the next start may poll again. Updates
+// are handled one at a time, in the order received, as when polling. Updates
+// that were accepted but not handled yet are handled before it returns.
+func (b *Bot) runWebhook(ctx context.Context) error {
+	u, err := url.Parse(b.cfg.WebhookURL)
+	if err != nil {
+		return fmt.Errorf("parse webhook url: %w", err)
+	}
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+
+	updates := make(chan tgbotapi.Update, webhookQueue)
+	stop := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.Handle(path, b.webhookHandler(updates, stop))
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	// Listen before registering, so that Telegram's first request finds us.
+	ln, err := net.Listen("tcp", b.cfg.WebhookListen)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %w", b.cfg.WebhookListen, err)
+	}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.Serve(ln) }()
+
+	if err := b.setWebhook(ctx); err != nil {
+		_ = srv.Close()
+		return err
+	}
+	b.log.Info("webhook registered", "url", u.Redacted(), "listen", ln.Addr().String())
+
+	defer func() {
+		if err := b.deleteWebhook(context.WithoutCancel(ctx)); err != nil {
+			b.log.Error("delete webhook", "error", err)
+		}
+		close(stop)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), webhookShutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			b.log.Error("shut down webhook server", "error", err)
+		}
+		// Telegram won't send the accepted updates again.
+		b.drainUpdates(context.WithoutCancel(ctx), updates)
+	}()
+
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	b.beat()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-serveErr:
+			if errors.Is(err, http.ErrServerClosed) {
+				return nil
+			}
+			return fmt.Errorf("serve webhook: %w", err)
+		case update := <-updates:
+			b.handleUpdate(ctx, update)
+			b.beat()
+		case <-ticker.C:
+			if b.webhookIdle(ctx) {
+				b.beat()
+			}
+		}
+	}
+}
+
+// drainUpdates handles the updates left in the queue.
+func (b *Bot) drainUpdates(ctx context.Context, updates <-chan tgbotapi.Update) {
+	for {
+		select {
+		case update := <-updates:
+			b.handleUpdate(ctx, update)
+		default:
+			return
+		}
+	}
+}
+
+// webhookHandler accepts updates posted by Telegram and queues them. Requests
+// without the configured secret are refused, and so are updates that arrive
+// once stop is closed, so that Telegram delivers them again.
+func (b *Bot) webhookHandler(updates chan<- tgbotapi.Update, stop <-chan struct{}) http.Handler {
+	secret := []byte(b.cfg.WebhookSecret)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(secretHeader)), secret) != 1 {
+			b.log.Warn("webhook request with a wrong secret", "remote_addr", r.RemoteAddr)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var update tgbotapi.Update
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxUpdateSize)).Decode(&update); err != nil {
+			http.Error(w, "invalid update", http.StatusBadRequest)
+			return
+		}
+
+		select {
+		case <-stop:
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+			return
+		default:
+		}
+		select {
+		case updates <- update:
+			w.WriteHeader(http.StatusOK)
+		case <-stop:
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		case <-r.Context().Done():
+			// Telegram gave up and will deliver the update again.
+		}
+	})
+}
+
+// webhookIdle reports whether Telegram has no updates waiting for the
+// webhook. Updates that keep waiting mean that they don't get through.
+func (b *Bot) webhookIdle(ctx context.Context) bool {
+	resp, err := b.request(ctx, "getWebhookInfo", tgbotapi.Params{})
+	if err != nil {
+		b.log.Warn("get webhook info", "error", err)
+		return false
+	}
+	var info tgbotapi.WebhookInfo
+	if err := json.Unmarshal(resp.Result, &info); err != nil {
+		b.log.Warn("decode webhook info", "error", err)
+		return false
+	}
+	if info.PendingUpdateCount > 0 {
+		b.log.Warn("webhook updates waiting", "pending", info.PendingUpdateCount, "last_error", info.LastErrorMessage)
+		return false
+	}
+	return true
+}
+
+// setWebhook registers the webhook. The secret_token parameter is set by
+// hand, since the library's WebhookConfig predates it.
+func (b *Bot) setWebhook(ctx context.Context) error {
+	params := tgbotapi.Params{
+		"url":          b.cfg.WebhookURL,
+		"secret_token": b.cfg.WebhookSecret,
+	}
+	if _, err := b.request(ctx, "setWebhook", params); err != nil {
+		return fmt.Errorf("set webhook: %w", err)
+	}
+	return nil
+}
+
+// deleteWebhook removes the webhook. Updates that arrive meanwhile wait at
+// Telegram for the next start.
+func (b *Bot) deleteWebhook(ctx context.Context) error {
+	_, err := b.request(ctx, "deleteWebhook", tgbotapi.Params{})
+	return err
+}
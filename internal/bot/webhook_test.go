@@ -0,0 +1,193 @@
+package bot
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/google/go-cmp/cmp"
+
+	"rss_bot/internal/metrics"
+)
+
+const startUpdate = `{"update_id":1,"message":{"message_id":5,"from":{"id":42},"chat":{"id":100},
+"text":"/start","entities":[{"type":"bot_command","offset":0,"length":6}]}}`
+
+func TestWebhookHandler(t *testing.T) {
+	b, _, _ := newTestBot(t, "")
+	b.cfg.WebhookSecret = "secret"
+	updates := make(chan tgbotapi.Update, 1)
+	handler := b.webhookHandler(updates, nil)
+
+	tests := []struct {
+		name   string
+		method string
+		secret string
+		body   string
+		want   int
+	}{
+		{"update", http.MethodPost, "secret", startUpdate, http.StatusOK},
+		{"wrong secret", http.MethodPost, "guess", startUpdate, http.StatusUnauthorized},
+		{"no secret", http.MethodPost, "", startUpdate, http.StatusUnauthorized},
+		{"not a post", http.MethodGet, "secret", "", http.StatusMethodNotAllowed},
+		{"not json", http.MethodPost, "secret", "hello", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/telegram", strings.NewReader(tt.body))
+			if tt.secret != "" {
+				req.Header.Set(secretHeader, tt.secret)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if diff := cmp.Diff(tt.want, rec.Code); diff != "" {
+				t.Errorf("status (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	select {
+	case u := <-updates:
+		if u.UpdateID != 1 || u.Message == nil || u.Message.Command() != "start" {
+			t.Errorf("queued update = %+v, want /start", u)
+		}
+	default:
+		t.Error("no update queued")
+	}
+	if len(updates) != 0 {
+		t.Errorf("%d refused update(s) queued", len(updates))
+	}
+}
+
+func TestWebhookShutdown(t *testing.T) {
+	b, api, _ := newTestBot(t, "")
+	b.cfg.WebhookSecret = "secret"
+	updates := make(chan tgbotapi.Update, 1)
+	stop := make(chan struct{})
+	handler := b.webhookHandler(updates, stop)
+
+	post := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/telegram", strings.NewReader(startUpdate))
+		req.Header.Set(secretHeader, "secret")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if diff := cmp.Diff(http.StatusOK, post()); diff != "" {
+		t.Errorf("status before stop (-want +got):\n%s", diff)
+	}
+	close(stop)
+	if diff := cmp.Diff(http.StatusServiceUnavailable, post()); diff != "" {
+		t.Errorf("status after stop (-want +got):\n%s", diff)
+	}
+
+	// The accepted update is still handled.
+	b.drainUpdates(context.Background(), updates)
+	requireContains(t, api.lastText(), "Welcome")
+}
+
+func TestWebhookIdle(t *testing.T) {
+	ctx := context.Background()
+	b, api, _ := newTestBot(t, "")
+	m := metrics.New()
+	b.SetMetrics(m)
+	if !b.webhookIdle(ctx) {
+		t.Error("webhook without waiting updates is not idle")
+	}
+	api.pendingUpdates = 3
+	if b.webhookIdle(ctx) {
+		t.Error("webhook with waiting updates is idle")
+	}
+
+	// A failed request counts as a send error like any other.
+	api.requestErr = &tgbotapi.Error{Code: http.StatusBadGateway, Message: "Bad Gateway"}
+	if b.webhookIdle(ctx) {
+		t.Error("webhook is idle although Telegram could not be asked")
+	}
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if line := `rss_bot_telegram_send_errors_total{type="server"} 1`; !strings.Contains(rec.Body.String(), line+"\n") {
+		t.Errorf("metrics lack %q:\n%s", line, rec.Body.String())
+	}
+}
+
+func TestRunWebhook(t *testing.T) {
+	b, api, _ := newTestBot(t, "")
+
+	// Reserve a free port for the receiver.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	b.cfg.WebhookURL = "https://bot.example.com/telegram"
+	b.cfg.WebhookListen = addr
+	b.cfg.WebhookSecret = "secret"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- b.Run(ctx) }()
+
+	waitFor(t, "webhook registered", func() bool { return len(api.requestsTo("setWebhook")) == 1 })
+	waitFor(t, "heartbeat", func() bool { return !b.LastHeartbeat().IsZero() })
+	want := tgbotapi.Params{"url": "https://bot.example.com/telegram", "secret_token": "secret"}
+	if diff := cmp.Diff(want, api.requestsTo("setWebhook")[0].Params); diff != "" {
+		t.Errorf("setWebhook params (-want +got):\n%s", diff)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "http://"+addr+"/telegram", strings.NewReader(startUpdate))
+	req.Header.Set(secretHeader, "secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("post update: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	waitFor(t, "reply to /start", func() bool { return strings.Contains(api.lastText(), "Welcome") })
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+	if got := len(api.requestsTo("deleteWebhook")); got != 1 {
+		t.Errorf("deleteWebhook called %d times, want 1", got)
+	}
+}
+
+func (m *mockAPI) requestsTo(endpoint string) []apiRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []apiRequest
+	for _, r := range m.requests {
+		if r.Endpoint == endpoint {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -3,19 +3,49 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Config holds the application configuration.
 type Config struct {
-	TelegramBotToken string
-	DatabasePath     string
-	LogLevel         string
-	AllowedUsers     []int64
+	TelegramBotToken   string
+	DatabasePath       string
+	LogLevel           string
+	AllowedUsers       []int64
+	AdminUsers         []int64
+	MaxFeedFailures    int
+	SchedulerWorkers   int
+	PerHostConcurrency int
+	PerHostDelay       time.Duration
+
+	// RetentionDays is how long seen items are kept, unless they are still
+	// in their feed.
+	RetentionDays int
+	// ContentMaxBytes caps the full content stored per item for "Show more".
+	ContentMaxBytes int
+
+	// WebhookURL is the public HTTPS address Telegram posts updates to.
+	// When empty, the bot polls for updates instead.
+	WebhookURL string
+	// WebhookListen is the address the webhook receiver listens on.
+	WebhookListen string
+	// WebhookSecret is sent by Telegram in the X-Telegram-Bot-Api-Secret-Token
+	// header of every webhook request.
+	WebhookSecret string
+
+	// HTTPListen is the address of the HTTP server for /metrics, /healthz and
+	// /readyz. When empty, the server is not started.
+	HTTPListen string
 }
 
+// webhookSecretRe matches the secret tokens Telegram accepts.
+var webhookSecretRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
 // Load reads configuration from environment variables.
 func Load() (*Config, error) {
 	token := os.Getenv("TELEGRAM_BOT_TOKEN")
@@ -33,29 +63,125 @@ func Load() (*Config, error) {
 		logLevel = "info"
 	}
 
-	var allowedUsers []int64
-	if raw := os.Getenv("ALLOWED_USERS"); raw != "" {
-		for _, s := range strings.Split(raw, ",") {
-			s = strings.TrimSpace(s)
-			if s == "" {
-				continue
-			}
-			uid, err := strconv.ParseInt(s, 10, 64)
-			if err != nil {
-				return nil, fmt.Errorf("invalid user ID %q in ALLOWED_USERS: %w", s, err)
-			}
-			allowedUsers = append(allowedUsers, uid)
+	allowedUsers, err := userIDsEnv("ALLOWED_USERS")
+	if err != nil {
+		return nil, err
+	}
+
+	adminUsers, err := userIDsEnv("ADMIN_USERS")
+	if err != nil {
+		return nil, err
+	}
+
+	maxFailures, err := intEnv("MAX_FEED_FAILURES", 10)
+	if err != nil {
+		return nil, err
+	}
+
+	workers, err := intEnv("SCHEDULER_WORKERS", 4)
+	if err != nil {
+		return nil, err
+	}
+
+	perHost, err := intEnv("PER_HOST_CONCURRENCY", 2)
+	if err != nil {
+		return nil, err
+	}
+
+	hostDelay, err := durationEnv("PER_HOST_DELAY", time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	retentionDays, err := intEnv("SEEN_RETENTION_DAYS", 90)
+	if err != nil {
+		return nil, err
+	}
+
+	contentMaxBytes, err := intEnv("CONTENT_MAX_BYTES", 64*1024)
+	if err != nil {
+		return nil, err
+	}
+
+	webhookURL := strings.TrimSpace(os.Getenv("WEBHOOK_URL"))
+	webhookListen := strings.TrimSpace(os.Getenv("WEBHOOK_LISTEN"))
+	webhookSecret := os.Getenv("WEBHOOK_SECRET")
+	if webhookURL != "" {
+		u, err := url.Parse(webhookURL)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return nil, fmt.Errorf("invalid WEBHOOK_URL %q: must be an https URL", webhookURL)
+		}
+		if !webhookSecretRe.MatchString(webhookSecret) {
+			return nil, fmt.Errorf("WEBHOOK_SECRET is required with WEBHOOK_URL: 1-256 characters A-Z, a-z, 0-9, _ and -")
+		}
+		if webhookListen == "" {
+			webhookListen = ":8080"
 		}
 	}
 
 	return &Config{
-		TelegramBotToken: token,
-		DatabasePath:     dbPath,
-		LogLevel:         logLevel,
-		AllowedUsers:     allowedUsers,
+		TelegramBotToken:   token,
+		DatabasePath:       dbPath,
+		LogLevel:           logLevel,
+		AllowedUsers:       allowedUsers,
+		AdminUsers:         adminUsers,
+		MaxFeedFailures:    maxFailures,
+		SchedulerWorkers:   workers,
+		PerHostConcurrency: perHost,
+		PerHostDelay:       hostDelay,
+		RetentionDays:      retentionDays,
+		ContentMaxBytes:    contentMaxBytes,
+		WebhookURL:         webhookURL,
+		WebhookListen:      webhookListen,
+		WebhookSecret:      webhookSecret,
+		HTTPListen:         strings.TrimSpace(os.Getenv("HTTP_LISTEN")),
 	}, nil
 }
 
+// userIDsEnv reads a comma-separated list of Telegram user IDs from the environment.
+func userIDsEnv(key string) ([]int64, error) {
+	var ids []int64
+	for _, s := range strings.Split(os.Getenv(key), ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		uid, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid user ID %q in %s: %w", s, key, err)
+		}
+		ids = append(ids, uid)
+	}
+	return ids, nil
+}
+
+// intEnv reads a positive integer from the environment, falling back to def when unset.
+func intEnv(key string, def int) (int, error) {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid %s %q: must be a positive integer", key, raw)
+	}
+	return n, nil
+}
+
+// durationEnv reads a non-negative duration such as "500ms" from the environment,
+// falling back to def when unset.
+func durationEnv(key string, def time.Duration) (time.Duration, error) {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be a duration like 500ms or 2s", key, raw)
+	}
+	return d, nil
+}
+
 // IsUserAllowed checks whether a user ID is in the allow list.
 // Returns true if the allow list is empty (all users permitted).
 func (c *Config) IsUserAllowed(userID int64) bool {
@@ -69,3 +195,13 @@ func (c *Config) IsUserAllowed(userID int64) bool {
 	}
 	return false
 }
+
+// IsAdmin checks whether a user may see the bot's maintenance commands.
+func (c *Config) IsAdmin(userID int64) bool {
+	for _, id := range c.AdminUsers {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
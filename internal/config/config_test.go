@@ -2,6 +2,7 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 )
@@ -22,25 +23,41 @@ func TestLoad(t *testing.T) {
 			name: "token only, defaults applied",
 			env:  map[string]string{"TELEGRAM_BOT_TOKEN": "test-token"},
 			want: &Config{
-				TelegramBotToken: "test-token",
-				DatabasePath:     "./data/bot.db",
-				LogLevel:         "info",
-				AllowedUsers:     nil,
+				TelegramBotToken:   "test-token",
+				DatabasePath:       "./data/bot.db",
+				LogLevel:           "info",
+				AllowedUsers:       nil,
+				MaxFeedFailures:    10,
+				SchedulerWorkers:   4,
+				PerHostConcurrency: 2,
+				PerHostDelay:       time.Second,
+				RetentionDays:      90,
+				ContentMaxBytes:    65536,
 			},
 		},
 		{
 			name: "all values set",
 			env: map[string]string{
-				"TELEGRAM_BOT_TOKEN": "tok",
-				"DATABASE_PATH":      "/tmp/bot.db",
-				"LOG_LEVEL":          "debug",
-				"ALLOWED_USERS":      "111,222,333",
+				"TELEGRAM_BOT_TOKEN":   "tok",
+				"DATABASE_PATH":        "/tmp/bot.db",
+				"LOG_LEVEL":            "debug",
+				"ALLOWED_USERS":        "111,222,333",
+				"MAX_FEED_FAILURES":    "3",
+				"SCHEDULER_WORKERS":    "8",
+				"PER_HOST_CONCURRENCY": "1",
+				"PER_HOST_DELAY":       "250ms",
 			},
 			want: &Config{
-				TelegramBotToken: "tok",
-				DatabasePath:     "/tmp/bot.db",
-				LogLevel:         "debug",
-				AllowedUsers:     []int64{111, 222, 333},
+				TelegramBotToken:   "tok",
+				DatabasePath:       "/tmp/bot.db",
+				LogLevel:           "debug",
+				AllowedUsers:       []int64{111, 222, 333},
+				MaxFeedFailures:    3,
+				SchedulerWorkers:   8,
+				PerHostConcurrency: 1,
+				PerHostDelay:       250 * time.Millisecond,
+				RetentionDays:      90,
+				ContentMaxBytes:    65536,
 			},
 		},
 		{
@@ -50,10 +67,16 @@ func TestLoad(t *testing.T) {
 				"ALLOWED_USERS":      " 10 , 20 , ",
 			},
 			want: &Config{
-				TelegramBotToken: "tok",
-				DatabasePath:     "./data/bot.db",
-				LogLevel:         "info",
-				AllowedUsers:     []int64{10, 20},
+				TelegramBotToken:   "tok",
+				DatabasePath:       "./data/bot.db",
+				LogLevel:           "info",
+				AllowedUsers:       []int64{10, 20},
+				MaxFeedFailures:    10,
+				SchedulerWorkers:   4,
+				PerHostConcurrency: 2,
+				PerHostDelay:       time.Second,
+				RetentionDays:      90,
+				ContentMaxBytes:    65536,
 			},
 		},
 		{
@@ -64,12 +87,128 @@ func TestLoad(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "invalid max feed failures",
+			env: map[string]string{
+				"TELEGRAM_BOT_TOKEN": "tok",
+				"MAX_FEED_FAILURES":  "0",
+			},
+			wantErr: true,
+		},
+		{
+			name: "webhook",
+			env: map[string]string{
+				"TELEGRAM_BOT_TOKEN": "tok",
+				"WEBHOOK_URL":        "https://bot.example.com/telegram",
+				"WEBHOOK_SECRET":     "s3cret_token-1",
+			},
+			want: &Config{
+				TelegramBotToken:   "tok",
+				DatabasePath:       "./data/bot.db",
+				LogLevel:           "info",
+				MaxFeedFailures:    10,
+				SchedulerWorkers:   4,
+				PerHostConcurrency: 2,
+				PerHostDelay:       time.Second,
+				RetentionDays:      90,
+				ContentMaxBytes:    65536,
+				WebhookURL:         "https://bot.example.com/telegram",
+				WebhookListen:      ":8080",
+				WebhookSecret:      "s3cret_token-1",
+			},
+		},
+		{
+			name: "webhook without secret",
+			env: map[string]string{
+				"TELEGRAM_BOT_TOKEN": "tok",
+				"WEBHOOK_URL":        "https://bot.example.com/telegram",
+			},
+			wantErr: true,
+		},
+		{
+			name: "webhook secret with invalid characters",
+			env: map[string]string{
+				"TELEGRAM_BOT_TOKEN": "tok",
+				"WEBHOOK_URL":        "https://bot.example.com/telegram",
+				"WEBHOOK_SECRET":     "not secret!",
+			},
+			wantErr: true,
+		},
+		{
+			name: "plain http webhook",
+			env: map[string]string{
+				"TELEGRAM_BOT_TOKEN": "tok",
+				"WEBHOOK_URL":        "http://bot.example.com/telegram",
+				"WEBHOOK_SECRET":     "secret",
+			},
+			wantErr: true,
+		},
+		{
+			name: "admins and retention",
+			env: map[string]string{
+				"TELEGRAM_BOT_TOKEN":  "tok",
+				"ADMIN_USERS":         "42, 7",
+				"SEEN_RETENTION_DAYS": "30",
+				"CONTENT_MAX_BYTES":   "4096",
+			},
+			want: &Config{
+				TelegramBotToken:   "tok",
+				DatabasePath:       "./data/bot.db",
+				LogLevel:           "info",
+				AdminUsers:         []int64{42, 7},
+				MaxFeedFailures:    10,
+				SchedulerWorkers:   4,
+				PerHostConcurrency: 2,
+				PerHostDelay:       time.Second,
+				RetentionDays:      30,
+				ContentMaxBytes:    4096,
+			},
+		},
+		{
+			name: "invalid admin user",
+			env: map[string]string{
+				"TELEGRAM_BOT_TOKEN": "tok",
+				"ADMIN_USERS":        "admin",
+			},
+			wantErr: true,
+		},
+		{
+			name: "http server",
+			env: map[string]string{
+				"TELEGRAM_BOT_TOKEN": "tok",
+				"HTTP_LISTEN":        " :9090 ",
+			},
+			want: &Config{
+				TelegramBotToken:   "tok",
+				DatabasePath:       "./data/bot.db",
+				LogLevel:           "info",
+				MaxFeedFailures:    10,
+				SchedulerWorkers:   4,
+				PerHostConcurrency: 2,
+				PerHostDelay:       time.Second,
+				RetentionDays:      90,
+				ContentMaxBytes:    65536,
+				HTTPListen:         ":9090",
+			},
+		},
+		{
+			name: "invalid per-host delay",
+			env: map[string]string{
+				"TELEGRAM_BOT_TOKEN": "tok",
+				"PER_HOST_DELAY":     "soon",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Clear relevant env vars
-			for _, key := range []string{"TELEGRAM_BOT_TOKEN", "DATABASE_PATH", "LOG_LEVEL", "ALLOWED_USERS"} {
+			for _, key := range []string{"TELEGRAM_BOT_TOKEN", "DATABASE_PATH", "LOG_LEVEL", "ALLOWED_USERS", "MAX_FEED_FAILURES",
+				"SCHEDULER_WORKERS", "PER_HOST_CONCURRENCY", "PER_HOST_DELAY",
+				"WEBHOOK_URL", "WEBHOOK_LISTEN", "WEBHOOK_SECRET", "HTTP_LISTEN",
+				"ADMIN_USERS", "SEEN_RETENTION_DAYS", "CONTENT_MAX_BYTES",
+			} {
 				t.Setenv(key, "")
 			}
 			for k, v := range tt.env {
@@ -130,3 +269,25 @@ func TestIsUserAllowed(t *testing.T) {
 		})
 	}
 }
+
+func TestIsAdmin(t *testing.T) {
+	tests := []struct {
+		name       string
+		adminUsers []int64
+		userID     int64
+		want       bool
+	}{
+		{name: "no admins", adminUsers: nil, userID: 42, want: false},
+		{name: "admin", adminUsers: []int64{10, 20}, userID: 20, want: true},
+		{name: "not an admin", adminUsers: []int64{10, 20}, userID: 99, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{AdminUsers: tt.adminUsers}
+			if diff := cmp.Diff(tt.want, cfg.IsAdmin(tt.userID)); diff != "" {
+				t.Errorf("IsAdmin() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+// Package digest schedules the summaries that collect the items of feeds in
+// digest mode instead of sending them one by one.
+package digest
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"rss_bot/internal/i18n"
+	"rss_bot/internal/model"
+)
+
+const clockLayout = "15:04"
+
+// maxTimes bounds how many digests a chat can get per day.
+const maxTimes = 24
+
+// ParseTimes parses digest times such as "09:00, 18:00" and returns them
+// sorted, without duplicates.
+func ParseTimes(s string) ([]string, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' })
+	if len(fields) == 0 {
+		return nil, i18n.Errorf("no times given")
+	}
+
+	seen := make(map[string]bool)
+	var times []string
+	for _, f := range fields {
+		t, err := time.Parse(clockLayout, f)
+		if err != nil {
+			return nil, i18n.Errorf("invalid time %q, expected HH:MM", f)
+		}
+		v := t.Format(clockLayout)
+		if !seen[v] {
+			seen[v] = true
+			times = append(times, v)
+		}
+	}
+	if len(times) > maxTimes {
+		return nil, i18n.Errorf("at most %d times a day", maxTimes)
+	}
+	sort.Strings(times)
+	return times, nil
+}
+
+// Location returns the time zone of a chat, falling back to UTC when the name
+// is unknown.
+func Location(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Next returns the first scheduled time after t, or the zero time if times is
+// empty. Times are wall-clock times in loc.
+func Next(times []string, loc *time.Location, after time.Time) time.Time {
+	local := after.In(loc)
+	for day := 0; day <= 1; day++ {
+		for _, v := range times {
+			c, err := time.Parse(clockLayout, v)
+			if err != nil {
+				continue
+			}
+			at := time.Date(local.Year(), local.Month(), local.Day()+day, c.Hour(), c.Minute(), 0, 0, loc)
+			if at.After(after) {
+				return at
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// Due reports whether a digest collected since the given time should be sent
+// now, that is whether a scheduled time has passed since then.
+func Due(times []string, loc *time.Location, since, now time.Time) bool {
+	next := Next(times, loc, since)
+	return !next.IsZero() && !next.After(now)
+}
+
+// Enabled reports whether the items of feed go to the chat's digest.
+func Enabled(feed *model.Feed, settings *model.ChatSettings) bool {
+	switch feed.Delivery {
+	case model.DeliveryDigest:
+		return true
+	case model.DeliveryInstant:
+		return false
+	default:
+		return settings != nil && settings.Digest
+	}
+}
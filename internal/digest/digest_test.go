@@ -0,0 +1,124 @@
+package digest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"rss_bot/internal/model"
+)
+
+func TestParseTimes(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{input: "09:00", want: []string{"09:00"}},
+		{input: "18:00, 9:30 18:00", want: []string{"09:30", "18:00"}},
+		{input: "", wantErr: true},
+		{input: "25:00", wantErr: true},
+		{input: "noon", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTimes(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTimes(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("ParseTimes(%q) (-want +got):\n%s", tt.input, diff)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("no time zone data: %v", err)
+	}
+	times := []string{"09:00", "18:00"}
+
+	tests := []struct {
+		name  string
+		after time.Time
+		want  time.Time
+	}{
+		{
+			name:  "later today",
+			after: time.Date(2024, 5, 10, 12, 0, 0, 0, berlin),
+			want:  time.Date(2024, 5, 10, 18, 0, 0, 0, berlin),
+		},
+		{
+			name:  "exactly on a time moves on",
+			after: time.Date(2024, 5, 10, 9, 0, 0, 0, berlin),
+			want:  time.Date(2024, 5, 10, 18, 0, 0, 0, berlin),
+		},
+		{
+			name:  "tomorrow morning",
+			after: time.Date(2024, 5, 10, 20, 0, 0, 0, berlin),
+			want:  time.Date(2024, 5, 11, 9, 0, 0, 0, berlin),
+		},
+		{
+			name:  "in the chat's time zone, not UTC",
+			after: time.Date(2024, 5, 10, 7, 30, 0, 0, time.UTC), // 09:30 in Berlin
+			want:  time.Date(2024, 5, 10, 18, 0, 0, 0, berlin),
+		},
+		{
+			name:  "across a daylight saving change",
+			after: time.Date(2024, 3, 30, 20, 0, 0, 0, berlin),
+			want:  time.Date(2024, 3, 31, 9, 0, 0, 0, berlin),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Next(times, berlin, tt.after)
+			if !got.Equal(tt.want) {
+				t.Errorf("Next = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := Next(nil, berlin, time.Now()); !got.IsZero() {
+		t.Errorf("Next without times = %v, want zero", got)
+	}
+}
+
+func TestDue(t *testing.T) {
+	times := []string{"09:00"}
+	since := time.Date(2024, 5, 10, 10, 0, 0, 0, time.UTC)
+
+	if Due(times, time.UTC, since, since.Add(22*time.Hour)) {
+		t.Error("digest should wait for the next morning")
+	}
+	if !Due(times, time.UTC, since, since.Add(23*time.Hour)) {
+		t.Error("digest should be due at 09:00")
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	on := &model.ChatSettings{Digest: true}
+	off := &model.ChatSettings{}
+
+	tests := []struct {
+		name     string
+		delivery model.DeliveryMode
+		settings *model.ChatSettings
+		want     bool
+	}{
+		{"chat default on", model.DeliveryDefault, on, true},
+		{"chat default off", model.DeliveryDefault, off, false},
+		{"no settings", model.DeliveryDefault, nil, false},
+		{"feed digest overrides chat", model.DeliveryDigest, off, true},
+		{"feed instant overrides chat", model.DeliveryInstant, on, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed := &model.Feed{Delivery: tt.delivery}
+			if diff := cmp.Diff(tt.want, Enabled(feed, tt.settings)); diff != "" {
+				t.Errorf("Enabled (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
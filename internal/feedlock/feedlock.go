@@ -0,0 +1,35 @@
+// Package feedlock tracks which feeds are currently being processed so that a
+// scheduled check and a manual check never work on the same feed at once.
+package feedlock
+
+import "sync"
+
+// Set is a set of per-feed locks. The zero value is not usable; use New.
+type Set struct {
+	mu   sync.Mutex
+	held map[int64]struct{}
+}
+
+// New creates an empty lock set.
+func New() *Set {
+	return &Set{held: make(map[int64]struct{})}
+}
+
+// TryLock acquires the lock for a feed. It returns false without blocking
+// if the feed is already locked.
+func (s *Set) TryLock(feedID int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.held[feedID]; ok {
+		return false
+	}
+	s.held[feedID] = struct{}{}
+	return true
+}
+
+// Unlock releases the lock for a feed.
+func (s *Set) Unlock(feedID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.held, feedID)
+}
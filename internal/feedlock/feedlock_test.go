@@ -0,0 +1,26 @@
+package feedlock
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSet(t *testing.T) {
+	s := New()
+
+	if diff := cmp.Diff(true, s.TryLock(1)); diff != "" {
+		t.Errorf("first lock (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(false, s.TryLock(1)); diff != "" {
+		t.Errorf("second lock of same feed (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(true, s.TryLock(2)); diff != "" {
+		t.Errorf("lock of another feed (-want +got):\n%s", diff)
+	}
+
+	s.Unlock(1)
+	if diff := cmp.Diff(true, s.TryLock(1)); diff != "" {
+		t.Errorf("lock after unlock (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,149 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ErrNotFeed is returned by Fetch when the URL points to an HTML page instead of a feed.
+var ErrNotFeed = errors.New("the page is HTML, not a feed")
+
+// NotFeedError is the ErrNotFeed returned by Fetch. It keeps the downloaded
+// page, so that autodiscovery does not have to download it again.
+type NotFeedError struct {
+	Page []byte
+}
+
+func (e *NotFeedError) Error() string {
+	return ErrNotFeed.Error()
+}
+
+// Is makes errors.Is(err, ErrNotFeed) hold.
+func (e *NotFeedError) Is(target error) bool {
+	return target == ErrNotFeed
+}
+
+// feedTypes are the link types recognised as feeds during autodiscovery.
+var feedTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/feed+json": true,
+}
+
+// commonFeedPaths are probed when a page does not advertise its feeds.
+var commonFeedPaths = []string{"/feed", "/rss", "/feed.xml", "/rss.xml", "/atom.xml", "/index.xml"}
+
+// DiscoveredFeed is a feed found on a web page.
+type DiscoveredFeed struct {
+	URL   string
+	Title string
+}
+
+// DiscoverPage looks for feeds advertised by a downloaded web page, such as
+// the one kept by a NotFeedError, through <link rel="alternate"> elements. If
+// the page advertises none, common feed paths on the same site are probed and
+// the first one that parses is returned.
+func (f *Fetcher) DiscoverPage(ctx context.Context, pageURL string, body []byte) ([]DiscoveredFeed, error) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse url: %w", err)
+	}
+
+	if found := FindFeedLinks(base, body); len(found) > 0 {
+		return found, nil
+	}
+
+	for _, p := range commonFeedPaths {
+		candidate := base.ResolveReference(&url.URL{Path: p}).String()
+		feed, err := f.Fetch(ctx, candidate)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		return []DiscoveredFeed{{URL: candidate, Title: feed.Title}}, nil
+	}
+	return nil, nil
+}
+
+// FindFeedLinks extracts feed URLs advertised by an HTML document through
+// <link rel="alternate" type="..."> elements, resolved against base.
+func FindFeedLinks(base *url.URL, body []byte) []DiscoveredFeed {
+	var found []DiscoveredFeed
+	seen := make(map[string]bool)
+
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return found
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		tok := z.Token()
+		switch tok.Data {
+		case "base":
+			if href := attr(tok, "href"); href != "" {
+				if u, err := base.Parse(href); err == nil {
+					base = u
+				}
+			}
+		case "link":
+			if !hasToken(attr(tok, "rel"), "alternate") {
+				continue
+			}
+			typ, _, _ := strings.Cut(strings.ToLower(attr(tok, "type")), ";")
+			if !feedTypes[strings.TrimSpace(typ)] {
+				continue
+			}
+			u, err := base.Parse(strings.TrimSpace(attr(tok, "href")))
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				continue
+			}
+			if seen[u.String()] {
+				continue
+			}
+			seen[u.String()] = true
+			found = append(found, DiscoveredFeed{URL: u.String(), Title: strings.TrimSpace(attr(tok, "title"))})
+		case "body":
+			// Feed links live in <head>; stop before scanning the page content.
+			return found
+		}
+	}
+}
+
+func attr(tok html.Token, key string) string {
+	for _, a := range tok.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasToken(list, token string) bool {
+	for _, t := range strings.Fields(strings.ToLower(list)) {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
+
+// looksLikeHTML reports whether a response is an HTML page rather than a feed.
+func looksLikeHTML(contentType string, body []byte) bool {
+	if strings.Contains(strings.ToLower(contentType), "text/html") {
+		return true
+	}
+	return strings.HasPrefix(http.DetectContentType(body), "text/html")
+}
@@ -0,0 +1,125 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// routeTransport serves canned bodies by URL and replies 404 to anything else.
+type routeTransport struct {
+	routes   map[string]string
+	requests []string
+}
+
+func (m *routeTransport) Do(req *http.Request) (*http.Response, error) {
+	m.requests = append(m.requests, req.URL.String())
+	body, ok := m.routes[req.URL.String()]
+	if !ok {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(bytes.NewBufferString(""))}, nil
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+}
+
+const blogPage = `<!DOCTYPE html>
+<html>
+<head>
+  <title>My Blog</title>
+  <link rel="stylesheet" href="/style.css">
+  <link rel="alternate" type="application/rss+xml" title="Posts" href="/posts.rss">
+  <link rel="alternate" type="application/atom+xml" title="Comments" href="https://blog.example.com/comments.atom">
+  <link rel="alternate" type="application/feed+json" href="feed.json">
+  <link rel="alternate" type="application/rss+xml" href="/posts.rss">
+  <link rel="alternate" hreflang="de" href="/de/">
+</head>
+<body><link rel="alternate" type="application/rss+xml" href="/ignored.rss"></body>
+</html>`
+
+func TestFindFeedLinks(t *testing.T) {
+	base, _ := url.Parse("https://blog.example.com/blog/")
+
+	got := FindFeedLinks(base, []byte(blogPage))
+	want := []DiscoveredFeed{
+		{URL: "https://blog.example.com/posts.rss", Title: "Posts"},
+		{URL: "https://blog.example.com/comments.atom", Title: "Comments"},
+		{URL: "https://blog.example.com/blog/feed.json"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("FindFeedLinks mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDiscoverPage(t *testing.T) {
+	xml := loadFixture(t, "../../internal/testdata/sample.xml")
+	plainPage := `<!DOCTYPE html><html><head><title>Plain</title></head><body>Hello</body></html>`
+
+	tests := []struct {
+		name   string
+		page   string
+		routes map[string]string
+		want   []DiscoveredFeed
+	}{
+		{
+			name: "advertised links",
+			page: blogPage,
+			want: []DiscoveredFeed{
+				{URL: "https://blog.example.com/posts.rss", Title: "Posts"},
+				{URL: "https://blog.example.com/comments.atom", Title: "Comments"},
+				{URL: "https://blog.example.com/feed.json"},
+			},
+		},
+		{
+			name:   "probes common paths",
+			page:   plainPage,
+			routes: map[string]string{"https://blog.example.com/rss.xml": xml},
+			want:   []DiscoveredFeed{{URL: "https://blog.example.com/rss.xml", Title: "DevOps Weekly"}},
+		},
+		{
+			name: "nothing found",
+			page: plainPage,
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New(&routeTransport{routes: tt.routes})
+			got, err := f.DiscoverPage(context.Background(), "https://blog.example.com/", []byte(tt.page))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("DiscoverPage mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFetchHTMLPage(t *testing.T) {
+	ctx := context.Background()
+	client := &routeTransport{routes: map[string]string{"https://blog.example.com/": blogPage}}
+	f := New(client)
+	_, err := f.Fetch(ctx, "https://blog.example.com/")
+	if !errors.Is(err, ErrNotFeed) {
+		t.Fatalf("expected ErrNotFeed, got %v", err)
+	}
+
+	// The page kept by the error is searched without downloading it again.
+	var notFeed *NotFeedError
+	if !errors.As(err, &notFeed) {
+		t.Fatalf("expected a NotFeedError, got %T", err)
+	}
+	found, err := f.DiscoverPage(ctx, "https://blog.example.com/", notFeed.Page)
+	if err != nil || len(found) != 3 {
+		t.Errorf("DiscoverPage = %v, %v; want 3 feeds", found, err)
+	}
+	if diff := cmp.Diff([]string{"https://blog.example.com/"}, client.requests); diff != "" {
+		t.Errorf("requests (-want +got):\n%s", diff)
+	}
+}
@@ -7,15 +7,22 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/mmcdole/gofeed"
 
 	"rss_bot/internal/filter"
+	"rss_bot/internal/metrics"
 	"rss_bot/internal/model"
 )
 
+const (
+	userAgent   = "RSSNotifyBot/1.0"
+	maxBodySize = 5 * 1024 * 1024
+)
+
 // HTTPClient is the interface for performing HTTP requests.
 type HTTPClient interface {
 	Do(req *http.Request) (*http.Response, error)
@@ -34,13 +41,34 @@ type MatchedItem struct {
 	Content     string
 	Link        string
 	GUID        string
+	Author      string
 	ImageURL    string
+	Media       []model.Media
+	Published   *time.Time
+}
+
+// ExplainedItem is an RSS item together with the trace of its filter decision.
+type ExplainedItem struct {
+	Title       string
+	Link        string
+	GUID        string
+	Explanation filter.Explanation
+}
+
+// StatusError is returned when the server replies with an unexpected HTTP status.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d", e.StatusCode)
 }
 
 // Fetcher downloads and parses RSS feeds.
 type Fetcher struct {
 	client  HTTPClient
 	timeout time.Duration
+	metrics *metrics.Metrics
 }
 
 // New creates a Fetcher with the given HTTP client.
@@ -51,25 +79,69 @@ func New(client HTTPClient) *Fetcher {
 	}
 }
 
+// SetMetrics makes the fetcher record the duration and outcome of feed requests.
+func (f *Fetcher) SetMetrics(m *metrics.Metrics) {
+	f.metrics = m
+}
+
+// Validators holds the HTTP cache validators used for conditional requests.
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+// Response holds the outcome of a conditional fetch.
+// When NotModified is true, Feed is nil and the previous validators remain valid.
+type Response struct {
+	Feed        *gofeed.Feed
+	NotModified bool
+	Validators  Validators
+}
+
 // Fetch downloads and parses an RSS feed from the given URL.
 func (f *Fetcher) Fetch(ctx context.Context, url string) (*gofeed.Feed, error) {
+	resp, err := f.FetchConditional(ctx, url, Validators{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Feed, nil
+}
+
+// FetchConditional downloads and parses an RSS feed, sending If-None-Match and
+// If-Modified-Since when validators from a previous fetch are known.
+// A 304 Not Modified reply is reported via Response.NotModified.
+func (f *Fetcher) FetchConditional(ctx context.Context, url string, v Validators) (*Response, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
 	}
-	req.Header.Set("User-Agent", "RSSNotifyBot/1.0")
+	req.Header.Set("User-Agent", userAgent)
+	if v.ETag != "" {
+		req.Header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		req.Header.Set("If-Modified-Since", v.LastModified)
+	}
+
+	code := "error"
+	start := time.Now()
+	defer func() { f.metrics.FetchDone(req.URL.Host, code, time.Since(start)) }()
 
 	resp, err := f.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("http get: %w", err)
 	}
 	defer func() { _ = resp.Body.Close() }()
+	code = strconv.Itoa(resp.StatusCode)
 
+	if resp.StatusCode == http.StatusNotModified {
+		return &Response{NotModified: true, Validators: v}, nil
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+		return nil, &StatusError{StatusCode: resp.StatusCode}
 	}
 
-	body, err := io.ReadAll(io.LimitReader(resp.Body, 5*1024*1024))
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
 	if err != nil {
 		return nil, fmt.Errorf("read body: %w", err)
 	}
@@ -77,9 +149,44 @@ func (f *Fetcher) Fetch(ctx context.Context, url string) (*gofeed.Feed, error) {
 	parser := gofeed.NewParser()
 	feed, err := parser.ParseString(string(body))
 	if err != nil {
+		f.metrics.ParseError(req.URL.Host)
+		if looksLikeHTML(resp.Header.Get("Content-Type"), body) {
+			return nil, &NotFeedError{Page: body}
+		}
 		return nil, fmt.Errorf("parse feed: %w", err)
 	}
-	return feed, nil
+	return &Response{
+		Feed: feed,
+		Validators: Validators{
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+		},
+	}, nil
+}
+
+// Download fetches a URL and returns at most limit bytes of its body.
+func (f *Fetcher) Download(ctx context.Context, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http get: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit))
+	if err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+	return body, nil
 }
 
 // ItemGUID returns the GUID for an RSS item.
@@ -95,19 +202,20 @@ func ItemGUID(item *gofeed.Item) string {
 // FilterItems applies filters to RSS items and returns those that match.
 func FilterItems(items []*gofeed.Item, filters []model.Filter) []MatchedItem {
 	var matched []MatchedItem
+	set := filter.Compile(filters)
 	for _, item := range items {
-		fi := filter.FeedItem{
-			Title:       item.Title,
-			Description: item.Description,
-		}
-		if filter.Match(fi, filters) {
+		fi := filterItem(item)
+		if set.Match(fi) {
 			mi := MatchedItem{
 				Title:       item.Title,
 				Description: item.Description,
 				Content:     item.Content,
 				Link:        item.Link,
 				GUID:        ItemGUID(item),
+				Author:      strings.Join(fi.Authors, ", "),
 				ImageURL:    extractImageURL(item),
+				Media:       extractMedia(item),
+				Published:   itemTime(item),
 			}
 			matched = append(matched, mi)
 		}
@@ -115,6 +223,55 @@ func FilterItems(items []*gofeed.Item, filters []model.Filter) []MatchedItem {
 	return matched
 }
 
+// ExplainItems matches every item against filters and reports why each one
+// passed or was rejected.
+func ExplainItems(items []*gofeed.Item, filters []model.Filter) []ExplainedItem {
+	out := make([]ExplainedItem, 0, len(items))
+	set := filter.Compile(filters)
+	for _, item := range items {
+		out = append(out, ExplainedItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			GUID:        ItemGUID(item),
+			Explanation: set.Explain(filterItem(item)),
+		})
+	}
+	return out
+}
+
+// itemTime returns the publication time of an item, falling back to its
+// update time. It returns nil when the feed provides neither.
+func itemTime(item *gofeed.Item) *time.Time {
+	if item.PublishedParsed != nil {
+		return item.PublishedParsed
+	}
+	return item.UpdatedParsed
+}
+
+func filterItem(item *gofeed.Item) filter.FeedItem {
+	fi := filter.FeedItem{
+		Title:       item.Title,
+		Description: item.Description,
+		Categories:  item.Categories,
+		Link:        item.Link,
+	}
+	for _, a := range item.Authors {
+		if a == nil {
+			continue
+		}
+		name := strings.TrimSpace(strings.Join([]string{a.Name, a.Email}, " "))
+		if name != "" {
+			fi.Authors = append(fi.Authors, name)
+		}
+	}
+	for _, enc := range item.Enclosures {
+		if enc != nil {
+			fi.Enclosures = append(fi.Enclosures, filter.Enclosure{URL: enc.URL, Type: enc.Type})
+		}
+	}
+	return fi
+}
+
 func extractImageURL(item *gofeed.Item) string {
 	for _, enc := range item.Enclosures {
 		if enc.URL != "" && strings.HasPrefix(enc.Type, "image/") {
@@ -0,0 +1,129 @@
+package fetcher
+
+import (
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+
+	"rss_bot/internal/model"
+)
+
+// mediaTypes guesses the MIME type of a file from its extension when the
+// feed does not declare one.
+var mediaTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".ogg":  "audio/ogg",
+	".mp4":  "video/mp4",
+	".webm": "video/webm",
+	".pdf":  "application/pdf",
+}
+
+// extractMedia collects the files attached to an item from its enclosures,
+// Media RSS elements and item image, in that order and without duplicates.
+// Only images, audio, video and PDF documents are kept.
+func extractMedia(item *gofeed.Item) []model.Media {
+	var out []model.Media
+	seen := make(map[string]bool)
+	add := func(url, mimeType, medium, size, duration string) {
+		url = strings.TrimSpace(url)
+		if url == "" || seen[url] {
+			return
+		}
+		m, ok := newMedia(url, mimeType, medium)
+		if !ok {
+			return
+		}
+		m.Size, _ = strconv.ParseInt(strings.TrimSpace(size), 10, 64)
+		if m.Kind == model.MediaAudio || m.Kind == model.MediaVideo {
+			m.Duration = parseDuration(duration)
+		}
+		seen[url] = true
+		out = append(out, m)
+	}
+
+	itunesDuration := ""
+	if item.ITunesExt != nil {
+		itunesDuration = item.ITunesExt.Duration
+	}
+	for _, enc := range item.Enclosures {
+		if enc != nil {
+			add(enc.URL, enc.Type, "", enc.Length, itunesDuration)
+		}
+	}
+
+	for _, c := range mediaContents(item.Extensions) {
+		add(c.Attrs["url"], c.Attrs["type"], c.Attrs["medium"], c.Attrs["fileSize"], c.Attrs["duration"])
+	}
+
+	if item.Image != nil {
+		add(item.Image.URL, "", "image", "", "")
+	}
+	return out
+}
+
+// mediaContents returns the media:content elements of an item, including
+// those grouped in media:group.
+func mediaContents(exts ext.Extensions) []ext.Extension {
+	media := exts["media"]
+	if media == nil {
+		return nil
+	}
+	contents := append([]ext.Extension(nil), media["content"]...)
+	for _, g := range media["group"] {
+		contents = append(contents, g.Children["content"]...)
+	}
+	return contents
+}
+
+func newMedia(url, mimeType, medium string) (model.Media, bool) {
+	mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+	if mimeType == "" {
+		ext := strings.ToLower(path.Ext(strings.SplitN(url, "?", 2)[0]))
+		mimeType = mediaTypes[ext]
+	}
+
+	m := model.Media{URL: url, Type: mimeType}
+	switch {
+	case strings.HasPrefix(mimeType, "image/"), mimeType == "" && medium == "image":
+		m.Kind = model.MediaPhoto
+	case strings.HasPrefix(mimeType, "audio/"), mimeType == "" && medium == "audio":
+		m.Kind = model.MediaAudio
+	case strings.HasPrefix(mimeType, "video/"), mimeType == "" && medium == "video":
+		m.Kind = model.MediaVideo
+	case mimeType == "application/pdf":
+		m.Kind = model.MediaDocument
+	default:
+		return model.Media{}, false
+	}
+	return m, true
+}
+
+// parseDuration parses a duration given in seconds or as [[HH:]MM:]SS, as
+// used by iTunes and Media RSS. It returns 0 if s is not a valid duration.
+func parseDuration(s string) int {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0
+	}
+	if whole, _, ok := strings.Cut(s, "."); ok {
+		s = whole
+	}
+	total := 0
+	for _, part := range strings.Split(s, ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0
+		}
+		total = total*60 + n
+	}
+	return total
+}
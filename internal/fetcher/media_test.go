@@ -0,0 +1,78 @@
+package fetcher
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mmcdole/gofeed"
+
+	"rss_bot/internal/model"
+)
+
+func TestExtractMedia(t *testing.T) {
+	feed, err := gofeed.NewParser().ParseString(loadTestXML(t, "with_media.xml"))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		want []model.Media
+	}{
+		{
+			name: "podcast episode",
+			want: []model.Media{
+				{Kind: model.MediaAudio, URL: "https://media.example.com/episode-1.mp3", Type: "audio/mpeg", Size: 12345678, Duration: 3723},
+			},
+		},
+		{
+			name: "media group and contents",
+			want: []model.Media{
+				{Kind: model.MediaPhoto, URL: "https://media.example.com/3.png", Type: "image/png"},
+				{Kind: model.MediaPhoto, URL: "https://media.example.com/1.jpg", Type: "image/jpeg"},
+				{Kind: model.MediaPhoto, URL: "https://media.example.com/2.jpg", Type: "image/jpeg", Size: 1000},
+			},
+		},
+		{
+			name: "video",
+			want: []model.Media{
+				{Kind: model.MediaVideo, URL: "https://media.example.com/clip.mp4", Type: "video/mp4", Duration: 95},
+			},
+		},
+		{
+			name: "pdf without type, archive skipped",
+			want: []model.Media{
+				{Kind: model.MediaDocument, URL: "https://media.example.com/report.pdf?v=2", Type: "application/pdf", Size: 1000},
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := extractMedia(feed.Items[i])
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("extractMedia mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{"", 0},
+		{"95", 95},
+		{"95.5", 95},
+		{"02:05", 125},
+		{"1:02:03", 3723},
+		{"abc", 0},
+		{"1:-2", 0},
+	}
+	for _, tt := range tests {
+		if diff := cmp.Diff(tt.want, parseDuration(tt.input)); diff != "" {
+			t.Errorf("parseDuration(%q) (-want +got):\n%s", tt.input, diff)
+		}
+	}
+}
@@ -13,61 +13,200 @@ import (
 type FeedItem struct {
 	Title       string
 	Description string
+	Authors     []string
+	Categories  []string
+	Link        string
+	Enclosures  []Enclosure
+}
+
+// Enclosure is a media file attached to an item.
+type Enclosure struct {
+	URL  string
+	Type string
+}
+
+// Reason describes why an item passed or was rejected.
+type Reason string
+
+// Possible match reasons.
+const (
+	ReasonNoFilters    Reason = "no_filters"    // passed: the feed has no filters
+	ReasonIncluded     Reason = "included"      // passed: Filter is the first include that matched
+	ReasonQueryMatched Reason = "query_matched" // passed: all queries matched, Filter is the first one
+	ReasonNotExcluded  Reason = "not_excluded"  // passed: no exclude filter matched
+	ReasonExcluded     Reason = "excluded"      // rejected: Filter is the first exclude that matched
+	ReasonQueryFailed  Reason = "query_failed"  // rejected: Filter is the first query that did not match
+	ReasonNoInclude    Reason = "no_include"    // rejected: include filters exist but none matched
+)
+
+// Step records whether a single filter matched an item.
+type Step struct {
+	Filter  model.Filter
+	Matched bool
+}
+
+// Explanation is the trace of matching an item against a set of filters.
+type Explanation struct {
+	Passed bool
+	Reason Reason
+	// Filter is the filter that decided the outcome, or nil when no single
+	// filter did (ReasonNoFilters, ReasonNotExcluded, ReasonNoInclude).
+	Filter *model.Filter
+	// Steps lists every filter in order with its individual result.
+	Steps []Step
 }
 
 // Match checks whether an item passes the given set of filters.
 // If no filters are provided, the item always passes.
 // Include filters use OR logic (at least one must match).
 // Exclude filters use AND logic (none must match).
+// Query filters must all match.
 func Match(item FeedItem, filters []model.Filter) bool {
+	return Compile(filters).Match(item)
+}
+
+// Explain matches an item against filters like Match and reports which
+// filter was responsible for the decision. Exclude filters take precedence
+// over failed queries, which take precedence over missing includes.
+func Explain(item FeedItem, filters []model.Filter) Explanation {
+	return Compile(filters).Explain(item)
+}
+
+// Set is a feed's filters prepared for matching many items: regular
+// expressions and queries are compiled once rather than for every item.
+type Set struct {
+	filters  []model.Filter
+	matchers []func(FeedItem) bool
+}
+
+// Compile prepares filters for matching. A filter whose regular expression
+// or query does not compile matches nothing.
+func Compile(filters []model.Filter) *Set {
+	s := &Set{filters: filters, matchers: make([]func(FeedItem) bool, len(filters))}
+	for i, f := range filters {
+		s.matchers[i] = compileFilter(f)
+	}
+	return s
+}
+
+// Match reports whether an item passes the filters, like the Match function.
+func (s *Set) Match(item FeedItem) bool {
+	return s.Explain(item).Passed
+}
+
+// Explain matches an item against the filters like the Explain function.
+func (s *Set) Explain(item FeedItem) Explanation {
+	filters := s.filters
 	if len(filters) == 0 {
-		return true
+		return Explanation{Passed: true, Reason: ReasonNoFilters}
 	}
 
-	hasIncludes := false
-	anyIncludeMatched := false
+	var (
+		steps           = make([]Step, len(filters))
+		hasIncludes     bool
+		firstInclude    = -1
+		firstExclude    = -1
+		firstQueryFail  = -1
+		firstQueryMatch = -1
+	)
+	for i, f := range filters {
+		matched := s.matchers[i](item)
+		steps[i] = Step{Filter: f, Matched: matched}
 
-	for _, f := range filters {
 		switch f.Kind {
 		case model.FilterInclude, model.FilterIncludeRe:
 			hasIncludes = true
-			if matchesFilter(item, f) {
-				anyIncludeMatched = true
+			if matched && firstInclude < 0 {
+				firstInclude = i
 			}
 		case model.FilterExclude, model.FilterExcludeRe:
-			if matchesFilter(item, f) {
-				return false
+			if matched && firstExclude < 0 {
+				firstExclude = i
+			}
+		case model.FilterQuery:
+			if !matched && firstQueryFail < 0 {
+				firstQueryFail = i
+			}
+			if matched && firstQueryMatch < 0 {
+				firstQueryMatch = i
 			}
 		}
 	}
 
-	if hasIncludes && !anyIncludeMatched {
-		return false
+	e := Explanation{Steps: steps}
+	decide := func(passed bool, reason Reason, idx int) Explanation {
+		e.Passed = passed
+		e.Reason = reason
+		if idx >= 0 {
+			f := filters[idx]
+			e.Filter = &f
+		}
+		return e
+	}
+
+	switch {
+	case firstExclude >= 0:
+		return decide(false, ReasonExcluded, firstExclude)
+	case firstQueryFail >= 0:
+		return decide(false, ReasonQueryFailed, firstQueryFail)
+	case hasIncludes && firstInclude < 0:
+		return decide(false, ReasonNoInclude, -1)
+	case firstInclude >= 0:
+		return decide(true, ReasonIncluded, firstInclude)
+	case firstQueryMatch >= 0:
+		return decide(true, ReasonQueryMatched, firstQueryMatch)
+	default:
+		return decide(true, ReasonNotExcluded, -1)
 	}
-	return true
 }
 
-func matchesFilter(item FeedItem, f model.Filter) bool {
-	text := textForScope(item, f.Scope)
+func compileFilter(f model.Filter) func(FeedItem) bool {
+	never := func(FeedItem) bool { return false }
 	switch f.Kind {
 	case model.FilterInclude, model.FilterExclude:
-		return strings.Contains(text, strings.ToLower(f.Value))
+		value := strings.ToLower(f.Value)
+		return func(item FeedItem) bool {
+			return strings.Contains(textForScope(item, f.Scope), value)
+		}
 	case model.FilterIncludeRe, model.FilterExcludeRe:
 		re, err := regexp.Compile("(?i)" + f.Value)
 		if err != nil {
-			return false
+			return never
+		}
+		return func(item FeedItem) bool {
+			return re.MatchString(textForScope(item, f.Scope))
 		}
-		return re.MatchString(text)
+	case model.FilterQuery:
+		q, err := ParseQuery(f.Value, f.Scope)
+		if err != nil {
+			return never
+		}
+		return q.Match
 	}
-	return false
+	return never
 }
 
+// textForScope returns the lowercased item text a filter with the given scope
+// matches against. List fields are joined with newlines so that a phrase
+// cannot span two authors or categories.
 func textForScope(item FeedItem, scope model.FilterScope) string {
 	switch scope {
 	case model.ScopeTitle:
 		return strings.ToLower(item.Title)
 	case model.ScopeContent:
 		return strings.ToLower(item.Description)
+	case model.ScopeAuthor:
+		return strings.ToLower(strings.Join(item.Authors, "\n"))
+	case model.ScopeCategories:
+		return strings.ToLower(strings.Join(item.Categories, "\n"))
+	case model.ScopeLink:
+		return strings.ToLower(item.Link)
+	case model.ScopeEnclosures:
+		lines := make([]string, len(item.Enclosures))
+		for i, enc := range item.Enclosures {
+			lines[i] = enc.Type + " " + enc.URL
+		}
+		return strings.ToLower(strings.Join(lines, "\n"))
 	default:
 		return strings.ToLower(item.Title + " " + item.Description)
 	}
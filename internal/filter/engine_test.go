@@ -215,6 +215,123 @@ func TestMatch(t *testing.T) {
 	}
 }
 
+func TestExplain(t *testing.T) {
+	include := model.Filter{Position: 1, Kind: model.FilterInclude, Scope: model.ScopeAll, Value: "kubernetes"}
+	exclude := model.Filter{Position: 2, Kind: model.FilterExclude, Scope: model.ScopeAll, Value: "vacancy"}
+	query := model.Filter{Position: 3, Kind: model.FilterQuery, Scope: model.ScopeAll, Value: "release OR update"}
+
+	tests := []struct {
+		name       string
+		item       FeedItem
+		filters    []model.Filter
+		wantPassed bool
+		wantReason Reason
+		wantFilter *model.Filter
+		wantSteps  []bool
+	}{
+		{
+			name:       "no filters",
+			item:       FeedItem{Title: "anything"},
+			wantPassed: true,
+			wantReason: ReasonNoFilters,
+		},
+		{
+			name:       "passed by include",
+			item:       FeedItem{Title: "Kubernetes release"},
+			filters:    []model.Filter{include, exclude},
+			wantPassed: true,
+			wantReason: ReasonIncluded,
+			wantFilter: &include,
+			wantSteps:  []bool{true, false},
+		},
+		{
+			name:       "no exclude matched",
+			item:       FeedItem{Title: "Docker release"},
+			filters:    []model.Filter{exclude},
+			wantPassed: true,
+			wantReason: ReasonNotExcluded,
+			wantSteps:  []bool{false},
+		},
+		{
+			name:       "exclude wins over include",
+			item:       FeedItem{Title: "Kubernetes vacancy"},
+			filters:    []model.Filter{include, exclude},
+			wantPassed: false,
+			wantReason: ReasonExcluded,
+			wantFilter: &exclude,
+			wantSteps:  []bool{true, true},
+		},
+		{
+			name:       "no include matched",
+			item:       FeedItem{Title: "Docker release"},
+			filters:    []model.Filter{include, exclude},
+			wantPassed: false,
+			wantReason: ReasonNoInclude,
+			wantSteps:  []bool{false, false},
+		},
+		{
+			name:       "query failed",
+			item:       FeedItem{Title: "Kubernetes webinar"},
+			filters:    []model.Filter{include, query},
+			wantPassed: false,
+			wantReason: ReasonQueryFailed,
+			wantFilter: &query,
+			wantSteps:  []bool{true, false},
+		},
+		{
+			name:       "passed by query",
+			item:       FeedItem{Title: "Docker update"},
+			filters:    []model.Filter{exclude, query},
+			wantPassed: true,
+			wantReason: ReasonQueryMatched,
+			wantFilter: &query,
+			wantSteps:  []bool{false, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Explain(tt.item, tt.filters)
+			if diff := cmp.Diff(tt.wantPassed, got.Passed); diff != "" {
+				t.Errorf("Passed mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantReason, got.Reason); diff != "" {
+				t.Errorf("Reason mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantFilter, got.Filter); diff != "" {
+				t.Errorf("Filter mismatch (-want +got):\n%s", diff)
+			}
+			var steps []bool
+			for _, s := range got.Steps {
+				steps = append(steps, s.Matched)
+			}
+			if diff := cmp.Diff(tt.wantSteps, steps); diff != "" {
+				t.Errorf("Steps mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCompile(t *testing.T) {
+	set := Compile([]model.Filter{
+		{Kind: model.FilterQuery, Scope: model.ScopeAll, Value: "kubernetes AND NOT webinar"},
+		{Kind: model.FilterExcludeRe, Scope: model.ScopeTitle, Value: "[broken"},
+	})
+	items := []FeedItem{
+		{Title: "Kubernetes 1.32"},
+		{Title: "Kubernetes webinar"},
+		{Title: "Docker news"},
+	}
+	var got []bool
+	for _, item := range items {
+		got = append(got, set.Match(item))
+	}
+	// The broken regex excludes nothing; the query decides every item.
+	if diff := cmp.Diff([]bool{true, false, false}, got); diff != "" {
+		t.Errorf("Match mismatch (-want +got):\n%s", diff)
+	}
+}
+
 func TestValidateRegex(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -0,0 +1,398 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"rss_bot/internal/model"
+)
+
+// QueryError describes a syntax error in a query expression.
+// Pos is the 1-based character position the error refers to.
+type QueryError struct {
+	Pos int
+	Msg string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("position %d: %s", e.Pos, e.Msg)
+}
+
+// Query is a parsed boolean filter expression such as
+//
+//	kubernetes AND (security OR "CVE-2024") AND NOT title:webinar
+//
+// Terms match case-insensitively as substrings. Adjacent terms are joined
+// with AND; NOT binds tighter than AND, which binds tighter than OR.
+// A term or a parenthesized group can be prefixed with a scope name
+// (title:, content:, author:, link:, ...) to override the filter's scope.
+type Query struct {
+	root queryNode
+}
+
+// ParseQuery parses a query expression. Terms without a scope prefix are
+// matched against the given scope.
+func ParseQuery(expr string, scope model.FilterScope) (*Query, error) {
+	toks, err := lexQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+	if scope == "" {
+		scope = model.ScopeAll
+	}
+	p := &queryParser{toks: toks, scope: scope}
+	if p.peek().kind == tokEOF {
+		return nil, &QueryError{Pos: 1, Msg: "empty expression"}
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, &QueryError{Pos: t.pos, Msg: fmt.Sprintf("unexpected %s", t)}
+	}
+	return &Query{root: root}, nil
+}
+
+// Match reports whether the item satisfies the query.
+func (q *Query) Match(item FeedItem) bool {
+	return q.root.eval(item)
+}
+
+// String returns the normalized form of the query with explicit operators.
+func (q *Query) String() string {
+	return q.root.String()
+}
+
+// ValidateQuery checks whether expr is a valid query expression.
+func ValidateQuery(expr string) error {
+	if _, err := ParseQuery(expr, model.ScopeAll); err != nil {
+		return fmt.Errorf("invalid query: %w", err)
+	}
+	return nil
+}
+
+// --- syntax tree ---
+
+type queryNode interface {
+	eval(item FeedItem) bool
+	String() string
+}
+
+type termNode struct {
+	scope model.FilterScope
+	text  string
+}
+
+func (n *termNode) eval(item FeedItem) bool {
+	return strings.Contains(textForScope(item, n.scope), n.text)
+}
+
+func (n *termNode) String() string {
+	return fmt.Sprintf("%s:%q", n.scope, n.text)
+}
+
+type notNode struct {
+	x queryNode
+}
+
+func (n *notNode) eval(item FeedItem) bool {
+	return !n.x.eval(item)
+}
+
+func (n *notNode) String() string {
+	return "NOT " + groupString(n.x)
+}
+
+type andNode struct {
+	xs []queryNode
+}
+
+func (n *andNode) eval(item FeedItem) bool {
+	for _, x := range n.xs {
+		if !x.eval(item) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *andNode) String() string {
+	return joinNodes(n.xs, " AND ")
+}
+
+type orNode struct {
+	xs []queryNode
+}
+
+func (n *orNode) eval(item FeedItem) bool {
+	for _, x := range n.xs {
+		if x.eval(item) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *orNode) String() string {
+	return joinNodes(n.xs, " OR ")
+}
+
+func joinNodes(xs []queryNode, sep string) string {
+	parts := make([]string, len(xs))
+	for i, x := range xs {
+		parts[i] = groupString(x)
+	}
+	return strings.Join(parts, sep)
+}
+
+// groupString wraps AND and OR nodes in parentheses.
+func groupString(x queryNode) string {
+	switch x.(type) {
+	case *andNode, *orNode:
+		return "(" + x.String() + ")"
+	}
+	return x.String()
+}
+
+// --- lexer ---
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokTerm
+	tokScope // scope prefix directly followed by a group, e.g. "title:("
+	tokAnd
+	tokOr
+	tokNot
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind  tokenKind
+	pos   int
+	text  string
+	scope model.FilterScope
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokAnd:
+		return "AND"
+	case tokOr:
+		return "OR"
+	case tokNot:
+		return "NOT"
+	case tokLParen:
+		return "'('"
+	case tokRParen:
+		return "')'"
+	case tokScope:
+		return fmt.Sprintf("%q", string(t.scope)+":")
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+var queryScopes = func() map[string]model.FilterScope {
+	m := make(map[string]model.FilterScope, len(model.FilterScopes))
+	for _, s := range model.FilterScopes {
+		m[string(s)] = s
+	}
+	return m
+}()
+
+func lexQuery(expr string) ([]token, error) {
+	rs := []rune(expr)
+	var toks []token
+	i := 0
+	for i < len(rs) {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			toks = append(toks, token{kind: tokLParen, pos: i + 1})
+			i++
+		case r == ')':
+			toks = append(toks, token{kind: tokRParen, pos: i + 1})
+			i++
+		case r == '"':
+			text, next, err := lexPhrase(rs, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, token{kind: tokTerm, pos: i + 1, text: text})
+			i = next
+		default:
+			start := i
+			for i < len(rs) && !unicode.IsSpace(rs[i]) && !strings.ContainsRune(`()"`, rs[i]) {
+				i++
+			}
+			word := string(rs[start:i])
+			tok := token{kind: tokTerm, pos: start + 1, text: word}
+
+			if name, rest, ok := strings.Cut(word, ":"); ok {
+				if scope, known := queryScopes[strings.ToLower(name)]; known {
+					tok.scope = scope
+					switch {
+					case rest != "":
+						tok.text = rest
+					case i < len(rs) && rs[i] == '"':
+						text, next, err := lexPhrase(rs, i)
+						if err != nil {
+							return nil, err
+						}
+						tok.text = text
+						i = next
+					case i < len(rs) && rs[i] == '(':
+						tok.kind = tokScope
+					default:
+						return nil, &QueryError{Pos: start + 1, Msg: fmt.Sprintf("missing term after %q", word)}
+					}
+				}
+			}
+
+			if tok.kind == tokTerm && tok.scope == "" {
+				switch strings.ToUpper(word) {
+				case "AND":
+					tok.kind = tokAnd
+				case "OR":
+					tok.kind = tokOr
+				case "NOT":
+					tok.kind = tokNot
+				}
+			}
+			toks = append(toks, tok)
+		}
+	}
+	toks = append(toks, token{kind: tokEOF, pos: len(rs) + 1})
+	return toks, nil
+}
+
+// lexPhrase reads a quoted phrase starting at the opening quote rs[i] and
+// returns its text and the index after the closing quote.
+func lexPhrase(rs []rune, i int) (string, int, error) {
+	end := i + 1
+	for end < len(rs) && rs[end] != '"' {
+		end++
+	}
+	if end == len(rs) {
+		return "", 0, &QueryError{Pos: i + 1, Msg: "unterminated quote"}
+	}
+	text := string(rs[i+1 : end])
+	if strings.TrimSpace(text) == "" {
+		return "", 0, &QueryError{Pos: i + 1, Msg: "empty phrase"}
+	}
+	return text, end + 1, nil
+}
+
+// --- parser ---
+
+type queryParser struct {
+	toks  []token
+	i     int
+	scope model.FilterScope
+}
+
+func (p *queryParser) peek() token {
+	return p.toks[p.i]
+}
+
+func (p *queryParser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// parseOr parses: and ( OR and )*
+func (p *queryParser) parseOr() (queryNode, error) {
+	x, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	xs := []queryNode{x}
+	for p.peek().kind == tokOr {
+		p.next()
+		x, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		xs = append(xs, x)
+	}
+	if len(xs) == 1 {
+		return xs[0], nil
+	}
+	return &orNode{xs: xs}, nil
+}
+
+// parseAnd parses: unary ( [AND] unary )*
+func (p *queryParser) parseAnd() (queryNode, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	xs := []queryNode{x}
+	for {
+		switch p.peek().kind {
+		case tokAnd:
+			p.next()
+		case tokTerm, tokScope, tokNot, tokLParen:
+		default:
+			if len(xs) == 1 {
+				return xs[0], nil
+			}
+			return &andNode{xs: xs}, nil
+		}
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		xs = append(xs, x)
+	}
+}
+
+// parseUnary parses: NOT unary | scope: ( or ) | ( or ) | term
+func (p *queryParser) parseUnary() (queryNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNot:
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{x: x}, nil
+	case tokScope:
+		saved := p.scope
+		p.scope = t.scope
+		defer func() { p.scope = saved }()
+		return p.parseUnary()
+	case tokLParen:
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != tokRParen {
+			return nil, &QueryError{Pos: t.pos, Msg: "missing ')' for this '('"}
+		}
+		p.next()
+		return x, nil
+	case tokTerm:
+		scope := t.scope
+		if scope == "" {
+			scope = p.scope
+		}
+		return &termNode{scope: scope, text: strings.ToLower(t.text)}, nil
+	case tokEOF:
+		return nil, &QueryError{Pos: t.pos, Msg: "unexpected end of expression, expected a term"}
+	default:
+		return nil, &QueryError{Pos: t.pos, Msg: fmt.Sprintf("unexpected %s, expected a term", t)}
+	}
+}
@@ -0,0 +1,234 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"rss_bot/internal/model"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want string
+	}{
+		{name: "single word", expr: "Kubernetes", want: `all:"kubernetes"`},
+		{name: "implicit and", expr: "go rust", want: `all:"go" AND all:"rust"`},
+		{name: "operators are case insensitive", expr: "go or rust and not zig", want: `all:"go" OR (all:"rust" AND NOT all:"zig")`},
+		{name: "and binds tighter than or", expr: "a OR b AND c", want: `all:"a" OR (all:"b" AND all:"c")`},
+		{name: "grouping", expr: "(a OR b) AND c", want: `(all:"a" OR all:"b") AND all:"c"`},
+		{name: "phrase", expr: `"release notes" AND go`, want: `all:"release notes" AND all:"go"`},
+		{name: "quoted operator is a term", expr: `"and"`, want: `all:"and"`},
+		{name: "scope prefix", expr: "title:deploy content:k8s", want: `title:"deploy" AND content:"k8s"`},
+		{name: "scope prefix on phrase", expr: `title:"open source"`, want: `title:"open source"`},
+		{name: "scope prefix on group", expr: "title:(go OR rust) news", want: `(title:"go" OR title:"rust") AND all:"news"`},
+		{name: "negated group", expr: "NOT (a b)", want: `NOT (all:"a" AND all:"b")`},
+		{name: "extended scope prefixes", expr: `author:"rob pike" link:go.dev`, want: `author:"rob pike" AND link:"go.dev"`},
+		{name: "unknown prefix is literal", expr: "https://go.dev", want: `all:"https://go.dev"`},
+		{
+			name: "full example",
+			expr: `kubernetes AND (security OR "CVE") AND NOT title:webinar`,
+			want: `all:"kubernetes" AND (all:"security" OR all:"cve") AND NOT title:"webinar"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := ParseQuery(tt.expr, model.ScopeAll)
+			if err != nil {
+				t.Fatalf("ParseQuery(%q): %v", tt.expr, err)
+			}
+			if diff := cmp.Diff(tt.want, q.String()); diff != "" {
+				t.Errorf("ParseQuery() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseQueryDefaultScope(t *testing.T) {
+	q, err := ParseQuery("deploy content:k8s", model.ScopeTitle)
+	if err != nil {
+		t.Fatalf("ParseQuery: %v", err)
+	}
+	if diff := cmp.Diff(`title:"deploy" AND content:"k8s"`, q.String()); diff != "" {
+		t.Errorf("ParseQuery() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want string
+	}{
+		{name: "empty", expr: "   ", want: "position 1: empty expression"},
+		{name: "trailing operator", expr: "go AND", want: "position 7: unexpected end of expression, expected a term"},
+		{name: "leading operator", expr: "OR go", want: "position 1: unexpected OR, expected a term"},
+		{name: "double operator", expr: "go AND OR rust", want: "position 8: unexpected OR, expected a term"},
+		{name: "missing close paren", expr: "(go OR rust", want: "position 1: missing ')' for this '('"},
+		{name: "unmatched close paren", expr: "go) rust", want: "position 3: unexpected ')'"},
+		{name: "empty group", expr: "go ()", want: "position 5: unexpected ')', expected a term"},
+		{name: "unterminated quote", expr: `go "release notes`, want: "position 4: unterminated quote"},
+		{name: "empty phrase", expr: `go ""`, want: "position 4: empty phrase"},
+		{name: "scope without term", expr: "title: go", want: `position 1: missing term after "title:"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseQuery(tt.expr, model.ScopeAll)
+			if err == nil {
+				t.Fatalf("ParseQuery(%q) expected error", tt.expr)
+			}
+			if diff := cmp.Diff(tt.want, err.Error()); diff != "" {
+				t.Errorf("error mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMatchQuery(t *testing.T) {
+	query := func(value string) []model.Filter {
+		return []model.Filter{{Kind: model.FilterQuery, Scope: model.ScopeAll, Value: value}}
+	}
+	const k8s = `kubernetes AND (security OR "CVE") AND NOT title:webinar`
+
+	tests := []struct {
+		name    string
+		item    FeedItem
+		filters []model.Filter
+		want    bool
+	}{
+		{
+			name:    "all terms match",
+			item:    FeedItem{Title: "Kubernetes security release", Description: "Patch now"},
+			filters: query(k8s),
+			want:    true,
+		},
+		{
+			name:    "alternative in group matches",
+			item:    FeedItem{Title: "Kubernetes 1.32", Description: "Fixes CVE-2024-1234"},
+			filters: query(k8s),
+			want:    true,
+		},
+		{
+			name:    "missing required term",
+			item:    FeedItem{Title: "Docker security release", Description: ""},
+			filters: query(k8s),
+			want:    false,
+		},
+		{
+			name:    "negated term in title",
+			item:    FeedItem{Title: "Kubernetes security webinar", Description: ""},
+			filters: query(k8s),
+			want:    false,
+		},
+		{
+			name:    "negated title term only in content still passes",
+			item:    FeedItem{Title: "Kubernetes security", Description: "Recording of the webinar"},
+			filters: query(k8s),
+			want:    true,
+		},
+		{
+			name:    "cyrillic phrase",
+			item:    FeedItem{Title: "Деплой в Kubernetes", Description: ""},
+			filters: query(`"деплой в"`),
+			want:    true,
+		},
+		{
+			name: "filter scope applies to unprefixed terms",
+			item: FeedItem{Title: "Release notes", Description: "kubernetes"},
+			filters: []model.Filter{
+				{Kind: model.FilterQuery, Scope: model.ScopeTitle, Value: "kubernetes"},
+			},
+			want: false,
+		},
+		{
+			name: "query combined with exclude",
+			item: FeedItem{Title: "Kubernetes security vacancy", Description: ""},
+			filters: append(query(k8s),
+				model.Filter{Kind: model.FilterExclude, Scope: model.ScopeAll, Value: "vacancy"},
+			),
+			want: false,
+		},
+		{
+			name: "query and include must both pass",
+			item: FeedItem{Title: "Kubernetes security", Description: ""},
+			filters: append(query(k8s),
+				model.Filter{Kind: model.FilterInclude, Scope: model.ScopeAll, Value: "docker"},
+			),
+			want: false,
+		},
+		{
+			name: "all queries must match",
+			item: FeedItem{Title: "Kubernetes security", Description: ""},
+			filters: append(query(k8s),
+				model.Filter{Kind: model.FilterQuery, Scope: model.ScopeAll, Value: "golang"},
+			),
+			want: false,
+		},
+		{
+			name:    "invalid query never matches",
+			item:    FeedItem{Title: "anything", Description: ""},
+			filters: query("(anything"),
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Match(tt.item, tt.filters)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Match() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestQueryFTS(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		want    string
+		wantErr string
+	}{
+		{name: "term", expr: "kube", want: `"kube"*`},
+		{name: "scoped phrase", expr: `title:"release notes"`, want: `title : "release notes"*`},
+		{
+			name: "and, or, not",
+			expr: `kubernetes AND (security OR "CVE") AND NOT title:webinar`,
+			want: `("kubernetes"* AND ("security"* OR "cve"*)) NOT (title : "webinar"*)`,
+		},
+		{name: "several negations", expr: "go NOT rust NOT java", want: `"go"* NOT ("rust"* OR "java"*)`},
+		{name: "negated group", expr: "go NOT (rust OR java)", want: `"go"* NOT (("rust"* OR "java"*))`},
+		{name: "only negated", expr: "NOT webinar", wantErr: ErrOnlyNegated.Error()},
+		{name: "negated alternative", expr: "go OR NOT rust", wantErr: ErrOnlyNegated.Error()},
+		{name: "unsearchable scope", expr: "categories:news", wantErr: "categories: cannot be searched, use title, content, author or link"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := ParseQuery(tt.expr, model.ScopeAll)
+			if err != nil {
+				t.Fatalf("ParseQuery(%q): %v", tt.expr, err)
+			}
+			got, err := q.FTS()
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatalf("FTS() = %q, want error", got)
+				}
+				if diff := cmp.Diff(tt.wantErr, err.Error()); diff != "" {
+					t.Errorf("error mismatch (-want +got):\n%s", diff)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FTS(): %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("FTS() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"rss_bot/internal/model"
+)
+
+// ErrOnlyNegated is returned by FTS for a query, or an OR alternative, that
+// only excludes words: a full-text index can only look up words that are there.
+var ErrOnlyNegated = errors.New("a search needs a term that is not negated, e.g. kubernetes AND NOT webinar")
+
+// FTS translates the query into an SQLite FTS5 match expression over the
+// columns title, content, author, link and feed. Terms without a scope match
+// every column. Since FTS5 matches words rather than substrings, a term
+// matches the words it starts: "kube" finds "Kubernetes" but "netes" does not.
+func (q *Query) FTS() (string, error) {
+	return ftsExpr(q.root)
+}
+
+func ftsExpr(x queryNode) (string, error) {
+	switch n := x.(type) {
+	case *termNode:
+		return ftsTerm(n)
+	case *andNode:
+		return ftsAnd(n)
+	case *orNode:
+		parts := make([]string, len(n.xs))
+		for i, x := range n.xs {
+			s, err := ftsExpr(x)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = ftsGroup(x, s)
+		}
+		return strings.Join(parts, " OR "), nil
+	default:
+		return "", ErrOnlyNegated
+	}
+}
+
+// ftsAnd joins the positive terms with AND and excludes the negated ones
+// with NOT, since FTS5 only has a binary NOT.
+func ftsAnd(n *andNode) (string, error) {
+	var pos, neg []string
+	for _, x := range n.xs {
+		if not, ok := x.(*notNode); ok {
+			s, err := ftsExpr(not.x)
+			if err != nil {
+				return "", err
+			}
+			neg = append(neg, ftsGroup(not.x, s))
+			continue
+		}
+		s, err := ftsExpr(x)
+		if err != nil {
+			return "", err
+		}
+		pos = append(pos, ftsGroup(x, s))
+	}
+	if len(pos) == 0 {
+		return "", ErrOnlyNegated
+	}
+	expr := strings.Join(pos, " AND ")
+	if len(neg) == 0 {
+		return expr, nil
+	}
+	if len(pos) > 1 {
+		expr = "(" + expr + ")"
+	}
+	return expr + " NOT (" + strings.Join(neg, " OR ") + ")", nil
+}
+
+// ftsTerm quotes the term as a prefix phrase, restricted to its scope's column.
+func ftsTerm(n *termNode) (string, error) {
+	phrase := `"` + strings.ReplaceAll(n.text, `"`, `""`) + `"*`
+	switch n.scope {
+	case model.ScopeAll:
+		return phrase, nil
+	case model.ScopeTitle, model.ScopeContent, model.ScopeAuthor, model.ScopeLink:
+		return string(n.scope) + " : " + phrase, nil
+	default:
+		return "", fmt.Errorf("%s: cannot be searched, use title, content, author or link", n.scope)
+	}
+}
+
+// ftsGroup parenthesizes the translation of AND and OR nodes.
+func ftsGroup(x queryNode, s string) string {
+	switch x.(type) {
+	case *andNode, *orNode:
+		return "(" + s + ")"
+	}
+	return s
+}
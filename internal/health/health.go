@@ -0,0 +1,139 @@
+// Package health serves liveness and readiness checks of the bot.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+const (
+	// checkAgeTicks is how many scheduler intervals may pass without progress
+	// before the scheduler counts as stuck.
+	checkAgeTicks     = 10
+	defaultMaxLoopAge = 2 * time.Minute
+	pingTimeout       = 2 * time.Second
+)
+
+// Pinger reports whether the database is reachable.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Checker inspects the database, the scheduler and the Telegram updates loop.
+type Checker struct {
+	db          Pinger
+	lastCheck   func() time.Time
+	lastLoop    func() time.Time
+	started     time.Time
+	maxCheckAge time.Duration
+	maxLoopAge  time.Duration
+	now         func() time.Time
+}
+
+// New creates a Checker for a scheduler that looks for due feeds every tick.
+// lastCheck returns when the scheduler last made progress and lastLoop when
+// the updates loop last showed it was running; both return the zero time if
+// that has not happened yet.
+func New(db Pinger, tick time.Duration, lastCheck, lastLoop func() time.Time) *Checker {
+	return &Checker{
+		db:          db,
+		lastCheck:   lastCheck,
+		lastLoop:    lastLoop,
+		started:     time.Now(),
+		maxCheckAge: checkAgeTicks * tick,
+		maxLoopAge:  defaultMaxLoopAge,
+		now:         time.Now,
+	}
+}
+
+// SetLimits sets how long ago the scheduler's last progress and the last sign
+// of the updates loop may be before the bot counts as stuck.
+func (c *Checker) SetLimits(maxCheckAge, maxLoopAge time.Duration) {
+	c.maxCheckAge = maxCheckAge
+	c.maxLoopAge = maxLoopAge
+}
+
+// Report is the outcome of a check, served as JSON.
+type Report struct {
+	Status   string `json:"status"`
+	Database string `json:"database"`
+	// LastCheck is when the scheduler last made progress with feed checks.
+	LastCheck             *time.Time `json:"last_check,omitempty"`
+	SinceLastCheckSeconds *float64   `json:"since_last_check_seconds,omitempty"`
+	UpdatesLoop           string     `json:"updates_loop"`
+}
+
+// Values of the Report fields.
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+
+	LoopAlive      = "alive"
+	LoopStalled    = "stalled"
+	LoopNotStarted = "not started"
+)
+
+// Check reports on the bot. When ready is false, a scheduler or updates loop
+// that has not started yet is given the same time to start as it has to keep
+// running; when ready is true, both must have started.
+func (c *Checker) Check(ctx context.Context, ready bool) Report {
+	now := c.now()
+	r := Report{Status: StatusOK, Database: StatusOK}
+
+	pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	if err := c.db.Ping(pingCtx); err != nil {
+		r.Database = err.Error()
+		r.Status = StatusFail
+	}
+
+	if last := c.lastCheck(); !last.IsZero() {
+		since := now.Sub(last).Seconds()
+		r.LastCheck, r.SinceLastCheckSeconds = &last, &since
+		if now.Sub(last) > c.maxCheckAge {
+			r.Status = StatusFail
+		}
+	} else if ready || now.Sub(c.started) > c.maxCheckAge {
+		r.Status = StatusFail
+	}
+
+	switch last := c.lastLoop(); {
+	case last.IsZero():
+		r.UpdatesLoop = LoopNotStarted
+		if ready || now.Sub(c.started) > c.maxLoopAge {
+			r.Status = StatusFail
+		}
+	case now.Sub(last) > c.maxLoopAge:
+		r.UpdatesLoop = LoopStalled
+		r.Status = StatusFail
+	default:
+		r.UpdatesLoop = LoopAlive
+	}
+	return r
+}
+
+// Healthz serves the liveness check: it fails when the database is
+// unreachable or the scheduler or updates loop is stuck.
+func (c *Checker) Healthz() http.Handler {
+	return c.handler(false)
+}
+
+// Readyz serves the readiness check: it also fails until the scheduler has
+// checked its first feeds and the updates loop has started.
+func (c *Checker) Readyz() http.Handler {
+	return c.handler(true)
+}
+
+func (c *Checker) handler(ready bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := c.Check(r.Context(), ready)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if report.Status != StatusOK {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(report)
+	})
+}
@@ -0,0 +1,141 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type fakeDB struct {
+	err error
+}
+
+func (f fakeDB) Ping(context.Context) error {
+	return f.err
+}
+
+func TestCheck(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	recent := now.Add(-time.Minute)
+	old := now.Add(-time.Hour)
+
+	tests := []struct {
+		name      string
+		db        error
+		tick      time.Duration
+		uptime    time.Duration
+		lastCheck time.Time
+		lastLoop  time.Time
+		ready     bool
+		want      Report
+	}{
+		{
+			name:      "healthy",
+			uptime:    time.Hour,
+			lastCheck: recent,
+			lastLoop:  recent,
+			want:      Report{Status: StatusOK, Database: StatusOK, LastCheck: &recent, SinceLastCheckSeconds: ptr(60.0), UpdatesLoop: LoopAlive},
+		},
+		{
+			name:      "database unreachable",
+			db:        errors.New("disk I/O error"),
+			uptime:    time.Hour,
+			lastCheck: recent,
+			lastLoop:  recent,
+			want:      Report{Status: StatusFail, Database: "disk I/O error", LastCheck: &recent, SinceLastCheckSeconds: ptr(60.0), UpdatesLoop: LoopAlive},
+		},
+		{
+			name:      "scheduler stuck",
+			uptime:    2 * time.Hour,
+			lastCheck: old,
+			lastLoop:  recent,
+			want:      Report{Status: StatusFail, Database: StatusOK, LastCheck: &old, SinceLastCheckSeconds: ptr(3600.0), UpdatesLoop: LoopAlive},
+		},
+		{
+			name:      "scheduler with a long interval",
+			tick:      10 * time.Minute,
+			uptime:    2 * time.Hour,
+			lastCheck: old,
+			lastLoop:  recent,
+			want:      Report{Status: StatusOK, Database: StatusOK, LastCheck: &old, SinceLastCheckSeconds: ptr(3600.0), UpdatesLoop: LoopAlive},
+		},
+		{
+			name:      "updates loop stalled",
+			uptime:    2 * time.Hour,
+			lastCheck: recent,
+			lastLoop:  old,
+			want:      Report{Status: StatusFail, Database: StatusOK, LastCheck: &recent, SinceLastCheckSeconds: ptr(60.0), UpdatesLoop: LoopStalled},
+		},
+		{
+			name:   "starting up is alive",
+			uptime: 10 * time.Second,
+			want:   Report{Status: StatusOK, Database: StatusOK, UpdatesLoop: LoopNotStarted},
+		},
+		{
+			name:   "starting up is not ready",
+			uptime: 10 * time.Second,
+			ready:  true,
+			want:   Report{Status: StatusFail, Database: StatusOK, UpdatesLoop: LoopNotStarted},
+		},
+		{
+			name:   "never started",
+			uptime: time.Hour,
+			want:   Report{Status: StatusFail, Database: StatusOK, UpdatesLoop: LoopNotStarted},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tick := tt.tick
+			if tick == 0 {
+				tick = time.Minute
+			}
+			c := New(fakeDB{tt.db}, tick, func() time.Time { return tt.lastCheck }, func() time.Time { return tt.lastLoop })
+			c.started = now.Add(-tt.uptime)
+			c.now = func() time.Time { return now }
+			if diff := cmp.Diff(tt.want, c.Check(context.Background(), tt.ready)); diff != "" {
+				t.Errorf("Check mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	now := time.Now()
+	c := New(fakeDB{}, time.Minute, func() time.Time { return time.Time{} }, func() time.Time { return now })
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		want    int
+	}{
+		{"healthz while the first check runs", c.Healthz(), http.StatusOK},
+		{"readyz before the first check", c.Readyz(), http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			if diff := cmp.Diff(tt.want, rec.Code); diff != "" {
+				t.Errorf("status (-want +got):\n%s", diff)
+			}
+			var report Report
+			if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+				t.Fatalf("decode report: %v", err)
+			}
+			if diff := cmp.Diff(LoopAlive, report.UpdatesLoop); diff != "" {
+				t.Errorf("updates loop (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
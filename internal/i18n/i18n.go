@@ -0,0 +1,101 @@
+// Package i18n translates the messages of the bot. Messages are looked up by
+// their English text, so English needs no catalog and a message missing from
+// a catalog is shown in English.
+package i18n
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Lang is a language the bot speaks.
+type Lang string
+
+// Supported languages.
+const (
+	English Lang = "en"
+	Russian Lang = "ru"
+)
+
+// Languages lists the supported languages in the order shown to users.
+var Languages = []Lang{English, Russian}
+
+var catalogs = map[Lang]map[string]string{
+	Russian: russian,
+}
+
+var names = map[Lang]string{
+	English: "English",
+	Russian: "Русский",
+}
+
+// Parse returns the supported language of a Telegram language code such as
+// "ru" or "en-US".
+func Parse(code string) (Lang, bool) {
+	base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(code)), "-")
+	for _, l := range Languages {
+		if string(l) == base {
+			return l, true
+		}
+	}
+	return "", false
+}
+
+// Choose returns the language set for a chat, or else the one of its
+// Telegram client, or else English.
+func Choose(chosen, telegram string) Lang {
+	if l, ok := Parse(chosen); ok {
+		return l
+	}
+	if l, ok := Parse(telegram); ok {
+		return l
+	}
+	return English
+}
+
+// Name returns the name of the language in itself.
+func (l Lang) Name() string {
+	if n, ok := names[l]; ok {
+		return n
+	}
+	return string(l)
+}
+
+// T returns the translation of msg.
+func (l Lang) T(msg string) string {
+	if t, ok := catalogs[l][msg]; ok {
+		return t
+	}
+	return msg
+}
+
+// Sprintf formats the translation of format.
+func (l Lang) Sprintf(format string, args ...any) string {
+	return fmt.Sprintf(l.T(format), args...)
+}
+
+// Error returns the message of err in l. Errors made by Errorf are
+// translated; others are shown as they are.
+func (l Lang) Error(err error) string {
+	var e *Message
+	if errors.As(err, &e) {
+		return l.Sprintf(e.Format, e.Args...)
+	}
+	return err.Error()
+}
+
+// Message is an error meant for the user, translated when it is shown.
+type Message struct {
+	Format string
+	Args   []any
+}
+
+// Errorf returns an error whose message can be translated by Lang.Error.
+func Errorf(format string, args ...any) error {
+	return &Message{Format: format, Args: args}
+}
+
+func (e *Message) Error() string {
+	return fmt.Sprintf(e.Format, e.Args...)
+}
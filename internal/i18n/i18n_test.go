@@ -0,0 +1,122 @@
+package i18n
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestChoose(t *testing.T) {
+	tests := []struct {
+		name     string
+		chosen   string
+		telegram string
+		want     Lang
+	}{
+		{"nothing known", "", "", English},
+		{"telegram language", "", "ru", Russian},
+		{"telegram region", "", "ru-RU", Russian},
+		{"unsupported telegram language", "", "de", English},
+		{"chosen overrides telegram", "en", "ru", English},
+		{"unknown choice falls back", "xx", "ru", Russian},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, Choose(tt.chosen, tt.telegram)); diff != "" {
+				t.Errorf("Choose(%q, %q) (-want +got):\n%s", tt.chosen, tt.telegram, diff)
+			}
+		})
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	if diff := cmp.Diff("Лента #3 не найдена.", Russian.Sprintf("Feed #%d not found.", 3)); diff != "" {
+		t.Errorf("Sprintf (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff("Feed #3 not found.", English.Sprintf("Feed #%d not found.", 3)); diff != "" {
+		t.Errorf("Sprintf (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff("no such message", Russian.T("no such message")); diff != "" {
+		t.Errorf("missing message (-want +got):\n%s", diff)
+	}
+
+	err := fmt.Errorf("parse: %w", Errorf("invalid feed number %q", "x"))
+	if diff := cmp.Diff(`неверный номер ленты "x"`, Russian.Error(err)); diff != "" {
+		t.Errorf("Error (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff("boom", Russian.Error(errors.New("boom"))); diff != "" {
+		t.Errorf("Error of a plain error (-want +got):\n%s", diff)
+	}
+}
+
+var verbRe = regexp.MustCompile(`%(\[\d+\])?[a-z]`)
+
+// TestCatalogVerbs checks that every translation takes the arguments of its
+// message.
+func TestCatalogVerbs(t *testing.T) {
+	for lang, catalog := range catalogs {
+		for msg, translation := range catalog {
+			var args []any
+			for _, verb := range verbRe.FindAllString(msg, -1) {
+				if strings.HasSuffix(verb, "d") {
+					args = append(args, 1)
+				} else {
+					args = append(args, "x")
+				}
+			}
+			if got := fmt.Sprintf(translation, args...); strings.Contains(got, "%!") {
+				t.Errorf("%s translation of %q does not fit its arguments: %s", lang, msg, got)
+			}
+		}
+	}
+}
+
+// messageRe finds the messages passed to the catalog in the sources.
+var messageRe = regexp.MustCompile("(\\w+|\\))\\.(?:T|Sprintf|Errorf)\\(\\s*(\"(?:[^\"\\\\]|\\\\.)*\"|`[^`]*`)")
+
+// TestCatalogComplete checks that every message of the bot is translated.
+func TestCatalogComplete(t *testing.T) {
+	root := filepath.Join("..")
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return err
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for _, m := range messageRe.FindAllStringSubmatch(string(src), -1) {
+			if m[1] == "fmt" {
+				continue
+			}
+			msg := m[2]
+			if msg[0] == '"' {
+				if msg, err = strconv.Unquote(msg); err != nil {
+					t.Errorf("%s: %v", path, err)
+					continue
+				}
+			} else {
+				msg = msg[1 : len(msg)-1]
+			}
+			for _, lang := range Languages {
+				if lang == English {
+					continue
+				}
+				if _, ok := catalogs[lang][msg]; !ok {
+					t.Errorf("%s: no %s translation of %q", path, lang, msg)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
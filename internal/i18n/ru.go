@@ -0,0 +1,329 @@
+package i18n
+
+// russian is the Russian catalog. Counts are written as "label: N" to avoid
+// plural forms.
+var russian = map[string]string{
+	// Commands.
+	"Access denied.": "Доступ запрещён.",
+	"Unknown command. Use /help for a list of commands.": "Неизвестная команда. Список команд: /help.",
+	`Welcome to RSS Notify Bot!
+
+Subscribe to RSS feeds and get filtered notifications.
+
+Quick start:
+1. /add <url> — add an RSS feed
+2. /include <id> <word> — add a whitelist filter
+3. /exclude <id> <word> — add a blacklist filter
+
+Use /help for the full command reference.`: `Добро пожаловать в RSS Notify Bot!
+
+Подписывайтесь на RSS-ленты и получайте отфильтрованные уведомления.
+
+Быстрый старт:
+1. /add <url> — добавить RSS-ленту
+2. /include <id> <слово> — добавить фильтр-разрешение
+3. /exclude <id> <слово> — добавить фильтр-запрет
+
+Полный список команд: /help.`,
+	`Feed management:
+/add <url> — add a new RSS feed
+/list — show all feeds
+/info <id> — feed details
+/remove <id> — delete a feed
+/rename <id> <name> — rename a feed
+/interval <id> <min> — set check interval (1-1440)
+/pause <id> — pause checking
+/resume <id> — resume checking
+/check <id> — force check now
+/test <id> — show which items pass the filters, without sending them
+/markread <id> — mark all current items as read
+/backlog <id> <all|newest N|ask N|skip> — what to send after add or resume
+/digest [on|off] — collect items into a digest instead of one message each
+/digest at <HH:MM> [HH:MM ...] — when to send the digest
+/digest <id> <on|off|default> — digest mode of a single feed
+/quiet <HH:MM-HH:MM|off> — quiet hours, e.g. /quiet 23:00-08:00
+/quiet <hold|silent> — hold notifications until quiet hours end, or send them silently
+/quiet <id> <hold|silent|default> — quiet mode of a single feed
+/timezone <Area/City> — time zone for digest times, quiet hours and feed info
+/settings — time zone, defaults for new feeds and filters, notification look
+/language <en|ru|auto> — language of the bot
+/search <query> — search the items delivered to this chat, with the syntax of /query
+/export — download your feeds as OPML
+Send an OPML file to import feeds.
+
+Filter management:
+/filters <id> — show filters for a feed
+/include <id> [-s scope] <word> — whitelist word/phrase
+/exclude <id> [-s scope] <word> — blacklist word/phrase
+/include_re <id> [-s scope] <regex> — whitelist regex
+/exclude_re <id> [-s scope] <regex> — blacklist regex
+/query <id> [-s scope] <expression> — boolean query, e.g.
+  kubernetes AND (security OR "CVE") AND NOT title:webinar
+/rmfilter <filter_id> — remove a filter
+
+Scope flag: -s title | content | all | author | categories | link | enclosures (default: all)`: `Ленты:
+/add <url> — добавить RSS-ленту
+/list — все ленты
+/info <id> — подробности о ленте
+/remove <id> — удалить ленту
+/rename <id> <имя> — переименовать ленту
+/interval <id> <мин> — интервал проверки (1-1440)
+/pause <id> — приостановить проверку
+/resume <id> — возобновить проверку
+/check <id> — проверить сейчас
+/test <id> — какие записи проходят фильтры, без отправки
+/markread <id> — отметить все текущие записи прочитанными
+/backlog <id> <all|newest N|ask N|skip> — что отправить после добавления или возобновления
+/digest [on|off] — собирать записи в дайджест вместо отдельных сообщений
+/digest at <ЧЧ:ММ> [ЧЧ:ММ ...] — когда отправлять дайджест
+/digest <id> <on|off|default> — режим дайджеста для одной ленты
+/quiet <ЧЧ:ММ-ЧЧ:ММ|off> — тихие часы, например /quiet 23:00-08:00
+/quiet <hold|silent> — задерживать уведомления до конца тихих часов или присылать без звука
+/quiet <id> <hold|silent|default> — режим тихих часов для одной ленты
+/timezone <Область/Город> — часовой пояс для дайджеста, тихих часов и сведений о лентах
+/settings — часовой пояс, значения по умолчанию для новых лент и фильтров, вид уведомлений
+/language <en|ru|auto> — язык бота
+/search <запрос> — поиск по записям, доставленным в этот чат, синтаксис как у /query
+/export — выгрузить ленты в OPML
+Пришлите OPML-файл, чтобы импортировать ленты.
+
+Фильтры:
+/filters <id> — фильтры ленты
+/include <id> [-s область] <слово> — разрешить слово/фразу
+/exclude <id> [-s область] <слово> — запретить слово/фразу
+/include_re <id> [-s область] <regex> — разрешить по регулярному выражению
+/exclude_re <id> [-s область] <regex> — запретить по регулярному выражению
+/query <id> [-s область] <выражение> — логический запрос, например
+  kubernetes AND (security OR "CVE") AND NOT title:webinar
+/rmfilter <filter_id> — удалить фильтр
+
+Область поиска: -s title | content | all | author | categories | link | enclosures (по умолчанию: all)`,
+
+	// Common replies.
+	"Error: %v":                "Ошибка: %v",
+	"Feed not found.":          "Лента не найдена.",
+	"Feed #%d not found.":      "Лента #%d не найдена.",
+	"Failed to fetch: %v":      "Не удалось загрузить: %v",
+	"Failed to fetch feed: %v": "Не удалось загрузить ленту: %v",
+	"This button has expired.": "Срок действия кнопки истёк.",
+	"This choice has expired.": "Срок выбора истёк.",
+	"Feed #%d is being checked right now. Try again in a moment.": "Лента #%d сейчас проверяется. Попробуйте чуть позже.",
+
+	// Feeds.
+	"Usage: /add <url>": "Использование: /add <url>",
+	"%s is a web page and no feeds were found on it.": "%s — веб-страница, и лент на ней не найдено.",
+	"Found %d feeds on %s. Pick one to add:":          "Найдено лент на %[2]s: %[1]d. Выберите ленту:",
+	"This choice has expired. Send /add again.":       "Срок выбора истёк. Отправьте /add ещё раз.",
+	"Failed to save feed: %v":                         "Не удалось сохранить ленту: %v",
+	"Feed added successfully!\n\n#%d %s (every %d min)\nURL: %s\nNo filters yet. Use /include, /exclude to add filters.": "Лента добавлена!\n\n#%d %s (каждые %d мин)\nURL: %s\nФильтров пока нет. Добавьте их командами /include, /exclude.",
+	"Usage: /info <number>":                     "Использование: /info <номер>",
+	"Usage: /remove <number>":                   "Использование: /remove <номер>",
+	"Error deleting feed: %v":                   "Ошибка удаления ленты: %v",
+	"Feed #%d \"%s\" deleted.":                  "Лента #%d «%s» удалена.",
+	"Delete #%d \"%s\"? This cannot be undone.": "Удалить #%d «%s»? Это нельзя отменить.",
+	"Yes, delete":                               "Да, удалить",
+	"Cancel":                                    "Отмена",
+	"Feed #%d renamed to \"%s\".":               "Лента #%d переименована в «%s».",
+	"Feed #%d interval set to %d min.":          "Интервал ленты #%d: %d мин.",
+	"Usage: /pause <number>":                    "Использование: /pause <номер>",
+	"Feed #%d \"%s\" paused.":                   "Лента #%d «%s» приостановлена.",
+	"Usage: /resume <number>":                   "Использование: /resume <номер>",
+	"Feed #%d \"%s\" resumed.":                  "Лента #%d «%s» возобновлена.",
+	"Usage: /check <number>":                    "Использование: /check <номер>",
+	"No new matching items in #%d \"%s\".":      "В #%d «%s» нет новых подходящих записей.",
+	"Found %d new item(s) in #%d \"%s\".":       "Новых записей в #%[2]d «%[3]s»: %[1]d.",
+	"Usage: /test <number>":                     "Использование: /test <номер>",
+	"Could not retrieve content.":               "Не удалось получить текст.",
+	"Full content not available.":               "Полный текст недоступен.",
+	"Show more":                                 "Показать полностью",
+	"Feed #%d \"%s\" was paused: %s.\nLast error: %s\nUse /resume %d to try again.": "Лента #%d «%s» приостановлена: %s.\nПоследняя ошибка: %s\nЧтобы попробовать снова: /resume %d.",
+	"the server reports the feed is gone (HTTP 410)":                                "сервер сообщает, что лента удалена (HTTP 410)",
+	"%d consecutive failed checks":                                                  "неудачных проверок подряд: %d",
+
+	// Feed list and info.
+	"You have no feeds yet. Use /add <url> to add one.": "Лент пока нет. Добавьте ленту командой /add <url>.",
+	"Your feeds:":                           "Ваши ленты:",
+	"active":                                "активна",
+	"paused":                                "приостановлена",
+	"no filters":                            "нет фильтров",
+	"%d include, %d exclude":                "разрешающих: %d, запрещающих: %d",
+	"\n#%d %s [%s]\nURL: %s\nFilters: %s\n": "\n#%d %s [%s]\nURL: %s\nФильтры: %s\n",
+	"Interval: every %d min\n":              "Интервал: каждые %d мин\n",
+	"Backlog: %s\n":                         "Накопившиеся записи: %s\n",
+	"Waiting for a backlog choice since %s: /check sends everything, /markread skips it\n": "Ждёт выбора накопившихся записей с %s: /check отправит все, /markread пропустит\n",
+	"Delivery: %s\n":          "Доставка: %s\n",
+	"Quiet hours: %s\n":       "Тихие часы: %s\n",
+	"Last check: %s\n":        "Последняя проверка: %s\n",
+	"Failures: %d in a row\n": "Ошибок подряд: %d\n",
+	"Last error: %s\n":        "Последняя ошибка: %s\n",
+	"Next retry: %s\n":        "Следующая попытка: %s\n",
+	"Filters:":                "Фильтры:",
+	"Recent failures:":        "Последние ошибки:",
+
+	// Backlog.
+	"Usage: /backlog <number> <all|newest N|ask N|skip>": "Использование: /backlog <номер> <all|newest N|ask N|skip>",
+	"The number of items must be between 1 and 100.":     "Число записей должно быть от 1 до 100.",
+	"Backlog policy of #%d \"%s\": %s.":                  "Накопившиеся записи #%d «%s»: %s.",
+	"Usage: /markread <number>":                          "Использование: /markread <номер>",
+	"Marked %d item(s) in #%d \"%s\" as read.":           "Отмечено прочитанными в #%[2]d «%[3]s»: %[1]d.",
+	"Sent %d item(s) of #%d \"%s\", marked %d as read.":  "#%[2]d «%[3]s»: отправлено %[1]d, отмечено прочитанными %[4]d.",
+	"#%d \"%s\" has %d new items. What should be sent?":  "В #%d «%s» новых записей: %d. Что отправить?",
+	"Send all (%d)":         "Все (%d)",
+	"Send %d":               "Последние %d",
+	"Mark read":             "Отметить прочитанными",
+	"send all":              "отправлять все",
+	"mark read":             "отмечать прочитанными",
+	"ask when more than %d": "спрашивать, если больше %d",
+	"newest %d":             "последние %d",
+
+	// Filters.
+	"Usage: /filters <number>":      "Использование: /filters <номер>",
+	"Filters for #%d \"%s\":\n\n%s": "Фильтры #%d «%s»:\n\n%s",
+	"Invalid regex: %v":             "Неверное регулярное выражение: %v",
+	"%v\n\nExample: /query %d kubernetes AND (security OR \"CVE\") AND NOT title:webinar":                  "%v\n\nПример: /query %d kubernetes AND (security OR \"CVE\") AND NOT title:webinar",
+	"Filter F%d added to #%d \"%s\".\n\nActual filters:\n\n%s":                                             "Фильтр F%d добавлен к #%d «%s».\n\nТекущие фильтры:\n\n%s",
+	"Usage: /rmfilter <filter_number>":                                                                     "Использование: /rmfilter <номер_фильтра>",
+	"Filter F%d not found.":                                                                                "Фильтр F%d не найден.",
+	"Filter F%d removed from #%d \"%s\".\n\nActual filters:\n\n%s":                                         "Фильтр F%d удалён из #%d «%s».\n\nТекущие фильтры:\n\n%s",
+	"Filter F%d removed from #%d \"%s\".\n\n%s":                                                            "Фильтр F%d удалён из #%d «%s».\n\n%s",
+	"No filters for #%d \"%s\".\nUse /include, /exclude, /include_re, /exclude_re, /query to add filters.": "У #%d «%s» нет фильтров.\nДобавьте их командами /include, /exclude, /include_re, /exclude_re, /query.",
+	"Include (word)":  "Разрешить (слово)",
+	"Include (regex)": "Разрешить (regex)",
+	"Exclude (word)":  "Запретить (слово)",
+	"Exclude (regex)": "Запретить (regex)",
+	"Query":           "Запрос",
+	"title only":      "только заголовок",
+	"content only":    "только текст",
+	"author":          "автор",
+	"categories":      "категории",
+	"link":            "ссылка",
+	"enclosures":      "вложения",
+	"title+content":   "заголовок и текст",
+
+	// Filter test.
+	"Feed #%d \"%s\" has no items.":                "В ленте #%d «%s» нет записей.",
+	"Test of #%d \"%s\": %d of %d item(s) pass.\n": "Проверка #%d «%s»: проходят %d из %d.\n",
+	"…and %d more item(s).":                        "…и ещё записей: %d.",
+	"(already seen)":                               "(уже отправлена)",
+	"passed: no filters":                           "пропущена: фильтров нет",
+	"passed by %s":                                 "пропущена фильтром %s",
+	"passed: no exclude filter matched":            "пропущена: ни один запрещающий фильтр не сработал",
+	"rejected by %s":                               "отклонена фильтром %s",
+	"rejected: %s did not match":                   "отклонена: %s не совпал",
+	"rejected: no include filter matched":          "отклонена: ни один разрешающий фильтр не сработал",
+
+	// Parse errors.
+	"usage: <feed_number> [-s scope] <value>": "использование: <номер_ленты> [-s область] <значение>",
+	"invalid feed number %q":                  "неверный номер ленты %q",
+	"invalid scope %q, use: title, content, all, author, categories, link, enclosures": "неверная область %q, допустимо: title, content, all, author, categories, link, enclosures",
+	"filter value is required":                    "нужно указать значение фильтра",
+	"feed number is required":                     "нужно указать номер ленты",
+	"filter number is required":                   "нужно указать номер фильтра",
+	"invalid filter number %q":                    "неверный номер фильтра %q",
+	"usage: /rename <number> <new_name>":          "использование: /rename <номер> <новое_имя>",
+	"new name cannot be empty":                    "новое имя не может быть пустым",
+	"usage: /interval <number> <minutes>":         "использование: /interval <номер> <минуты>",
+	"interval must be between 1 and 1440 minutes": "интервал должен быть от 1 до 1440 минут",
+	"no times given":                              "время не указано",
+	"invalid time %q, expected HH:MM":             "неверное время %q, ожидается ЧЧ:ММ",
+	"at most %d times a day":                      "не больше %d раз в день",
+	"expected HH:MM-HH:MM":                        "ожидается ЧЧ:ММ-ЧЧ:ММ",
+	"start and end are the same":                  "начало и конец совпадают",
+
+	// Digest.
+	"Usage: /digest [on|off] | /digest at HH:MM [HH:MM ...] | /digest <number> <on|off|default>": "Использование: /digest [on|off] | /digest at ЧЧ:ММ [ЧЧ:ММ ...] | /digest <номер> <on|off|default>",
+	"Invalid digest times: %s.\n%s":     "Неверное время дайджеста: %s.\n%s",
+	"Digest %s by default, at %s (%s).": "Дайджест по умолчанию: %s, время: %s (%s).",
+	"Delivery of #%d \"%s\": %s.":       "Доставка #%d «%s»: %s.",
+	"Digest: %s by default\n":           "Дайджест по умолчанию: %s\n",
+	"Times: %s (%s)\n":                  "Время: %s (%s)\n",
+	"<b>Digest</b>: %d new item(s)":     "<b>Дайджест</b>, новых записей: %d",
+	"digest":                            "дайджест",
+	"instant":                           "сразу",
+	"chat default":                      "как в чате",
+	"on":                                "вкл",
+	"off":                               "выкл",
+
+	// Time zone.
+	"Time zone: %s\nUsage: /timezone <Area/City>, e.g. /timezone Europe/Berlin": "Часовой пояс: %s\nИспользование: /timezone <Область/Город>, например /timezone Europe/Berlin",
+	"Unknown time zone %q. Use a name like Europe/Berlin or UTC.":               "Неизвестный часовой пояс %q. Укажите название вроде Europe/Berlin или UTC.",
+	"Time zone set to %s.": "Часовой пояс: %s.",
+
+	// Quiet hours.
+	"Usage: /quiet <HH:MM-HH:MM|off> | /quiet <hold|silent> | /quiet <number> <hold|silent|default>": "Использование: /quiet <ЧЧ:ММ-ЧЧ:ММ|off> | /quiet <hold|silent> | /quiet <номер> <hold|silent|default>",
+	"Invalid quiet hours: %s.\n%s":                         "Неверные тихие часы: %s.\n%s",
+	"Quiet hours turned off.":                              "Тихие часы выключены.",
+	"Quiet hours: %s-%s (%s), notifications %s.":           "Тихие часы: %s-%s (%s), уведомления: %s.",
+	"During quiet hours, notifications of #%d \"%s\": %s.": "Уведомления #%d «%s» в тихие часы: %s.",
+	"Quiet hours: off":                                     "Тихие часы: выкл",
+	"Quiet hours: %s-%s (%s)\n":                            "Тихие часы: %s-%s (%s)\n",
+	"Notifications: %s\n":                                  "Уведомления: %s\n",
+	"held until the end":                                   "задерживаются до конца",
+	"sent silently":                                        "приходят без звука",
+
+	// Settings.
+	"Settings:":                        "Настройки:",
+	"Language: %s\n":                   "Язык: %s\n",
+	"Time zone: %s\n":                  "Часовой пояс: %s\n",
+	"Default interval: every %d min\n": "Интервал по умолчанию: каждые %d мин\n",
+	"Default filter scope: %s (%s)\n":  "Область фильтров по умолчанию: %s (%s)\n",
+	"Preview length: %d characters\n":  "Длина превью: %d символов\n",
+	"Images: %s\n":                     "Картинки: %s\n",
+	"Link previews: %s\n":              "Превью ссылок: %s\n",
+	"Tap a button to change a setting. The interval and scope apply to feeds and filters added from now on.": "Нажмите кнопку, чтобы изменить настройку. Интервал и область применяются к лентам и фильтрам, добавленным после этого.",
+	"Time zone: %s\nPick a time zone, or send /timezone <Area/City> for any other.":                          "Часовой пояс: %s\nВыберите пояс или отправьте /timezone <Область/Город> для любого другого.",
+	"Language: %s":      "Язык: %s",
+	"Time zone: %s":     "Часовой пояс: %s",
+	"Interval: %d min":  "Интервал: %d мин",
+	"Scope: %s":         "Область: %s",
+	"Preview: %d":       "Превью: %d",
+	"Images: %s":        "Картинки: %s",
+	"Link previews: %s": "Превью ссылок: %s",
+	"Back":              "Назад",
+	"auto":              "авто",
+
+	// Language.
+	"Language: %s\nUsage: /language <en|ru|auto>": "Язык: %s\nИспользование: /language <en|ru|auto>",
+	"Usage: /language <en|ru|auto>":               "Использование: /language <en|ru|auto>",
+	"Language set to %s.":                         "Язык: %s.",
+
+	// OPML.
+	"You have no feeds to export. Use /add <url> to add one.": "Нет лент для выгрузки. Добавьте ленту командой /add <url>.",
+	"%d feed(s) exported.":          "Выгружено лент: %d.",
+	"Failed to send the OPML file.": "Не удалось отправить OPML-файл.",
+	"Only OPML files can be imported. Use /export to see the expected format.": "Импортировать можно только OPML-файлы. Пример формата: /export.",
+	"The OPML file is too large (max 1 MB).":                                   "OPML-файл слишком большой (максимум 1 МБ).",
+	"Failed to download file.":                                                 "Не удалось скачать файл.",
+	"The OPML file has %d feeds, at most %d can be imported at once.":          "В OPML-файле лент: %d, за один раз можно импортировать не больше %d.",
+	"Failed to read OPML: %v":                                                  "Не удалось прочитать OPML: %v",
+	"No feeds found in the OPML file.":                                         "В OPML-файле не найдено лент.",
+	"Imported %d feed(s) from OPML.":                                           "Импортировано лент из OPML: %d.",
+	"Skipped %d duplicate(s).":                                                 "Пропущено повторов: %d.",
+	"Skipped %d feed(s) without an http or https URL.":                         "Пропущено лент без адреса http или https: %d.",
+	"Failed to save %d feed(s).":                                               "Не удалось сохранить лент: %d.",
+	"Use /list to see your feeds.":                                             "Список лент: /list.",
+
+	// Storage statistics.
+	"Storage:": "Хранилище:",
+	"Seen items: %d, %d of them in the latest downloads\n": "Просмотренных записей: %d, из них в последних загрузках: %d\n",
+	"Stored content: %s\n":                                 "Сохранённый текст: %s\n",
+	"Not compressed yet: %d item(s)\n":                     "Ещё не сжато записей: %d\n",
+	"Oldest seen item: %s\n":                               "Самая старая запись: %s\n",
+	"Retention: %d days\n":                                 "Срок хранения: %d дн.\n",
+	"Last retention run: not yet":                          "Последняя очистка: ещё не было",
+	"Last retention run: %s, failed: %s":                   "Последняя очистка: %s, ошибка: %s",
+	"Last retention run: %s, %d pruned, %d compressed":     "Последняя очистка: %s, удалено: %d, сжато: %d",
+	"Could not read the storage statistics.":               "Не удалось прочитать статистику хранилища.",
+	"%s MB":                                                "%s МБ",
+	"%s KB":                                                "%s КБ",
+	"%d bytes":                                             "%d байт",
+
+	// Search.
+	"Usage: /search <query>\nExample: /search %s": "Использование: /search <запрос>\nПример: /search %s",
+	"%v\n\nExample: /search %s":                   "%v\n\nПример: /search %s",
+	"Search failed. Please try again later.":      "Не удалось выполнить поиск. Попробуйте позже.",
+	"Nothing found among the delivered items.":    "Среди доставленных записей ничего не найдено.",
+	"Found %d item(s), page %d of %d:":            "Найдено записей: %d, страница %d из %d:",
+	"« Newer":                                     "« Новее",
+	"Older »":                                     "Старше »",
+}
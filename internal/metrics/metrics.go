@@ -0,0 +1,153 @@
+// Package metrics collects the bot's counters and histograms and exposes them
+// to Prometheus.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// durationBuckets suit HTTP requests and filter runs, in seconds.
+	durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+	// lagBuckets suit how overdue a feed check is, in seconds.
+	lagBuckets = []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600}
+)
+
+// Metrics are the bot's metrics. The components record into them through the
+// methods below, all of which do nothing on a nil *Metrics, so that metrics
+// stay optional.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	fetchDuration  *prometheus.HistogramVec
+	fetchResponses *prometheus.CounterVec
+	parseErrors    *prometheus.CounterVec
+	itemsFetched   prometheus.Counter
+	itemsMatched   prometheus.Counter
+	itemsSent      prometheus.Counter
+	filterDuration prometheus.Histogram
+	sendErrors     *prometheus.CounterVec
+	schedulerLag   prometheus.Histogram
+}
+
+// New creates the bot's metrics in a new registry. Feed requests are
+// labelled with the host of the feed rather than the feed itself: a
+// download serves every subscription to its URL, and a label per feed would
+// add a histogram of 15 series for every subscription of every chat, while
+// hosts stay few. The host also keeps tokens in feed URLs out of the metrics.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		fetchDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "rss_bot_fetch_duration_seconds",
+			Help:    "Time taken to download and parse a feed.",
+			Buckets: durationBuckets,
+		}, []string{"host"}),
+		fetchResponses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rss_bot_fetch_responses_total",
+			Help: `Feed requests by HTTP status code, or "error" if no response was received.`,
+		}, []string{"host", "code"}),
+		parseErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rss_bot_parse_errors_total",
+			Help: "Feed downloads that could not be parsed.",
+		}, []string{"host"}),
+		itemsFetched: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "rss_bot_items_fetched_total",
+			Help: "Items found in downloaded feeds.",
+		}),
+		itemsMatched: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "rss_bot_items_matched_total",
+			Help: "Items that passed a subscription's filters.",
+		}),
+		itemsSent: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "rss_bot_items_sent_total",
+			Help: "Notifications accepted by Telegram.",
+		}),
+		filterDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "rss_bot_filter_duration_seconds",
+			Help:    "Time taken to run a subscription's filters against a feed.",
+			Buckets: durationBuckets,
+		}),
+		sendErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rss_bot_telegram_send_errors_total",
+			Help: "Failed Telegram requests by type of error.",
+		}, []string{"type"}),
+		schedulerLag: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "rss_bot_scheduler_lag_seconds",
+			Help:    "How long a feed was overdue when the scheduler picked it up.",
+			Buckets: lagBuckets,
+		}),
+	}
+	m.registry.MustRegister(m.fetchDuration, m.fetchResponses, m.parseErrors, m.itemsFetched,
+		m.itemsMatched, m.itemsSent, m.filterDuration, m.sendErrors, m.schedulerLag)
+	return m
+}
+
+// Handler serves the metrics to Prometheus.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// FetchDone records a request to a feed on host that got the given HTTP
+// status code, or "error", and took d.
+func (m *Metrics) FetchDone(host, code string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.fetchDuration.WithLabelValues(host).Observe(d.Seconds())
+	m.fetchResponses.WithLabelValues(host, code).Inc()
+}
+
+// ParseError records a download from a feed on host that could not be parsed.
+func (m *Metrics) ParseError(host string) {
+	if m == nil {
+		return
+	}
+	m.parseErrors.WithLabelValues(host).Inc()
+}
+
+// ItemsFetched records the items found in a downloaded feed.
+func (m *Metrics) ItemsFetched(n int) {
+	if m == nil {
+		return
+	}
+	m.itemsFetched.Add(float64(n))
+}
+
+// FilterDone records a filter run that took d and matched n items.
+func (m *Metrics) FilterDone(n int, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.filterDuration.Observe(d.Seconds())
+	m.itemsMatched.Add(float64(n))
+}
+
+// ItemSent records a notification accepted by Telegram.
+func (m *Metrics) ItemSent() {
+	if m == nil {
+		return
+	}
+	m.itemsSent.Inc()
+}
+
+// SendError records a failed Telegram request of the given type, such as
+// "rate_limited" or "forbidden".
+func (m *Metrics) SendError(kind string) {
+	if m == nil {
+		return
+	}
+	m.sendErrors.WithLabelValues(kind).Inc()
+}
+
+// SchedulerLag records how long a feed was overdue when it was picked up.
+func (m *Metrics) SchedulerLag(d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.schedulerLag.Observe(max(d, 0).Seconds())
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	m := New()
+	m.FetchDone("example.com", "200", 300*time.Millisecond)
+	m.FetchDone("example.com", "error", time.Second)
+	m.ParseError("example.com")
+	m.ItemsFetched(10)
+	m.FilterDone(3, time.Millisecond)
+	m.ItemSent()
+	m.SendError("rate_limited")
+	m.SchedulerLag(-time.Second)
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, line := range []string{
+		`# HELP rss_bot_fetch_responses_total Feed requests by HTTP status code, or "error" if no response was received.`,
+		`rss_bot_fetch_duration_seconds_count{host="example.com"} 2`,
+		`rss_bot_fetch_responses_total{code="200",host="example.com"} 1`,
+		`rss_bot_fetch_responses_total{code="error",host="example.com"} 1`,
+		`rss_bot_parse_errors_total{host="example.com"} 1`,
+		`rss_bot_items_fetched_total 10`,
+		`rss_bot_items_matched_total 3`,
+		`rss_bot_items_sent_total 1`,
+		`rss_bot_filter_duration_seconds_count 1`,
+		`rss_bot_telegram_send_errors_total{type="rate_limited"} 1`,
+		`rss_bot_scheduler_lag_seconds_bucket{le="1"} 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("output lacks %q:\n%s", line, body)
+		}
+	}
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain") {
+		t.Errorf("Content-Type = %q, want text/plain", got)
+	}
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *Metrics
+	m.FetchDone("example.com", "200", time.Second)
+	m.ParseError("example.com")
+	m.ItemsFetched(1)
+	m.FilterDone(1, time.Second)
+	m.ItemSent()
+	m.SendError("other")
+	m.SchedulerLag(time.Second)
+}
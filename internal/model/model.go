@@ -13,9 +13,144 @@ type Feed struct {
 	IntervalMinutes int
 	IsActive        bool
 	LastCheckAt     *time.Time
+	ETag            string
+	LastModified    string
+	FailureCount    int
+	LastError       string
+	NextRetryAt     *time.Time
+	BacklogPolicy   BacklogPolicy
+	BacklogLimit    int
+	BacklogState    BacklogState
+	BacklogAskedAt  *time.Time
+	Delivery        DeliveryMode
+	Quiet           QuietMode
 	CreatedAt       time.Time
 }
 
+// BacklogPolicy defines what happens to the unseen items a feed has when it is
+// checked for the first time after being added or resumed.
+type BacklogPolicy string
+
+// Supported backlog policies.
+const (
+	BacklogAll    BacklogPolicy = "all"    // send every unseen item
+	BacklogNewest BacklogPolicy = "newest" // send the newest BacklogLimit items, mark the rest seen
+	BacklogSkip   BacklogPolicy = "skip"   // mark every unseen item seen
+	BacklogAsk    BacklogPolicy = "ask"    // ask the chat when there are more than BacklogLimit items
+)
+
+// Defaults for new feeds.
+const (
+	DefaultBacklogPolicy = BacklogNewest
+	DefaultBacklogLimit  = 5
+)
+
+// BacklogState tracks whether the backlog policy still has to be applied.
+type BacklogState string
+
+// Backlog states.
+const (
+	BacklogDone    BacklogState = ""        // items are delivered as usual
+	BacklogPending BacklogState = "pending" // apply the policy on the next check
+	BacklogAsked   BacklogState = "asked"   // waiting for the chat to answer the prompt
+)
+
+// DeliveryMode defines how the items of a feed reach the chat.
+type DeliveryMode string
+
+// Delivery modes.
+const (
+	DeliveryDefault DeliveryMode = ""        // follow the chat's digest setting
+	DeliveryInstant DeliveryMode = "instant" // one message per item
+	DeliveryDigest  DeliveryMode = "digest"  // collect items into the chat's digest
+)
+
+// QuietMode defines what happens to the notifications of a feed during the
+// chat's quiet hours.
+type QuietMode string
+
+// Quiet modes.
+const (
+	QuietDefault QuietMode = ""       // follow the chat's quiet mode
+	QuietHold    QuietMode = "hold"   // deliver when the quiet hours end
+	QuietSilent  QuietMode = "silent" // deliver at once, without a sound
+)
+
+// ChatSettings holds the preferences of a chat.
+type ChatSettings struct {
+	ChatID   int64
+	Timezone string // IANA time zone name
+	// Digest sends the items of feeds without their own delivery mode in a
+	// digest at DigestTimes instead of one by one.
+	Digest      bool
+	DigestTimes []string // "15:04" in Timezone, sorted
+	// QuietStart and QuietEnd bound the quiet hours in Timezone; both are
+	// empty when the chat has none. The window may span midnight.
+	QuietStart string
+	QuietEnd   string
+	QuietMode  QuietMode // for feeds without their own quiet mode
+
+	IntervalMinutes int         // check interval of new feeds
+	FilterScope     FilterScope // scope of filters added without -s
+	PreviewLength   int         // characters of the item body in a notification
+	Images          bool        // attach photos to notifications
+	LinkPreview     bool        // let Telegram show a preview of the item link
+
+	// Language is the language chosen for the chat, empty to follow
+	// TelegramLanguage, the language code of the client that wrote last.
+	Language         string
+	TelegramLanguage string
+}
+
+// Defaults for chats without settings.
+const (
+	DefaultTimezone        = "UTC"
+	DefaultDigestTimes     = "09:00"
+	DefaultIntervalMinutes = 15
+	DefaultPreviewLength   = 1500
+)
+
+// DefaultChatSettings returns the settings of a chat that has not changed any.
+func DefaultChatSettings(chatID int64) *ChatSettings {
+	return &ChatSettings{
+		ChatID:          chatID,
+		Timezone:        DefaultTimezone,
+		DigestTimes:     []string{DefaultDigestTimes},
+		QuietMode:       QuietHold,
+		IntervalMinutes: DefaultIntervalMinutes,
+		FilterScope:     ScopeAll,
+		PreviewLength:   DefaultPreviewLength,
+		Images:          true,
+	}
+}
+
+// DigestItem is an item collected for the next digest of a chat.
+type DigestItem struct {
+	ID        int64
+	ChatID    int64
+	FeedID    int64
+	FeedName  string
+	GUID      string
+	Title     string
+	Link      string
+	Archive   *ArchivedItem // stored in the archive once the digest is delivered
+	CreatedAt time.Time
+}
+
+// PendingDigest is a chat with collected digest items, Since being the time
+// the oldest of them was collected.
+type PendingDigest struct {
+	ChatID int64
+	Since  time.Time
+}
+
+// FeedFailure records a single failed check of a feed.
+type FeedFailure struct {
+	FeedID   int64
+	Error    string
+	FailedAt time.Time
+}
+
 // FilterKind defines the type of filter rule.
 type FilterKind string
 
@@ -25,6 +160,7 @@ const (
 	FilterExclude   FilterKind = "exclude"
 	FilterIncludeRe FilterKind = "include_re"
 	FilterExcludeRe FilterKind = "exclude_re"
+	FilterQuery     FilterKind = "query"
 )
 
 // FilterScope defines which part of the RSS item a filter matches against.
@@ -32,11 +168,20 @@ type FilterScope string
 
 // Supported filter scopes.
 const (
-	ScopeTitle   FilterScope = "title"
-	ScopeContent FilterScope = "content"
-	ScopeAll     FilterScope = "all"
+	ScopeTitle      FilterScope = "title"
+	ScopeContent    FilterScope = "content"
+	ScopeAll        FilterScope = "all"
+	ScopeAuthor     FilterScope = "author"
+	ScopeCategories FilterScope = "categories"
+	ScopeLink       FilterScope = "link"
+	ScopeEnclosures FilterScope = "enclosures"
 )
 
+// FilterScopes lists every supported scope in the order shown to users.
+var FilterScopes = []FilterScope{
+	ScopeTitle, ScopeContent, ScopeAll, ScopeAuthor, ScopeCategories, ScopeLink, ScopeEnclosures,
+}
+
 // Filter represents a single filtering rule attached to a feed.
 type Filter struct {
 	ID        int64
@@ -48,9 +193,105 @@ type Filter struct {
 	CreatedAt time.Time
 }
 
+// OutboxMessage is a rendered notification waiting to be delivered.
+// The item it announces is marked seen only once Telegram accepts it.
+type OutboxMessage struct {
+	ID            int64
+	ChatID        int64
+	FeedID        int64
+	GUID          string
+	Text          string
+	Markup        string         // JSON-encoded inline keyboard, empty for none
+	Media         string         // JSON-encoded []Media sent with the text, empty for none
+	FullContent   string         // stored with the seen item for "Show more"
+	Silent        bool           // sent without a notification sound
+	Archive       []ArchivedItem // stored in the archive once delivered
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	CreatedAt     time.Time
+}
+
+// MediaKind is how an attachment is sent to Telegram.
+type MediaKind string
+
+// Media kinds.
+const (
+	MediaPhoto    MediaKind = "photo"
+	MediaAudio    MediaKind = "audio"
+	MediaVideo    MediaKind = "video"
+	MediaDocument MediaKind = "document"
+)
+
+// Media is a file attached to a feed item, such as an image or a podcast
+// episode. Size and Duration are zero when the feed does not report them.
+type Media struct {
+	Kind     MediaKind `json:"kind"`
+	URL      string    `json:"url"`
+	Type     string    `json:"type,omitempty"`     // MIME type
+	Size     int64     `json:"size,omitempty"`     // bytes
+	Duration int       `json:"duration,omitempty"` // seconds
+}
+
+// CallbackAction is what an inline button does when pressed.
+type CallbackAction string
+
+// Callback actions.
+const (
+	CallbackShowMore      CallbackAction = "show_more"
+	CallbackAddFeed       CallbackAction = "add_feed"
+	CallbackBacklog       CallbackAction = "backlog"
+	CallbackDeleteConfirm CallbackAction = "delete_confirm"
+	CallbackDelete        CallbackAction = "delete"
+	CallbackFilters       CallbackAction = "filters"
+	CallbackCheck         CallbackAction = "check"
+	CallbackRmFilter      CallbackAction = "rmfilter"
+	CallbackSettings      CallbackAction = "settings"
+	CallbackSearch        CallbackAction = "search"
+	CallbackNoop          CallbackAction = "noop"
+)
+
+// Callback is the payload behind an inline button. Telegram only carries the
+// short Token, since callback data is limited to 64 bytes.
+type Callback struct {
+	Token     string
+	ChatID    int64
+	Action    CallbackAction
+	FeedID    int64
+	GUID      string
+	Arg       string
+	ExpiresAt time.Time
+}
+
 // SeenItem tracks an RSS item that has already been processed.
 type SeenItem struct {
 	FeedID int64
 	GUID   string
 	SeenAt time.Time
 }
+
+// SeenStats describes the stored seen items.
+type SeenStats struct {
+	Items        int
+	InFeed       int        // items of the feeds' latest downloads, kept by retention
+	ContentBytes int64      // stored full content, after compression
+	Uncompressed int        // items whose content was stored before compression
+	Oldest       *time.Time // nil when there are no items
+}
+
+// ArchivedItem is a delivered item kept for /search. It travels to the
+// archive as JSON with the message that delivers it; the chat is taken from
+// the message.
+type ArchivedItem struct {
+	ID          int64      `json:"-"`
+	ChatID      int64      `json:"-"`
+	FeedID      int64      `json:"feed_id"`
+	GUID        string     `json:"guid"`
+	FeedName    string     `json:"feed"`
+	Title       string     `json:"title"`
+	Link        string     `json:"link,omitempty"`
+	Author      string     `json:"author,omitempty"`
+	Published   *time.Time `json:"published,omitempty"`
+	Content     string     `json:"content,omitempty"` // plain text
+	DeliveredAt time.Time  `json:"-"`
+}
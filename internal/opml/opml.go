@@ -0,0 +1,96 @@
+// Package opml reads and writes OPML subscription lists.
+package opml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"rss_bot/internal/model"
+)
+
+type document struct {
+	XMLName xml.Name  `xml:"opml"`
+	Version string    `xml:"version,attr"`
+	Head    head      `xml:"head"`
+	Body    []outline `xml:"body>outline"`
+}
+
+type head struct {
+	Title string `xml:"title"`
+}
+
+type outline struct {
+	Text     string    `xml:"text,attr"`
+	Title    string    `xml:"title,attr,omitempty"`
+	Type     string    `xml:"type,attr,omitempty"`
+	XMLURL   string    `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string    `xml:"htmlUrl,attr,omitempty"`
+	Interval string    `xml:"interval,attr,omitempty"`
+	Outlines []outline `xml:"outline"`
+}
+
+// Subscription is a feed entry read from an OPML document.
+type Subscription struct {
+	Name string
+	URL  string
+	// IntervalMinutes is zero when the document does not specify an interval.
+	IntervalMinutes int
+}
+
+// Export writes feeds as an OPML 2.0 document. The check interval is stored
+// in a non-standard "interval" attribute, which other readers ignore.
+func Export(w io.Writer, title string, feeds []model.Feed) error {
+	doc := document{Version: "2.0", Head: head{Title: title}}
+	for _, f := range feeds {
+		doc.Body = append(doc.Body, outline{
+			Text:     f.Name,
+			Title:    f.Name,
+			Type:     "rss",
+			XMLURL:   f.URL,
+			Interval: strconv.Itoa(f.IntervalMinutes),
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("write opml: %w", err)
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("encode opml: %w", err)
+	}
+	return nil
+}
+
+// Import reads the feed subscriptions of an OPML document. Nested outlines
+// (folders) are flattened; outlines without an xmlUrl are skipped.
+func Import(r io.Reader) ([]Subscription, error) {
+	var doc document
+	dec := xml.NewDecoder(r)
+	dec.Strict = false
+	dec.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) { return input, nil }
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode opml: %w", err)
+	}
+
+	var subs []Subscription
+	var walk func([]outline)
+	walk = func(outlines []outline) {
+		for _, o := range outlines {
+			if u := strings.TrimSpace(o.XMLURL); u != "" {
+				name := strings.TrimSpace(o.Title)
+				if name == "" {
+					name = strings.TrimSpace(o.Text)
+				}
+				interval, _ := strconv.Atoi(strings.TrimSpace(o.Interval))
+				subs = append(subs, Subscription{Name: name, URL: u, IntervalMinutes: interval})
+			}
+			walk(o.Outlines)
+		}
+	}
+	walk(doc.Body)
+	return subs, nil
+}
@@ -0,0 +1,86 @@
+package opml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"rss_bot/internal/model"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	feeds := []model.Feed{
+		{Name: "DevOps Weekly", URL: "https://devops.example.com/rss", IntervalMinutes: 15},
+		{Name: "Go & Rust", URL: "https://lang.example.com/atom?x=1&y=2", IntervalMinutes: 60},
+	}
+
+	var buf bytes.Buffer
+	if err := Export(&buf, "RSS Notify Bot feeds", feeds); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	got, err := Import(&buf)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	want := []Subscription{
+		{Name: "DevOps Weekly", URL: "https://devops.example.com/rss", IntervalMinutes: 15},
+		{Name: "Go & Rust", URL: "https://lang.example.com/atom?x=1&y=2", IntervalMinutes: 60},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("round trip mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []Subscription
+		wantErr bool
+	}{
+		{
+			name: "nested folders and text fallback",
+			input: `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="1.0">
+  <head><title>Reader export</title></head>
+  <body>
+    <outline text="Tech">
+      <outline text="Blog A" type="rss" xmlUrl="https://a.example.com/feed" htmlUrl="https://a.example.com"/>
+      <outline text="Blog B" title="B title" type="rss" xmlUrl=" https://b.example.com/rss "/>
+    </outline>
+    <outline text="No feed here" htmlUrl="https://c.example.com"/>
+  </body>
+</opml>`,
+			want: []Subscription{
+				{Name: "Blog A", URL: "https://a.example.com/feed"},
+				{Name: "B title", URL: "https://b.example.com/rss"},
+			},
+		},
+		{
+			name:    "not xml",
+			input:   "hello",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Import(strings.NewReader(tt.input))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Import mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
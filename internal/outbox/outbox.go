@@ -0,0 +1,242 @@
+// Package outbox delivers queued notifications with retries, so that an item
+// is only marked seen once Telegram has accepted its message.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"rss_bot/internal/model"
+	"rss_bot/internal/storage"
+)
+
+const (
+	defaultPollInterval = time.Second
+	defaultMaxAttempts  = 10
+	defaultWorkers      = 8
+	perChatBatch        = 5
+	baseRetryDelay      = 5 * time.Second
+	maxRetryDelay       = time.Hour
+)
+
+// Deliverer sends a single queued message.
+type Deliverer interface {
+	Deliver(ctx context.Context, m model.OutboxMessage) error
+}
+
+// DeliveryError classifies a failed delivery.
+type DeliveryError struct {
+	Err error
+	// RetryAfter is the wait requested by Telegram's flood control (HTTP 429).
+	RetryAfter time.Duration
+	// Permanent is set when retrying cannot succeed, e.g. the bot was blocked.
+	Permanent bool
+}
+
+func (e *DeliveryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DeliveryError) Unwrap() error {
+	return e.Err
+}
+
+// Dispatcher periodically sends due outbox messages.
+type Dispatcher struct {
+	store       storage.Storage
+	deliverer   Deliverer
+	log         *slog.Logger
+	poll        time.Duration
+	maxAttempts int
+	// slots limits how many chats are served at the same time.
+	slots chan struct{}
+	// wg tracks the goroutines serving chats.
+	wg sync.WaitGroup
+
+	mu sync.Mutex
+	// busy holds the chats that are being served or waiting for a slot.
+	busy map[int64]bool
+	// notBefore holds the chats paused by flood control and when the pause ends.
+	notBefore map[int64]time.Time
+}
+
+// New creates a Dispatcher.
+func New(store storage.Storage, deliverer Deliverer, log *slog.Logger) *Dispatcher {
+	return &Dispatcher{
+		store:       store,
+		deliverer:   deliverer,
+		log:         log,
+		poll:        defaultPollInterval,
+		maxAttempts: defaultMaxAttempts,
+		slots:       make(chan struct{}, defaultWorkers),
+		busy:        make(map[int64]bool),
+		notBefore:   make(map[int64]time.Time),
+	}
+}
+
+// SetPollInterval overrides how often the outbox is checked for due messages.
+func (d *Dispatcher) SetPollInterval(poll time.Duration) {
+	d.poll = poll
+}
+
+// SetMaxAttempts overrides how many times a message is tried before giving up.
+func (d *Dispatcher) SetMaxAttempts(n int) {
+	d.maxAttempts = n
+}
+
+// SetWorkers overrides how many chats are served at the same time. It must be
+// called before the dispatcher is started.
+func (d *Dispatcher) SetWorkers(n int) {
+	d.slots = make(chan struct{}, max(n, 1))
+}
+
+// Run delivers messages until ctx is cancelled. It polls the outbox without
+// waiting for the chats that are still being served, and returns once they
+// are done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	d.log.Info("outbox started", "poll", d.poll)
+	for {
+		d.dispatch(ctx)
+		select {
+		case <-ctx.Done():
+			d.wg.Wait()
+			d.log.Info("outbox stopped")
+			return
+		case <-time.After(d.poll):
+		}
+	}
+}
+
+// Flush sends one batch of due messages and waits until it is delivered.
+func (d *Dispatcher) Flush(ctx context.Context) {
+	d.dispatch(ctx)
+	d.wg.Wait()
+}
+
+// dispatch starts serving the chats with due messages. Up to the configured
+// number of chats are served concurrently, each in queue order, so that a
+// slow or rate-limited chat doesn't hold up the others. Chats that are still
+// being served or paused by flood control are skipped until a later poll.
+func (d *Dispatcher) dispatch(ctx context.Context) {
+	now := time.Now()
+	msgs, err := d.store.ListDueMessages(ctx, now, perChatBatch)
+	if err != nil {
+		d.log.Error("list due messages", "error", err)
+		return
+	}
+
+	var chats []int64
+	byChat := make(map[int64][]model.OutboxMessage)
+	for _, m := range msgs {
+		if _, ok := byChat[m.ChatID]; !ok {
+			chats = append(chats, m.ChatID)
+		}
+		byChat[m.ChatID] = append(byChat[m.ChatID], m)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, chatID := range chats {
+		if d.busy[chatID] {
+			continue
+		}
+		if until, ok := d.notBefore[chatID]; ok {
+			if now.Before(until) {
+				continue
+			}
+			delete(d.notBefore, chatID)
+		}
+		d.busy[chatID] = true
+		d.wg.Add(1)
+		go d.serve(ctx, chatID, byChat[chatID])
+	}
+}
+
+// serve delivers a chat's messages once a slot is free.
+func (d *Dispatcher) serve(ctx context.Context, chatID int64, queue []model.OutboxMessage) {
+	defer d.wg.Done()
+	defer func() {
+		d.mu.Lock()
+		delete(d.busy, chatID)
+		d.mu.Unlock()
+	}()
+
+	select {
+	case d.slots <- struct{}{}:
+	case <-ctx.Done():
+		return
+	}
+	defer func() { <-d.slots }()
+
+	if pause := d.sendQueue(ctx, queue); pause > 0 {
+		d.mu.Lock()
+		d.notBefore[chatID] = time.Now().Add(pause)
+		d.mu.Unlock()
+	}
+}
+
+// sendQueue delivers a chat's messages in order, stopping at the first one
+// that hits flood control.
+func (d *Dispatcher) sendQueue(ctx context.Context, queue []model.OutboxMessage) time.Duration {
+	for i := range queue {
+		if ctx.Err() != nil {
+			return 0
+		}
+		if p := d.send(ctx, &queue[i]); p > 0 {
+			return p
+		}
+	}
+	return 0
+}
+
+// send delivers one message and records the outcome. It returns a non-zero
+// pause when the message hit flood control.
+func (d *Dispatcher) send(ctx context.Context, m *model.OutboxMessage) time.Duration {
+	err := d.deliverer.Deliver(ctx, *m)
+	if err == nil {
+		if err := d.store.CompleteMessage(ctx, m); err != nil {
+			d.log.Error("complete message", "id", m.ID, "error", err)
+		}
+		return 0
+	}
+	if ctx.Err() != nil {
+		return 0
+	}
+
+	var de *DeliveryError
+	errors.As(err, &de)
+
+	switch {
+	case de != nil && de.RetryAfter > 0:
+		// The message stays due and goes first once the chat's pause is over.
+		d.log.Warn("flood control", "chat_id", m.ChatID, "retry_after", de.RetryAfter)
+		return de.RetryAfter
+	case de != nil && de.Permanent, m.Attempts+1 >= d.maxAttempts:
+		d.log.Error("give up message", "id", m.ID, "chat_id", m.ChatID, "attempts", m.Attempts+1, "error", err)
+		if err := d.store.FailMessage(ctx, m); err != nil {
+			d.log.Error("fail message", "id", m.ID, "error", err)
+		}
+	default:
+		next := time.Now().Add(retryDelay(m.Attempts + 1))
+		d.log.Warn("retry message", "id", m.ID, "chat_id", m.ChatID, "attempt", m.Attempts+1, "next", next, "error", err)
+		if err := d.store.RetryMessage(ctx, m.ID, err.Error(), next); err != nil {
+			d.log.Error("retry message", "id", m.ID, "error", err)
+		}
+	}
+	return 0
+}
+
+// retryDelay doubles the wait after every failed attempt, up to maxRetryDelay.
+func retryDelay(attempts int) time.Duration {
+	d := baseRetryDelay
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= maxRetryDelay {
+			return maxRetryDelay
+		}
+	}
+	return d
+}
@@ -0,0 +1,326 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"rss_bot/internal/model"
+	"rss_bot/internal/storage"
+)
+
+type fakeDeliverer struct {
+	mu   sync.Mutex
+	errs []error
+	sent []string
+}
+
+func (f *fakeDeliverer) Deliver(_ context.Context, m model.OutboxMessage) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		if err != nil {
+			return err
+		}
+	}
+	f.sent = append(f.sent, m.GUID)
+	return nil
+}
+
+func setup(t *testing.T, guids ...string) (*storage.SQLite, *model.Feed) {
+	t.Helper()
+	ctx := context.Background()
+	store, err := storage.NewSQLite(":memory:")
+	if err != nil {
+		t.Fatalf("new sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+
+	feed := &model.Feed{ChatID: 100, Name: "Feed", URL: "https://example.com/rss", IntervalMinutes: 15, IsActive: true}
+	if err := store.CreateFeed(ctx, feed); err != nil {
+		t.Fatalf("create feed: %v", err)
+	}
+	for _, guid := range guids {
+		m := &model.OutboxMessage{ChatID: feed.ChatID, FeedID: feed.ID, GUID: guid, Text: guid}
+		if err := store.EnqueueMessage(ctx, m); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+	return store, feed
+}
+
+func newDispatcher(store storage.Storage, d Deliverer) *Dispatcher {
+	return New(store, d, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func pending(t *testing.T, store storage.Storage, at time.Time) []model.OutboxMessage {
+	t.Helper()
+	msgs, err := store.ListDueMessages(context.Background(), at, 100)
+	if err != nil {
+		t.Fatalf("list due: %v", err)
+	}
+	return msgs
+}
+
+func TestFlushDelivers(t *testing.T) {
+	ctx := context.Background()
+	store, feed := setup(t, "a", "b")
+	d := &fakeDeliverer{}
+
+	newDispatcher(store, d).Flush(ctx)
+	if diff := cmp.Diff([]string{"a", "b"}, d.sent); diff != "" {
+		t.Errorf("sent (-want +got):\n%s", diff)
+	}
+	if got := pending(t, store, time.Now()); len(got) != 0 {
+		t.Errorf("pending = %d, want 0", len(got))
+	}
+	for _, guid := range []string{"a", "b"} {
+		if seen, _ := store.IsSeen(ctx, feed.ID, guid); !seen {
+			t.Errorf("item %q should be seen", guid)
+		}
+	}
+}
+
+func TestFlushServesChatsSeparately(t *testing.T) {
+	ctx := context.Background()
+	store, _ := setup(t, "a1", "a2")
+	other := &model.Feed{ChatID: 200, Name: "Other", URL: "https://example.org/rss", IntervalMinutes: 15, IsActive: true}
+	if err := store.CreateFeed(ctx, other); err != nil {
+		t.Fatalf("create feed: %v", err)
+	}
+	for _, guid := range []string{"b1", "b2"} {
+		if err := store.EnqueueMessage(ctx, &model.OutboxMessage{ChatID: 200, FeedID: other.ID, GUID: guid}); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+
+	d := &fakeDeliverer{}
+	newDispatcher(store, d).Flush(ctx)
+
+	// Chats are delivered concurrently; only the order within a chat is fixed.
+	byChat := map[byte][]string{}
+	for _, guid := range d.sent {
+		byChat[guid[0]] = append(byChat[guid[0]], guid)
+	}
+	want := map[byte][]string{'a': {"a1", "a2"}, 'b': {"b1", "b2"}}
+	if diff := cmp.Diff(want, byChat); diff != "" {
+		t.Errorf("sent (-want +got):\n%s", diff)
+	}
+}
+
+// slowDeliverer records how many messages are delivered at the same time.
+type slowDeliverer struct {
+	mu      sync.Mutex
+	running int
+	peak    int
+}
+
+func (s *slowDeliverer) Deliver(_ context.Context, _ model.OutboxMessage) error {
+	s.mu.Lock()
+	s.running++
+	s.peak = max(s.peak, s.running)
+	s.mu.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	s.mu.Lock()
+	s.running--
+	s.mu.Unlock()
+	return nil
+}
+
+func TestFlushLimitsWorkers(t *testing.T) {
+	ctx := context.Background()
+	store, _ := setup(t)
+	for chatID := int64(1); chatID <= 6; chatID++ {
+		feed := &model.Feed{ChatID: chatID, Name: "Feed", URL: "https://example.com/rss", IntervalMinutes: 15, IsActive: true}
+		if err := store.CreateFeed(ctx, feed); err != nil {
+			t.Fatalf("create feed: %v", err)
+		}
+		if err := store.EnqueueMessage(ctx, &model.OutboxMessage{ChatID: chatID, FeedID: feed.ID, GUID: "a"}); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+
+	d := &slowDeliverer{}
+	disp := newDispatcher(store, d)
+	disp.SetWorkers(2)
+	disp.Flush(ctx)
+
+	if d.peak > 2 {
+		t.Errorf("%d chats served at once, want at most 2", d.peak)
+	}
+	if diff := cmp.Diff(0, len(pending(t, store, time.Now()))); diff != "" {
+		t.Errorf("pending (-want +got):\n%s", diff)
+	}
+}
+
+func TestFlushRetriesTransientError(t *testing.T) {
+	ctx := context.Background()
+	store, feed := setup(t, "a")
+	d := &fakeDeliverer{errs: []error{errors.New("connection reset")}}
+	disp := newDispatcher(store, d)
+
+	disp.Flush(ctx)
+	if got := pending(t, store, time.Now()); len(got) != 0 {
+		t.Fatalf("message should wait for its retry, got %d due", len(got))
+	}
+	later := pending(t, store, time.Now().Add(baseRetryDelay+time.Second))
+	if len(later) != 1 {
+		t.Fatalf("due after delay = %d, want 1", len(later))
+	}
+	if diff := cmp.Diff(1, later[0].Attempts); diff != "" {
+		t.Errorf("attempts (-want +got):\n%s", diff)
+	}
+	if seen, _ := store.IsSeen(ctx, feed.ID, "a"); seen {
+		t.Error("undelivered item should not be seen")
+	}
+}
+
+func TestFlushGivesUp(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		maxAttempts int
+	}{
+		{
+			name:        "permanent error",
+			err:         &DeliveryError{Err: errors.New("bot was blocked"), Permanent: true},
+			maxAttempts: 10,
+		},
+		{
+			name:        "attempts exhausted",
+			err:         errors.New("timeout"),
+			maxAttempts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store, feed := setup(t, "a")
+			disp := newDispatcher(store, &fakeDeliverer{errs: []error{tt.err}})
+			disp.SetMaxAttempts(tt.maxAttempts)
+
+			disp.Flush(ctx)
+			if got := pending(t, store, time.Now().Add(24*time.Hour)); len(got) != 0 {
+				t.Errorf("pending = %d, want 0", len(got))
+			}
+			if seen, _ := store.IsSeen(ctx, feed.ID, "a"); !seen {
+				t.Error("failed item should be seen so that it is not queued again")
+			}
+		})
+	}
+}
+
+func TestFlushPausesOnlyTheFloodedChat(t *testing.T) {
+	ctx := context.Background()
+	store, _ := setup(t, "a1", "a2")
+	d := &fakeDeliverer{errs: []error{&DeliveryError{Err: errors.New("too many requests"), RetryAfter: time.Minute}}}
+	disp := newDispatcher(store, d)
+
+	disp.Flush(ctx)
+	if len(d.sent) != 0 {
+		t.Errorf("sent = %v, want nothing after flood control", d.sent)
+	}
+
+	// Another chat is still served while the first one waits out its pause.
+	other := &model.Feed{ChatID: 200, Name: "Other", URL: "https://example.org/rss", IntervalMinutes: 15, IsActive: true}
+	if err := store.CreateFeed(ctx, other); err != nil {
+		t.Fatalf("create feed: %v", err)
+	}
+	if err := store.EnqueueMessage(ctx, &model.OutboxMessage{ChatID: 200, FeedID: other.ID, GUID: "b1"}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	disp.Flush(ctx)
+	if diff := cmp.Diff([]string{"b1"}, d.sent); diff != "" {
+		t.Errorf("sent (-want +got):\n%s", diff)
+	}
+
+	got := pending(t, store, time.Now())
+	if diff := cmp.Diff(2, len(got)); diff != "" {
+		t.Fatalf("pending (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(0, got[0].Attempts); diff != "" {
+		t.Errorf("flood control should not count as an attempt (-want +got):\n%s", diff)
+	}
+}
+
+// blockingDeliverer holds the messages of one chat until release is closed.
+type blockingDeliverer struct {
+	fakeDeliverer
+	chatID  int64
+	release chan struct{}
+}
+
+func (b *blockingDeliverer) Deliver(ctx context.Context, m model.OutboxMessage) error {
+	if m.ChatID == b.chatID {
+		<-b.release
+	}
+	return b.fakeDeliverer.Deliver(ctx, m)
+}
+
+func TestDispatchDoesNotWaitForSlowChat(t *testing.T) {
+	ctx := context.Background()
+	store, _ := setup(t, "a1")
+	d := &blockingDeliverer{chatID: 100, release: make(chan struct{})}
+	disp := newDispatcher(store, d)
+	disp.dispatch(ctx)
+
+	other := &model.Feed{ChatID: 200, Name: "Other", URL: "https://example.org/rss", IntervalMinutes: 15, IsActive: true}
+	if err := store.CreateFeed(ctx, other); err != nil {
+		t.Fatalf("create feed: %v", err)
+	}
+	if err := store.EnqueueMessage(ctx, &model.OutboxMessage{ChatID: 200, FeedID: other.ID, GUID: "b1"}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	// The next poll serves the other chat and leaves the busy one alone.
+	disp.dispatch(ctx)
+	deadline := time.Now().Add(time.Second)
+	for {
+		d.mu.Lock()
+		sent := append([]string(nil), d.sent...)
+		d.mu.Unlock()
+		if len(sent) > 0 {
+			if diff := cmp.Diff([]string{"b1"}, sent); diff != "" {
+				t.Errorf("sent (-want +got):\n%s", diff)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the other chat was not served while the first one was busy")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	close(d.release)
+	disp.wg.Wait()
+	if diff := cmp.Diff([]string{"b1", "a1"}, d.sent); diff != "" {
+		t.Errorf("sent (-want +got):\n%s", diff)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 5 * time.Second},
+		{2, 10 * time.Second},
+		{4, 40 * time.Second},
+		{20, time.Hour},
+	}
+	for _, tt := range tests {
+		if diff := cmp.Diff(tt.want, retryDelay(tt.attempts)); diff != "" {
+			t.Errorf("retryDelay(%d) (-want +got):\n%s", tt.attempts, diff)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Package quiet decides how notifications are delivered during the quiet
+// hours of a chat.
+package quiet
+
+import (
+	"strings"
+	"time"
+
+	"rss_bot/internal/digest"
+	"rss_bot/internal/i18n"
+	"rss_bot/internal/model"
+)
+
+const clockLayout = "15:04"
+
+// ParseWindow parses quiet hours such as "23:00-08:00". The window may span
+// midnight but must not be empty.
+func ParseWindow(s string) (start, end string, err error) {
+	from, to, ok := strings.Cut(strings.ReplaceAll(s, " ", ""), "-")
+	if !ok {
+		return "", "", i18n.Errorf("expected HH:MM-HH:MM")
+	}
+	a, err := time.Parse(clockLayout, from)
+	if err != nil {
+		return "", "", i18n.Errorf("invalid time %q, expected HH:MM", from)
+	}
+	b, err := time.Parse(clockLayout, to)
+	if err != nil {
+		return "", "", i18n.Errorf("invalid time %q, expected HH:MM", to)
+	}
+	if a.Equal(b) {
+		return "", "", i18n.Errorf("start and end are the same")
+	}
+	return a.Format(clockLayout), b.Format(clockLayout), nil
+}
+
+// Until returns the end of the quiet hours that t falls into, and false if t
+// is outside them. Start and end are wall-clock times in loc.
+func Until(start, end string, loc *time.Location, t time.Time) (time.Time, bool) {
+	a, err := time.Parse(clockLayout, start)
+	if err != nil {
+		return time.Time{}, false
+	}
+	b, err := time.Parse(clockLayout, end)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	local := t.In(loc)
+	// The window containing t started either today or yesterday.
+	for day := -1; day <= 0; day++ {
+		from := time.Date(local.Year(), local.Month(), local.Day()+day, a.Hour(), a.Minute(), 0, 0, loc)
+		to := time.Date(local.Year(), local.Month(), local.Day()+day, b.Hour(), b.Minute(), 0, 0, loc)
+		if !to.After(from) {
+			to = time.Date(local.Year(), local.Month(), local.Day()+day+1, b.Hour(), b.Minute(), 0, 0, loc)
+		}
+		if !t.Before(from) && t.Before(to) {
+			return to, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Active returns the end of the chat's current quiet hours, and false if the
+// chat has none or they are not in effect at t.
+func Active(settings *model.ChatSettings, t time.Time) (time.Time, bool) {
+	if settings == nil || settings.QuietStart == "" || settings.QuietEnd == "" {
+		return time.Time{}, false
+	}
+	return Until(settings.QuietStart, settings.QuietEnd, digest.Location(settings.Timezone), t)
+}
+
+// Mode returns how the notifications of feed are delivered during quiet
+// hours: the feed's own mode, else the chat's, else they are held.
+func Mode(feed *model.Feed, settings *model.ChatSettings) model.QuietMode {
+	if feed.Quiet != model.QuietDefault {
+		return feed.Quiet
+	}
+	if settings != nil && settings.QuietMode == model.QuietSilent {
+		return model.QuietSilent
+	}
+	return model.QuietHold
+}
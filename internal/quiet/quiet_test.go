@@ -0,0 +1,139 @@
+package quiet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"rss_bot/internal/model"
+)
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		input     string
+		wantStart string
+		wantEnd   string
+		wantErr   bool
+	}{
+		{input: "23:00-08:00", wantStart: "23:00", wantEnd: "08:00"},
+		{input: "9:30 - 12:00", wantStart: "09:30", wantEnd: "12:00"},
+		{input: "23:00", wantErr: true},
+		{input: "23:00-25:00", wantErr: true},
+		{input: "08:00-08:00", wantErr: true},
+	}
+	for _, tt := range tests {
+		start, end, err := ParseWindow(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseWindow(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if diff := cmp.Diff([]string{tt.wantStart, tt.wantEnd}, []string{start, end}); diff != "" {
+			t.Errorf("ParseWindow(%q) (-want +got):\n%s", tt.input, diff)
+		}
+	}
+}
+
+func TestUntil(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("no time zone data: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		start  string
+		end    string
+		t      time.Time
+		want   time.Time
+		wantOK bool
+	}{
+		{
+			name:  "late evening",
+			start: "23:00", end: "08:00",
+			t:      time.Date(2024, 5, 10, 23, 30, 0, 0, berlin),
+			want:   time.Date(2024, 5, 11, 8, 0, 0, 0, berlin),
+			wantOK: true,
+		},
+		{
+			name:  "after midnight",
+			start: "23:00", end: "08:00",
+			t:      time.Date(2024, 5, 11, 3, 0, 0, 0, berlin),
+			want:   time.Date(2024, 5, 11, 8, 0, 0, 0, berlin),
+			wantOK: true,
+		},
+		{
+			name:  "end is not quiet",
+			start: "23:00", end: "08:00",
+			t: time.Date(2024, 5, 11, 8, 0, 0, 0, berlin),
+		},
+		{
+			name:  "daytime",
+			start: "23:00", end: "08:00",
+			t: time.Date(2024, 5, 11, 12, 0, 0, 0, berlin),
+		},
+		{
+			name:  "window within a day",
+			start: "12:00", end: "14:00",
+			t:      time.Date(2024, 5, 11, 12, 0, 0, 0, berlin),
+			want:   time.Date(2024, 5, 11, 14, 0, 0, 0, berlin),
+			wantOK: true,
+		},
+		{
+			name:  "in the chat's time zone, not UTC",
+			start: "23:00", end: "08:00",
+			t:      time.Date(2024, 5, 10, 22, 0, 0, 0, time.UTC), // midnight in Berlin
+			want:   time.Date(2024, 5, 11, 8, 0, 0, 0, berlin),
+			wantOK: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Until(tt.start, tt.end, berlin, tt.t)
+			if ok != tt.wantOK || !got.Equal(tt.want) {
+				t.Errorf("Until = %v, %v; want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestActive(t *testing.T) {
+	now := time.Date(2024, 5, 10, 23, 30, 0, 0, time.UTC)
+
+	if _, ok := Active(nil, now); ok {
+		t.Error("no settings should not be quiet")
+	}
+	if _, ok := Active(&model.ChatSettings{Timezone: "UTC"}, now); ok {
+		t.Error("chat without quiet hours should not be quiet")
+	}
+	cs := &model.ChatSettings{Timezone: "UTC", QuietStart: "23:00", QuietEnd: "08:00"}
+	if _, ok := Active(cs, now); !ok {
+		t.Error("23:30 should be quiet")
+	}
+}
+
+func TestMode(t *testing.T) {
+	hold := &model.ChatSettings{QuietMode: model.QuietHold}
+	silent := &model.ChatSettings{QuietMode: model.QuietSilent}
+
+	tests := []struct {
+		name     string
+		feed     model.QuietMode
+		settings *model.ChatSettings
+		want     model.QuietMode
+	}{
+		{"chat hold", model.QuietDefault, hold, model.QuietHold},
+		{"chat silent", model.QuietDefault, silent, model.QuietSilent},
+		{"no settings", model.QuietDefault, nil, model.QuietHold},
+		{"feed overrides chat", model.QuietSilent, hold, model.QuietSilent},
+		{"feed hold overrides chat", model.QuietHold, silent, model.QuietHold},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Mode(&model.Feed{Quiet: tt.feed}, tt.settings)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Mode (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
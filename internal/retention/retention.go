@@ -0,0 +1,125 @@
+// Package retention prunes old seen items and archived items and compresses
+// the full content stored with seen items, so that the database stops growing
+// with every delivery.
+package retention
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"rss_bot/internal/storage"
+)
+
+const (
+	defaultMaxAge   = 90 * 24 * time.Hour
+	defaultInterval = 6 * time.Hour
+	// vacuumPages bounds the free pages returned to the file system per run,
+	// so that the job holds the database only briefly.
+	vacuumPages = 2048
+)
+
+// Result is the outcome of a retention run.
+type Result struct {
+	At        time.Time
+	Pruned    int64 // seen items deleted
+	Archived  int64 // archived items deleted
+	Compacted int   // items whose content was compressed
+	Err       error
+}
+
+// Job periodically prunes seen items and archived items older than the
+// maximum age. Seen items still in their feed's latest download are kept, so
+// that they are not sent again.
+type Job struct {
+	store    storage.Storage
+	log      *slog.Logger
+	maxAge   time.Duration
+	interval time.Duration
+
+	mu   sync.Mutex
+	last *Result
+}
+
+// New creates a Job.
+func New(store storage.Storage, log *slog.Logger) *Job {
+	return &Job{
+		store:    store,
+		log:      log,
+		maxAge:   defaultMaxAge,
+		interval: defaultInterval,
+	}
+}
+
+// SetMaxAge sets how long seen items and archived items are kept.
+func (j *Job) SetMaxAge(d time.Duration) {
+	j.maxAge = d
+}
+
+// MaxAge returns how long seen items and archived items are kept.
+func (j *Job) MaxAge() time.Duration {
+	return j.maxAge
+}
+
+// SetInterval overrides how often the job runs.
+func (j *Job) SetInterval(d time.Duration) {
+	j.interval = d
+}
+
+// Run runs the job at once and then at every interval until ctx is cancelled.
+func (j *Job) Run(ctx context.Context) {
+	j.log.Info("retention started", "max_age", j.maxAge, "interval", j.interval)
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		j.RunOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce compresses content stored before compression, prunes old seen and
+// archived items and returns some of the freed space to the file system.
+func (j *Job) RunOnce(ctx context.Context) Result {
+	res := Result{At: time.Now()}
+	defer func() {
+		j.mu.Lock()
+		j.last = &res
+		j.mu.Unlock()
+	}()
+
+	if res.Compacted, res.Err = j.store.CompactContent(ctx); res.Err != nil {
+		j.log.Error("compact content", "error", res.Err)
+		return res
+	}
+	if res.Pruned, res.Err = j.store.PruneSeenItems(ctx, res.At.Add(-j.maxAge)); res.Err != nil {
+		j.log.Error("prune seen items", "error", res.Err)
+		return res
+	}
+	if res.Archived, res.Err = j.store.PruneArchive(ctx, res.At.Add(-j.maxAge)); res.Err != nil {
+		j.log.Error("prune archive", "error", res.Err)
+		return res
+	}
+	// Pages left over are returned by the following runs.
+	if res.Err = j.store.IncrementalVacuum(ctx, vacuumPages); res.Err != nil {
+		j.log.Error("incremental vacuum", "error", res.Err)
+		return res
+	}
+	j.log.Info("retention done", "pruned", res.Pruned, "archived", res.Archived, "compacted", res.Compacted)
+	return res
+}
+
+// Last returns the outcome of the latest run, or nil before the first one.
+func (j *Job) Last() *Result {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.last == nil {
+		return nil
+	}
+	res := *j.last
+	return &res
+}
@@ -0,0 +1,69 @@
+package retention
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"rss_bot/internal/model"
+	"rss_bot/internal/storage"
+)
+
+func TestRunOnce(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.NewSQLite(":memory:")
+	if err != nil {
+		t.Fatalf("new sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+
+	feed := model.Feed{ChatID: 100, Name: "Feed", URL: "https://example.com/rss", IntervalMinutes: 15, IsActive: true}
+	if err := store.CreateFeed(ctx, &feed); err != nil {
+		t.Fatalf("create feed: %v", err)
+	}
+	for _, guid := range []string{"old", "in feed"} {
+		if err := store.MarkSeen(ctx, feed.ID, guid, "content"); err != nil {
+			t.Fatalf("mark seen: %v", err)
+		}
+	}
+	if err := store.MarkInFeed(ctx, feed.ID, []string{"in feed"}); err != nil {
+		t.Fatalf("mark in feed: %v", err)
+	}
+	delivered := &model.OutboxMessage{ChatID: 100, FeedID: feed.ID, GUID: "in feed", Archive: []model.ArchivedItem{
+		{FeedID: feed.ID, GUID: "in feed", FeedName: "Feed", Title: "Archived"},
+	}}
+	if err := store.CompleteMessage(ctx, delivered); err != nil {
+		t.Fatalf("complete message: %v", err)
+	}
+
+	job := New(store, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if job.Last() != nil {
+		t.Error("Last before the first run: want nil")
+	}
+
+	// A negative age makes everything just seen count as old.
+	job.SetMaxAge(-time.Minute)
+	res := job.RunOnce(ctx)
+	want := Result{Pruned: 1, Archived: 1}
+	if diff := cmp.Diff(want, res, cmpopts.IgnoreFields(Result{}, "At")); diff != "" {
+		t.Errorf("result (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(&res, job.Last()); diff != "" {
+		t.Errorf("Last (-want +got):\n%s", diff)
+	}
+	for guid, want := range map[string]bool{"old": false, "in feed": true} {
+		if seen, _ := store.IsSeen(ctx, feed.ID, guid); seen != want {
+			t.Errorf("IsSeen(%q) = %v, want %v", guid, seen, want)
+		}
+	}
+
+	job.SetMaxAge(time.Hour)
+	if res := job.RunOnce(ctx); res.Pruned != 0 || res.Err != nil {
+		t.Errorf("second run = %+v, want nothing pruned", res)
+	}
+}
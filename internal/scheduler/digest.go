@@ -0,0 +1,84 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"rss_bot/internal/bot"
+	"rss_bot/internal/digest"
+	"rss_bot/internal/fetcher"
+	"rss_bot/internal/model"
+	"rss_bot/internal/quiet"
+)
+
+// collect adds an item to the chat's next digest. The item is marked seen
+// right away.
+func (s *Scheduler) collect(ctx context.Context, feed *model.Feed, item fetcher.MatchedItem) error {
+	return s.store.AddDigestItem(ctx, &model.DigestItem{
+		ChatID:  feed.ChatID,
+		FeedID:  feed.ID,
+		GUID:    item.GUID,
+		Title:   item.Title,
+		Link:    item.Link,
+		Archive: bot.ArchiveEntry(feed, item),
+	}, item.Description)
+}
+
+// sendDigests queues the digest of every chat for which a scheduled time has
+// passed since its oldest collected item. Digests that fall into the chat's
+// quiet hours are sent silently, since their times were chosen by the chat.
+func (s *Scheduler) sendDigests(ctx context.Context, now time.Time) {
+	pending, err := s.store.ListPendingDigests(ctx)
+	if err != nil {
+		s.log.Error("list pending digests", "error", err)
+		return
+	}
+
+	for _, p := range pending {
+		settings, err := s.store.GetChatSettings(ctx, p.ChatID)
+		if err != nil {
+			s.log.Error("get chat settings", "chat_id", p.ChatID, "error", err)
+			continue
+		}
+		if !digest.Due(settings.DigestTimes, digest.Location(settings.Timezone), p.Since, now) {
+			continue
+		}
+
+		items, err := s.store.ListDigestItems(ctx, p.ChatID)
+		if err != nil {
+			s.log.Error("list digest items", "chat_id", p.ChatID, "error", err)
+			continue
+		}
+		if len(items) == 0 {
+			continue
+		}
+
+		_, silent := quiet.Active(settings, now)
+		parts := bot.FormatDigest(bot.ChatLanguage(settings), items)
+		msgs := make([]model.OutboxMessage, 0, len(parts))
+		for i, part := range parts {
+			msgs = append(msgs, model.OutboxMessage{
+				ChatID: p.ChatID,
+				// Unique per digest, so that the outbox does not take it for a repeat.
+				GUID:   fmt.Sprintf("digest:%d:%d", items[len(items)-1].ID, i+1),
+				Text:   part,
+				Silent: silent,
+			})
+		}
+		// The items go to the archive once the last message is delivered.
+		last := &msgs[len(msgs)-1]
+		for _, item := range items {
+			if item.Archive != nil {
+				a := *item.Archive
+				a.FeedID, a.GUID = item.FeedID, item.GUID
+				last.Archive = append(last.Archive, a)
+			}
+		}
+		if err := s.store.CompleteDigest(ctx, items, msgs); err != nil {
+			s.log.Error("queue digest", "chat_id", p.ChatID, "error", err)
+			continue
+		}
+		s.log.Info("digest queued", "chat_id", p.ChatID, "items", len(items), "messages", len(msgs))
+	}
+}
@@ -0,0 +1,89 @@
+package scheduler
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// hostLimiter bounds the number of concurrent requests per host and enforces
+// a minimum delay between the starts of consecutive requests to the same host.
+type hostLimiter struct {
+	perHost int
+	delay   time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+type hostState struct {
+	slots chan struct{}
+	next  time.Time
+}
+
+func newHostLimiter(perHost int, delay time.Duration) *hostLimiter {
+	return &hostLimiter{
+		perHost: max(perHost, 1),
+		delay:   delay,
+		hosts:   make(map[string]*hostState),
+	}
+}
+
+// acquire blocks until a request to host may start. Every successful acquire
+// must be paired with a release.
+func (l *hostLimiter) acquire(ctx context.Context, host string) error {
+	h := l.state(host)
+
+	select {
+	case h.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	start := h.next
+	if start.Before(now) {
+		start = now
+	}
+	h.next = start.Add(l.delay)
+	l.mu.Unlock()
+
+	if wait := time.Until(start); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			<-h.slots
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// release frees the slot taken by acquire.
+func (l *hostLimiter) release(host string) {
+	<-l.state(host).slots
+}
+
+func (l *hostLimiter) state(host string) *hostState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h, ok := l.hosts[host]
+	if !ok {
+		h = &hostState{slots: make(chan struct{}, l.perHost)}
+		l.hosts[host] = h
+	}
+	return h
+}
+
+// hostOf returns the host part of a feed URL, or the URL itself if it cannot be parsed.
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	return u.Hostname()
+}
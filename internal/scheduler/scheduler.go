@@ -2,13 +2,24 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/mmcdole/gofeed"
+
+	"rss_bot/internal/backlog"
 	"rss_bot/internal/bot"
+	"rss_bot/internal/digest"
+	"rss_bot/internal/feedlock"
 	"rss_bot/internal/fetcher"
+	"rss_bot/internal/i18n"
+	"rss_bot/internal/metrics"
 	"rss_bot/internal/model"
+	"rss_bot/internal/quiet"
 	"rss_bot/internal/storage"
 )
 
@@ -18,34 +29,49 @@ type Sender interface {
 	SendMessageWithKeyboard(chatID int64, text string, markup interface{})
 }
 
+const (
+	defaultMaxFailures = 10
+	maxRetryDelay      = 24 * time.Hour
+
+	defaultWorkers   = 4
+	defaultPerHost   = 2
+	defaultHostDelay = time.Second
+)
+
 // Scheduler periodically checks RSS feeds and sends notifications.
 type Scheduler struct {
-	store   storage.Storage
-	fetcher *fetcher.Fetcher
-	sender  Sender
-	log     *slog.Logger
-	tick    time.Duration
+	store       storage.Storage
+	fetcher     *fetcher.Fetcher
+	sender      Sender
+	log         *slog.Logger
+	tick        time.Duration
+	maxFailures int
+	workers     int
+	hosts       *hostLimiter
+	locks       *feedlock.Set
+	metrics     *metrics.Metrics
+	// lastCheck is when checkAll last completed or, during a round, last
+	// finished checking a feed URL, in Unix nanoseconds.
+	lastCheck atomic.Int64
 }
 
 // New creates a Scheduler with the default HTTP client.
 func New(store storage.Storage, sender Sender, log *slog.Logger) *Scheduler {
-	return &Scheduler{
-		store:   store,
-		fetcher: fetcher.New(http.DefaultClient),
-		sender:  sender,
-		log:     log,
-		tick:    1 * time.Minute,
-	}
+	return NewWithFetcher(store, fetcher.New(http.DefaultClient), sender, log)
 }
 
 // NewWithFetcher creates a Scheduler with a custom fetcher (useful for testing).
 func NewWithFetcher(store storage.Storage, f *fetcher.Fetcher, sender Sender, log *slog.Logger) *Scheduler {
 	return &Scheduler{
-		store:   store,
-		fetcher: f,
-		sender:  sender,
-		log:     log,
-		tick:    1 * time.Minute,
+		store:       store,
+		fetcher:     f,
+		sender:      sender,
+		log:         log,
+		tick:        1 * time.Minute,
+		maxFailures: defaultMaxFailures,
+		workers:     defaultWorkers,
+		hosts:       newHostLimiter(defaultPerHost, defaultHostDelay),
+		locks:       feedlock.New(),
 	}
 }
 
@@ -54,9 +80,51 @@ func (s *Scheduler) SetTickInterval(d time.Duration) {
 	s.tick = d
 }
 
+// TickInterval returns how often the scheduler looks for due feeds.
+func (s *Scheduler) TickInterval() time.Duration {
+	return s.tick
+}
+
+// SetMaxFailures sets how many consecutive failed checks pause a feed.
+func (s *Scheduler) SetMaxFailures(n int) {
+	s.maxFailures = n
+}
+
+// SetConcurrency sets the number of feeds checked in parallel, the number of
+// concurrent requests allowed per host and the minimum delay between requests
+// to the same host.
+func (s *Scheduler) SetConcurrency(workers, perHost int, hostDelay time.Duration) {
+	s.workers = max(workers, 1)
+	s.hosts = newHostLimiter(perHost, hostDelay)
+}
+
+// SetFeedLocks shares a lock set with other components that process feeds,
+// such as the bot's manual /check command.
+func (s *Scheduler) SetFeedLocks(locks *feedlock.Set) {
+	s.locks = locks
+}
+
+// SetMetrics makes the scheduler and its fetcher record metrics.
+func (s *Scheduler) SetMetrics(m *metrics.Metrics) {
+	s.metrics = m
+	s.fetcher.SetMetrics(m)
+}
+
+// LastCheck returns when the scheduler last made progress: completed a round
+// of feed checks or, during a long round, finished checking a feed URL. It
+// returns the zero time if neither has happened yet.
+func (s *Scheduler) LastCheck() time.Time {
+	ns := s.lastCheck.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
 // Run starts the scheduler loop, blocking until ctx is cancelled.
 func (s *Scheduler) Run(ctx context.Context) {
 	s.checkAll(ctx)
+	s.sendDigests(ctx, time.Now())
 
 	ticker := time.NewTicker(s.tick)
 	defer ticker.Stop()
@@ -67,6 +135,7 @@ func (s *Scheduler) Run(ctx context.Context) {
 			return
 		case <-ticker.C:
 			s.checkAll(ctx)
+			s.sendDigests(ctx, time.Now())
 		}
 	}
 }
@@ -77,71 +146,349 @@ func (s *Scheduler) checkAll(ctx context.Context) {
 		s.log.Error("list due feeds", "error", err)
 		return
 	}
+	now := time.Now()
+	for _, feed := range feeds {
+		if due, ok := dueAt(feed); ok {
+			s.metrics.SchedulerLag(now.Sub(due))
+		}
+	}
+
+	groups := groupByURL(feeds)
+
+	jobs := make(chan []model.Feed)
+	var wg sync.WaitGroup
+	for i := 0; i < min(s.workers, len(groups)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for group := range jobs {
+				s.processGroup(ctx, group)
+				if ctx.Err() == nil {
+					s.lastCheck.Store(time.Now().UnixNano())
+				}
+			}
+		}()
+	}
+
+groups:
+	for _, group := range groups {
+		select {
+		case <-ctx.Done():
+			break groups
+		case jobs <- group:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if ctx.Err() == nil {
+		s.lastCheck.Store(time.Now().UnixNano())
+	}
+}
+
+// dueAt returns when a feed became due for a check. A feed that was never
+// checked has no due time.
+func dueAt(feed model.Feed) (time.Time, bool) {
+	if feed.LastCheckAt == nil {
+		return time.Time{}, false
+	}
+	due := feed.LastCheckAt.Add(time.Duration(feed.IntervalMinutes) * time.Minute)
+	if feed.NextRetryAt != nil && feed.NextRetryAt.After(due) {
+		due = *feed.NextRetryAt
+	}
+	return due, true
+}
 
+// groupByURL groups subscriptions that share a feed URL, keeping the order in
+// which each URL first appears.
+func groupByURL(feeds []model.Feed) [][]model.Feed {
+	index := make(map[string]int)
+	var groups [][]model.Feed
 	for _, feed := range feeds {
-		if ctx.Err() != nil {
-			return
+		i, ok := index[feed.URL]
+		if !ok {
+			i = len(groups)
+			index[feed.URL] = i
+			groups = append(groups, nil)
 		}
-		s.processFeed(ctx, feed)
+		groups[i] = append(groups[i], feed)
 	}
+	return groups
 }
 
-func (s *Scheduler) processFeed(ctx context.Context, feed model.Feed) {
-	s.log.Info("feed check started", "feed_id", feed.ID, "name", feed.Name, "url", feed.URL)
+// processGroup fetches a URL once and delivers its items to every subscription in the group.
+func (s *Scheduler) processGroup(ctx context.Context, group []model.Feed) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	var locked []int64
+	defer func() {
+		for _, id := range locked {
+			s.locks.Unlock(id)
+		}
+	}()
+
+	var feeds []model.Feed
+	for _, feed := range group {
+		if !s.locks.TryLock(feed.ID) {
+			s.log.Info("feed check skipped, already in progress", "feed_id", feed.ID, "name", feed.Name)
+			continue
+		}
+		locked = append(locked, feed.ID)
+
+		// The feed may have been paused or edited while it was waiting for a worker.
+		current, err := s.store.GetFeed(ctx, feed.ID)
+		if err != nil {
+			s.log.Error("reload feed", "feed_id", feed.ID, "error", err)
+			continue
+		}
+		if current.IsActive {
+			feeds = append(feeds, *current)
+		}
+	}
+	if len(feeds) == 0 {
+		return
+	}
 
-	rssFeed, err := s.fetcher.Fetch(ctx, feed.URL)
+	url := feeds[0].URL
+	s.log.Info("feed check started", "url", url, "subscriptions", len(feeds))
+
+	host := hostOf(url)
+	if err := s.hosts.acquire(ctx, host); err != nil {
+		return
+	}
+	resp, err := s.fetcher.FetchConditional(ctx, url, sharedValidators(feeds))
+	s.hosts.release(host)
 	if err != nil {
-		s.log.Error("fetch feed", "feed_id", feed.ID, "url", feed.URL, "error", err)
-		s.updateLastCheck(ctx, &feed)
+		if ctx.Err() != nil {
+			return
+		}
+		s.log.Error("fetch feed", "url", url, "error", err)
+		for i := range feeds {
+			s.recordFailure(ctx, &feeds[i], err)
+		}
 		return
 	}
+	var guids []string
+	if !resp.NotModified {
+		s.metrics.ItemsFetched(len(resp.Feed.Items))
+		for _, item := range resp.Feed.Items {
+			guids = append(guids, fetcher.ItemGUID(item))
+		}
+	}
+
+	for i := range feeds {
+		feed := &feeds[i]
+		if feed.FailureCount > 0 {
+			if err := s.store.ResetFeedFailures(ctx, feed.ID); err != nil {
+				s.log.Error("reset feed failures", "feed_id", feed.ID, "error", err)
+			}
+		}
+
+		if resp.NotModified {
+			s.log.Info("feed not modified", "feed_id", feed.ID, "name", feed.Name)
+			s.updateLastCheck(ctx, feed)
+			continue
+		}
+
+		if err := s.store.MarkInFeed(ctx, feed.ID, guids); err != nil {
+			s.log.Error("mark items in feed", "feed_id", feed.ID, "error", err)
+		}
+		if !s.deliver(ctx, feed, resp.Feed) {
+			continue
+		}
+
+		// Validators are stored only after the items were processed, so a failure
+		// above makes the next check download the feed again instead of getting a 304.
+		if resp.Validators.ETag != feed.ETag || resp.Validators.LastModified != feed.LastModified {
+			if err := s.store.UpdateFeedValidators(ctx, feed.ID, resp.Validators.ETag, resp.Validators.LastModified); err != nil {
+				s.log.Error("update feed validators", "feed_id", feed.ID, "error", err)
+			}
+		}
+
+		s.updateLastCheck(ctx, feed)
+	}
+}
+
+// sharedValidators returns the cache validators to send for a group of
+// subscriptions. A conditional request is only safe when all of them have
+// processed the same version of the feed.
+func sharedValidators(feeds []model.Feed) fetcher.Validators {
+	v := fetcher.Validators{ETag: feeds[0].ETag, LastModified: feeds[0].LastModified}
+	for _, feed := range feeds[1:] {
+		if feed.ETag != v.ETag || feed.LastModified != v.LastModified {
+			return fetcher.Validators{}
+		}
+	}
+	return v
+}
 
-	totalItems := len(rssFeed.Items)
+// deliver runs a subscription's filters against the fetched feed and sends its
+// unseen matching items. It returns false if the subscription could not be processed.
+func (s *Scheduler) deliver(ctx context.Context, feed *model.Feed, rssFeed *gofeed.Feed) bool {
+	if feed.BacklogState == model.BacklogAsked && !bot.BacklogPromptExpired(feed, time.Now()) {
+		s.log.Debug("waiting for backlog choice", "feed_id", feed.ID)
+		return true
+	}
 
 	filters, err := s.store.ListFilters(ctx, feed.ID)
 	if err != nil {
 		s.log.Error("list filters", "feed_id", feed.ID, "error", err)
-		return
+		return false
 	}
 
+	start := time.Now()
 	matched := fetcher.FilterItems(rssFeed.Items, filters)
+	s.metrics.FilterDone(len(matched), time.Since(start))
 
-	sent := 0
+	var unseen []fetcher.MatchedItem
 	for _, item := range matched {
 		seen, err := s.store.IsSeen(ctx, feed.ID, item.GUID)
 		if err != nil {
 			s.log.Error("check seen", "feed_id", feed.ID, "guid", item.GUID, "error", err)
 			continue
 		}
-		if seen {
-			continue
+		if !seen {
+			unseen = append(unseen, item)
 		}
+	}
 
-		msg := bot.FormatNotificationShort(int(feed.Position), feed.Name, item)
-		if msg.Markup != nil {
-			s.sender.SendMessageWithKeyboard(feed.ChatID, msg.Text, msg.Markup)
-		} else {
-			s.sender.SendMessage(feed.ChatID, msg.Text)
+	settings, err := s.store.GetChatSettings(ctx, feed.ChatID)
+	if err != nil {
+		s.log.Error("get chat settings", "chat_id", feed.ChatID, "error", err)
+	}
+
+	toSend := unseen
+	if feed.BacklogState != model.BacklogDone {
+		policy := feed.BacklogPolicy
+		if feed.BacklogState == model.BacklogAsked {
+			// Nobody answered the prompt before its buttons expired.
+			s.log.Info("backlog prompt expired", "feed_id", feed.ID)
+			policy = model.DefaultBacklogPolicy
+		}
+		plan := backlog.Apply(policy, feed.BacklogLimit, unseen)
+		if plan.Ask {
+			text, markup, err := bot.BacklogPrompt(ctx, s.store, bot.ChatLanguage(settings), feed, len(unseen))
+			if err != nil {
+				s.log.Error("backlog prompt", "feed_id", feed.ID, "error", err)
+				return true
+			}
+			s.sender.SendMessageWithKeyboard(feed.ChatID, text, markup)
+			s.setBacklogState(ctx, feed, model.BacklogAsked)
+			return true
+		}
+		for _, item := range plan.Skip {
+			if err := s.store.MarkSeen(ctx, feed.ID, item.GUID, item.Description); err != nil {
+				s.log.Error("mark seen", "feed_id", feed.ID, "guid", item.GUID, "error", err)
+			}
 		}
-		sent++
+		toSend = plan.Send
+		s.setBacklogState(ctx, feed, model.BacklogDone)
+	}
 
-		if err := s.store.MarkSeen(ctx, feed.ID, item.GUID, item.Description); err != nil {
-			s.log.Error("mark seen", "feed_id", feed.ID, "guid", item.GUID, "error", err)
+	queue := func(item fetcher.MatchedItem) error {
+		return s.enqueue(ctx, feed, settings, item)
+	}
+	if digest.Enabled(feed, settings) {
+		queue = func(item fetcher.MatchedItem) error {
+			return s.collect(ctx, feed, item)
 		}
+	}
 
-		// Rate limit: ~20 messages/sec max for Telegram
-		time.Sleep(50 * time.Millisecond)
+	queued := 0
+	for _, item := range toSend {
+		if err := queue(item); err != nil {
+			s.log.Error("enqueue message", "feed_id", feed.ID, "guid", item.GUID, "error", err)
+			continue
+		}
+		queued++
 	}
 
 	s.log.Info("feed check done",
 		"feed_id", feed.ID,
 		"name", feed.Name,
-		"total_items", totalItems,
+		"total_items", len(rssFeed.Items),
 		"matched", len(matched),
-		"sent", sent,
+		"queued", queued,
+		"skipped", len(unseen)-len(toSend),
 	)
+	return true
+}
+
+// enqueue renders a notification into the outbox. The item is marked seen
+// by the outbox once Telegram accepts the message. During the chat's quiet
+// hours the message is either held until they end or sent silently.
+func (s *Scheduler) enqueue(ctx context.Context, feed *model.Feed, settings *model.ChatSettings, item fetcher.MatchedItem) error {
+	m, err := bot.ComposeOutboxMessage(ctx, s.store, feed, settings, item)
+	if err != nil {
+		return err
+	}
+	if until, ok := quiet.Active(settings, time.Now()); ok {
+		if quiet.Mode(feed, settings) == model.QuietSilent {
+			m.Silent = true
+		} else {
+			m.NextAttemptAt = until
+		}
+	}
+	return s.store.EnqueueMessage(ctx, m)
+}
+
+func (s *Scheduler) setBacklogState(ctx context.Context, feed *model.Feed, state model.BacklogState) {
+	if err := s.store.SetBacklogState(ctx, feed.ID, state); err != nil {
+		s.log.Error("set backlog state", "feed_id", feed.ID, "error", err)
+	}
+	feed.BacklogState = state
+}
+
+// recordFailure stores a failed check and backs off exponentially. The feed is
+// paused and its owner notified once it keeps failing or the server reports it gone.
+func (s *Scheduler) recordFailure(ctx context.Context, feed *model.Feed, fetchErr error) {
+	failures, err := s.store.RecordFeedFailure(ctx, feed.ID, fetchErr.Error(),
+		time.Now().Add(retryDelay(feed.IntervalMinutes, feed.FailureCount+1)))
+	if err != nil {
+		s.log.Error("record feed failure", "feed_id", feed.ID, "error", err)
+		failures = feed.FailureCount + 1
+	}
+	feed.FailureCount = failures
+	feed.LastError = fetchErr.Error()
+
+	// The reason is told to the chat in its language.
+	var reason error
+	var statusErr *fetcher.StatusError
+	switch {
+	case errors.As(fetchErr, &statusErr) && statusErr.StatusCode == http.StatusGone:
+		reason = i18n.Errorf("the server reports the feed is gone (HTTP 410)")
+	case failures >= s.maxFailures:
+		reason = i18n.Errorf("%d consecutive failed checks", failures)
+	}
+
+	if reason != nil {
+		feed.IsActive = false
+		s.log.Warn("feed paused", "feed_id", feed.ID, "name", feed.Name, "failures", failures, "reason", reason)
+		lang := s.chatLanguage(ctx, feed.ChatID)
+		s.sender.SendMessage(feed.ChatID, bot.FormatFeedPaused(lang, feed, lang.Error(reason)))
+	}
 
-	s.updateLastCheck(ctx, &feed)
+	s.updateLastCheck(ctx, feed)
+}
+
+// chatLanguage returns the language of a chat's messages.
+func (s *Scheduler) chatLanguage(ctx context.Context, chatID int64) i18n.Lang {
+	settings, err := s.store.GetChatSettings(ctx, chatID)
+	if err != nil {
+		s.log.Error("get chat settings", "chat_id", chatID, "error", err)
+	}
+	return bot.ChatLanguage(settings)
+}
+
+// retryDelay returns the wait before the next check after the given number of
+// consecutive failures: the feed interval, doubled for every further failure.
+func retryDelay(intervalMinutes, failures int) time.Duration {
+	delay := time.Duration(intervalMinutes) * time.Minute
+	for i := 1; i < failures && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
 }
 
 func (s *Scheduler) updateLastCheck(ctx context.Context, feed *model.Feed) {
@@ -3,24 +3,31 @@ package scheduler
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"io"
 	"log/slog"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 
+	"rss_bot/internal/feedlock"
 	"rss_bot/internal/fetcher"
+	"rss_bot/internal/metrics"
 	"rss_bot/internal/model"
+	"rss_bot/internal/outbox"
 	"rss_bot/internal/storage"
 )
 
 type sentMessage struct {
 	ChatID int64
 	Text   string
+	Silent bool
 }
 
 type mockSender struct {
@@ -40,6 +47,13 @@ func (m *mockSender) SendMessageWithKeyboard(chatID int64, text string, _ interf
 	m.messages = append(m.messages, sentMessage{ChatID: chatID, Text: text})
 }
 
+func (m *mockSender) Deliver(_ context.Context, msg model.OutboxMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, sentMessage{ChatID: msg.ChatID, Text: msg.Text, Silent: msg.Silent})
+	return nil
+}
+
 func (m *mockSender) getMessages() []sentMessage {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -48,6 +62,13 @@ func (m *mockSender) getMessages() []sentMessage {
 	return cp
 }
 
+// drain delivers everything the scheduler queued in the outbox.
+func drain(t *testing.T, store storage.Storage, sender *mockSender) {
+	t.Helper()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	outbox.New(store, sender, log).Flush(context.Background())
+}
+
 type mockHTTP struct {
 	body string
 }
@@ -109,7 +130,14 @@ func TestSchedulerProcessesDueFeeds(t *testing.T) {
 	log := slog.New(slog.NewTextHandler(io.Discard, nil))
 
 	sched := NewWithFetcher(store, f, sender, log)
+	if !sched.LastCheck().IsZero() {
+		t.Error("LastCheck set before the first check")
+	}
 	sched.checkAll(ctx)
+	if time.Since(sched.LastCheck()) > time.Minute {
+		t.Errorf("LastCheck = %v, want now", sched.LastCheck())
+	}
+	drain(t, store, sender)
 
 	msgs := sender.getMessages()
 
@@ -126,6 +154,13 @@ func TestSchedulerProcessesDueFeeds(t *testing.T) {
 			t.Errorf("chatID mismatch (-want +got):\n%s", diff)
 		}
 	}
+
+	// Delivered items are archived with their content; filtered ones are not.
+	for match, want := range map[string]int{`"sidecar"*`: 1, `"kubernetes"*`: 3, `"docker"*`: 0} {
+		if _, total, err := store.SearchArchive(ctx, 100, match, 10, 0); err != nil || total != want {
+			t.Errorf("SearchArchive(%s) = %d, %v; want %d", match, total, err, want)
+		}
+	}
 }
 
 func TestSchedulerSkipsSeenItems(t *testing.T) {
@@ -158,6 +193,7 @@ func TestSchedulerSkipsSeenItems(t *testing.T) {
 
 	sched := NewWithFetcher(store, f, sender, log)
 	sched.checkAll(ctx)
+	drain(t, store, sender)
 
 	msgs := sender.getMessages()
 	if diff := cmp.Diff(0, len(msgs)); diff != "" {
@@ -190,6 +226,7 @@ func TestSchedulerUpdatesLastCheck(t *testing.T) {
 
 	sched := NewWithFetcher(store, f, sender, log)
 	sched.checkAll(ctx)
+	drain(t, store, sender)
 
 	updated, err := store.GetFeed(ctx, feed.ID)
 	if err != nil {
@@ -236,6 +273,7 @@ func TestSchedulerWithExcludeFilter(t *testing.T) {
 
 	sched := NewWithFetcher(store, f, sender, log)
 	sched.checkAll(ctx)
+	drain(t, store, sender)
 
 	msgs := sender.getMessages()
 
@@ -273,6 +311,7 @@ func TestSchedulerCancelledContext(t *testing.T) {
 
 	sched := NewWithFetcher(store, f, sender, log)
 	sched.checkAll(ctx)
+	drain(t, store, sender)
 
 	msgs := sender.getMessages()
 	if diff := cmp.Diff(0, len(msgs)); diff != "" {
@@ -324,6 +363,7 @@ func TestSchedulerFetchError(t *testing.T) {
 
 	sched := NewWithFetcher(store, f, sender, log)
 	sched.checkAll(ctx)
+	drain(t, store, sender)
 
 	msgs := sender.getMessages()
 	if diff := cmp.Diff(0, len(msgs)); diff != "" {
@@ -361,6 +401,7 @@ func TestSchedulerNoFiltersPassesAll(t *testing.T) {
 
 	sched := NewWithFetcher(store, f, sender, log)
 	sched.checkAll(ctx)
+	drain(t, store, sender)
 
 	msgs := sender.getMessages()
 	wantCount := 5
@@ -369,6 +410,180 @@ func TestSchedulerNoFiltersPassesAll(t *testing.T) {
 	}
 }
 
+func TestSchedulerQueuesMedia(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	data, err := os.ReadFile("../../internal/testdata/with_media.xml")
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+
+	feed := model.Feed{
+		ChatID: 100, Name: "Media", URL: "https://media.example.com/rss",
+		IntervalMinutes: 15, IsActive: true,
+	}
+	if err := store.CreateFeed(ctx, &feed); err != nil {
+		t.Fatalf("create feed: %v", err)
+	}
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sched := NewWithFetcher(store, fetcher.New(&mockHTTP{body: string(data)}), &mockSender{}, log)
+	sched.checkAll(ctx)
+
+	msgs, err := store.ListDueMessages(ctx, time.Now(), 10)
+	if err != nil {
+		t.Fatalf("list due: %v", err)
+	}
+	got := map[string][]model.MediaKind{}
+	for _, m := range msgs {
+		var media []model.Media
+		if m.Media == "" {
+			continue
+		}
+		if err := json.Unmarshal([]byte(m.Media), &media); err != nil {
+			t.Fatalf("decode media of %s: %v", m.GUID, err)
+		}
+		for _, a := range media {
+			got[m.GUID] = append(got[m.GUID], a.Kind)
+		}
+	}
+	want := map[string][]model.MediaKind{
+		"episode-1": {model.MediaAudio},
+		"gallery-1": {model.MediaPhoto, model.MediaPhoto, model.MediaPhoto},
+		"clip-1":    {model.MediaVideo},
+		"report-1":  {model.MediaDocument},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("queued media (-want +got):\n%s", diff)
+	}
+}
+
+func TestSchedulerDigest(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	feed := model.Feed{
+		ChatID: 100, Name: "Busy", URL: "https://example.com/rss",
+		IntervalMinutes: 15, IsActive: true, BacklogPolicy: model.BacklogAll,
+	}
+	if err := store.CreateFeed(ctx, &feed); err != nil {
+		t.Fatalf("create feed: %v", err)
+	}
+	if err := store.SaveChatSettings(ctx, &model.ChatSettings{
+		ChatID: 100, Timezone: "UTC", Digest: true, DigestTimes: []string{"09:00", "18:00"},
+	}); err != nil {
+		t.Fatalf("save settings: %v", err)
+	}
+
+	sender := &mockSender{}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sched := NewWithFetcher(store, fetcher.New(&mockHTTP{body: loadFixture(t)}), sender, log)
+	sched.checkAll(ctx)
+	drain(t, store, sender)
+
+	if diff := cmp.Diff(0, len(sender.getMessages())); diff != "" {
+		t.Fatalf("items should be collected, not sent (-want +got):\n%s", diff)
+	}
+	pending, err := store.ListPendingDigests(ctx)
+	if err != nil || len(pending) != 1 {
+		t.Fatalf("pending digests = %v, %v; want one", pending, err)
+	}
+
+	// Collected items are seen, so the next check does not collect them again.
+	sched.processGroup(ctx, []model.Feed{feed})
+	items, _ := store.ListDigestItems(ctx, 100)
+	if diff := cmp.Diff(5, len(items)); diff != "" {
+		t.Errorf("collected items (-want +got):\n%s", diff)
+	}
+
+	since := pending[0].Since
+	sched.sendDigests(ctx, since)
+	drain(t, store, sender)
+	if len(sender.getMessages()) != 0 {
+		t.Fatal("digest sent before its time")
+	}
+
+	sched.sendDigests(ctx, since.Add(24*time.Hour))
+	if _, total, _ := store.SearchArchive(ctx, 100, `feed : "busy"`, 10, 0); total != 0 {
+		t.Errorf("digest items archived before delivery = %d, want 0", total)
+	}
+	drain(t, store, sender)
+	msgs := sender.getMessages()
+	if diff := cmp.Diff(1, len(msgs)); diff != "" {
+		t.Fatalf("digest messages (-want +got):\n%s", diff)
+	}
+	if !strings.Contains(msgs[0].Text, "<b>[Busy]</b>") || !strings.Contains(msgs[0].Text, "5 new item(s)") {
+		t.Errorf("unexpected digest:\n%s", msgs[0].Text)
+	}
+	if pending, _ := store.ListPendingDigests(ctx); len(pending) != 0 {
+		t.Errorf("pending after digest = %v, want none", pending)
+	}
+	if _, total, _ := store.SearchArchive(ctx, 100, `feed : "busy"`, 10, 0); total != 5 {
+		t.Errorf("archived digest items = %d, want 5", total)
+	}
+}
+
+func TestSchedulerQuietHours(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	hold := model.Feed{
+		ChatID: 100, Name: "Held", URL: "https://example.com/held",
+		IntervalMinutes: 15, IsActive: true, BacklogPolicy: model.BacklogAll,
+	}
+	silent := model.Feed{
+		ChatID: 100, Name: "Silent", URL: "https://example.com/silent",
+		IntervalMinutes: 15, IsActive: true, BacklogPolicy: model.BacklogAll, Quiet: model.QuietSilent,
+	}
+	for _, f := range []*model.Feed{&hold, &silent} {
+		if err := store.CreateFeed(ctx, f); err != nil {
+			t.Fatalf("create feed: %v", err)
+		}
+		if err := store.UpdateFeed(ctx, f); err != nil {
+			t.Fatalf("update feed: %v", err)
+		}
+	}
+
+	// Quiet hours around the current time.
+	now := time.Now().UTC()
+	if err := store.SaveChatSettings(ctx, &model.ChatSettings{
+		ChatID: 100, Timezone: "UTC", DigestTimes: []string{"09:00"},
+		QuietStart: now.Add(-time.Hour).Format("15:04"), QuietEnd: now.Add(time.Hour).Format("15:04"),
+		QuietMode: model.QuietHold,
+	}); err != nil {
+		t.Fatalf("save settings: %v", err)
+	}
+
+	sender := &mockSender{}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sched := NewWithFetcher(store, fetcher.New(&mockHTTP{body: loadFixture(t)}), sender, log)
+	sched.checkAll(ctx)
+	drain(t, store, sender)
+
+	msgs := sender.getMessages()
+	if diff := cmp.Diff(5, len(msgs)); diff != "" {
+		t.Fatalf("messages sent during quiet hours (-want +got):\n%s", diff)
+	}
+	for _, m := range msgs {
+		if !strings.Contains(m.Text, "Silent") || !m.Silent {
+			t.Errorf("expected only silent notifications, got %+v", m)
+		}
+	}
+
+	held, err := store.ListDueMessages(ctx, now.Add(2*time.Hour), 10)
+	if err != nil {
+		t.Fatalf("list due: %v", err)
+	}
+	if diff := cmp.Diff(5, len(held)); diff != "" {
+		t.Errorf("messages held until the end of quiet hours (-want +got):\n%s", diff)
+	}
+	for _, m := range held {
+		if m.FeedID != hold.ID || m.Silent {
+			t.Errorf("unexpected held message %+v", m)
+		}
+	}
+}
+
 func TestSchedulerInactiveFeedSkipped(t *testing.T) {
 	ctx := context.Background()
 	store := newTestStore(t)
@@ -388,9 +603,744 @@ func TestSchedulerInactiveFeedSkipped(t *testing.T) {
 
 	sched := NewWithFetcher(store, f, sender, log)
 	sched.checkAll(ctx)
+	drain(t, store, sender)
 
 	msgs := sender.getMessages()
 	if diff := cmp.Diff(0, len(msgs)); diff != "" {
 		t.Errorf("inactive feed should not produce messages (-want +got):\n%s", diff)
 	}
 }
+
+type conditionalHTTP struct {
+	mu       sync.Mutex
+	body     string
+	requests []*http.Request
+}
+
+func (m *conditionalHTTP) Do(req *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, req)
+	if req.Header.Get("If-None-Match") == `"v1"` {
+		return &http.Response{StatusCode: http.StatusNotModified, Body: io.NopCloser(bytes.NewBufferString(""))}, nil
+	}
+	header := http.Header{}
+	header.Set("ETag", `"v1"`)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     header,
+		Body:       io.NopCloser(bytes.NewBufferString(m.body)),
+	}, nil
+}
+
+func TestSchedulerConditionalGet(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	feed := model.Feed{
+		ChatID: 100, Name: "Cached", URL: "https://example.com/rss",
+		IntervalMinutes: 15, IsActive: true,
+	}
+	if err := store.CreateFeed(ctx, &feed); err != nil {
+		t.Fatalf("create feed: %v", err)
+	}
+
+	sender := &mockSender{}
+	httpClient := &conditionalHTTP{body: loadFixture(t)}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sched := NewWithFetcher(store, fetcher.New(httpClient), sender, log)
+
+	sched.checkAll(ctx)
+	drain(t, store, sender)
+
+	updated, err := store.GetFeed(ctx, feed.ID)
+	if err != nil {
+		t.Fatalf("get feed: %v", err)
+	}
+	if diff := cmp.Diff(`"v1"`, updated.ETag); diff != "" {
+		t.Errorf("stored ETag mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(5, len(sender.getMessages())); diff != "" {
+		t.Errorf("first check message count (-want +got):\n%s", diff)
+	}
+
+	// Make the feed due again; the server now answers 304.
+	updated.LastCheckAt = nil
+	if err := store.UpdateFeed(ctx, updated); err != nil {
+		t.Fatalf("update feed: %v", err)
+	}
+	sched.checkAll(ctx)
+	drain(t, store, sender)
+
+	if diff := cmp.Diff(2, len(httpClient.requests)); diff != "" {
+		t.Fatalf("request count (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(`"v1"`, httpClient.requests[1].Header.Get("If-None-Match")); diff != "" {
+		t.Errorf("If-None-Match mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(5, len(sender.getMessages())); diff != "" {
+		t.Errorf("304 should not send messages (-want +got):\n%s", diff)
+	}
+}
+
+type statusHTTP struct {
+	status int
+}
+
+func (m *statusHTTP) Do(_ *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: m.status,
+		Body:       io.NopCloser(bytes.NewBufferString("")),
+	}, nil
+}
+
+func TestSchedulerFailureBackoffAndPause(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	feed := model.Feed{
+		ChatID: 100, Name: "Flaky", URL: "https://flaky.example.com/rss",
+		IntervalMinutes: 15, IsActive: true,
+	}
+	if err := store.CreateFeed(ctx, &feed); err != nil {
+		t.Fatalf("create feed: %v", err)
+	}
+
+	sender := &mockSender{}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sched := NewWithFetcher(store, fetcher.New(&statusHTTP{status: http.StatusInternalServerError}), sender, log)
+	sched.SetMaxFailures(3)
+	sched.SetConcurrency(1, 1, 0)
+
+	for i := 1; i <= 3; i++ {
+		current, err := store.GetFeed(ctx, feed.ID)
+		if err != nil {
+			t.Fatalf("get feed: %v", err)
+		}
+		sched.processGroup(ctx, []model.Feed{*current})
+		drain(t, store, sender)
+	}
+
+	got, err := store.GetFeed(ctx, feed.ID)
+	if err != nil {
+		t.Fatalf("get feed: %v", err)
+	}
+	if diff := cmp.Diff(3, got.FailureCount); diff != "" {
+		t.Errorf("failure count (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff("unexpected status 500", got.LastError); diff != "" {
+		t.Errorf("last error (-want +got):\n%s", diff)
+	}
+	if got.IsActive {
+		t.Error("expected feed to be paused after max failures")
+	}
+	if got.NextRetryAt == nil || got.NextRetryAt.Before(time.Now().Add(59*time.Minute)) {
+		t.Errorf("expected next retry at least 60 min ahead, got %v", got.NextRetryAt)
+	}
+
+	msgs := sender.getMessages()
+	if diff := cmp.Diff(1, len(msgs)); diff != "" {
+		t.Fatalf("notification count (-want +got):\n%s", diff)
+	}
+	if !strings.Contains(msgs[0].Text, "3 consecutive failed checks") {
+		t.Errorf("unexpected notification: %s", msgs[0].Text)
+	}
+
+	failures, err := store.ListFeedFailures(ctx, feed.ID, 10)
+	if err != nil {
+		t.Fatalf("list failures: %v", err)
+	}
+	if diff := cmp.Diff(3, len(failures)); diff != "" {
+		t.Errorf("failure history length (-want +got):\n%s", diff)
+	}
+}
+
+func TestSchedulerPausesGoneFeed(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	feed := model.Feed{
+		ChatID: 100, Name: "Gone", URL: "https://gone.example.com/rss",
+		IntervalMinutes: 15, IsActive: true,
+	}
+	if err := store.CreateFeed(ctx, &feed); err != nil {
+		t.Fatalf("create feed: %v", err)
+	}
+
+	sender := &mockSender{}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sched := NewWithFetcher(store, fetcher.New(&statusHTTP{status: http.StatusGone}), sender, log)
+	sched.checkAll(ctx)
+	drain(t, store, sender)
+
+	got, err := store.GetFeed(ctx, feed.ID)
+	if err != nil {
+		t.Fatalf("get feed: %v", err)
+	}
+	if got.IsActive {
+		t.Error("expected feed to be paused on HTTP 410")
+	}
+	msgs := sender.getMessages()
+	if diff := cmp.Diff(1, len(msgs)); diff != "" {
+		t.Fatalf("notification count (-want +got):\n%s", diff)
+	}
+	if !strings.Contains(msgs[0].Text, "HTTP 410") {
+		t.Errorf("unexpected notification: %s", msgs[0].Text)
+	}
+}
+
+func TestSchedulerSuccessResetsFailures(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	feed := model.Feed{
+		ChatID: 100, Name: "Recovered", URL: "https://example.com/rss",
+		IntervalMinutes: 15, IsActive: true,
+	}
+	if err := store.CreateFeed(ctx, &feed); err != nil {
+		t.Fatalf("create feed: %v", err)
+	}
+	if _, err := store.RecordFeedFailure(ctx, feed.ID, "timeout", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("record failure: %v", err)
+	}
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sched := NewWithFetcher(store, fetcher.New(&mockHTTP{body: loadFixture(t)}), &mockSender{}, log)
+	sched.checkAll(ctx)
+
+	got, err := store.GetFeed(ctx, feed.ID)
+	if err != nil {
+		t.Fatalf("get feed: %v", err)
+	}
+	if diff := cmp.Diff(0, got.FailureCount); diff != "" {
+		t.Errorf("failure count (-want +got):\n%s", diff)
+	}
+	if got.NextRetryAt != nil {
+		t.Errorf("expected next retry to be cleared, got %v", got.NextRetryAt)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval int
+		failures int
+		want     time.Duration
+	}{
+		{name: "first failure waits one interval", interval: 15, failures: 1, want: 15 * time.Minute},
+		{name: "second failure doubles", interval: 15, failures: 2, want: 30 * time.Minute},
+		{name: "fourth failure", interval: 15, failures: 4, want: 2 * time.Hour},
+		{name: "capped at one day", interval: 60, failures: 20, want: 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, retryDelay(tt.interval, tt.failures)); diff != "" {
+				t.Errorf("retryDelay mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDueAt(t *testing.T) {
+	last := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	retry := last.Add(time.Hour)
+	early := last.Add(time.Minute)
+
+	tests := []struct {
+		name   string
+		feed   model.Feed
+		want   time.Time
+		wantOK bool
+	}{
+		{name: "never checked", feed: model.Feed{IntervalMinutes: 15}},
+		{name: "one interval after the last check", feed: model.Feed{IntervalMinutes: 15, LastCheckAt: &last}, want: last.Add(15 * time.Minute), wantOK: true},
+		{name: "retry after the interval", feed: model.Feed{IntervalMinutes: 15, LastCheckAt: &last, NextRetryAt: &retry}, want: retry, wantOK: true},
+		{name: "retry before the interval", feed: model.Feed{IntervalMinutes: 15, LastCheckAt: &last, NextRetryAt: &early}, want: last.Add(15 * time.Minute), wantOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := dueAt(tt.feed)
+			if diff := cmp.Diff(tt.wantOK, ok); diff != "" {
+				t.Fatalf("dueAt ok mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("dueAt mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSchedulerMetrics(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	feed := model.Feed{ChatID: 100, Name: "DevOps Weekly", URL: "https://devops.example.com/rss", IntervalMinutes: 15, IsActive: true}
+	if err := store.CreateFeed(ctx, &feed); err != nil {
+		t.Fatalf("create feed: %v", err)
+	}
+	if err := store.CreateFilter(ctx, &model.Filter{FeedID: feed.ID, Kind: model.FilterInclude, Scope: model.ScopeAll, Value: "kubernetes"}); err != nil {
+		t.Fatalf("create filter: %v", err)
+	}
+	// Overdue by 55 minutes.
+	checked := time.Now().Add(-70 * time.Minute)
+	feed.LastCheckAt = &checked
+	if err := store.UpdateFeed(ctx, &feed); err != nil {
+		t.Fatalf("update feed: %v", err)
+	}
+
+	m := metrics.New()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sched := NewWithFetcher(store, fetcher.New(&mockHTTP{body: loadFixture(t)}), &mockSender{}, log)
+	sched.SetMetrics(m)
+	sched.checkAll(ctx)
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, line := range []string{
+		`rss_bot_fetch_responses_total{code="200",host="devops.example.com"} 1`,
+		`rss_bot_items_fetched_total 5`,
+		`rss_bot_items_matched_total 3`,
+		`rss_bot_filter_duration_seconds_count 1`,
+		`rss_bot_scheduler_lag_seconds_bucket{le="1800"} 0`,
+		`rss_bot_scheduler_lag_seconds_bucket{le="3600"} 1`,
+	} {
+		if !strings.Contains(rec.Body.String(), line+"\n") {
+			t.Errorf("metrics lack %q:\n%s", line, rec.Body.String())
+		}
+	}
+}
+
+// slowHTTP serves the same body after a delay and records peak concurrency overall and per host.
+type slowHTTP struct {
+	body  string
+	delay time.Duration
+
+	mu          sync.Mutex
+	active      int
+	peak        int
+	activeHost  map[string]int
+	peakPerHost int
+}
+
+func (m *slowHTTP) Do(req *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	if m.activeHost == nil {
+		m.activeHost = make(map[string]int)
+	}
+	m.active++
+	m.activeHost[req.URL.Host]++
+	m.peak = max(m.peak, m.active)
+	m.peakPerHost = max(m.peakPerHost, m.activeHost[req.URL.Host])
+	m.mu.Unlock()
+
+	time.Sleep(m.delay)
+
+	m.mu.Lock()
+	m.active--
+	m.activeHost[req.URL.Host]--
+	m.mu.Unlock()
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewBufferString(m.body)),
+	}, nil
+}
+
+func TestSchedulerWorkerPool(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	urls := []string{
+		"https://a.example.com/rss",
+		"https://b.example.com/rss",
+		"https://c.example.com/rss",
+		"https://shared.example.com/one",
+		"https://shared.example.com/two",
+	}
+	for _, u := range urls {
+		feed := model.Feed{ChatID: 100, Name: u, URL: u, IntervalMinutes: 15, IsActive: true}
+		if err := store.CreateFeed(ctx, &feed); err != nil {
+			t.Fatalf("create feed: %v", err)
+		}
+	}
+
+	httpClient := &slowHTTP{body: "<rss><channel></channel></rss>", delay: 50 * time.Millisecond}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sched := NewWithFetcher(store, fetcher.New(httpClient), &mockSender{}, log)
+	sched.SetConcurrency(4, 1, 0)
+
+	sched.checkAll(ctx)
+
+	if httpClient.peak < 2 {
+		t.Errorf("expected feeds to be fetched concurrently, peak concurrency %d", httpClient.peak)
+	}
+	if httpClient.peak > 4 {
+		t.Errorf("worker limit exceeded, peak concurrency %d", httpClient.peak)
+	}
+	if diff := cmp.Diff(1, httpClient.peakPerHost); diff != "" {
+		t.Errorf("per-host concurrency (-want +got):\n%s", diff)
+	}
+}
+
+// blockingHTTP holds the requests to one host until release is closed.
+type blockingHTTP struct {
+	body    string
+	host    string
+	release chan struct{}
+}
+
+func (m *blockingHTTP) Do(req *http.Request) (*http.Response, error) {
+	if req.URL.Host == m.host {
+		<-m.release
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewBufferString(m.body)),
+	}, nil
+}
+
+func TestSchedulerLastCheckDuringLongRound(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	for _, u := range []string{"https://slow.example.com/rss", "https://fast.example.com/rss"} {
+		feed := model.Feed{ChatID: 100, Name: u, URL: u, IntervalMinutes: 15, IsActive: true}
+		if err := store.CreateFeed(ctx, &feed); err != nil {
+			t.Fatalf("create feed: %v", err)
+		}
+	}
+
+	httpClient := &blockingHTTP{body: "<rss><channel></channel></rss>", host: "slow.example.com", release: make(chan struct{})}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sched := NewWithFetcher(store, fetcher.New(httpClient), &mockSender{}, log)
+	done := make(chan struct{})
+	go func() {
+		sched.checkAll(ctx)
+		close(done)
+	}()
+
+	// The round is still running, but the fast feed counts as progress.
+	deadline := time.Now().Add(time.Second)
+	for sched.LastCheck().IsZero() {
+		if time.Now().After(deadline) {
+			t.Fatal("LastCheck not set while the round makes progress")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	select {
+	case <-done:
+		t.Fatal("round completed while a feed was still being fetched")
+	default:
+	}
+	close(httpClient.release)
+	<-done
+}
+
+func TestSchedulerSkipsLockedFeed(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	feed := model.Feed{
+		ChatID: 100, Name: "Busy", URL: "https://example.com/rss",
+		IntervalMinutes: 15, IsActive: true,
+	}
+	if err := store.CreateFeed(ctx, &feed); err != nil {
+		t.Fatalf("create feed: %v", err)
+	}
+
+	locks := feedlock.New()
+	locks.TryLock(feed.ID)
+
+	sender := &mockSender{}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sched := NewWithFetcher(store, fetcher.New(&mockHTTP{body: loadFixture(t)}), sender, log)
+	sched.SetFeedLocks(locks)
+	sched.checkAll(ctx)
+	drain(t, store, sender)
+
+	if diff := cmp.Diff(0, len(sender.getMessages())); diff != "" {
+		t.Errorf("locked feed must not be processed (-want +got):\n%s", diff)
+	}
+
+	locks.Unlock(feed.ID)
+	sched.checkAll(ctx)
+	drain(t, store, sender)
+	if diff := cmp.Diff(5, len(sender.getMessages())); diff != "" {
+		t.Errorf("unlocked feed should be processed (-want +got):\n%s", diff)
+	}
+}
+
+func TestHostLimiterDelay(t *testing.T) {
+	ctx := context.Background()
+	l := newHostLimiter(2, 30*time.Millisecond)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.acquire(ctx, "example.com"); err != nil {
+			t.Fatalf("acquire: %v", err)
+		}
+		l.release("example.com")
+	}
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("expected at least 60ms between three requests, got %v", elapsed)
+	}
+
+	// Other hosts are not delayed.
+	start = time.Now()
+	if err := l.acquire(ctx, "other.com"); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	l.release("other.com")
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Errorf("unexpected delay for another host: %v", elapsed)
+	}
+}
+
+type countingHTTP struct {
+	mu    sync.Mutex
+	body  string
+	calls map[string]int
+}
+
+func (m *countingHTTP) Do(req *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	if m.calls == nil {
+		m.calls = make(map[string]int)
+	}
+	m.calls[req.URL.String()]++
+	m.mu.Unlock()
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewBufferString(m.body)),
+	}, nil
+}
+
+func TestSchedulerFetchesSharedURLOnce(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	const url = "https://devops.example.com/rss"
+	subscriptions := []struct {
+		chatID int64
+		filter string
+		want   int
+	}{
+		{chatID: 100, filter: "", want: 5},
+		{chatID: 200, filter: "kubernetes", want: 3},
+		{chatID: 300, filter: "docker", want: 1},
+	}
+	for _, sub := range subscriptions {
+		feed := model.Feed{ChatID: sub.chatID, Name: "Shared", URL: url, IntervalMinutes: 15, IsActive: true}
+		if err := store.CreateFeed(ctx, &feed); err != nil {
+			t.Fatalf("create feed: %v", err)
+		}
+		if sub.filter != "" {
+			if err := store.CreateFilter(ctx, &model.Filter{
+				FeedID: feed.ID, Kind: model.FilterInclude, Scope: model.ScopeAll, Value: sub.filter,
+			}); err != nil {
+				t.Fatalf("create filter: %v", err)
+			}
+		}
+	}
+
+	sender := &mockSender{}
+	httpClient := &countingHTTP{body: loadFixture(t)}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sched := NewWithFetcher(store, fetcher.New(httpClient), sender, log)
+	sched.checkAll(ctx)
+	drain(t, store, sender)
+
+	if diff := cmp.Diff(map[string]int{url: 1}, httpClient.calls); diff != "" {
+		t.Errorf("requests per URL (-want +got):\n%s", diff)
+	}
+
+	perChat := make(map[int64]int)
+	for _, m := range sender.getMessages() {
+		perChat[m.ChatID]++
+	}
+	for _, sub := range subscriptions {
+		if diff := cmp.Diff(sub.want, perChat[sub.chatID]); diff != "" {
+			t.Errorf("messages for chat %d (-want +got):\n%s", sub.chatID, diff)
+		}
+	}
+}
+
+func TestSharedValidators(t *testing.T) {
+	tests := []struct {
+		name  string
+		feeds []model.Feed
+		want  fetcher.Validators
+	}{
+		{
+			name:  "single subscription",
+			feeds: []model.Feed{{ETag: `"a"`, LastModified: "lm"}},
+			want:  fetcher.Validators{ETag: `"a"`, LastModified: "lm"},
+		},
+		{
+			name:  "all agree",
+			feeds: []model.Feed{{ETag: `"a"`}, {ETag: `"a"`}},
+			want:  fetcher.Validators{ETag: `"a"`},
+		},
+		{
+			name:  "new subscription forces full fetch",
+			feeds: []model.Feed{{ETag: `"a"`}, {}},
+			want:  fetcher.Validators{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, sharedValidators(tt.feeds)); diff != "" {
+				t.Errorf("sharedValidators mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSchedulerBacklogPolicy(t *testing.T) {
+	ctx := context.Background()
+	xml := loadFixture(t)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	setup := func(t *testing.T, policy model.BacklogPolicy, limit int) (*Scheduler, *storage.SQLite, *mockSender, *model.Feed, *mockHTTP) {
+		t.Helper()
+		store := newTestStore(t)
+		feed := &model.Feed{
+			ChatID: 100, Name: "DevOps Weekly", URL: "https://devops.example.com/rss",
+			IntervalMinutes: 15, IsActive: true, BacklogPolicy: policy, BacklogLimit: limit,
+		}
+		if err := store.CreateFeed(ctx, feed); err != nil {
+			t.Fatalf("create feed: %v", err)
+		}
+		sender := &mockSender{}
+		httpClient := &mockHTTP{body: xml}
+		sched := NewWithFetcher(store, fetcher.New(httpClient), sender, log)
+		return sched, store, sender, feed, httpClient
+	}
+	seenCount := func(t *testing.T, store *storage.SQLite, feedID int64) int {
+		t.Helper()
+		n := 0
+		for _, guid := range []string{"item-1", "item-2", "item-3", "item-4", "item-5"} {
+			if seen, _ := store.IsSeen(ctx, feedID, guid); seen {
+				n++
+			}
+		}
+		return n
+	}
+	backlogState := func(t *testing.T, store *storage.SQLite, feedID int64) model.BacklogState {
+		t.Helper()
+		got, err := store.GetFeed(ctx, feedID)
+		if err != nil {
+			t.Fatalf("get feed: %v", err)
+		}
+		return got.BacklogState
+	}
+
+	t.Run("newest sends limit and marks the rest seen", func(t *testing.T) {
+		sched, store, sender, feed, _ := setup(t, model.BacklogNewest, 2)
+		sched.checkAll(ctx)
+		drain(t, store, sender)
+
+		msgs := sender.getMessages()
+		if diff := cmp.Diff(2, len(msgs)); diff != "" {
+			t.Fatalf("message count (-want +got):\n%s", diff)
+		}
+		if !strings.Contains(msgs[0].Text, "Kubernetes 1.32") {
+			t.Errorf("first message = %q, want the first feed item", msgs[0].Text)
+		}
+		if diff := cmp.Diff(5, seenCount(t, store, feed.ID)); diff != "" {
+			t.Errorf("seen count (-want +got):\n%s", diff)
+		}
+		if diff := cmp.Diff(model.BacklogDone, backlogState(t, store, feed.ID)); diff != "" {
+			t.Errorf("backlog state (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("skip marks everything seen", func(t *testing.T) {
+		sched, store, sender, feed, _ := setup(t, model.BacklogSkip, 5)
+		sched.checkAll(ctx)
+		drain(t, store, sender)
+
+		if diff := cmp.Diff(0, len(sender.getMessages())); diff != "" {
+			t.Errorf("message count (-want +got):\n%s", diff)
+		}
+		if diff := cmp.Diff(5, seenCount(t, store, feed.ID)); diff != "" {
+			t.Errorf("seen count (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("ask prompts once and waits", func(t *testing.T) {
+		sched, store, sender, feed, _ := setup(t, model.BacklogAsk, 3)
+		sched.checkAll(ctx)
+		drain(t, store, sender)
+
+		msgs := sender.getMessages()
+		if diff := cmp.Diff(1, len(msgs)); diff != "" {
+			t.Fatalf("message count (-want +got):\n%s", diff)
+		}
+		if !strings.Contains(msgs[0].Text, "has 5 new items") {
+			t.Errorf("prompt = %q", msgs[0].Text)
+		}
+		if diff := cmp.Diff(model.BacklogAsked, backlogState(t, store, feed.ID)); diff != "" {
+			t.Errorf("backlog state (-want +got):\n%s", diff)
+		}
+
+		// Later checks neither deliver nor ask again until the chat answers.
+		sched.processGroup(ctx, []model.Feed{*feed})
+		drain(t, store, sender)
+		if diff := cmp.Diff(1, len(sender.getMessages())); diff != "" {
+			t.Errorf("message count after second check (-want +got):\n%s", diff)
+		}
+		if diff := cmp.Diff(0, seenCount(t, store, feed.ID)); diff != "" {
+			t.Errorf("seen count (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("expired prompt falls back to the default policy", func(t *testing.T) {
+		sched, store, sender, feed, _ := setup(t, model.BacklogAsk, 3)
+		sched.checkAll(ctx)
+		drain(t, store, sender)
+
+		// Nobody answered before the prompt's buttons expired.
+		got, err := store.GetFeed(ctx, feed.ID)
+		if err != nil {
+			t.Fatalf("get feed: %v", err)
+		}
+		askedAt := got.BacklogAskedAt.Add(-8 * 24 * time.Hour)
+		got.BacklogAskedAt = &askedAt
+		rssFeed, err := sched.fetcher.Fetch(ctx, got.URL)
+		if err != nil {
+			t.Fatalf("fetch: %v", err)
+		}
+		sched.deliver(ctx, got, rssFeed)
+		drain(t, store, sender)
+
+		if diff := cmp.Diff(1+3, len(sender.getMessages())); diff != "" {
+			t.Errorf("message count (-want +got):\n%s", diff)
+		}
+		if diff := cmp.Diff(5, seenCount(t, store, feed.ID)); diff != "" {
+			t.Errorf("seen count (-want +got):\n%s", diff)
+		}
+		if diff := cmp.Diff(model.BacklogDone, backlogState(t, store, feed.ID)); diff != "" {
+			t.Errorf("backlog state (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("policy applies only once", func(t *testing.T) {
+		sched, store, sender, feed, httpClient := setup(t, model.BacklogSkip, 5)
+		sched.checkAll(ctx)
+		drain(t, store, sender)
+
+		// Two items are published after the first check.
+		httpClient.body = strings.NewReplacer(
+			"<guid>item-1</guid>", "<guid>item-6</guid>",
+			"<guid>item-2</guid>", "<guid>item-7</guid>",
+		).Replace(xml)
+		sched.processGroup(ctx, []model.Feed{*feed})
+		drain(t, store, sender)
+		if diff := cmp.Diff(2, len(sender.getMessages())); diff != "" {
+			t.Errorf("message count (-want +got):\n%s", diff)
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"rss_bot/internal/model"
+)
+
+// encodeArchive returns the JSON stored with a message or digest item for the
+// archive, or an empty string for none.
+func encodeArchive(items []model.ArchivedItem) (string, error) {
+	if len(items) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(items)
+	if err != nil {
+		return "", fmt.Errorf("encode archived items: %w", err)
+	}
+	return string(data), nil
+}
+
+func decodeArchive(data string) ([]model.ArchivedItem, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var items []model.ArchivedItem
+	if err := json.Unmarshal([]byte(data), &items); err != nil {
+		return nil, fmt.Errorf("decode archived items: %w", err)
+	}
+	return items, nil
+}
+
+// archiveItem stores an item delivered to a chat in the archive, cutting its
+// content to the content limit. An item that is already archived is ignored.
+func (s *SQLite) archiveItem(ctx context.Context, db execer, chatID int64, a model.ArchivedItem) error {
+	content := a.Content
+	if s.contentLimit > 0 && len(content) > s.contentLimit {
+		content = truncateUTF8(content, s.contentLimit)
+	}
+	var published any
+	if a.Published != nil {
+		published = a.Published.UTC().Format(timeLayout)
+	}
+	if _, err := db.ExecContext(ctx,
+		`INSERT OR IGNORE INTO archive (chat_id, feed_id, guid, feed, title, link, author, published_at, content, delivered_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		chatID, a.FeedID, a.GUID, a.FeedName, a.Title, a.Link, a.Author, published, content,
+		time.Now().UTC().Format(timeLayout),
+	); err != nil {
+		return fmt.Errorf("archive item: %w", err)
+	}
+	return nil
+}
+
+// PruneArchive deletes archived items delivered before the given time and
+// returns how many were deleted.
+func (s *SQLite) PruneArchive(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM archive WHERE delivered_at < ?`,
+		before.UTC().Format(timeLayout),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("prune archive: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("prune archive: %w", err)
+	}
+	return n, nil
+}
+
+// SearchArchive returns a page of the chat's archived items that match an
+// FTS5 expression, newest first, along with the number of all matching items.
+// The content of the items is not loaded.
+func (s *SQLite) SearchArchive(ctx context.Context, chatID int64, match string, limit, offset int) ([]model.ArchivedItem, int, error) {
+	var total int
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM archive_fts JOIN archive a ON a.id = archive_fts.rowid
+		 WHERE archive_fts MATCH ? AND a.chat_id = ?`,
+		match, chatID,
+	).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count archived items: %w", err)
+	}
+	if total == 0 {
+		return nil, 0, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT a.id, a.chat_id, a.feed_id, a.guid, a.feed, a.title, a.link, a.author, a.published_at, a.delivered_at
+		 FROM archive_fts JOIN archive a ON a.id = archive_fts.rowid
+		 WHERE archive_fts MATCH ? AND a.chat_id = ?
+		 ORDER BY a.delivered_at DESC, a.id DESC
+		 LIMIT ? OFFSET ?`,
+		match, chatID, limit, offset,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("search archive: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []model.ArchivedItem
+	for rows.Next() {
+		var a model.ArchivedItem
+		var published sql.NullString
+		var delivered string
+		if err := rows.Scan(&a.ID, &a.ChatID, &a.FeedID, &a.GUID, &a.FeedName, &a.Title, &a.Link, &a.Author,
+			&published, &delivered); err != nil {
+			return nil, 0, fmt.Errorf("scan archived item: %w", err)
+		}
+		if published.Valid {
+			if t, err := time.Parse(timeLayout, published.String); err == nil {
+				a.Published = &t
+			}
+		}
+		a.DeliveredAt, _ = time.Parse(timeLayout, delivered)
+		out = append(out, a)
+	}
+	return out, total, rows.Err()
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"rss_bot/internal/model"
+)
+
+// tokenBytes gives 16-character tokens, well within Telegram's 64-byte limit.
+const tokenBytes = 12
+
+// CreateCallback stores a button payload under a new random token, which is
+// set on cb. Expired callbacks are dropped along the way.
+func (s *SQLite) CreateCallback(ctx context.Context, cb *model.Callback) error {
+	buf := make([]byte, tokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Errorf("generate token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	now := time.Now().UTC().Format(timeLayout)
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM callbacks WHERE expires_at < ?`, now); err != nil {
+		return fmt.Errorf("delete expired callbacks: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT INTO callbacks (token, chat_id, action, feed_id, guid, arg, expires_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		token, cb.ChatID, string(cb.Action), cb.FeedID, cb.GUID, cb.Arg, cb.ExpiresAt.UTC().Format(timeLayout),
+	); err != nil {
+		return fmt.Errorf("insert callback: %w", err)
+	}
+	cb.Token = token
+	return nil
+}
+
+// GetCallback returns the payload of a token that has not expired by now.
+func (s *SQLite) GetCallback(ctx context.Context, token string, now time.Time) (*model.Callback, error) {
+	var cb model.Callback
+	var action, expires string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT token, chat_id, action, feed_id, guid, arg, expires_at
+		 FROM callbacks WHERE token = ? AND expires_at >= ?`,
+		token, now.UTC().Format(timeLayout),
+	).Scan(&cb.Token, &cb.ChatID, &action, &cb.FeedID, &cb.GUID, &cb.Arg, &expires)
+	if err != nil {
+		return nil, fmt.Errorf("get callback: %w", err)
+	}
+	cb.Action = model.CallbackAction(action)
+	cb.ExpiresAt, _ = time.Parse(timeLayout, expires)
+	return &cb, nil
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"rss_bot/internal/model"
+)
+
+// AddDigestItem collects an item for the chat's next digest and marks it seen.
+// An item that is already collected is ignored.
+func (s *SQLite) AddDigestItem(ctx context.Context, item *model.DigestItem, fullContent string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var entries []model.ArchivedItem
+	if item.Archive != nil {
+		entries = append(entries, *item.Archive)
+	}
+	archive, err := encodeArchive(entries)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT OR IGNORE INTO digest_items (chat_id, feed_id, guid, title, link, archive, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		item.ChatID, item.FeedID, item.GUID, item.Title, item.Link, archive, time.Now().UTC().Format(timeLayout),
+	); err != nil {
+		return fmt.Errorf("insert digest item: %w", err)
+	}
+	if err := s.markSeen(ctx, tx, item.FeedID, item.GUID, fullContent); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListPendingDigests returns the chats that have collected digest items.
+func (s *SQLite) ListPendingDigests(ctx context.Context) ([]model.PendingDigest, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT chat_id, MIN(created_at) FROM digest_items GROUP BY chat_id ORDER BY chat_id`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query pending digests: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []model.PendingDigest
+	for rows.Next() {
+		var d model.PendingDigest
+		var since string
+		if err := rows.Scan(&d.ChatID, &since); err != nil {
+			return nil, fmt.Errorf("scan pending digest: %w", err)
+		}
+		d.Since, _ = time.Parse(timeLayout, since)
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// ListDigestItems returns the items collected for a chat, grouped by feed in
+// the order of the chat's feed list.
+func (s *SQLite) ListDigestItems(ctx context.Context, chatID int64) ([]model.DigestItem, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT d.id, d.chat_id, d.feed_id, f.name, d.guid, d.title, d.link, d.archive, d.created_at
+		 FROM digest_items d JOIN feeds f ON f.id = d.feed_id
+		 WHERE d.chat_id = ?
+		 ORDER BY f.position, d.id`, chatID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query digest items: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []model.DigestItem
+	for rows.Next() {
+		var d model.DigestItem
+		var archive, created string
+		if err := rows.Scan(&d.ID, &d.ChatID, &d.FeedID, &d.FeedName, &d.GUID, &d.Title, &d.Link, &archive, &created); err != nil {
+			return nil, fmt.Errorf("scan digest item: %w", err)
+		}
+		entries, err := decodeArchive(archive)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) > 0 {
+			d.Archive = &entries[0]
+		}
+		d.CreatedAt, _ = time.Parse(timeLayout, created)
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// CompleteDigest queues the rendered digest of a chat in the outbox and drops
+// the items it covers, so that a restart neither loses nor repeats them. The
+// messages carry the items to the archive.
+func (s *SQLite) CompleteDigest(ctx context.Context, items []model.DigestItem, msgs []model.OutboxMessage) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for i := range msgs {
+		if err := insertMessage(ctx, tx, &msgs[i]); err != nil {
+			return err
+		}
+	}
+	for _, item := range items {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM digest_items WHERE id = ?`, item.ID); err != nil {
+			return fmt.Errorf("delete digest item: %w", err)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"rss_bot/internal/model"
+)
+
+const outboxColumns = `id, chat_id, feed_id, guid, text, markup, media, full_content, archive,
+	silent, attempts, next_attempt_at, last_error, created_at`
+
+// EnqueueMessage adds a notification to the outbox. A message for an item
+// that is already queued is ignored, so repeated checks don't duplicate it.
+// A message with NextAttemptAt set is held until then.
+func (s *SQLite) EnqueueMessage(ctx context.Context, m *model.OutboxMessage) error {
+	return insertMessage(ctx, s.db, m)
+}
+
+func insertMessage(ctx context.Context, db execer, m *model.OutboxMessage) error {
+	now := time.Now().UTC()
+	next := now
+	if !m.NextAttemptAt.IsZero() {
+		next = m.NextAttemptAt.UTC()
+	}
+	archive, err := encodeArchive(m.Archive)
+	if err != nil {
+		return err
+	}
+	res, err := db.ExecContext(ctx,
+		`INSERT OR IGNORE INTO outbox (chat_id, feed_id, guid, text, markup, media, full_content, archive, silent, next_attempt_at, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		m.ChatID, m.FeedID, m.GUID, m.Text, m.Markup, m.Media, m.FullContent, archive, boolToInt(m.Silent),
+		next.Format(timeLayout), now.Format(timeLayout),
+	)
+	if err != nil {
+		return fmt.Errorf("enqueue message: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		m.ID, _ = res.LastInsertId()
+	}
+	return nil
+}
+
+// ListDueMessages returns pending messages whose next attempt is due, oldest
+// first and at most perChat of them for each chat.
+func (s *SQLite) ListDueMessages(ctx context.Context, now time.Time, perChat int) ([]model.OutboxMessage, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+outboxColumns+` FROM (
+			SELECT *, ROW_NUMBER() OVER (PARTITION BY chat_id ORDER BY id) AS n
+			FROM outbox
+			WHERE datetime(next_attempt_at) <= datetime(?)
+		 )
+		 WHERE n <= ?
+		 ORDER BY id`,
+		now.UTC().Format(timeLayout), perChat,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list due messages: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []model.OutboxMessage
+	for rows.Next() {
+		var m model.OutboxMessage
+		var silent int
+		var archive, next, created string
+		if err := rows.Scan(&m.ID, &m.ChatID, &m.FeedID, &m.GUID, &m.Text, &m.Markup, &m.Media, &m.FullContent, &archive,
+			&silent, &m.Attempts, &next, &m.LastError, &created); err != nil {
+			return nil, fmt.Errorf("scan message: %w", err)
+		}
+		if m.Archive, err = decodeArchive(archive); err != nil {
+			return nil, err
+		}
+		m.Silent = silent == 1
+		m.NextAttemptAt, _ = time.Parse(timeLayout, next)
+		m.CreatedAt, _ = time.Parse(timeLayout, created)
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// CompleteMessage marks the message's item seen, stores the items it carries
+// in the archive and removes the message from the outbox. Messages without a
+// feed, such as digests, have no item to mark.
+func (s *SQLite) CompleteMessage(ctx context.Context, m *model.OutboxMessage) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if m.FeedID != 0 {
+		if err := s.markSeen(ctx, tx, m.FeedID, m.GUID, m.FullContent); err != nil {
+			return err
+		}
+	}
+	for _, a := range m.Archive {
+		if err := s.archiveItem(ctx, tx, m.ChatID, a); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM outbox WHERE id = ?`, m.ID); err != nil {
+		return fmt.Errorf("delete message: %w", err)
+	}
+	return tx.Commit()
+}
+
+// RetryMessage records a failed attempt and schedules the next one.
+func (s *SQLite) RetryMessage(ctx context.Context, id int64, errText string, next time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE outbox SET attempts = attempts + 1, last_error = ?, next_attempt_at = ? WHERE id = ?`,
+		errText, next.UTC().Format(timeLayout), id,
+	)
+	if err != nil {
+		return fmt.Errorf("retry message: %w", err)
+	}
+	return nil
+}
+
+// DropMessageMedia removes the attachments of a message whose album was sent,
+// so that retrying it only sends its text.
+func (s *SQLite) DropMessageMedia(ctx context.Context, id int64) error {
+	if _, err := s.db.ExecContext(ctx, `UPDATE outbox SET media = '' WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("drop message media: %w", err)
+	}
+	return nil
+}
+
+// FailMessage gives up on a message: its item is marked seen, so that it is
+// not queued again, and the message is removed from the outbox.
+func (s *SQLite) FailMessage(ctx context.Context, m *model.OutboxMessage) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if m.FeedID != 0 {
+		if err := s.markSeen(ctx, tx, m.FeedID, m.GUID, m.FullContent); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM outbox WHERE id = ?`, m.ID); err != nil {
+		return fmt.Errorf("fail message: %w", err)
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"time"
+	"unicode/utf8"
+
+	"rss_bot/internal/model"
+)
+
+const (
+	// defaultContentLimit caps the full content stored per item, before compression.
+	defaultContentLimit = 64 << 10
+	// compactBatch is how many rows CompactContent rewrites per transaction.
+	compactBatch = 500
+)
+
+// SetContentLimit caps the full content stored with a seen item at n bytes
+// before compression; longer content is cut. Zero or less stores it whole.
+func (s *SQLite) SetContentLimit(n int) {
+	s.contentLimit = n
+}
+
+// markSeen records a seen item with its compressed full content.
+func (s *SQLite) markSeen(ctx context.Context, db execer, feedID int64, guid, fullContent string) error {
+	packed, err := s.packContent(fullContent)
+	if err != nil {
+		return fmt.Errorf("mark seen: %w", err)
+	}
+	if _, err := db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO seen_items (feed_id, guid, content) VALUES (?, ?, ?)`,
+		feedID, guid, packed,
+	); err != nil {
+		return fmt.Errorf("mark seen: %w", err)
+	}
+	return nil
+}
+
+// packContent cuts content to the limit and compresses it. Empty content is
+// stored as NULL.
+func (s *SQLite) packContent(content string) (any, error) {
+	if content == "" {
+		return nil, nil
+	}
+	if s.contentLimit > 0 && len(content) > s.contentLimit {
+		content = truncateUTF8(content, s.contentLimit)
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := io.WriteString(zw, content); err != nil {
+		return nil, fmt.Errorf("compress content: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("compress content: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func unpackContent(packed []byte) (string, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(packed))
+	if err != nil {
+		return "", fmt.Errorf("decompress content: %w", err)
+	}
+	content, err := io.ReadAll(zr)
+	if err != nil {
+		return "", fmt.Errorf("decompress content: %w", err)
+	}
+	return string(content), nil
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a character.
+func truncateUTF8(s string, n int) string {
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// MarkInFeed records which of a feed's seen items are in its latest download.
+// Retention never prunes those, so that they are not sent again.
+func (s *SQLite) MarkInFeed(ctx context.Context, feedID int64, guids []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE seen_items SET in_feed = 0 WHERE feed_id = ? AND in_feed = 1`, feedID,
+	); err != nil {
+		return fmt.Errorf("clear in-feed items: %w", err)
+	}
+	stmt, err := tx.PrepareContext(ctx, `UPDATE seen_items SET in_feed = 1 WHERE feed_id = ? AND guid = ?`)
+	if err != nil {
+		return fmt.Errorf("prepare in-feed update: %w", err)
+	}
+	defer func() { _ = stmt.Close() }()
+	for _, guid := range guids {
+		if _, err := stmt.ExecContext(ctx, feedID, guid); err != nil {
+			return fmt.Errorf("mark in-feed item: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// PruneSeenItems deletes the items seen before the given time that are not in
+// their feed's latest download, and returns how many were deleted.
+func (s *SQLite) PruneSeenItems(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM seen_items WHERE in_feed = 0 AND seen_at < ?`,
+		before.UTC().Format(timeLayout),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("prune seen items: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("prune seen items: %w", err)
+	}
+	return n, nil
+}
+
+// CompactContent compresses, and cuts to the limit, the content of items
+// stored before compression. It returns how many items were rewritten.
+func (s *SQLite) CompactContent(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		n, err := s.compactBatch(ctx)
+		total += n
+		if err != nil || n < compactBatch {
+			return total, err
+		}
+	}
+}
+
+func (s *SQLite) compactBatch(ctx context.Context) (int, error) {
+	type row struct {
+		id      int64
+		content string
+	}
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT rowid, full_content FROM seen_items WHERE full_content IS NOT NULL LIMIT ?`, compactBatch)
+	if err != nil {
+		return 0, fmt.Errorf("query uncompressed content: %w", err)
+	}
+	var batch []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.content); err != nil {
+			_ = rows.Close()
+			return 0, fmt.Errorf("scan uncompressed content: %w", err)
+		}
+		batch = append(batch, r)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("query uncompressed content: %w", err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	for _, r := range batch {
+		packed, err := s.packContent(r.content)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE seen_items SET content = ?, full_content = NULL WHERE rowid = ?`, packed, r.id,
+		); err != nil {
+			return 0, fmt.Errorf("compact content: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit compaction: %w", err)
+	}
+	return len(batch), nil
+}
+
+// SeenStats summarizes the stored seen items.
+func (s *SQLite) SeenStats(ctx context.Context) (*model.SeenStats, error) {
+	var st model.SeenStats
+	var oldest sql.NullString
+	err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*), COALESCE(SUM(in_feed), 0),
+		        COALESCE(SUM(LENGTH(content)), 0) + COALESCE(SUM(LENGTH(CAST(full_content AS BLOB))), 0),
+		        COUNT(full_content), MIN(seen_at)
+		 FROM seen_items`,
+	).Scan(&st.Items, &st.InFeed, &st.ContentBytes, &st.Uncompressed, &oldest)
+	if err != nil {
+		return nil, fmt.Errorf("seen stats: %w", err)
+	}
+	if oldest.Valid {
+		if t, err := time.Parse(timeLayout, oldest.String); err == nil {
+			st.Oldest = &t
+		}
+	}
+	return &st, nil
+}
+
+// IncrementalVacuum returns up to pages free pages of the database file to
+// the file system. Unlike VACUUM, it doesn't rebuild the file, so it holds the
+// connection only briefly.
+func (s *SQLite) IncrementalVacuum(ctx context.Context, pages int) error {
+	// The pragma frees one page per step, so its rows are read to the end.
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf("PRAGMA incremental_vacuum(%d)", pages))
+	if err != nil {
+		return fmt.Errorf("incremental vacuum: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("incremental vacuum: %w", err)
+	}
+	return nil
+}
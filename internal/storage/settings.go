@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"rss_bot/internal/model"
+)
+
+// GetChatSettings returns the settings of a chat, or the defaults if the chat
+// has not changed any.
+func (s *SQLite) GetChatSettings(ctx context.Context, chatID int64) (*model.ChatSettings, error) {
+	cs := model.ChatSettings{ChatID: chatID}
+	var digest, images, linkPreview int
+	var times, quietMode, scope string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT timezone, digest, digest_times, quiet_start, quiet_end, quiet_mode,
+		        interval_minutes, filter_scope, preview_length, images, link_preview,
+		        language, telegram_language
+		 FROM chat_settings WHERE chat_id = ?`, chatID,
+	).Scan(&cs.Timezone, &digest, &times, &cs.QuietStart, &cs.QuietEnd, &quietMode,
+		&cs.IntervalMinutes, &scope, &cs.PreviewLength, &images, &linkPreview,
+		&cs.Language, &cs.TelegramLanguage)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.DefaultChatSettings(chatID), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get chat settings: %w", err)
+	}
+	cs.Digest = digest == 1
+	if times != "" {
+		cs.DigestTimes = strings.Split(times, ",")
+	}
+	cs.QuietMode = model.QuietMode(quietMode)
+	cs.FilterScope = model.FilterScope(scope)
+	cs.Images = images == 1
+	cs.LinkPreview = linkPreview == 1
+	return &cs, nil
+}
+
+// SaveChatSettings stores the settings of a chat.
+func (s *SQLite) SaveChatSettings(ctx context.Context, cs *model.ChatSettings) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO chat_settings (chat_id, timezone, digest, digest_times, quiet_start, quiet_end, quiet_mode,
+		                            interval_minutes, filter_scope, preview_length, images, link_preview,
+		                            language, telegram_language)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (chat_id) DO UPDATE SET
+		     timezone = excluded.timezone, digest = excluded.digest, digest_times = excluded.digest_times,
+		     quiet_start = excluded.quiet_start, quiet_end = excluded.quiet_end, quiet_mode = excluded.quiet_mode,
+		     interval_minutes = excluded.interval_minutes, filter_scope = excluded.filter_scope,
+		     preview_length = excluded.preview_length, images = excluded.images, link_preview = excluded.link_preview,
+		     language = excluded.language, telegram_language = excluded.telegram_language`,
+		cs.ChatID, cs.Timezone, boolToInt(cs.Digest), strings.Join(cs.DigestTimes, ","),
+		cs.QuietStart, cs.QuietEnd, cs.QuietMode,
+		cs.IntervalMinutes, cs.FilterScope, cs.PreviewLength, boolToInt(cs.Images), boolToInt(cs.LinkPreview),
+		cs.Language, cs.TelegramLanguage,
+	)
+	if err != nil {
+		return fmt.Errorf("save chat settings: %w", err)
+	}
+	return nil
+}
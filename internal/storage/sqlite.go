@@ -14,9 +14,20 @@ import (
 
 const timeLayout = "2006-01-02T15:04:05Z"
 
+const feedColumns = `id, chat_id, position, name, url, interval_minutes, is_active, last_check_at,
+	etag, last_modified, failure_count, last_error, next_retry_at,
+	backlog_policy, backlog_limit, backlog_state, backlog_asked_at, delivery, quiet, created_at`
+
+// maxFailureHistory is the number of recent failures kept per feed.
+const maxFailureHistory = 10
+
+// autoVacuumIncremental is the value of PRAGMA auto_vacuum for INCREMENTAL.
+const autoVacuumIncremental = 2
+
 // SQLite implements Storage backed by a SQLite database.
 type SQLite struct {
-	db *sql.DB
+	db           *sql.DB
+	contentLimit int
 }
 
 // NewSQLite opens a SQLite database at dsn and runs pending migrations.
@@ -26,6 +37,15 @@ func NewSQLite(dsn string) (*SQLite, error) {
 		return nil, fmt.Errorf("open sqlite: %w", err)
 	}
 
+	// SQLite allows a single writer at a time. Feeds are processed concurrently,
+	// so all access goes through one connection to avoid SQLITE_BUSY errors; this
+	// also keeps ":memory:" databases shared between goroutines.
+	db.SetMaxOpenConns(1)
+
+	if err := enableIncrementalVacuum(db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
 	if _, err := db.Exec("PRAGMA journal_mode=WAL"); err != nil {
 		_ = db.Close()
 		return nil, fmt.Errorf("set WAL mode: %w", err)
@@ -40,7 +60,38 @@ func NewSQLite(dsn string) (*SQLite, error) {
 		return nil, fmt.Errorf("run migrations: %w", err)
 	}
 
-	return &SQLite{db: db}, nil
+	return &SQLite{db: db, contentLimit: defaultContentLimit}, nil
+}
+
+// enableIncrementalVacuum lets IncrementalVacuum return freed pages in small
+// steps. The setting only takes effect on a database without tables; an
+// existing database keeps its mode until it is rebuilt with VACUUM, which is
+// left to the operator because it holds the connection and needs free disk
+// space of up to twice the file size.
+func enableIncrementalVacuum(db *sql.DB) error {
+	if _, err := db.Exec("PRAGMA auto_vacuum=INCREMENTAL"); err != nil {
+		return fmt.Errorf("set auto_vacuum: %w", err)
+	}
+	return nil
+}
+
+// IncrementalVacuumEnabled reports whether the database can return freed
+// pages with IncrementalVacuum.
+func (s *SQLite) IncrementalVacuumEnabled(ctx context.Context) (bool, error) {
+	var mode int
+	if err := s.db.QueryRowContext(ctx, "PRAGMA auto_vacuum").Scan(&mode); err != nil {
+		return false, fmt.Errorf("read auto_vacuum: %w", err)
+	}
+	return mode == autoVacuumIncremental, nil
+}
+
+// Ping checks that the database can be read.
+func (s *SQLite) Ping(ctx context.Context) error {
+	var n int
+	if err := s.db.QueryRowContext(ctx, `SELECT count(*) FROM sqlite_master`).Scan(&n); err != nil {
+		return fmt.Errorf("ping database: %w", err)
+	}
+	return nil
 }
 
 // Close closes the underlying database connection.
@@ -61,10 +112,20 @@ func (s *SQLite) CreateFeed(ctx context.Context, feed *model.Feed) error {
 		return fmt.Errorf("get next position: %w", err)
 	}
 
+	if feed.BacklogPolicy == "" {
+		feed.BacklogPolicy = model.DefaultBacklogPolicy
+	}
+	if feed.BacklogLimit <= 0 {
+		feed.BacklogLimit = model.DefaultBacklogLimit
+	}
+	feed.BacklogState = model.BacklogPending
+
 	res, err := s.db.ExecContext(ctx,
-		`INSERT INTO feeds (chat_id, position, name, url, interval_minutes, is_active, created_at)
-		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		feed.ChatID, position, feed.Name, feed.URL, feed.IntervalMinutes, boolToInt(feed.IsActive), now,
+		`INSERT INTO feeds (chat_id, position, name, url, interval_minutes, is_active,
+		                    backlog_policy, backlog_limit, backlog_state, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		feed.ChatID, position, feed.Name, feed.URL, feed.IntervalMinutes, boolToInt(feed.IsActive),
+		feed.BacklogPolicy, feed.BacklogLimit, feed.BacklogState, now,
 	)
 	if err != nil {
 		return fmt.Errorf("insert feed: %w", err)
@@ -82,7 +143,7 @@ func (s *SQLite) CreateFeed(ctx context.Context, feed *model.Feed) error {
 // GetFeed returns a single feed by its ID.
 func (s *SQLite) GetFeed(ctx context.Context, id int64) (*model.Feed, error) {
 	row := s.db.QueryRowContext(ctx,
-		`SELECT id, chat_id, position, name, url, interval_minutes, is_active, last_check_at, created_at
+		`SELECT `+feedColumns+`
 		 FROM feeds WHERE id = ?`, id,
 	)
 	return scanFeed(row)
@@ -91,7 +152,7 @@ func (s *SQLite) GetFeed(ctx context.Context, id int64) (*model.Feed, error) {
 // GetFeedByPosition returns a feed by its local position for a chat.
 func (s *SQLite) GetFeedByPosition(ctx context.Context, chatID int64, position int) (*model.Feed, error) {
 	row := s.db.QueryRowContext(ctx,
-		`SELECT id, chat_id, position, name, url, interval_minutes, is_active, last_check_at, created_at
+		`SELECT `+feedColumns+`
 		 FROM feeds WHERE chat_id = ? AND position = ?`, chatID, position,
 	)
 	return scanFeed(row)
@@ -100,7 +161,7 @@ func (s *SQLite) GetFeedByPosition(ctx context.Context, chatID int64, position i
 // ListFeeds returns all feeds belonging to the given chat.
 func (s *SQLite) ListFeeds(ctx context.Context, chatID int64) ([]model.Feed, error) {
 	rows, err := s.db.QueryContext(ctx,
-		`SELECT id, chat_id, position, name, url, interval_minutes, is_active, last_check_at, created_at
+		`SELECT `+feedColumns+`
 		 FROM feeds WHERE chat_id = ? ORDER BY position`, chatID,
 	)
 	if err != nil {
@@ -114,12 +175,13 @@ func (s *SQLite) ListFeeds(ctx context.Context, chatID int64) ([]model.Feed, err
 func (s *SQLite) ListDueFeeds(ctx context.Context) ([]model.Feed, error) {
 	now := time.Now().UTC().Format(timeLayout)
 	rows, err := s.db.QueryContext(ctx,
-		`SELECT id, chat_id, position, name, url, interval_minutes, is_active, last_check_at, created_at
+		`SELECT `+feedColumns+`
 		 FROM feeds
 		 WHERE is_active = 1
 		   AND (last_check_at IS NULL
-		        OR datetime(last_check_at, '+' || interval_minutes || ' minutes') <= datetime(?))`,
-		now,
+		        OR datetime(last_check_at, '+' || interval_minutes || ' minutes') <= datetime(?))
+		   AND (next_retry_at IS NULL OR datetime(next_retry_at) <= datetime(?))`,
+		now, now,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("query due feeds: %w", err)
@@ -136,9 +198,11 @@ func (s *SQLite) UpdateFeed(ctx context.Context, feed *model.Feed) error {
 		lastCheck = &v
 	}
 	_, err := s.db.ExecContext(ctx,
-		`UPDATE feeds SET name = ?, url = ?, interval_minutes = ?, is_active = ?, last_check_at = ?
+		`UPDATE feeds SET name = ?, url = ?, interval_minutes = ?, is_active = ?, last_check_at = ?,
+		                  backlog_policy = ?, backlog_limit = ?, delivery = ?, quiet = ?
 		 WHERE id = ?`,
-		feed.Name, feed.URL, feed.IntervalMinutes, boolToInt(feed.IsActive), lastCheck, feed.ID,
+		feed.Name, feed.URL, feed.IntervalMinutes, boolToInt(feed.IsActive), lastCheck,
+		feed.BacklogPolicy, feed.BacklogLimit, feed.Delivery, feed.Quiet, feed.ID,
 	)
 	if err != nil {
 		return fmt.Errorf("update feed: %w", err)
@@ -166,6 +230,18 @@ func (s *SQLite) DeleteFeed(ctx context.Context, id int64) error {
 	if _, err := tx.ExecContext(ctx, `DELETE FROM filters WHERE feed_id = ?`, id); err != nil {
 		return fmt.Errorf("delete filters: %w", err)
 	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM feed_failures WHERE feed_id = ?`, id); err != nil {
+		return fmt.Errorf("delete feed_failures: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM outbox WHERE feed_id = ?`, id); err != nil {
+		return fmt.Errorf("delete outbox: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM callbacks WHERE feed_id = ?`, id); err != nil {
+		return fmt.Errorf("delete callbacks: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM digest_items WHERE feed_id = ?`, id); err != nil {
+		return fmt.Errorf("delete digest_items: %w", err)
+	}
 	if _, err := tx.ExecContext(ctx, `DELETE FROM feeds WHERE id = ?`, id); err != nil {
 		return fmt.Errorf("delete feed: %w", err)
 	}
@@ -180,6 +256,105 @@ func (s *SQLite) DeleteFeed(ctx context.Context, id int64) error {
 	return tx.Commit()
 }
 
+// UpdateFeedValidators stores the HTTP cache validators returned by the feed server.
+func (s *SQLite) UpdateFeedValidators(ctx context.Context, id int64, etag, lastModified string) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE feeds SET etag = ?, last_modified = ? WHERE id = ?`,
+		etag, lastModified, id,
+	)
+	if err != nil {
+		return fmt.Errorf("update feed validators: %w", err)
+	}
+	return nil
+}
+
+// SetBacklogState records whether the feed's backlog policy still has to be
+// applied. Entering BacklogAsked also records when the chat was asked.
+func (s *SQLite) SetBacklogState(ctx context.Context, id int64, state model.BacklogState) error {
+	var askedAt any
+	if state == model.BacklogAsked {
+		askedAt = time.Now().UTC().Format(timeLayout)
+	}
+	_, err := s.db.ExecContext(ctx, `UPDATE feeds SET backlog_state = ?, backlog_asked_at = ? WHERE id = ?`, state, askedAt, id)
+	if err != nil {
+		return fmt.Errorf("set backlog state: %w", err)
+	}
+	return nil
+}
+
+// RecordFeedFailure stores a failed check, schedules the next retry and returns
+// the number of consecutive failures including this one.
+func (s *SQLite) RecordFeedFailure(ctx context.Context, id int64, errText string, nextRetry time.Time) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now().UTC().Format(timeLayout)
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE feeds SET failure_count = failure_count + 1, last_error = ?, next_retry_at = ? WHERE id = ?`,
+		errText, nextRetry.UTC().Format(timeLayout), id,
+	); err != nil {
+		return 0, fmt.Errorf("update feed failure: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO feed_failures (feed_id, error, failed_at) VALUES (?, ?, ?)`,
+		id, errText, now,
+	); err != nil {
+		return 0, fmt.Errorf("insert feed failure: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM feed_failures WHERE feed_id = ? AND id NOT IN (
+		     SELECT id FROM feed_failures WHERE feed_id = ? ORDER BY id DESC LIMIT ?)`,
+		id, id, maxFailureHistory,
+	); err != nil {
+		return 0, fmt.Errorf("trim feed failures: %w", err)
+	}
+
+	var count int
+	if err := tx.QueryRowContext(ctx, `SELECT failure_count FROM feeds WHERE id = ?`, id).Scan(&count); err != nil {
+		return 0, fmt.Errorf("get failure count: %w", err)
+	}
+	return count, tx.Commit()
+}
+
+// ResetFeedFailures clears the consecutive failure counter and the retry delay.
+// The failure history is kept for display.
+func (s *SQLite) ResetFeedFailures(ctx context.Context, id int64) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE feeds SET failure_count = 0, last_error = '', next_retry_at = NULL WHERE id = ?`, id,
+	)
+	if err != nil {
+		return fmt.Errorf("reset feed failures: %w", err)
+	}
+	return nil
+}
+
+// ListFeedFailures returns the most recent failures of a feed, newest first.
+func (s *SQLite) ListFeedFailures(ctx context.Context, feedID int64, limit int) ([]model.FeedFailure, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT feed_id, error, failed_at FROM feed_failures WHERE feed_id = ? ORDER BY id DESC LIMIT ?`,
+		feedID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query feed failures: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var failures []model.FeedFailure
+	for rows.Next() {
+		var f model.FeedFailure
+		var failedAt string
+		if err := rows.Scan(&f.FeedID, &f.Error, &failedAt); err != nil {
+			return nil, fmt.Errorf("scan feed failure: %w", err)
+		}
+		f.FailedAt, _ = time.Parse(timeLayout, failedAt)
+		failures = append(failures, f)
+	}
+	return failures, rows.Err()
+}
+
 // CreateFilter inserts a new filter and populates its ID, Position and CreatedAt.
 func (s *SQLite) CreateFilter(ctx context.Context, f *model.Filter) error {
 	now := time.Now().UTC().Format(timeLayout)
@@ -285,14 +460,7 @@ func (s *SQLite) DeleteFilter(ctx context.Context, id int64) error {
 
 // MarkSeen records that an RSS item has been processed.
 func (s *SQLite) MarkSeen(ctx context.Context, feedID int64, guid string, fullContent string) error {
-	_, err := s.db.ExecContext(ctx,
-		`INSERT OR REPLACE INTO seen_items (feed_id, guid, full_content) VALUES (?, ?, ?)`,
-		feedID, guid, fullContent,
-	)
-	if err != nil {
-		return fmt.Errorf("mark seen: %w", err)
-	}
-	return nil
+	return s.markSeen(ctx, s.db, feedID, guid, fullContent)
 }
 
 // IsSeen checks whether an RSS item has already been processed.
@@ -310,18 +478,23 @@ func (s *SQLite) IsSeen(ctx context.Context, feedID int64, guid string) (bool, e
 
 // GetFullContent retrieves the full content for an RSS item.
 func (s *SQLite) GetFullContent(ctx context.Context, feedID int64, guid string) (string, error) {
-	var content sql.NullString
+	var legacy sql.NullString
+	var packed []byte
 	err := s.db.QueryRowContext(ctx,
-		`SELECT full_content FROM seen_items WHERE feed_id = ? AND guid = ?`,
+		`SELECT full_content, content FROM seen_items WHERE feed_id = ? AND guid = ?`,
 		feedID, guid,
-	).Scan(&content)
+	).Scan(&legacy, &packed)
 	if err != nil {
 		return "", fmt.Errorf("get full content: %w", err)
 	}
-	if !content.Valid {
-		return "", nil
+	if packed != nil {
+		content, err := unpackContent(packed)
+		if err != nil {
+			return "", fmt.Errorf("get full content: %w", err)
+		}
+		return content, nil
 	}
-	return content.String, nil
+	return legacy.String, nil
 }
 
 func boolToInt(b bool) int {
@@ -335,19 +508,39 @@ type scannable interface {
 	Scan(dest ...any) error
 }
 
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
 func scanFeed(row scannable) (*model.Feed, error) {
 	var f model.Feed
 	var isActive int
-	var lastCheck, created sql.NullString
-	err := row.Scan(&f.ID, &f.ChatID, &f.Position, &f.Name, &f.URL, &f.IntervalMinutes, &isActive, &lastCheck, &created)
+	var lastCheck, nextRetry, askedAt, created sql.NullString
+	var policy, state, delivery, quiet string
+	err := row.Scan(&f.ID, &f.ChatID, &f.Position, &f.Name, &f.URL, &f.IntervalMinutes, &isActive, &lastCheck,
+		&f.ETag, &f.LastModified, &f.FailureCount, &f.LastError, &nextRetry,
+		&policy, &f.BacklogLimit, &state, &askedAt, &delivery, &quiet, &created)
 	if err != nil {
 		return nil, fmt.Errorf("scan feed: %w", err)
 	}
 	f.IsActive = isActive == 1
+	f.BacklogPolicy = model.BacklogPolicy(policy)
+	f.BacklogState = model.BacklogState(state)
+	f.Delivery = model.DeliveryMode(delivery)
+	f.Quiet = model.QuietMode(quiet)
 	if lastCheck.Valid {
 		t, _ := time.Parse(timeLayout, lastCheck.String)
 		f.LastCheckAt = &t
 	}
+	if nextRetry.Valid {
+		t, _ := time.Parse(timeLayout, nextRetry.String)
+		f.NextRetryAt = &t
+	}
+	if askedAt.Valid {
+		t, _ := time.Parse(timeLayout, askedAt.String)
+		f.BacklogAskedAt = &t
+	}
 	if created.Valid {
 		f.CreatedAt, _ = time.Parse(timeLayout, created.String)
 	}
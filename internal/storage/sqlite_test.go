@@ -2,6 +2,11 @@ package storage
 
 import (
 	"context"
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -24,6 +29,21 @@ func newTestDB(t *testing.T) *SQLite {
 	return s
 }
 
+func TestPing(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewSQLite(":memory:")
+	if err != nil {
+		t.Fatalf("new sqlite: %v", err)
+	}
+	if err := s.Ping(ctx); err != nil {
+		t.Errorf("ping open database: %v", err)
+	}
+	_ = s.Close()
+	if err := s.Ping(ctx); err == nil {
+		t.Error("ping closed database: want error")
+	}
+}
+
 func TestFeedCRUD(t *testing.T) {
 	ctx := context.Background()
 	s := newTestDB(t)
@@ -71,6 +91,9 @@ func TestFeedCRUD(t *testing.T) {
 
 			want := tt.feed
 			want.ID = feed.ID
+			want.BacklogPolicy = model.DefaultBacklogPolicy
+			want.BacklogLimit = model.DefaultBacklogLimit
+			want.BacklogState = model.BacklogPending
 			if diff := cmp.Diff(want, *got, ignoreTimestamps); diff != "" {
 				t.Errorf("GetFeed mismatch (-want +got):\n%s", diff)
 			}
@@ -106,6 +129,11 @@ func TestListFeeds(t *testing.T) {
 		{ID: feeds[0].ID, ChatID: chatID, Name: "Feed A", URL: "https://a.com/rss", IntervalMinutes: 10, IsActive: true},
 		{ID: feeds[1].ID, ChatID: chatID, Name: "Feed B", URL: "https://b.com/rss", IntervalMinutes: 30, IsActive: false},
 	}
+	for i := range want {
+		want[i].BacklogPolicy = model.DefaultBacklogPolicy
+		want[i].BacklogLimit = model.DefaultBacklogLimit
+		want[i].BacklogState = model.BacklogPending
+	}
 	if diff := cmp.Diff(want, got, ignoreTimestamps); diff != "" {
 		t.Errorf("ListFeeds mismatch (-want +got):\n%s", diff)
 	}
@@ -125,6 +153,9 @@ func TestUpdateFeed(t *testing.T) {
 	feed.IntervalMinutes = 60
 	feed.IsActive = false
 	feed.LastCheckAt = &now
+	feed.BacklogPolicy = model.BacklogAsk
+	feed.BacklogLimit = 10
+	feed.Quiet = model.QuietSilent
 
 	if err := s.UpdateFeed(ctx, &feed); err != nil {
 		t.Fatalf("update: %v", err)
@@ -138,6 +169,8 @@ func TestUpdateFeed(t *testing.T) {
 	want := model.Feed{
 		ID: feed.ID, ChatID: 1, Name: "New", URL: "https://old.com",
 		IntervalMinutes: 60, IsActive: false,
+		BacklogPolicy: model.BacklogAsk, BacklogLimit: 10, BacklogState: model.BacklogPending,
+		Quiet: model.QuietSilent,
 	}
 	if diff := cmp.Diff(want, *got, ignoreTimestamps); diff != "" {
 		t.Errorf("UpdateFeed mismatch (-want +got):\n%s", diff)
@@ -147,6 +180,63 @@ func TestUpdateFeed(t *testing.T) {
 	}
 }
 
+func TestSetBacklogState(t *testing.T) {
+	ctx := context.Background()
+	s := newTestDB(t)
+
+	feed := model.Feed{ChatID: 1, Name: "F", URL: "https://f.com", IntervalMinutes: 15, IsActive: true}
+	if err := s.CreateFeed(ctx, &feed); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	for _, state := range []model.BacklogState{model.BacklogAsked, model.BacklogDone} {
+		if err := s.SetBacklogState(ctx, feed.ID, state); err != nil {
+			t.Fatalf("set backlog state: %v", err)
+		}
+		got, err := s.GetFeed(ctx, feed.ID)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		if diff := cmp.Diff(state, got.BacklogState); diff != "" {
+			t.Errorf("backlog state (-want +got):\n%s", diff)
+		}
+		if asked := got.BacklogAskedAt != nil; asked != (state == model.BacklogAsked) {
+			t.Errorf("state %q: backlog asked at = %v", state, got.BacklogAskedAt)
+		}
+	}
+}
+
+func TestUpdateFeedValidators(t *testing.T) {
+	ctx := context.Background()
+	s := newTestDB(t)
+
+	feed := model.Feed{ChatID: 1, Name: "F", URL: "https://f.com", IntervalMinutes: 15, IsActive: true}
+	if err := s.CreateFeed(ctx, &feed); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	if err := s.UpdateFeedValidators(ctx, feed.ID, `"etag-1"`, "Mon, 02 Jan 2006 15:04:05 GMT"); err != nil {
+		t.Fatalf("update validators: %v", err)
+	}
+
+	// A regular update must not clobber the stored validators.
+	feed.Name = "Renamed"
+	if err := s.UpdateFeed(ctx, &feed); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	got, err := s.GetFeed(ctx, feed.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if diff := cmp.Diff(`"etag-1"`, got.ETag); diff != "" {
+		t.Errorf("ETag mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff("Mon, 02 Jan 2006 15:04:05 GMT", got.LastModified); diff != "" {
+		t.Errorf("LastModified mismatch (-want +got):\n%s", diff)
+	}
+}
+
 func TestDeleteFeedCascade(t *testing.T) {
 	ctx := context.Background()
 	s := newTestDB(t)
@@ -376,5 +466,713 @@ func TestListDueFeeds(t *testing.T) {
 	}
 }
 
+func TestFeedFailures(t *testing.T) {
+	ctx := context.Background()
+	s := newTestDB(t)
+
+	feed := model.Feed{ChatID: 1, Name: "F", URL: "https://f.com", IntervalMinutes: 15, IsActive: true}
+	if err := s.CreateFeed(ctx, &feed); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	future := time.Now().UTC().Add(time.Hour)
+	for i := 1; i <= maxFailureHistory+2; i++ {
+		count, err := s.RecordFeedFailure(ctx, feed.ID, fmt.Sprintf("error %d", i), future)
+		if err != nil {
+			t.Fatalf("record failure: %v", err)
+		}
+		if diff := cmp.Diff(i, count); diff != "" {
+			t.Errorf("failure count (-want +got):\n%s", diff)
+		}
+	}
+
+	got, err := s.GetFeed(ctx, feed.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if diff := cmp.Diff(fmt.Sprintf("error %d", maxFailureHistory+2), got.LastError); diff != "" {
+		t.Errorf("last error (-want +got):\n%s", diff)
+	}
+	if got.NextRetryAt == nil {
+		t.Fatal("expected NextRetryAt to be set")
+	}
+
+	due, err := s.ListDueFeeds(ctx)
+	if err != nil {
+		t.Fatalf("list due: %v", err)
+	}
+	if diff := cmp.Diff(0, len(due)); diff != "" {
+		t.Errorf("feed in backoff must not be due (-want +got):\n%s", diff)
+	}
+
+	history, err := s.ListFeedFailures(ctx, feed.ID, 100)
+	if err != nil {
+		t.Fatalf("list failures: %v", err)
+	}
+	if diff := cmp.Diff(maxFailureHistory, len(history)); diff != "" {
+		t.Errorf("history length (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(fmt.Sprintf("error %d", maxFailureHistory+2), history[0].Error); diff != "" {
+		t.Errorf("newest failure first (-want +got):\n%s", diff)
+	}
+
+	if err := s.ResetFeedFailures(ctx, feed.ID); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	got, _ = s.GetFeed(ctx, feed.ID)
+	if diff := cmp.Diff(0, got.FailureCount); diff != "" {
+		t.Errorf("failure count after reset (-want +got):\n%s", diff)
+	}
+	due, _ = s.ListDueFeeds(ctx)
+	if diff := cmp.Diff(1, len(due)); diff != "" {
+		t.Errorf("feed should be due after reset (-want +got):\n%s", diff)
+	}
+}
+
 // Ensure the Storage interface is satisfied.
 var _ Storage = (*SQLite)(nil)
+
+func TestOutbox(t *testing.T) {
+	ctx := context.Background()
+	s := newTestDB(t)
+
+	feed := &model.Feed{ChatID: 100, Name: "Feed", URL: "https://example.com/rss", IntervalMinutes: 15, IsActive: true}
+	if err := s.CreateFeed(ctx, feed); err != nil {
+		t.Fatalf("create feed: %v", err)
+	}
+
+	enqueue := func(guid string) *model.OutboxMessage {
+		t.Helper()
+		m := &model.OutboxMessage{ChatID: 100, FeedID: feed.ID, GUID: guid, Text: "text " + guid, Markup: `{"inline_keyboard":[]}`, Media: `[{"kind":"photo","url":"https://example.com/a.jpg"}]`, FullContent: "full"}
+		if err := s.EnqueueMessage(ctx, m); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+		return m
+	}
+	dueGUIDs := func(now time.Time) []string {
+		t.Helper()
+		msgs, err := s.ListDueMessages(ctx, now, 10)
+		if err != nil {
+			t.Fatalf("list due: %v", err)
+		}
+		var out []string
+		for _, m := range msgs {
+			out = append(out, m.GUID)
+		}
+		return out
+	}
+
+	a := enqueue("a")
+	b := enqueue("b")
+	enqueue("a") // already queued
+	if diff := cmp.Diff([]string{"a", "b"}, dueGUIDs(time.Now())); diff != "" {
+		t.Fatalf("due messages (-want +got):\n%s", diff)
+	}
+
+	msgs, _ := s.ListDueMessages(ctx, time.Now(), 1)
+	want := model.OutboxMessage{ID: a.ID, ChatID: 100, FeedID: feed.ID, GUID: "a", Text: "text a", Markup: `{"inline_keyboard":[]}`, Media: `[{"kind":"photo","url":"https://example.com/a.jpg"}]`, FullContent: "full"}
+	if diff := cmp.Diff([]model.OutboxMessage{want}, msgs, cmpopts.IgnoreFields(model.OutboxMessage{}, "NextAttemptAt", "CreatedAt")); diff != "" {
+		t.Errorf("message mismatch (-want +got):\n%s", diff)
+	}
+
+	t.Run("complete marks seen and removes", func(t *testing.T) {
+		if err := s.CompleteMessage(ctx, a); err != nil {
+			t.Fatalf("complete: %v", err)
+		}
+		if seen, _ := s.IsSeen(ctx, feed.ID, "a"); !seen {
+			t.Error("completed item should be seen")
+		}
+		if diff := cmp.Diff([]string{"b"}, dueGUIDs(time.Now())); diff != "" {
+			t.Errorf("due messages (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("retry postpones", func(t *testing.T) {
+		next := time.Now().Add(time.Minute)
+		if err := s.RetryMessage(ctx, b.ID, "timeout", next); err != nil {
+			t.Fatalf("retry: %v", err)
+		}
+		if got := dueGUIDs(time.Now()); len(got) != 0 {
+			t.Errorf("due before retry time = %v, want none", got)
+		}
+		msgs, _ := s.ListDueMessages(ctx, next.Add(time.Second), 10)
+		if len(msgs) != 1 {
+			t.Fatalf("due after retry time = %d, want 1", len(msgs))
+		}
+		if diff := cmp.Diff(1, msgs[0].Attempts); diff != "" {
+			t.Errorf("attempts (-want +got):\n%s", diff)
+		}
+		if diff := cmp.Diff("timeout", msgs[0].LastError); diff != "" {
+			t.Errorf("last error (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("drop media", func(t *testing.T) {
+		if err := s.DropMessageMedia(ctx, b.ID); err != nil {
+			t.Fatalf("drop media: %v", err)
+		}
+		msgs, _ := s.ListDueMessages(ctx, time.Now().Add(time.Hour), 10)
+		if len(msgs) != 1 {
+			t.Fatalf("due messages = %d, want 1", len(msgs))
+		}
+		if diff := cmp.Diff("", msgs[0].Media); diff != "" {
+			t.Errorf("media (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("failed message is removed and its item seen", func(t *testing.T) {
+		if err := s.FailMessage(ctx, b); err != nil {
+			t.Fatalf("fail: %v", err)
+		}
+		if got := dueGUIDs(time.Now().Add(time.Hour)); len(got) != 0 {
+			t.Errorf("due messages = %v, want none", got)
+		}
+		if seen, _ := s.IsSeen(ctx, feed.ID, "b"); !seen {
+			t.Error("failed item should be seen so that it is not queued again")
+		}
+	})
+
+	t.Run("held message waits", func(t *testing.T) {
+		until := time.Now().Add(time.Hour)
+		m := &model.OutboxMessage{ChatID: 100, FeedID: feed.ID, GUID: "held", Silent: true, NextAttemptAt: until}
+		if err := s.EnqueueMessage(ctx, m); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+		if got := dueGUIDs(time.Now()); len(got) != 0 {
+			t.Errorf("due messages = %v, want none", got)
+		}
+		msgs, _ := s.ListDueMessages(ctx, until.Add(time.Second), 10)
+		if len(msgs) != 1 || !msgs[0].Silent {
+			t.Errorf("due after hold = %+v, want one silent message", msgs)
+		}
+		_ = s.CompleteMessage(ctx, m)
+	})
+
+	t.Run("deleting the feed clears its messages", func(t *testing.T) {
+		enqueue("c")
+		if err := s.DeleteFeed(ctx, feed.ID); err != nil {
+			t.Fatalf("delete feed: %v", err)
+		}
+		if got := dueGUIDs(time.Now()); len(got) != 0 {
+			t.Errorf("due messages = %v, want none", got)
+		}
+	})
+}
+
+func TestListDueMessagesPerChat(t *testing.T) {
+	ctx := context.Background()
+	s := newTestDB(t)
+
+	for _, chatID := range []int64{100, 200} {
+		feed := &model.Feed{ChatID: chatID, Name: "Feed", URL: "https://example.com/rss", IntervalMinutes: 15, IsActive: true}
+		if err := s.CreateFeed(ctx, feed); err != nil {
+			t.Fatalf("create feed: %v", err)
+		}
+		for i := 1; i <= 3; i++ {
+			m := &model.OutboxMessage{ChatID: chatID, FeedID: feed.ID, GUID: fmt.Sprintf("%d-%d", chatID, i)}
+			if err := s.EnqueueMessage(ctx, m); err != nil {
+				t.Fatalf("enqueue: %v", err)
+			}
+		}
+	}
+
+	msgs, err := s.ListDueMessages(ctx, time.Now(), 2)
+	if err != nil {
+		t.Fatalf("list due: %v", err)
+	}
+	var got []string
+	for _, m := range msgs {
+		got = append(got, m.GUID)
+	}
+	if diff := cmp.Diff([]string{"100-1", "100-2", "200-1", "200-2"}, got); diff != "" {
+		t.Errorf("due messages (-want +got):\n%s", diff)
+	}
+}
+
+func TestCallbacks(t *testing.T) {
+	ctx := context.Background()
+	s := newTestDB(t)
+
+	feed := &model.Feed{ChatID: 100, Name: "Feed", URL: "https://example.com/rss", IntervalMinutes: 15, IsActive: true}
+	if err := s.CreateFeed(ctx, feed); err != nil {
+		t.Fatalf("create feed: %v", err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	cb := &model.Callback{
+		ChatID:    100,
+		Action:    model.CallbackShowMore,
+		FeedID:    feed.ID,
+		GUID:      "https://example.com/a/very/long/link/that/would/never/fit/into/sixty/four/bytes",
+		ExpiresAt: now.Add(time.Hour),
+	}
+	if err := s.CreateCallback(ctx, cb); err != nil {
+		t.Fatalf("create callback: %v", err)
+	}
+	if len(cb.Token) == 0 || len(cb.Token) > 64 {
+		t.Fatalf("token %q does not fit into callback data", cb.Token)
+	}
+
+	got, err := s.GetCallback(ctx, cb.Token, now)
+	if err != nil {
+		t.Fatalf("get callback: %v", err)
+	}
+	if diff := cmp.Diff(cb, got); diff != "" {
+		t.Errorf("callback mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := s.GetCallback(ctx, cb.Token, now.Add(2*time.Hour)); err == nil {
+		t.Error("expected error for expired callback")
+	}
+	if _, err := s.GetCallback(ctx, "unknown", now); err == nil {
+		t.Error("expected error for unknown token")
+	}
+
+	if err := s.DeleteFeed(ctx, feed.ID); err != nil {
+		t.Fatalf("delete feed: %v", err)
+	}
+	if _, err := s.GetCallback(ctx, cb.Token, now); err == nil {
+		t.Error("callbacks of a deleted feed should be gone")
+	}
+}
+
+func TestChatSettings(t *testing.T) {
+	ctx := context.Background()
+	s := newTestDB(t)
+
+	got, err := s.GetChatSettings(ctx, 100)
+	if err != nil {
+		t.Fatalf("get defaults: %v", err)
+	}
+	want := &model.ChatSettings{
+		ChatID: 100, Timezone: "UTC", DigestTimes: []string{"09:00"}, QuietMode: model.QuietHold,
+		IntervalMinutes: 15, FilterScope: model.ScopeAll, PreviewLength: 1500, Images: true,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("defaults mismatch (-want +got):\n%s", diff)
+	}
+
+	want = &model.ChatSettings{
+		ChatID: 100, Timezone: "Europe/Berlin", Digest: true, DigestTimes: []string{"09:00", "18:00"},
+		QuietStart: "23:00", QuietEnd: "08:00", QuietMode: model.QuietSilent,
+		IntervalMinutes: 60, FilterScope: model.ScopeTitle, PreviewLength: 500, LinkPreview: true,
+		Language: "ru", TelegramLanguage: "en-US",
+	}
+	if err := s.SaveChatSettings(ctx, want); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	want.Timezone = "Asia/Tokyo"
+	if err := s.SaveChatSettings(ctx, want); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	got, err = s.GetChatSettings(ctx, 100)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("settings mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDigestItems(t *testing.T) {
+	ctx := context.Background()
+	s := newTestDB(t)
+
+	var feeds []*model.Feed
+	for _, name := range []string{"First", "Second"} {
+		f := &model.Feed{ChatID: 100, Name: name, URL: "https://example.com/" + name, IntervalMinutes: 15, IsActive: true}
+		if err := s.CreateFeed(ctx, f); err != nil {
+			t.Fatalf("create feed: %v", err)
+		}
+		feeds = append(feeds, f)
+	}
+
+	add := func(f *model.Feed, guid string) {
+		t.Helper()
+		item := &model.DigestItem{ChatID: f.ChatID, FeedID: f.ID, GUID: guid, Title: "Title " + guid, Link: "https://example.com/" + guid}
+		if err := s.AddDigestItem(ctx, item, "full "+guid); err != nil {
+			t.Fatalf("add digest item: %v", err)
+		}
+	}
+	add(feeds[1], "b1")
+	add(feeds[0], "a1")
+	add(feeds[1], "b2")
+	add(feeds[1], "b2") // collected twice by a repeated check
+
+	if seen, _ := s.IsSeen(ctx, feeds[0].ID, "a1"); !seen {
+		t.Error("collected item should be seen")
+	}
+	if content, _ := s.GetFullContent(ctx, feeds[0].ID, "a1"); content != "full a1" {
+		t.Errorf("full content = %q", content)
+	}
+
+	pending, err := s.ListPendingDigests(ctx)
+	if err != nil {
+		t.Fatalf("list pending: %v", err)
+	}
+	if len(pending) != 1 || pending[0].ChatID != 100 || pending[0].Since.IsZero() {
+		t.Fatalf("pending = %+v, want chat 100", pending)
+	}
+
+	items, err := s.ListDigestItems(ctx, 100)
+	if err != nil {
+		t.Fatalf("list items: %v", err)
+	}
+	var got []string
+	for _, it := range items {
+		got = append(got, it.FeedName+"/"+it.GUID)
+	}
+	if diff := cmp.Diff([]string{"First/a1", "Second/b1", "Second/b2"}, got); diff != "" {
+		t.Errorf("items by feed (-want +got):\n%s", diff)
+	}
+
+	msgs := []model.OutboxMessage{{ChatID: 100, GUID: "digest:1:1", Text: "digest"}}
+	if err := s.CompleteDigest(ctx, items, msgs); err != nil {
+		t.Fatalf("complete digest: %v", err)
+	}
+	if pending, _ := s.ListPendingDigests(ctx); len(pending) != 0 {
+		t.Errorf("pending after digest = %+v, want none", pending)
+	}
+	due, err := s.ListDueMessages(ctx, time.Now(), 10)
+	if err != nil {
+		t.Fatalf("list due: %v", err)
+	}
+	if len(due) != 1 || due[0].Text != "digest" {
+		t.Fatalf("due = %+v, want the digest", due)
+	}
+	if err := s.CompleteMessage(ctx, &due[0]); err != nil {
+		t.Fatalf("complete message: %v", err)
+	}
+	if seen, _ := s.IsSeen(ctx, 0, "digest:1:1"); seen {
+		t.Error("a digest has no item to mark seen")
+	}
+}
+
+func TestFullContentCompression(t *testing.T) {
+	ctx := context.Background()
+	s := newTestDB(t)
+	s.SetContentLimit(10)
+
+	feed := model.Feed{ChatID: 100, Name: "Feed", URL: "https://example.com/rss", IntervalMinutes: 15, IsActive: true}
+	if err := s.CreateFeed(ctx, &feed); err != nil {
+		t.Fatalf("create feed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"short", "<p>hi</p>", "<p>hi</p>"},
+		{"cut to the limit", "<p>hello world</p>", "<p>hello w"},
+		{"cut between characters", "привет мир", "приве"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.MarkSeen(ctx, feed.ID, tt.name, tt.content); err != nil {
+				t.Fatalf("mark seen: %v", err)
+			}
+			got, err := s.GetFullContent(ctx, feed.ID, tt.name)
+			if err != nil {
+				t.Fatalf("get full content: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("content mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("compacts content stored before compression", func(t *testing.T) {
+		if _, err := s.db.ExecContext(ctx,
+			`INSERT INTO seen_items (feed_id, guid, full_content) VALUES (?, 'legacy', '<p>old item</p>')`, feed.ID,
+		); err != nil {
+			t.Fatalf("insert legacy item: %v", err)
+		}
+		if got, _ := s.GetFullContent(ctx, feed.ID, "legacy"); got != "<p>old item</p>" {
+			t.Errorf("legacy content = %q before compaction", got)
+		}
+
+		n, err := s.CompactContent(ctx)
+		if err != nil {
+			t.Fatalf("compact: %v", err)
+		}
+		if diff := cmp.Diff(1, n); diff != "" {
+			t.Errorf("compacted (-want +got):\n%s", diff)
+		}
+		if got, _ := s.GetFullContent(ctx, feed.ID, "legacy"); got != "<p>old ite" {
+			t.Errorf("legacy content = %q after compaction, want it cut to the limit", got)
+		}
+		st, err := s.SeenStats(ctx)
+		if err != nil {
+			t.Fatalf("seen stats: %v", err)
+		}
+		if st.Items != 5 || st.Uncompressed != 0 || st.ContentBytes == 0 {
+			t.Errorf("stats = %+v, want 5 compressed items", st)
+		}
+	})
+}
+
+func TestPruneSeenItems(t *testing.T) {
+	ctx := context.Background()
+	s := newTestDB(t)
+
+	feed := model.Feed{ChatID: 100, Name: "Feed", URL: "https://example.com/rss", IntervalMinutes: 15, IsActive: true}
+	if err := s.CreateFeed(ctx, &feed); err != nil {
+		t.Fatalf("create feed: %v", err)
+	}
+	for _, guid := range []string{"gone", "still in feed", "recent"} {
+		if err := s.MarkSeen(ctx, feed.ID, guid, "content"); err != nil {
+			t.Fatalf("mark seen: %v", err)
+		}
+	}
+	old := time.Now().AddDate(0, 0, -100).UTC().Format(timeLayout)
+	if _, err := s.db.ExecContext(ctx,
+		`UPDATE seen_items SET seen_at = ? WHERE guid IN ('gone', 'still in feed')`, old,
+	); err != nil {
+		t.Fatalf("age items: %v", err)
+	}
+	if err := s.MarkInFeed(ctx, feed.ID, []string{"still in feed", "recent", "unseen"}); err != nil {
+		t.Fatalf("mark in feed: %v", err)
+	}
+
+	st, err := s.SeenStats(ctx)
+	if err != nil {
+		t.Fatalf("seen stats: %v", err)
+	}
+	if st.Items != 3 || st.InFeed != 2 || st.Oldest == nil || time.Since(*st.Oldest) < 99*24*time.Hour {
+		t.Errorf("stats = %+v, want 3 items, 2 in feed, oldest 100 days ago", st)
+	}
+
+	n, err := s.PruneSeenItems(ctx, time.Now().AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if diff := cmp.Diff(int64(1), n); diff != "" {
+		t.Errorf("pruned (-want +got):\n%s", diff)
+	}
+	for guid, want := range map[string]bool{"gone": false, "still in feed": true, "recent": true} {
+		if seen, _ := s.IsSeen(ctx, feed.ID, guid); seen != want {
+			t.Errorf("IsSeen(%q) = %v, want %v", guid, seen, want)
+		}
+	}
+}
+
+func TestIncrementalVacuumEnabled(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "old.db")
+
+	// A database created by an older version, without incremental vacuum.
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := db.Exec(`CREATE TABLE legacy (id INTEGER)`); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	_ = db.Close()
+
+	old, err := NewSQLite(path)
+	if err != nil {
+		t.Fatalf("new sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = old.Close() })
+	enabled, err := old.IncrementalVacuumEnabled(ctx)
+	if err != nil {
+		t.Fatalf("incremental vacuum enabled: %v", err)
+	}
+	if enabled {
+		t.Error("existing database was rebuilt with incremental vacuum at startup")
+	}
+
+	enabled, err = newTestDB(t).IncrementalVacuumEnabled(ctx)
+	if err != nil {
+		t.Fatalf("incremental vacuum enabled: %v", err)
+	}
+	if !enabled {
+		t.Error("new database was created without incremental vacuum")
+	}
+}
+
+func TestIncrementalVacuum(t *testing.T) {
+	ctx := context.Background()
+	s := newTestDB(t)
+
+	feed := &model.Feed{ChatID: 100, Name: "Feed", URL: "https://example.com/rss", IntervalMinutes: 15, IsActive: true}
+	if err := s.CreateFeed(ctx, feed); err != nil {
+		t.Fatalf("create feed: %v", err)
+	}
+	for i := range 200 {
+		// Random text doesn't compress, so the items take up many pages.
+		var content strings.Builder
+		for range 40 {
+			content.WriteString(rand.Text())
+		}
+		if err := s.MarkSeen(ctx, feed.ID, fmt.Sprint(i), content.String()); err != nil {
+			t.Fatalf("mark seen: %v", err)
+		}
+	}
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM seen_items`); err != nil {
+		t.Fatalf("delete seen items: %v", err)
+	}
+
+	freelist := func() int {
+		t.Helper()
+		var n int
+		if err := s.db.QueryRowContext(ctx, `PRAGMA freelist_count`).Scan(&n); err != nil {
+			t.Fatalf("freelist_count: %v", err)
+		}
+		return n
+	}
+	before := freelist()
+	if before < 10 {
+		t.Fatalf("freelist_count = %d after pruning, want at least 10", before)
+	}
+	if err := s.IncrementalVacuum(ctx, 5); err != nil {
+		t.Fatalf("incremental vacuum: %v", err)
+	}
+	if diff := cmp.Diff(before-5, freelist()); diff != "" {
+		t.Errorf("freelist_count (-want +got):\n%s", diff)
+	}
+}
+
+func TestArchive(t *testing.T) {
+	ctx := context.Background()
+	s := newTestDB(t)
+
+	feed := &model.Feed{ChatID: 100, Name: "Feed", URL: "https://example.com/rss", IntervalMinutes: 15, IsActive: true}
+	if err := s.CreateFeed(ctx, feed); err != nil {
+		t.Fatalf("create feed: %v", err)
+	}
+	published := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	deliver := func(chatID int64, guid, title, content string) {
+		t.Helper()
+		m := &model.OutboxMessage{ChatID: chatID, FeedID: feed.ID, GUID: guid, Text: title, Archive: []model.ArchivedItem{{
+			FeedID: feed.ID, GUID: guid, FeedName: "Feed", Title: title, Link: "https://example.com/" + guid, Author: "Jane Doe", Published: &published, Content: content,
+		}}}
+		if err := s.EnqueueMessage(ctx, m); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+		msgs, err := s.ListDueMessages(ctx, time.Now(), 10)
+		if err != nil || len(msgs) != 1 {
+			t.Fatalf("list due = %d, %v, want one message", len(msgs), err)
+		}
+		if err := s.CompleteMessage(ctx, &msgs[0]); err != nil {
+			t.Fatalf("complete: %v", err)
+		}
+	}
+	deliver(100, "a", "Kubernetes 1.30 released", "Security fixes for the scheduler.")
+	deliver(100, "b", "Docker Desktop update", "Faster Kubernetes startup.")
+	deliver(100, "c", "Kubernetes webinar", "Join us online.")
+	deliver(200, "d", "Kubernetes elsewhere", "Another chat.")
+
+	item := &model.DigestItem{ChatID: 100, FeedID: feed.ID, GUID: "e", Title: "Rust 1.80", Archive: &model.ArchivedItem{FeedID: feed.ID, GUID: "e", FeedName: "Feed", Title: "Rust 1.80"}}
+	if err := s.AddDigestItem(ctx, item, ""); err != nil {
+		t.Fatalf("add digest item: %v", err)
+	}
+	search := func(match string, limit, offset int) ([]string, int) {
+		t.Helper()
+		found, total, err := s.SearchArchive(ctx, 100, match, limit, offset)
+		if err != nil {
+			t.Fatalf("search %q: %v", match, err)
+		}
+		var guids []string
+		for _, a := range found {
+			guids = append(guids, a.GUID)
+		}
+		return guids, total
+	}
+
+	// Digest items are archived once the digest is delivered.
+	items, _ := s.ListDigestItems(ctx, 100)
+	digest := model.OutboxMessage{ChatID: 100, GUID: "digest:1:1", Text: "Digest", Archive: []model.ArchivedItem{*items[0].Archive}}
+	if err := s.CompleteDigest(ctx, items, []model.OutboxMessage{digest}); err != nil {
+		t.Fatalf("complete digest: %v", err)
+	}
+	if got, _ := search(`"rust"*`, 10, 0); got != nil {
+		t.Errorf("queued digest item archived: %v", got)
+	}
+	msgs, _ := s.ListDueMessages(ctx, time.Now(), 10)
+	if len(msgs) != 1 {
+		t.Fatalf("due messages = %d, want the digest", len(msgs))
+	}
+	if err := s.CompleteMessage(ctx, &msgs[0]); err != nil {
+		t.Fatalf("complete digest message: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		match string
+		want  []string
+	}{
+		{name: "any column, newest first", match: `"kube"*`, want: []string{"c", "b", "a"}},
+		{name: "title only", match: `title : "kubernetes"*`, want: []string{"c", "a"}},
+		{name: "negation", match: `"kubernetes"* NOT ("webinar"*)`, want: []string{"b", "a"}},
+		{name: "author", match: `author : "jane doe"*`, want: []string{"c", "b", "a"}},
+		{name: "feed name", match: `feed : "feed"*`, want: []string{"e", "c", "b", "a"}},
+		{name: "digest item", match: `"rust"*`, want: []string{"e"}},
+		{name: "nothing", match: `"golang"*`, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, total := search(tt.match, 10, 0)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("found (-want +got):\n%s", diff)
+			}
+			if total != len(tt.want) {
+				t.Errorf("total = %d, want %d", total, len(tt.want))
+			}
+		})
+	}
+
+	t.Run("page", func(t *testing.T) {
+		got, total := search(`"kubernetes"*`, 1, 1)
+		if diff := cmp.Diff([]string{"b"}, got); diff != "" || total != 3 {
+			t.Errorf("page = %v of %d, want [b] of 3 (-want +got):\n%s", got, total, diff)
+		}
+	})
+
+	t.Run("fields", func(t *testing.T) {
+		found, _, err := s.SearchArchive(ctx, 100, `"docker"*`, 10, 0)
+		if err != nil || len(found) != 1 {
+			t.Fatalf("search = %v, %v, want one item", found, err)
+		}
+		want := model.ArchivedItem{
+			ChatID: 100, FeedID: feed.ID, GUID: "b", FeedName: "Feed", Title: "Docker Desktop update",
+			Link: "https://example.com/b", Author: "Jane Doe", Published: &published,
+		}
+		if diff := cmp.Diff(want, found[0], cmpopts.IgnoreFields(model.ArchivedItem{}, "ID", "DeliveredAt")); diff != "" {
+			t.Errorf("item (-want +got):\n%s", diff)
+		}
+		if found[0].DeliveredAt.IsZero() {
+			t.Error("delivery time not set")
+		}
+	})
+
+	t.Run("kept after the feed is deleted", func(t *testing.T) {
+		if err := s.DeleteFeed(ctx, feed.ID); err != nil {
+			t.Fatalf("delete feed: %v", err)
+		}
+		if _, total := search(`"kubernetes"*`, 10, 0); total != 3 {
+			t.Errorf("total = %d, want 3", total)
+		}
+	})
+
+	t.Run("prune", func(t *testing.T) {
+		n, err := s.PruneArchive(ctx, time.Now().Add(-time.Hour))
+		if err != nil || n != 0 {
+			t.Errorf("prune recent = %d, %v; want 0", n, err)
+		}
+		n, err = s.PruneArchive(ctx, time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatalf("prune: %v", err)
+		}
+		if diff := cmp.Diff(int64(5), n); diff != "" {
+			t.Errorf("pruned (-want +got):\n%s", diff)
+		}
+		if _, total := search(`"kube"*`, 10, 0); total != 0 {
+			t.Errorf("total after prune = %d, want 0", total)
+		}
+	})
+}
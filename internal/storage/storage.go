@@ -3,6 +3,7 @@ package storage
 
 import (
 	"context"
+	"time"
 
 	"rss_bot/internal/model"
 )
@@ -16,6 +17,12 @@ type Storage interface {
 	ListDueFeeds(ctx context.Context) ([]model.Feed, error)
 	UpdateFeed(ctx context.Context, feed *model.Feed) error
 	DeleteFeed(ctx context.Context, id int64) error
+	UpdateFeedValidators(ctx context.Context, id int64, etag, lastModified string) error
+	SetBacklogState(ctx context.Context, id int64, state model.BacklogState) error
+
+	RecordFeedFailure(ctx context.Context, id int64, errText string, nextRetry time.Time) (int, error)
+	ResetFeedFailures(ctx context.Context, id int64) error
+	ListFeedFailures(ctx context.Context, feedID int64, limit int) ([]model.FeedFailure, error)
 
 	CreateFilter(ctx context.Context, f *model.Filter) error
 	ListFilters(ctx context.Context, feedID int64) ([]model.Filter, error)
@@ -23,9 +30,37 @@ type Storage interface {
 	GetFilterByPosition(ctx context.Context, feedID int64, position int) (*model.Filter, error)
 	DeleteFilter(ctx context.Context, id int64) error
 
+	EnqueueMessage(ctx context.Context, m *model.OutboxMessage) error
+	ListDueMessages(ctx context.Context, now time.Time, perChat int) ([]model.OutboxMessage, error)
+	CompleteMessage(ctx context.Context, m *model.OutboxMessage) error
+	RetryMessage(ctx context.Context, id int64, errText string, next time.Time) error
+	FailMessage(ctx context.Context, m *model.OutboxMessage) error
+	DropMessageMedia(ctx context.Context, id int64) error
+
+	GetChatSettings(ctx context.Context, chatID int64) (*model.ChatSettings, error)
+	SaveChatSettings(ctx context.Context, cs *model.ChatSettings) error
+
+	AddDigestItem(ctx context.Context, item *model.DigestItem, fullContent string) error
+	ListPendingDigests(ctx context.Context) ([]model.PendingDigest, error)
+	ListDigestItems(ctx context.Context, chatID int64) ([]model.DigestItem, error)
+	CompleteDigest(ctx context.Context, items []model.DigestItem, msgs []model.OutboxMessage) error
+
+	CreateCallback(ctx context.Context, cb *model.Callback) error
+	GetCallback(ctx context.Context, token string, now time.Time) (*model.Callback, error)
+
 	MarkSeen(ctx context.Context, feedID int64, guid string, fullContent string) error
 	IsSeen(ctx context.Context, feedID int64, guid string) (bool, error)
 	GetFullContent(ctx context.Context, feedID int64, guid string) (string, error)
+	MarkInFeed(ctx context.Context, feedID int64, guids []string) error
+	PruneSeenItems(ctx context.Context, before time.Time) (int64, error)
+	CompactContent(ctx context.Context) (int, error)
+	SeenStats(ctx context.Context) (*model.SeenStats, error)
+	IncrementalVacuum(ctx context.Context, pages int) error
+	PruneArchive(ctx context.Context, before time.Time) (int64, error)
+
+	SearchArchive(ctx context.Context, chatID int64, match string, limit, offset int) ([]model.ArchivedItem, int, error)
+
+	Ping(ctx context.Context) error
 
 	Close() error
 }
@@ -0,0 +1,215 @@
+package text
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Telegram's length limits, counted in UTF-16 code units of the text after
+// HTML entities are parsed.
+const (
+	MaxMessageLength = 4096
+	MaxCaptionLength = 1024
+)
+
+const (
+	ellipsis = "…"
+	// partLabelReserve is the room kept for a "(12/34) " part label.
+	partLabelReserve = 12
+)
+
+// Cut boundaries, from least to most preferred.
+const (
+	cutWord = iota + 1
+	cutSentence
+	cutLine
+	cutParagraph
+)
+
+// token is a tag or a single visible character of Telegram HTML.
+type token struct {
+	raw     string
+	tag     string // tag name; empty for text
+	closing bool
+	width   int  // UTF-16 length; zero for tags
+	char    rune // the visible character; zero for tags
+}
+
+func tokenize(s string) []token {
+	var out []token
+	for i := 0; i < len(s); {
+		switch {
+		case s[i] == '<':
+			end := strings.IndexByte(s[i:], '>')
+			if end < 0 {
+				end = len(s) - i - 1
+			}
+			raw := s[i : i+end+1]
+			name := strings.Trim(raw, "<>/")
+			if sp := strings.IndexByte(name, ' '); sp >= 0 {
+				name = name[:sp]
+			}
+			out = append(out, token{raw: raw, tag: name, closing: strings.HasPrefix(raw, "</")})
+			i += end + 1
+			continue
+		case s[i] == '&':
+			if end := strings.IndexByte(s[i:], ';'); end > 0 && end < 10 {
+				raw := s[i : i+end+1]
+				r, _ := utf8.DecodeRuneInString(html.UnescapeString(raw))
+				out = append(out, token{raw: raw, width: utf16.RuneLen(r), char: r})
+				i += end + 1
+				continue
+			}
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		width := utf16.RuneLen(r)
+		if width < 0 {
+			width = 1
+		}
+		out = append(out, token{raw: s[i : i+size], width: width, char: r})
+		i += size
+	}
+	return out
+}
+
+// VisibleLength returns the length Telegram counts for Telegram HTML.
+func VisibleLength(s string) int {
+	n := 0
+	for _, t := range tokenize(s) {
+		n += t.width
+	}
+	return n
+}
+
+// cut returns the end of the longest run of tokens from start that fits into
+// limit. It prefers to cut on a paragraph, line, sentence or word boundary in
+// the second half of the run, then on any boundary, and only then in the
+// middle of a word.
+func cut(tokens []token, start, limit int) int {
+	width := 0
+	best, bestRank := -1, 0
+	last := -1
+	for i := start; i < len(tokens); i++ {
+		t := tokens[i]
+		if width+t.width > limit {
+			switch {
+			case best >= 0:
+				return best
+			case last >= 0:
+				return last
+			default:
+				return max(i, start+1)
+			}
+		}
+		width += t.width
+
+		rank := 0
+		switch {
+		case t.char == '\n' && i > start && tokens[i-1].char == '\n':
+			rank = cutParagraph
+		case t.char == '\n':
+			rank = cutLine
+		case t.char == ' ' && i > start && strings.ContainsRune(".!?…", tokens[i-1].char):
+			rank = cutSentence
+		case t.char == ' ':
+			rank = cutWord
+		}
+		if rank == 0 {
+			continue
+		}
+		last = i + 1
+		if width > limit/2 && rank >= bestRank {
+			best, bestRank = i+1, rank
+		}
+	}
+	return len(tokens)
+}
+
+// render writes tokens[start:end] as well-formed HTML: tags open before start
+// are reopened, tags still open at end are closed and whitespace at the edges
+// is dropped. suffix is written before the closing tags.
+func render(tokens []token, start, end int, suffix string) string {
+	var open []token
+	for _, t := range tokens[:start] {
+		open = track(open, t)
+	}
+
+	for start < end && isSpace(tokens[start]) {
+		start++
+	}
+	for end > start && isSpace(tokens[end-1]) {
+		end--
+	}
+
+	var b strings.Builder
+	for _, t := range open {
+		b.WriteString(t.raw)
+	}
+	for _, t := range tokens[start:end] {
+		b.WriteString(t.raw)
+		open = track(open, t)
+	}
+	b.WriteString(suffix)
+	for i := len(open) - 1; i >= 0; i-- {
+		fmt.Fprintf(&b, "</%s>", open[i].tag)
+	}
+	return b.String()
+}
+
+func track(open []token, t token) []token {
+	switch {
+	case t.tag == "":
+	case t.closing:
+		if len(open) > 0 {
+			open = open[:len(open)-1]
+		}
+	default:
+		open = append(open, t)
+	}
+	return open
+}
+
+func isSpace(t token) bool {
+	return t.tag == "" && (t.char == ' ' || t.char == '\n')
+}
+
+// TruncateHTML shortens Telegram HTML to at most limit visible characters,
+// cutting on a sentence or word boundary where possible, adding an ellipsis
+// and closing any tags left open. It reports whether anything was cut.
+func TruncateHTML(s string, limit int) (string, bool) {
+	tokens := tokenize(s)
+	if cut(tokens, 0, limit) == len(tokens) {
+		return s, false
+	}
+	if limit <= 0 {
+		return "", true
+	}
+	end := cut(tokens, 0, limit-VisibleLength(ellipsis))
+	return render(tokens, 0, end, ellipsis), true
+}
+
+// SplitHTML splits Telegram HTML into parts of at most limit visible
+// characters, cutting on paragraph, line, sentence or word boundaries. When
+// there is more than one part, each is labelled "(n/total)".
+func SplitHTML(s string, limit int) []string {
+	tokens := tokenize(s)
+	if cut(tokens, 0, limit) == len(tokens) {
+		return []string{s}
+	}
+
+	var parts []string
+	for start := 0; start < len(tokens); {
+		end := cut(tokens, start, limit-partLabelReserve)
+		if part := render(tokens, start, end, ""); VisibleLength(part) > 0 {
+			parts = append(parts, part)
+		}
+		start = end
+	}
+	for i, part := range parts {
+		parts[i] = fmt.Sprintf("(%d/%d) %s", i+1, len(parts), part)
+	}
+	return parts
+}
@@ -0,0 +1,131 @@
+package text
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestVisibleLength(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  int
+	}{
+		{name: "tags are not counted", input: `<a href="https://example.com"><b>link</b></a>`, want: 4},
+		{name: "entities count once", input: "a &amp; b &lt;", want: 7},
+		{name: "cyrillic", input: "привет", want: 6},
+		{name: "emoji take two units", input: "ok 👍", want: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, VisibleLength(tt.input)); diff != "" {
+				t.Errorf("VisibleLength mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTruncateHTML(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		limit     int
+		want      string
+		truncated bool
+	}{
+		{
+			name:  "short text is unchanged",
+			input: "<b>bold</b> text",
+			limit: 20,
+			want:  "<b>bold</b> text",
+		},
+		{
+			name:      "open tags are closed",
+			input:     `<a href="https://example.com"><b>boldlink</b></a> tail`,
+			limit:     5,
+			want:      `<a href="https://example.com"><b>bold…</b></a>`,
+			truncated: true,
+		},
+		{
+			name:      "cuts on a word boundary",
+			input:     "one two three four",
+			limit:     12,
+			want:      "one two…",
+			truncated: true,
+		},
+		{
+			name:      "prefers a sentence boundary",
+			input:     "First one. Second sentence here",
+			limit:     20,
+			want:      "First one.…",
+			truncated: true,
+		},
+		{
+			name:      "entities are not split",
+			input:     "&amp;&amp;&amp;&amp;",
+			limit:     3,
+			want:      "&amp;&amp;…",
+			truncated: true,
+		},
+		{
+			name:      "multibyte characters are not split",
+			input:     "приветмир",
+			limit:     7,
+			want:      "привет…",
+			truncated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, truncated := TruncateHTML(tt.input, tt.limit)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("TruncateHTML mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.truncated, truncated); diff != "" {
+				t.Errorf("truncated mismatch (-want +got):\n%s", diff)
+			}
+			if n := VisibleLength(got); n > tt.limit {
+				t.Errorf("visible length %d exceeds limit %d", n, tt.limit)
+			}
+		})
+	}
+}
+
+func TestSplitHTML(t *testing.T) {
+	t.Run("short text is one part", func(t *testing.T) {
+		if diff := cmp.Diff([]string{"short"}, SplitHTML("short", 100)); diff != "" {
+			t.Errorf("SplitHTML mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("splits on paragraphs and numbers parts", func(t *testing.T) {
+		input := "First paragraph here.\n\nSecond paragraph here.\n\nThird one."
+		want := []string{
+			"(1/3) First paragraph here.",
+			"(2/3) Second paragraph here.",
+			"(3/3) Third one.",
+		}
+		if diff := cmp.Diff(want, SplitHTML(input, 40)); diff != "" {
+			t.Errorf("SplitHTML mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("tags are reopened in the next part", func(t *testing.T) {
+		input := "<blockquote>" + strings.Repeat("слово ", 10) + "</blockquote>"
+		parts := SplitHTML(input, 40)
+		if len(parts) < 2 {
+			t.Fatalf("parts = %d, want at least 2", len(parts))
+		}
+		for _, part := range parts {
+			if n := VisibleLength(part); n > 40 {
+				t.Errorf("part %q is %d long, over the limit", part, n)
+			}
+			if !strings.Contains(part, "<blockquote>слово") || !strings.HasSuffix(part, "</blockquote>") {
+				t.Errorf("part %q is not well-formed", part)
+			}
+		}
+	})
+}
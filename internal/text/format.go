@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"rss_bot/internal/fetcher"
+	"rss_bot/internal/model"
 )
 
 // FormattedContent holds formatted text and optional image URL.
@@ -13,8 +14,6 @@ type FormattedContent struct {
 	ImageURL string
 }
 
-const maxPreviewLength = 1500
-
 // FormatItemContent formats an RSS item content for display.
 func FormatItemContent(item fetcher.MatchedItem) FormattedContent {
 	var text strings.Builder
@@ -32,18 +31,13 @@ func FormatItemContent(item fetcher.MatchedItem) FormattedContent {
 	}
 }
 
+// getItemText returns the item body rendered for Telegram's HTML parse mode.
 func getItemText(item fetcher.MatchedItem) string {
 	if item.Content != "" {
-		if IsHTML(item.Content) {
-			return ParseHTMLToPlain(item.Content).Text
-		}
-		return item.Content
+		return RenderTelegramHTML(item.Content).Text
 	}
 	if item.Description != "" {
-		if IsHTML(item.Description) {
-			return ParseHTMLToPlain(item.Description).Text
-		}
-		return item.Description
+		return RenderTelegramHTML(item.Description).Text
 	}
 	return ""
 }
@@ -61,11 +55,12 @@ func getItemImageURL(item fetcher.MatchedItem) string {
 	return ""
 }
 
-// FormatNotification formats an RSS item as a notification message.
+// FormatNotification formats an RSS item as a notification message in
+// Telegram's HTML parse mode.
 func FormatNotification(feedName string, item fetcher.MatchedItem) string {
 	var b strings.Builder
-	fmt.Fprintf(&b, "[%s]\n\n", feedName)
-	b.WriteString(item.Title)
+	fmt.Fprintf(&b, "[%s]\n\n", EscapeHTML(feedName))
+	b.WriteString(EscapeHTML(item.Title))
 	desc := getItemText(item)
 	if desc != "" {
 		b.WriteString("\n\n")
@@ -73,49 +68,77 @@ func FormatNotification(feedName string, item fetcher.MatchedItem) string {
 	}
 	if item.Link != "" {
 		b.WriteString("\n\n")
-		b.WriteString(item.Link)
+		b.WriteString(EscapeHTML(item.Link))
 	}
 	return b.String()
 }
 
+// Options adjust a notification to the preferences of a chat. The zero value
+// gives the defaults.
+type Options struct {
+	// PreviewLength bounds the item body of a short notification, in
+	// characters; zero means model.DefaultPreviewLength.
+	PreviewLength int
+	// NoImages leaves photos out of the attachments.
+	NoImages bool
+}
+
 // NotificationWithKeyboard holds a formatted notification and optional keyboard.
 type NotificationWithKeyboard struct {
 	Text      string
 	ImageURL  string
+	Media     []model.Media
 	Truncated bool
 }
 
-// FormatNotificationShort formats a shortened notification with a "Show more" button.
-func FormatNotificationShort(_ int64, feedName string, item fetcher.MatchedItem) NotificationWithKeyboard {
-	var b strings.Builder
-	fmt.Fprintf(&b, "[%s]\n\n", feedName)
-	b.WriteString(item.Title)
+// FormatNotificationShort formats a shortened notification with a "Show more"
+// button in Telegram's HTML parse mode. The text fits into a caption when the
+// item has a single attachment and into a message otherwise, since albums are
+// followed by a separate message.
+func FormatNotificationShort(_ int64, feedName string, item fetcher.MatchedItem, opts Options) NotificationWithKeyboard {
+	imageURL := getItemImageURL(item)
+	media := SelectMedia(item)
+	if opts.NoImages && len(media) > 0 && media[0].Kind == model.MediaPhoto {
+		imageURL, media = "", nil
+	}
+	preview := opts.PreviewLength
+	if preview <= 0 {
+		preview = model.DefaultPreviewLength
+	}
+	limit := MaxMessageLength
+	if len(media) == 1 {
+		limit = MaxCaptionLength
+	}
 
-	desc := getItemText(item)
-	truncated := false
-	if len(desc) > maxPreviewLength {
-		desc = desc[:maxPreviewLength]
-		truncated = true
+	header := fmt.Sprintf("[%s]\n\n", EscapeHTML(feedName))
+	title, _ := TruncateHTML(EscapeHTML(item.Title), limit/4)
+	link := ""
+	if item.Link != "" {
+		link = "\n\n" + EscapeHTML(item.Link)
 	}
 
+	room := min(preview, limit-VisibleLength(header+title+link)-2)
+	desc, truncated := TruncateHTML(getItemText(item), room)
+
+	var b strings.Builder
+	b.WriteString(header)
+	b.WriteString(title)
 	if desc != "" {
 		b.WriteString("\n\n")
 		b.WriteString(desc)
 	}
-
-	if item.Link != "" {
-		b.WriteString("\n\n")
-		b.WriteString(item.Link)
-	}
+	b.WriteString(link)
 
 	return NotificationWithKeyboard{
 		Text:      b.String(),
-		ImageURL:  getItemImageURL(item),
+		ImageURL:  imageURL,
+		Media:     media,
 		Truncated: truncated,
 	}
 }
 
-// FormatNotificationFull formats a full notification without truncation.
-func FormatNotificationFull(feedName string, item fetcher.MatchedItem) string {
-	return FormatNotification(feedName, item)
+// FormatNotificationFull formats a full notification without truncation,
+// split into numbered messages when it exceeds Telegram's message limit.
+func FormatNotificationFull(feedName string, item fetcher.MatchedItem) []string {
+	return SplitHTML(FormatNotification(feedName, item), MaxMessageLength)
 }
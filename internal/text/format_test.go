@@ -1,11 +1,14 @@
 package text
 
 import (
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/google/go-cmp/cmp"
 
 	"rss_bot/internal/fetcher"
+	"rss_bot/internal/model"
 )
 
 func TestGetItemText(t *testing.T) {
@@ -31,20 +34,20 @@ func TestGetItemText(t *testing.T) {
 			want: "Plain description",
 		},
 		{
-			name: "parses HTML content",
+			name: "renders HTML content",
 			item: fetcher.MatchedItem{
 				Content:     "<p>HTML <strong>content</strong></p>",
 				Description: "",
 			},
-			want: "HTML content",
+			want: "HTML <b>content</b>",
 		},
 		{
-			name: "parses HTML description",
+			name: "renders HTML description",
 			item: fetcher.MatchedItem{
 				Content:     "",
 				Description: "<p>HTML <em>description</em></p>",
 			},
-			want: "HTML description",
+			want: "HTML <i>description</i>",
 		},
 		{
 			name: "empty returns empty",
@@ -117,3 +120,80 @@ func TestGetItemImageURL(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatNotificationShortLimits(t *testing.T) {
+	long := strings.Repeat("Длинное предложение на русском языке. ", 200)
+
+	t.Run("cyrillic is cut on a boundary", func(t *testing.T) {
+		got := FormatNotificationShort(1, "Лента", fetcher.MatchedItem{Title: "Заголовок", Description: long, Link: "https://example.com/a"}, Options{})
+		if !utf8.ValidString(got.Text) {
+			t.Fatal("text is not valid UTF-8")
+		}
+		if !got.Truncated {
+			t.Error("long text should be truncated")
+		}
+		if !strings.Contains(got.Text, "языке.…\n\nhttps://example.com/a") {
+			t.Errorf("text should end with a whole sentence and the link, got tail %q", got.Text[len(got.Text)-80:])
+		}
+	})
+
+	t.Run("caption limit with an image", func(t *testing.T) {
+		got := FormatNotificationShort(1, "Feed", fetcher.MatchedItem{Title: "Title", Description: long, ImageURL: "https://example.com/a.jpg"}, Options{})
+		if n := VisibleLength(got.Text); n > MaxCaptionLength {
+			t.Errorf("caption length = %d, want at most %d", n, MaxCaptionLength)
+		}
+	})
+
+	t.Run("message limit with an album", func(t *testing.T) {
+		got := FormatNotificationShort(1, "Feed", fetcher.MatchedItem{Title: "Title", Description: long, Media: []model.Media{
+			{Kind: model.MediaPhoto, URL: "https://example.com/a.jpg"},
+			{Kind: model.MediaPhoto, URL: "https://example.com/b.jpg"},
+		}}, Options{})
+		if diff := cmp.Diff(2, len(got.Media)); diff != "" {
+			t.Errorf("media count (-want +got):\n%s", diff)
+		}
+		if n := VisibleLength(got.Text); n <= MaxCaptionLength {
+			t.Errorf("album text length = %d, should not be cut to a caption", n)
+		}
+	})
+
+	t.Run("chat options", func(t *testing.T) {
+		item := fetcher.MatchedItem{Title: "Title", Description: long, ImageURL: "https://example.com/a.jpg"}
+		got := FormatNotificationShort(1, "Feed", item, Options{PreviewLength: 100, NoImages: true})
+		if len(got.Media) != 0 || got.ImageURL != "" {
+			t.Errorf("images should be left out, got %v", got.Media)
+		}
+		if n := VisibleLength(got.Text); n > 120 {
+			t.Errorf("text length = %d, want the body cut to about 100", n)
+		}
+	})
+
+	t.Run("huge title leaves room for the rest", func(t *testing.T) {
+		got := FormatNotificationShort(1, "Feed", fetcher.MatchedItem{Title: strings.Repeat("x", 5000), Description: "body", Link: "https://example.com/a"}, Options{})
+		if n := VisibleLength(got.Text); n > MaxMessageLength {
+			t.Errorf("message length = %d, want at most %d", n, MaxMessageLength)
+		}
+		if !strings.HasSuffix(got.Text, "body\n\nhttps://example.com/a") {
+			t.Error("body and link should be kept")
+		}
+	})
+}
+
+func TestFormatNotificationFullSplits(t *testing.T) {
+	long := strings.Repeat("<p>"+strings.Repeat("слово ", 100)+"</p>", 20)
+	parts := FormatNotificationFull("Feed", fetcher.MatchedItem{Title: "Title", Content: long})
+	if len(parts) < 2 {
+		t.Fatalf("parts = %d, want several", len(parts))
+	}
+	for i, part := range parts {
+		if n := VisibleLength(part); n > MaxMessageLength {
+			t.Errorf("part %d length = %d, over the limit", i+1, n)
+		}
+		if !utf8.ValidString(part) {
+			t.Errorf("part %d is not valid UTF-8", i+1)
+		}
+	}
+	if !strings.HasPrefix(parts[0], "(1/") {
+		t.Errorf("first part should be numbered, got %q", parts[0][:20])
+	}
+}
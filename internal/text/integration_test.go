@@ -54,7 +54,7 @@ func TestIntegration_RSSParserToFormattedMessage(t *testing.T) {
 
 Article with Lists and Formatting
 
-This is a bold and italic text.
+This is a <b>bold</b> and <i>italic</i> text.
 
 Here is a list:
 
@@ -68,7 +68,7 @@ And a numbered list:
 2. Step two
 3. Step three
 
-Check out this link.
+Check out <a href="https://example.com">this link</a>.
 
 https://html.example.com/article-1`
 
@@ -86,7 +86,7 @@ https://html.example.com/article-1`
 
 		matchedItems := fetcher.FilterItems(feed.Items, nil)
 
-		formatted := FormatNotificationShort(123, "Test Feed", matchedItems[1])
+		formatted := FormatNotificationShort(123, "Test Feed", matchedItems[1], Options{})
 
 		if formatted.ImageURL != "https://example.com/image.jpg" {
 			t.Errorf("ImageURL = %q, want %q", formatted.ImageURL, "https://example.com/image.jpg")
@@ -174,7 +174,7 @@ https://plain.example.com/article-2`
 			t.Errorf("ImageURL = %q, want %q", matchedItems[0].ImageURL, testImageURL)
 		}
 
-		formatted := FormatNotificationShort(1, "Feed with Images", matchedItems[0])
+		formatted := FormatNotificationShort(1, "Feed with Images", matchedItems[0], Options{})
 
 		expectedText := `[Feed with Images]
 
@@ -218,7 +218,7 @@ https://images.example.com/article-1`
 
 Article with Content and Image
 
-This is HTML content with an image:
+This is <b>HTML content</b> with an image:
 
 https://images.example.com/article-4`
 
@@ -272,7 +272,7 @@ https://html.example.com/article-3`
 
 Plain Text with Special Characters
 
-Contains & ampersand, < less than, > greater than, " quotes and more text here.
+Contains &amp; ampersand, &lt; less than, &gt; greater than, " quotes and more text here.
 
 https://plain.example.com/article-3`
 
@@ -0,0 +1,58 @@
+package text
+
+import (
+	"rss_bot/internal/fetcher"
+	"rss_bot/internal/model"
+)
+
+// Telegram's limits for files it downloads by URL.
+const (
+	MaxPhotoSize = 5 << 20
+	MaxFileSize  = 20 << 20
+	MaxAlbumSize = 10
+)
+
+// Formats Telegram accepts by URL for each kind of file; photos may be any
+// raster image.
+var urlMediaTypes = map[model.MediaKind]map[string]bool{
+	model.MediaAudio:    {"audio/mpeg": true, "audio/mp3": true, "audio/mp4": true, "audio/x-m4a": true},
+	model.MediaVideo:    {"video/mp4": true},
+	model.MediaDocument: {"application/pdf": true},
+}
+
+// SelectMedia picks the attachments of a notification: the first audio,
+// video or document of the item if it has one, otherwise up to MaxAlbumSize
+// photos. Files Telegram would refuse to fetch, because of their format or
+// size, are left out; the item link still leads to them.
+func SelectMedia(item fetcher.MatchedItem) []model.Media {
+	var photos []model.Media
+	seen := make(map[string]bool)
+	for _, m := range item.Media {
+		if seen[m.URL] {
+			continue
+		}
+		seen[m.URL] = true
+		if !sendable(m) {
+			continue
+		}
+		if m.Kind != model.MediaPhoto {
+			return []model.Media{m}
+		}
+		if len(photos) < MaxAlbumSize {
+			photos = append(photos, m)
+		}
+	}
+
+	// An image found only in the item body.
+	if url := getItemImageURL(item); url != "" && !seen[url] && len(photos) < MaxAlbumSize {
+		photos = append(photos, model.Media{Kind: model.MediaPhoto, URL: url})
+	}
+	return photos
+}
+
+func sendable(m model.Media) bool {
+	if m.Kind == model.MediaPhoto {
+		return m.Type != "image/svg+xml" && m.Size <= MaxPhotoSize
+	}
+	return urlMediaTypes[m.Kind][m.Type] && m.Size <= MaxFileSize
+}
@@ -0,0 +1,83 @@
+package text
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"rss_bot/internal/fetcher"
+	"rss_bot/internal/model"
+)
+
+func TestSelectMedia(t *testing.T) {
+	photo := func(url string) model.Media {
+		return model.Media{Kind: model.MediaPhoto, URL: url, Type: "image/jpeg"}
+	}
+	episode := model.Media{Kind: model.MediaAudio, URL: "https://example.com/e.mp3", Type: "audio/mpeg", Size: 15 << 20, Duration: 60}
+
+	var many []model.Media
+	for i := range 12 {
+		many = append(many, photo(fmt.Sprintf("https://example.com/%d.jpg", i)))
+	}
+
+	tests := []struct {
+		name string
+		item fetcher.MatchedItem
+		want []model.Media
+	}{
+		{
+			name: "nothing to attach",
+			item: fetcher.MatchedItem{Description: "text"},
+			want: nil,
+		},
+		{
+			name: "audio wins over photos",
+			item: fetcher.MatchedItem{Media: []model.Media{photo("https://example.com/cover.jpg"), episode}},
+			want: []model.Media{episode},
+		},
+		{
+			name: "album is capped",
+			item: fetcher.MatchedItem{Media: many},
+			want: many[:MaxAlbumSize],
+		},
+		{
+			name: "image from the body is added",
+			item: fetcher.MatchedItem{
+				Content: `<p>Text <img src="https://example.com/body.png"></p>`,
+				Media:   []model.Media{photo("https://example.com/a.jpg")},
+			},
+			want: []model.Media{photo("https://example.com/a.jpg"), {Kind: model.MediaPhoto, URL: "https://example.com/body.png"}},
+		},
+		{
+			name: "oversized photo is not sent",
+			item: fetcher.MatchedItem{
+				ImageURL: "https://example.com/huge.jpg",
+				Media:    []model.Media{{Kind: model.MediaPhoto, URL: "https://example.com/huge.jpg", Type: "image/jpeg", Size: MaxPhotoSize + 1}},
+			},
+			want: nil,
+		},
+		{
+			name: "oversized video is not sent",
+			item: fetcher.MatchedItem{Media: []model.Media{{Kind: model.MediaVideo, URL: "https://example.com/v.mp4", Type: "video/mp4", Size: MaxFileSize + 1}}},
+			want: nil,
+		},
+		{
+			name: "formats Telegram cannot fetch are skipped",
+			item: fetcher.MatchedItem{Media: []model.Media{
+				{Kind: model.MediaAudio, URL: "https://example.com/e.ogg", Type: "audio/ogg"},
+				{Kind: model.MediaVideo, URL: "https://example.com/v.webm", Type: "video/webm"},
+				{Kind: model.MediaPhoto, URL: "https://example.com/logo.svg", Type: "image/svg+xml"},
+			}},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, SelectMedia(tt.item)); diff != "" {
+				t.Errorf("SelectMedia mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,337 @@
+package text
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// Telegram's HTML parse mode understands only a few tags; see
+// https://core.telegram.org/bots/api#html-style.
+const (
+	tagBold       = "b"
+	tagItalic     = "i"
+	tagUnderline  = "u"
+	tagStrike     = "s"
+	tagLink       = "a"
+	tagCode       = "code"
+	tagBlockquote = "blockquote"
+	tagSpoiler    = "tg-spoiler"
+)
+
+// telegramTags maps feed HTML inline tags to their Telegram equivalent.
+var telegramTags = map[string]string{
+	"b":      tagBold,
+	"strong": tagBold,
+	"i":      tagItalic,
+	"em":     tagItalic,
+	"cite":   tagItalic,
+	"u":      tagUnderline,
+	"ins":    tagUnderline,
+	"s":      tagStrike,
+	"strike": tagStrike,
+	"del":    tagStrike,
+	"code":   tagCode,
+	"kbd":    tagCode,
+	"samp":   tagCode,
+	"tt":     tagCode,
+}
+
+var (
+	htmlSpaceRegex = regexp.MustCompile(`[ \t\r\n\f]+`)
+	lineSpaceRegex = regexp.MustCompile(` *\n *`)
+	blankLineRegex = regexp.MustCompile(`\n{3,}`)
+	lateCloseRegex = regexp.MustCompile(`(\n+)((?:</[a-z-]+>)+)`)
+	earlyOpenRegex = regexp.MustCompile(`((?:<[a-z-]+(?: [^>]*)?>)+)(\n+)`)
+	htmlTagRegex   = regexp.MustCompile(`<[^>]*>`)
+)
+
+// EscapeHTML escapes text for Telegram's HTML parse mode.
+func EscapeHTML(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// StripHTML turns text rendered for Telegram's HTML parse mode back into
+// plain text. It is the fallback when Telegram rejects the markup.
+func StripHTML(s string) string {
+	return html.UnescapeString(htmlTagRegex.ReplaceAllString(s, ""))
+}
+
+// RenderTelegramHTML converts feed HTML into the subset Telegram accepts:
+// bold, italic, underline, strikethrough, links, code, pre, blockquotes and
+// spoilers. Other tags are dropped but their text is kept, and block structure
+// is preserved with line breaks. The first image is returned separately.
+func RenderTelegramHTML(htmlContent string) ParseResult {
+	if !IsHTML(htmlContent) {
+		return ParseResult{Text: EscapeHTML(strings.TrimSpace(htmlContent))}
+	}
+
+	doc, err := nethtml.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return ParseResult{Text: EscapeHTML(strings.TrimSpace(htmlContent))}
+	}
+
+	r := &telegramRenderer{}
+	r.render(doc)
+
+	return ParseResult{
+		Text:     strings.TrimSpace(tidyLines(r.b.String())),
+		ImageURL: r.imageURL,
+	}
+}
+
+// tidyLines trims spaces around line breaks and squeezes blank lines, leaving
+// preformatted blocks untouched.
+func tidyLines(s string) string {
+	var b strings.Builder
+	for s != "" {
+		start := strings.Index(s, "<pre>")
+		if start < 0 {
+			start = len(s)
+		}
+		outside := lineSpaceRegex.ReplaceAllString(s[:start], "\n")
+		// Keep line breaks outside of tags, Telegram would render them inside.
+		outside = lateCloseRegex.ReplaceAllString(outside, "$2$1")
+		outside = earlyOpenRegex.ReplaceAllString(outside, "$2$1")
+		b.WriteString(blankLineRegex.ReplaceAllString(outside, "\n\n"))
+		s = s[start:]
+
+		end := strings.Index(s, "</pre>")
+		if end < 0 {
+			b.WriteString(s)
+			break
+		}
+		b.WriteString(s[:end+len("</pre>")])
+		s = s[end+len("</pre>"):]
+	}
+	return b.String()
+}
+
+type telegramRenderer struct {
+	b         strings.Builder
+	imageURL  string
+	open      []openTag // innermost last
+	listIndex []int     // item counter per open list; -1 for unordered lists
+}
+
+type openTag struct {
+	name  string
+	attrs string
+}
+
+func (r *telegramRenderer) render(n *nethtml.Node) {
+	switch n.Type {
+	case nethtml.TextNode:
+		r.b.WriteString(EscapeHTML(htmlSpaceRegex.ReplaceAllString(n.Data, " ")))
+		return
+	case nethtml.ElementNode:
+	default:
+		r.children(n)
+		return
+	}
+
+	switch tag := n.Data; tag {
+	case "script", "style", "iframe", "noscript", "head":
+	case "img":
+		if r.imageURL == "" {
+			r.imageURL = attr(n, "src")
+		}
+	case "br":
+		r.b.WriteString("\n")
+	case "hr":
+		r.paragraph()
+		r.b.WriteString("———")
+		r.paragraph()
+	case "pre":
+		r.paragraph()
+		r.pre(n)
+		r.paragraph()
+	case "a":
+		r.link(n)
+	case "blockquote":
+		r.paragraph()
+		r.wrap(tagBlockquote, "", n)
+		r.paragraph()
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		r.paragraph()
+		r.wrap(tagBold, "", n)
+		r.paragraph()
+	case tagUnorderedList, tagOrderedList:
+		start := 0
+		if tag == tagUnorderedList {
+			start = -1
+		}
+		if len(r.listIndex) == 0 {
+			r.paragraph()
+		}
+		r.block()
+		r.listIndex = append(r.listIndex, start)
+		r.children(n)
+		r.listIndex = r.listIndex[:len(r.listIndex)-1]
+		if len(r.listIndex) == 0 {
+			r.paragraph()
+		}
+		r.block()
+	case "li":
+		r.block()
+		// No-break spaces survive the whitespace cleanup.
+		r.b.WriteString(strings.Repeat("\u00a0\u00a0", max(len(r.listIndex)-1, 0)))
+		if last := len(r.listIndex) - 1; last >= 0 && r.listIndex[last] >= 0 {
+			r.listIndex[last]++
+			fmt.Fprintf(&r.b, "%d. ", r.listIndex[last])
+		} else {
+			r.b.WriteString("• ")
+		}
+		r.children(n)
+		r.b.WriteString("\n")
+	case "p", "table", "figure":
+		r.paragraph()
+		r.children(n)
+		r.paragraph()
+	case "div", "section", "article", "figcaption", "tr", "dl", "dt", "dd":
+		r.block()
+		r.children(n)
+		r.block()
+	case "td", "th":
+		r.children(n)
+		r.b.WriteString(" ")
+	case "span", "tg-spoiler":
+		if tag == tagSpoiler || strings.Contains(attr(n, "class"), "spoiler") {
+			r.wrap(tagSpoiler, "", n)
+			return
+		}
+		r.children(n)
+	default:
+		if tg, ok := telegramTags[tag]; ok {
+			r.wrap(tg, "", n)
+			return
+		}
+		r.children(n)
+	}
+}
+
+func (r *telegramRenderer) children(n *nethtml.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.render(c)
+	}
+}
+
+// wrap renders n's children inside tag. Tags that are already open are not
+// repeated, since Telegram rejects nested quotes and links, and nothing is
+// nested inside code.
+func (r *telegramRenderer) wrap(tag, attrs string, n *nethtml.Node) {
+	if r.isOpen(tag) || r.isOpen(tagCode) {
+		r.children(n)
+		return
+	}
+	fmt.Fprintf(&r.b, "<%s%s>", tag, attrs)
+	r.open = append(r.open, openTag{name: tag, attrs: attrs})
+	r.children(n)
+	r.open = r.open[:len(r.open)-1]
+	fmt.Fprintf(&r.b, "</%s>", tag)
+}
+
+func (r *telegramRenderer) isOpen(tag string) bool {
+	for _, t := range r.open {
+		if t.name == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// link keeps a link only if its target is safe to open; other anchors are
+// rendered as their text.
+func (r *telegramRenderer) link(n *nethtml.Node) {
+	href := strings.TrimSpace(attr(n, "href"))
+	lower := strings.ToLower(href)
+	safe := strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") ||
+		strings.HasPrefix(lower, "mailto:") || strings.HasPrefix(lower, "tg://")
+	if !safe {
+		r.children(n)
+		return
+	}
+	r.wrap(tagLink, ` href="`+html.EscapeString(href)+`"`, n)
+}
+
+// pre renders a preformatted block verbatim. A language class on a nested
+// code element is kept for syntax highlighting.
+func (r *telegramRenderer) pre(n *nethtml.Node) {
+	if r.isOpen(tagCode) {
+		r.b.WriteString(EscapeHTML(nodeText(n)))
+		return
+	}
+
+	lang := ""
+	if c := n.FirstChild; c != nil && c.NextSibling == nil && c.Type == nethtml.ElementNode && c.Data == "code" {
+		for _, class := range strings.Fields(attr(c, "class")) {
+			if l, ok := strings.CutPrefix(class, "language-"); ok {
+				lang = l
+				break
+			}
+		}
+	}
+
+	// Whatever is open around the block would make Telegram reject it.
+	open := r.open
+	for i := len(open) - 1; i >= 0; i-- {
+		fmt.Fprintf(&r.b, "</%s>", open[i].name)
+	}
+
+	body := EscapeHTML(strings.Trim(nodeText(n), "\n"))
+	if lang != "" {
+		fmt.Fprintf(&r.b, `<pre><code class="language-%s">%s</code></pre>`, html.EscapeString(lang), body)
+	} else {
+		fmt.Fprintf(&r.b, "<pre>%s</pre>", body)
+	}
+
+	for _, t := range open {
+		fmt.Fprintf(&r.b, "<%s%s>", t.name, t.attrs)
+	}
+}
+
+// block starts a new line unless the output already ends with one.
+func (r *telegramRenderer) block() {
+	s := strings.TrimRight(r.b.String(), " ")
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		r.b.WriteString("\n")
+	}
+}
+
+// paragraph separates what follows with a blank line.
+func (r *telegramRenderer) paragraph() {
+	s := strings.TrimRight(r.b.String(), " ")
+	switch {
+	case s == "", strings.HasSuffix(s, "\n\n"):
+	case strings.HasSuffix(s, "\n"):
+		r.b.WriteString("\n")
+	default:
+		r.b.WriteString("\n\n")
+	}
+}
+
+func attr(n *nethtml.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func nodeText(n *nethtml.Node) string {
+	if n.Type == nethtml.TextNode {
+		return n.Data
+	}
+	if n.Type == nethtml.ElementNode && n.Data == "br" {
+		return "\n"
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(nodeText(c))
+	}
+	return b.String()
+}
@@ -0,0 +1,110 @@
+package text
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRenderTelegramHTML(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "plain text is escaped",
+			input: "1 < 2 & 3 > 2",
+			want:  "1 &lt; 2 &amp; 3 &gt; 2",
+		},
+		{
+			name:  "inline formatting is mapped",
+			input: "<p><strong>bold</strong> <em>italic</em> <u>under</u> <del>gone</del> <code>x := 1</code></p>",
+			want:  "<b>bold</b> <i>italic</i> <u>under</u> <s>gone</s> <code>x := 1</code>",
+		},
+		{
+			name:  "unsupported tags are dropped but text is kept",
+			input: `<p><span style="color:red">red</span> <font>old</font> <mark>marked</mark></p>`,
+			want:  "red old marked",
+		},
+		{
+			name:  "link text and href are escaped",
+			input: `<p><a href="https://example.com/?a=1&b=2">Tom &amp; Jerry</a></p>`,
+			want:  `<a href="https://example.com/?a=1&amp;b=2">Tom &amp; Jerry</a>`,
+		},
+		{
+			name:  "unsafe link keeps only its text",
+			input: `<p><a href="javascript:alert(1)">click</a></p>`,
+			want:  "click",
+		},
+		{
+			name:  "nested links are flattened",
+			input: `<p><a href="https://a.example">outer <a href="https://b.example">inner</a></a></p>`,
+			want:  `<a href="https://a.example">outer </a><a href="https://b.example">inner</a>`,
+		},
+		{
+			name:  "nothing is nested in code",
+			input: "<p><code>a <b>b</b></code></p>",
+			want:  "<code>a b</code>",
+		},
+		{
+			name:  "pre keeps whitespace and language",
+			input: "<p>Code:</p><pre><code class=\"language-go\">if a < b {\n    return\n}</code></pre>",
+			want:  "Code:\n\n<pre><code class=\"language-go\">if a &lt; b {\n    return\n}</code></pre>",
+		},
+		{
+			name:  "pre inside formatting closes it around the block",
+			input: "<b>before<pre>x</pre>after</b>",
+			want:  "<b>before</b>\n\n<pre>x</pre>\n\n<b>after</b>",
+		},
+		{
+			name:  "blockquote",
+			input: "<blockquote><p>Quoted <blockquote>twice</blockquote></p></blockquote><p>After</p>",
+			want:  "<blockquote>Quoted\n\ntwice</blockquote>\n\nAfter",
+		},
+		{
+			name:  "spoilers",
+			input: `<p><span class="spoiler">hidden</span> and <tg-spoiler>secret</tg-spoiler></p>`,
+			want:  "<tg-spoiler>hidden</tg-spoiler> and <tg-spoiler>secret</tg-spoiler>",
+		},
+		{
+			name:  "headings become bold paragraphs",
+			input: "<h2>Title</h2><p>Body</p>",
+			want:  "<b>Title</b>\n\nBody",
+		},
+		{
+			name:  "lists",
+			input: "<ul><li>one</li><li>two<ol><li>a</li><li>b</li></ol></li></ul>",
+			want:  "• one\n• two\n\u00a0\u00a01. a\n\u00a0\u00a02. b",
+		},
+		{
+			name:  "scripts are removed",
+			input: "<p>Text</p><script>alert(1)</script><style>p{}</style>",
+			want:  "Text",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RenderTelegramHTML(tt.input).Text
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("RenderTelegramHTML mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRenderTelegramHTMLImage(t *testing.T) {
+	got := RenderTelegramHTML(`<p>Text<img src="https://example.com/a.jpg"/><img src="https://example.com/b.jpg"/></p>`)
+	want := ParseResult{Text: "Text", ImageURL: "https://example.com/a.jpg"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("RenderTelegramHTML mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestStripHTML(t *testing.T) {
+	got := StripHTML(`<b>Tom &amp; Jerry</b> <a href="https://example.com">&lt;3</a>`)
+	if diff := cmp.Diff("Tom & Jerry <3", got); diff != "" {
+		t.Errorf("StripHTML mismatch (-want +got):\n%s", diff)
+	}
+}
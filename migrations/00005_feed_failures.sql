@@ -0,0 +1,20 @@
+-- +goose Up
+ALTER TABLE feeds ADD COLUMN failure_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE feeds ADD COLUMN last_error TEXT NOT NULL DEFAULT '';
+ALTER TABLE feeds ADD COLUMN next_retry_at TEXT;
+
+CREATE TABLE IF NOT EXISTS feed_failures (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    feed_id    INTEGER NOT NULL,
+    error      TEXT NOT NULL,
+    failed_at  TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS feed_failures_feed ON feed_failures(feed_id, id);
+
+-- +goose Down
+DROP INDEX IF EXISTS feed_failures_feed;
+DROP TABLE IF EXISTS feed_failures;
+ALTER TABLE feeds DROP COLUMN next_retry_at;
+ALTER TABLE feeds DROP COLUMN last_error;
+ALTER TABLE feeds DROP COLUMN failure_count;
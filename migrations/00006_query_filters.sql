@@ -0,0 +1,38 @@
+-- +goose Up
+-- SQLite can't alter a CHECK constraint, so we recreate the table
+CREATE TABLE filters_new (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    feed_id     INTEGER NOT NULL,
+    kind        TEXT NOT NULL CHECK(kind IN ('include','exclude','include_re','exclude_re','query')),
+    scope       TEXT NOT NULL DEFAULT 'all' CHECK(scope IN ('title','content','all')),
+    value       TEXT NOT NULL,
+    created_at  TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    position    INTEGER NOT NULL DEFAULT 0
+);
+
+INSERT INTO filters_new (id, feed_id, kind, scope, value, created_at, position)
+SELECT id, feed_id, kind, scope, value, created_at, position FROM filters;
+
+DROP TABLE filters;
+ALTER TABLE filters_new RENAME TO filters;
+
+CREATE UNIQUE INDEX IF NOT EXISTS filters_feed_position ON filters(feed_id, position);
+
+-- +goose Down
+CREATE TABLE filters_old (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    feed_id     INTEGER NOT NULL,
+    kind        TEXT NOT NULL CHECK(kind IN ('include','exclude','include_re','exclude_re')),
+    scope       TEXT NOT NULL DEFAULT 'all' CHECK(scope IN ('title','content','all')),
+    value       TEXT NOT NULL,
+    created_at  TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    position    INTEGER NOT NULL DEFAULT 0
+);
+
+INSERT INTO filters_old (id, feed_id, kind, scope, value, created_at, position)
+SELECT id, feed_id, kind, scope, value, created_at, position FROM filters WHERE kind != 'query';
+
+DROP TABLE filters;
+ALTER TABLE filters_old RENAME TO filters;
+
+CREATE UNIQUE INDEX IF NOT EXISTS filters_feed_position ON filters(feed_id, position);
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE feeds ADD COLUMN backlog_policy TEXT NOT NULL DEFAULT 'newest';
+ALTER TABLE feeds ADD COLUMN backlog_limit INTEGER NOT NULL DEFAULT 5;
+ALTER TABLE feeds ADD COLUMN backlog_state TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE feeds DROP COLUMN backlog_state;
+ALTER TABLE feeds DROP COLUMN backlog_limit;
+ALTER TABLE feeds DROP COLUMN backlog_policy;
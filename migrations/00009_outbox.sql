@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS outbox (
+    id               INTEGER PRIMARY KEY AUTOINCREMENT,
+    chat_id          INTEGER NOT NULL,
+    feed_id          INTEGER NOT NULL,
+    guid             TEXT NOT NULL,
+    text             TEXT NOT NULL,
+    markup           TEXT NOT NULL DEFAULT '',
+    full_content     TEXT NOT NULL DEFAULT '',
+    attempts         INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at  TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    last_error       TEXT NOT NULL DEFAULT '',
+    failed           INTEGER NOT NULL DEFAULT 0,
+    created_at       TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS outbox_feed_guid ON outbox(feed_id, guid);
+CREATE INDEX IF NOT EXISTS outbox_due ON outbox(failed, next_attempt_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS outbox_due;
+DROP INDEX IF EXISTS outbox_feed_guid;
+DROP TABLE IF EXISTS outbox;
@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS callbacks (
+    token       TEXT PRIMARY KEY,
+    chat_id     INTEGER NOT NULL,
+    action      TEXT NOT NULL,
+    feed_id     INTEGER NOT NULL DEFAULT 0,
+    guid        TEXT NOT NULL DEFAULT '',
+    arg         TEXT NOT NULL DEFAULT '',
+    expires_at  TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS callbacks_expires ON callbacks(expires_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS callbacks_expires;
+DROP TABLE IF EXISTS callbacks;
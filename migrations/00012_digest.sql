@@ -0,0 +1,29 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS chat_settings (
+    chat_id       INTEGER PRIMARY KEY,
+    timezone      TEXT NOT NULL DEFAULT 'UTC',
+    digest        INTEGER NOT NULL DEFAULT 0,
+    digest_times  TEXT NOT NULL DEFAULT '09:00'
+);
+
+ALTER TABLE feeds ADD COLUMN delivery TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS digest_items (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    chat_id     INTEGER NOT NULL,
+    feed_id     INTEGER NOT NULL,
+    guid        TEXT NOT NULL,
+    title       TEXT NOT NULL,
+    link        TEXT NOT NULL DEFAULT '',
+    created_at  TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS digest_items_feed_guid ON digest_items(feed_id, guid);
+CREATE INDEX IF NOT EXISTS digest_items_chat ON digest_items(chat_id, id);
+
+-- +goose Down
+DROP INDEX IF EXISTS digest_items_chat;
+DROP INDEX IF EXISTS digest_items_feed_guid;
+DROP TABLE IF EXISTS digest_items;
+ALTER TABLE feeds DROP COLUMN delivery;
+DROP TABLE IF EXISTS chat_settings;
@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE chat_settings ADD COLUMN quiet_start TEXT NOT NULL DEFAULT '';
+ALTER TABLE chat_settings ADD COLUMN quiet_end TEXT NOT NULL DEFAULT '';
+ALTER TABLE chat_settings ADD COLUMN quiet_mode TEXT NOT NULL DEFAULT 'hold';
+
+ALTER TABLE feeds ADD COLUMN quiet TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE outbox ADD COLUMN silent INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE outbox DROP COLUMN silent;
+ALTER TABLE feeds DROP COLUMN quiet;
+ALTER TABLE chat_settings DROP COLUMN quiet_mode;
+ALTER TABLE chat_settings DROP COLUMN quiet_end;
+ALTER TABLE chat_settings DROP COLUMN quiet_start;
@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE chat_settings ADD COLUMN interval_minutes INTEGER NOT NULL DEFAULT 15;
+ALTER TABLE chat_settings ADD COLUMN filter_scope TEXT NOT NULL DEFAULT 'all';
+ALTER TABLE chat_settings ADD COLUMN preview_length INTEGER NOT NULL DEFAULT 1500;
+ALTER TABLE chat_settings ADD COLUMN images INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE chat_settings ADD COLUMN link_preview INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE chat_settings DROP COLUMN link_preview;
+ALTER TABLE chat_settings DROP COLUMN images;
+ALTER TABLE chat_settings DROP COLUMN preview_length;
+ALTER TABLE chat_settings DROP COLUMN filter_scope;
+ALTER TABLE chat_settings DROP COLUMN interval_minutes;
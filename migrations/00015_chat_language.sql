@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE chat_settings ADD COLUMN language TEXT NOT NULL DEFAULT '';
+ALTER TABLE chat_settings ADD COLUMN telegram_language TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE chat_settings DROP COLUMN telegram_language;
+ALTER TABLE chat_settings DROP COLUMN language;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- in_feed marks the items of a feed's latest download; retention keeps them.
+ALTER TABLE seen_items ADD COLUMN in_feed INTEGER NOT NULL DEFAULT 1;
+-- content holds the gzip-compressed full content; full_content is left for
+-- rows stored before compression until they are compacted.
+ALTER TABLE seen_items ADD COLUMN content BLOB;
+CREATE INDEX IF NOT EXISTS idx_seen_items_seen_at ON seen_items(seen_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_seen_items_seen_at;
+ALTER TABLE seen_items DROP COLUMN content;
+ALTER TABLE seen_items DROP COLUMN in_feed;
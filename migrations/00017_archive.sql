@@ -0,0 +1,53 @@
+-- +goose Up
+-- archive holds the JSON-encoded item that goes to the archive once the
+-- message or digest is delivered.
+ALTER TABLE outbox ADD COLUMN archive TEXT NOT NULL DEFAULT '';
+ALTER TABLE digest_items ADD COLUMN archive TEXT NOT NULL DEFAULT '';
+
+-- Delivered items, kept for /search after their feed is removed.
+CREATE TABLE IF NOT EXISTS archive (
+    id            INTEGER PRIMARY KEY AUTOINCREMENT,
+    chat_id       INTEGER NOT NULL,
+    feed_id       INTEGER NOT NULL,
+    guid          TEXT NOT NULL,
+    feed          TEXT NOT NULL DEFAULT '',
+    title         TEXT NOT NULL DEFAULT '',
+    link          TEXT NOT NULL DEFAULT '',
+    author        TEXT NOT NULL DEFAULT '',
+    published_at  TEXT,
+    content       TEXT NOT NULL DEFAULT '',
+    delivered_at  TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS archive_feed_guid ON archive(feed_id, guid);
+CREATE INDEX IF NOT EXISTS archive_chat ON archive(chat_id, delivered_at);
+
+CREATE VIRTUAL TABLE IF NOT EXISTS archive_fts USING fts5(
+    title, content, author, link, feed,
+    content='archive', content_rowid='id',
+    tokenize='unicode61 remove_diacritics 2'
+);
+
+-- +goose StatementBegin
+CREATE TRIGGER IF NOT EXISTS archive_ai AFTER INSERT ON archive BEGIN
+    INSERT INTO archive_fts (rowid, title, content, author, link, feed)
+    VALUES (new.id, new.title, new.content, new.author, new.link, new.feed);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER IF NOT EXISTS archive_ad AFTER DELETE ON archive BEGIN
+    INSERT INTO archive_fts (archive_fts, rowid, title, content, author, link, feed)
+    VALUES ('delete', old.id, old.title, old.content, old.author, old.link, old.feed);
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TRIGGER IF EXISTS archive_ad;
+DROP TRIGGER IF EXISTS archive_ai;
+DROP TABLE IF EXISTS archive_fts;
+DROP INDEX IF EXISTS archive_chat;
+DROP INDEX IF EXISTS archive_feed_guid;
+DROP TABLE IF EXISTS archive;
+ALTER TABLE digest_items DROP COLUMN archive;
+ALTER TABLE outbox DROP COLUMN archive;
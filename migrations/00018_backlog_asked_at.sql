@@ -0,0 +1,8 @@
+-- +goose Up
+-- backlog_asked_at is when the chat was asked about a feed's backlog, so that
+-- an unanswered prompt does not hold the feed back after its buttons expire.
+ALTER TABLE feeds ADD COLUMN backlog_asked_at TEXT;
+UPDATE feeds SET backlog_asked_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now') WHERE backlog_state = 'asked';
+
+-- +goose Down
+ALTER TABLE feeds DROP COLUMN backlog_asked_at;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- Messages the outbox gave up on are no longer kept: their items are marked
+-- seen so that they are not queued again.
+INSERT OR IGNORE INTO seen_items (feed_id, guid)
+SELECT feed_id, guid FROM outbox WHERE failed = 1 AND feed_id != 0;
+DELETE FROM outbox WHERE failed = 1;
+
+-- +goose Down